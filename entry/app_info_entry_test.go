@@ -54,6 +54,25 @@ app:
 	assert.NotEmpty(t, entry.GetDescription())
 }
 
+func TestAppInfoEntry_MarshalJSON(t *testing.T) {
+	entry := &appInfoEntry{
+		entryName:        "ut-name",
+		entryType:        "ut-type",
+		entryDescription: "ut-desc",
+		AppName:          "ut-app",
+		Lang:             "golang",
+		Maintainers:      []string{"a", "b"},
+	}
+
+	// encoding/json sorts keys of map, output should be stable across runs
+	expected := `{"appName":"ut-app","description":"ut-desc","docsUrl":null,"homeUrl":"","lang":"golang","maintainers":"a,b","name":"ut-name","type":"ut-type"}`
+	for i := 0; i < 10; i++ {
+		bytes, err := entry.MarshalJSON()
+		assert.Nil(t, err)
+		assert.Equal(t, expected, string(bytes))
+	}
+}
+
 func TestAppInfoEntry_UnmarshalJSON(t *testing.T) {
 	defer assertNotPanic(t)
 
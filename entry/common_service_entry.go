@@ -200,12 +200,34 @@ func (entry *CommonServiceEntry) UnmarshalJSON([]byte) error {
 // @produce application/json
 // @Success 200 {object} readyResp
 // @Failure 500 {object} rkerror.ErrorInterface
+// @Failure 503 {object} readyResp
 // @Router /rk/v1/ready [get]
 func (entry *CommonServiceEntry) Ready(writer http.ResponseWriter, request *http.Request) {
 	if GlobalAppCtx.readinessCheck != nil && !GlobalAppCtx.readinessCheck(request, writer) {
 		return
 	}
 
+	// aggregate entries which implements HealthReporter
+	reqCtx := context.Background()
+	if request != nil {
+		reqCtx = request.Context()
+	}
+
+	if errs := GlobalAppCtx.CheckHealth(reqCtx); len(errs) > 0 {
+		resp := &readyResp{
+			Ready:     false,
+			Unhealthy: make(map[string]string),
+		}
+		for k, v := range errs {
+			resp.Unhealthy[k] = v.Error()
+		}
+
+		writer.WriteHeader(http.StatusServiceUnavailable)
+		bytes, _ := json.MarshalIndent(resp, "", "  ")
+		writer.Write(bytes)
+		return
+	}
+
 	writer.WriteHeader(http.StatusOK)
 	bytes, _ := json.MarshalIndent(&readyResp{
 		Ready: true,
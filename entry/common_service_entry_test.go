@@ -7,7 +7,9 @@ package rkentry
 
 import (
 	"context"
+	"errors"
 	"github.com/stretchr/testify/assert"
+	"net/http"
 	"net/http/httptest"
 	"testing"
 )
@@ -62,6 +64,21 @@ func TestCommonServiceEntry_Healthy(t *testing.T) {
 	assert.Contains(t, writer.Body.String(), "true")
 }
 
+func TestCommonServiceEntry_Ready_WithHealthReporter(t *testing.T) {
+	defer GlobalAppCtx.RemoveEntryByType("mock")
+
+	entry := RegisterCommonServiceEntry(&BootCommonService{
+		Enabled: true,
+	})
+
+	GlobalAppCtx.AddEntry(&healthReporterMock{EntryMock: EntryMock{Name: "ut-unhealthy"}, err: errors.New("ut-error")})
+
+	writer := httptest.NewRecorder()
+	entry.Ready(writer, httptest.NewRequest(http.MethodGet, "/rk/v1/ready", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, writer.Code)
+	assert.Contains(t, writer.Body.String(), "ut-error")
+}
+
 func TestCommonServiceEntry_GC(t *testing.T) {
 	defer assertNotPanic(t)
 
@@ -7,15 +7,46 @@ package rkentry
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"github.com/spf13/viper"
+	"go.uber.org/zap"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
+// ConfigEntryOption option for ConfigEntry
+type ConfigEntryOption func(entry *ConfigEntry)
+
+// WithRequiredKeysConfigEntry provide keys which must be set in ConfigEntry, checked while Bootstrap.
+// Keys are case-insensitive and nested keys are joined with dot(.), like "db.port".
+func WithRequiredKeysConfigEntry(keys ...string) ConfigEntryOption {
+	return func(entry *ConfigEntry) {
+		for i := range keys {
+			if len(keys[i]) > 0 {
+				entry.requiredKeys = append(entry.requiredKeys, keys[i])
+			}
+		}
+	}
+}
+
+// WithValidatorConfigEntry provide validation function which runs while Bootstrap after required keys checked.
+// Types of values could be checked in validator.
+func WithValidatorConfigEntry(validator func(*viper.Viper) error) ConfigEntryOption {
+	return func(entry *ConfigEntry) {
+		if validator != nil {
+			entry.validators = append(entry.validators, validator)
+		}
+	}
+}
+
 // RegisterConfigEntry create ConfigEntry with BootConfigConfig.
-func RegisterConfigEntry(boot *BootConfig) []*ConfigEntry {
+//
+// Options will be applied to every ConfigEntry registered.
+func RegisterConfigEntry(boot *BootConfig, opts ...ConfigEntryOption) []*ConfigEntry {
 	res := make([]*ConfigEntry, 0)
 
 	// filter out based domain
@@ -46,50 +77,80 @@ func RegisterConfigEntry(boot *BootConfig) []*ConfigEntry {
 	}
 
 	for _, config := range configMap {
-		entry := &ConfigEntry{
-			entryName:        config.Name,
-			entryType:        ConfigEntryType,
-			entryDescription: config.Description,
-			content:          config.Content,
-			Viper:            viper.New(),
-			Path:             config.Path,
-			EnvPrefix:        config.EnvPrefix,
+		entry, err := newConfigEntry(config, opts...)
+		if err != nil {
+			ShutdownWithError(err)
 		}
 
-		// if file path was provided
-		if len(entry.Path) > 0 {
-			if !filepath.IsAbs(entry.Path) {
-				if wd, err := os.Getwd(); err != nil {
-					ShutdownWithError(err)
-				} else {
-					entry.Path = filepath.ToSlash(filepath.Join(wd, entry.Path))
-				}
-			}
+		GlobalAppCtx.AddEntry(entry)
+		res = append(res, entry)
+	}
+
+	return res
+}
 
-			// skip this element if path is not valid
-			if fileExists(entry.Path) {
-				entry.Viper.SetConfigFile(entry.Path)
-				if err := entry.Viper.ReadInConfig(); err != nil {
-					ShutdownWithError(fmt.Errorf("failed to read file, path:%s", entry.Path))
-				}
+// newConfigEntry create ConfigEntry with BootConfigE without registering it into GlobalAppCtx
+func newConfigEntry(config *BootConfigE, opts ...ConfigEntryOption) (*ConfigEntry, error) {
+	entry := &ConfigEntry{
+		entryName:        config.Name,
+		entryType:        ConfigEntryType,
+		entryDescription: config.Description,
+		content:          config.Content,
+		Viper:            viper.New(),
+		Path:             config.Path,
+		EnvPrefix:        config.EnvPrefix,
+		requiredKeys:     make([]string, 0),
+		validators:       make([]func(*viper.Viper) error, 0),
+	}
+
+	WithRequiredKeysConfigEntry(config.RequiredKeys...)(entry)
+	for i := range opts {
+		opts[i](entry)
+	}
+
+	// checksum of file and content loaded
+	hash := sha256.New()
+
+	// if file path was provided
+	if len(entry.Path) > 0 {
+		if !filepath.IsAbs(entry.Path) {
+			wd, err := os.Getwd()
+			if err != nil {
+				return nil, err
 			}
+			entry.Path = filepath.ToSlash(filepath.Join(wd, entry.Path))
 		}
 
-		// if content exist, then fill viper
-		for k, v := range entry.content {
-			entry.Viper.Set(k, v)
+		// skip this element if path is not valid
+		if fileExists(entry.Path) {
+			entry.Viper.SetConfigFile(entry.Path)
+			if err := entry.Viper.ReadInConfig(); err != nil {
+				return nil, fmt.Errorf("failed to read file, path:%s", entry.Path)
+			}
+
+			if raw, err := os.ReadFile(entry.Path); err == nil {
+				hash.Write(raw)
+			}
 		}
+	}
 
-		// enable automatic env
-		// issue: https://github.com/rookie-ninja/rk-boot/issues/55
-		entry.Viper.AutomaticEnv()
-		entry.Viper.SetEnvPrefix(entry.EnvPrefix)
+	// if content exist, then fill viper
+	for k, v := range entry.content {
+		entry.Viper.Set(k, v)
+	}
 
-		GlobalAppCtx.AddEntry(entry)
-		res = append(res, entry)
+	// keys of map are sorted by fmt, so checksum of the same content is stable
+	if len(entry.content) > 0 {
+		hash.Write([]byte(fmt.Sprint(entry.content)))
 	}
+	entry.checksum = hex.EncodeToString(hash.Sum(nil))
 
-	return res
+	// enable automatic env
+	// issue: https://github.com/rookie-ninja/rk-boot/issues/55
+	entry.Viper.AutomaticEnv()
+	entry.Viper.SetEnvPrefix(entry.EnvPrefix)
+
+	return entry, nil
 }
 
 // RegisterConfigEntryYAML register function
@@ -115,29 +176,70 @@ type BootConfig struct {
 
 // BootConfigE element of ConfigEntry
 type BootConfigE struct {
-	Name        string                 `yaml:"name" json:"name"`
-	Description string                 `yaml:"description" json:"description"`
-	Domain      string                 `yaml:"domain" json:"domain"`
-	Path        string                 `yaml:"path" json:"name"`
-	EnvPrefix   string                 `yaml:"envPrefix" json:"envPrefix"`
-	Content     map[string]interface{} `yaml:"content" json:"content"`
+	Name         string                 `yaml:"name" json:"name"`
+	Description  string                 `yaml:"description" json:"description"`
+	Domain       string                 `yaml:"domain" json:"domain"`
+	Path         string                 `yaml:"path" json:"name"`
+	EnvPrefix    string                 `yaml:"envPrefix" json:"envPrefix"`
+	Content      map[string]interface{} `yaml:"content" json:"content"`
+	RequiredKeys []string               `yaml:"requiredKeys" json:"requiredKeys"`
 }
 
 // ConfigEntry contains bellow fields.
 type ConfigEntry struct {
 	*viper.Viper
 
-	entryName        string                 `yaml:"-" json:"-"`
-	entryType        string                 `yaml:"-" json:"-"`
-	entryDescription string                 `yaml:"-" json:"-"`
-	Locale           string                 `yaml:"-" json:"-"`
-	Path             string                 `yaml:"-" json:"-"`
-	EnvPrefix        string                 `yaml:"-" json:"-"`
-	content          map[string]interface{} `yaml:"-" json:"-"`
+	entryName        string                     `yaml:"-" json:"-"`
+	entryType        string                     `yaml:"-" json:"-"`
+	entryDescription string                     `yaml:"-" json:"-"`
+	Locale           string                     `yaml:"-" json:"-"`
+	Path             string                     `yaml:"-" json:"-"`
+	EnvPrefix        string                     `yaml:"-" json:"-"`
+	content          map[string]interface{}     `yaml:"-" json:"-"`
+	requiredKeys     []string                   `yaml:"-" json:"-"`
+	validators       []func(*viper.Viper) error `yaml:"-" json:"-"`
+	checksum         string                     `yaml:"-" json:"-"`
+}
+
+// Bootstrap entry, application will shutdown if Validate returns error.
+func (entry *ConfigEntry) Bootstrap(context.Context) {
+	if err := entry.Validate(); err != nil {
+		ShutdownWithError(err)
+	}
+
+	LoggerEntryStdout.Info("Loaded config entry",
+		zap.String("entryName", entry.entryName),
+		zap.String("path", entry.Path),
+		zap.String("checksum", entry.checksum))
 }
 
-// Bootstrap entry.
-func (entry *ConfigEntry) Bootstrap(context.Context) {}
+// Checksum returns hex encoded SHA-256 checksum of file and content loaded,
+// which could be used to verify which version of config is live.
+func (entry *ConfigEntry) Checksum() string {
+	return entry.checksum
+}
+
+// Validate checks required keys and runs validators, error will be returned instead of shutdown.
+func (entry *ConfigEntry) Validate() error {
+	missing := make([]string, 0)
+	for i := range entry.requiredKeys {
+		if !entry.Viper.IsSet(entry.requiredKeys[i]) {
+			missing = append(missing, entry.requiredKeys[i])
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("missing required keys in config entry %s, keys:%s", entry.entryName, strings.Join(missing, ","))
+	}
+
+	for i := range entry.validators {
+		if err := entry.validators[i](entry.Viper); err != nil {
+			return fmt.Errorf("invalid config entry %s, %v", entry.entryName, err)
+		}
+	}
+
+	return nil
+}
 
 // Interrupt entry.
 func (entry *ConfigEntry) Interrupt(context.Context) {}
@@ -161,12 +263,14 @@ func (entry *ConfigEntry) String() string {
 // MarshalJSON marshal entry.
 func (entry *ConfigEntry) MarshalJSON() ([]byte, error) {
 	m := map[string]interface{}{
-		"name":        entry.GetName(),
-		"type":        entry.GetType(),
-		"description": entry.GetDescription(),
-		"locale":      entry.Locale,
-		"path":        entry.Path,
-		"envPrefix":   entry.EnvPrefix,
+		"name":         entry.GetName(),
+		"type":         entry.GetType(),
+		"description":  entry.GetDescription(),
+		"locale":       entry.Locale,
+		"path":         entry.Path,
+		"envPrefix":    entry.EnvPrefix,
+		"requiredKeys": entry.requiredKeys,
+		"checksum":     entry.checksum,
 	}
 
 	return json.Marshal(m)
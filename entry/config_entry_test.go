@@ -6,9 +6,11 @@ package rkentry
 
 import (
 	"context"
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 	"os"
 	"path/filepath"
+	"strconv"
 	"testing"
 )
 
@@ -78,6 +80,33 @@ key: value
 	assert.Equal(t, "value", entries[0].GetString("key"))
 }
 
+func TestConfigEntry_Checksum(t *testing.T) {
+	path := filepath.ToSlash(filepath.Join(t.TempDir(), "ut-checksum.yaml"))
+	assert.Nil(t, os.WriteFile(path, []byte("key: value"), os.ModePerm))
+
+	boot := &BootConfigE{
+		Name:    "ut-checksum",
+		Path:    path,
+		Content: map[string]interface{}{"a": 1, "b": 2},
+	}
+
+	entry, err := newConfigEntry(boot)
+	assert.Nil(t, err)
+	assert.Len(t, entry.Checksum(), 64)
+	assert.Contains(t, entry.String(), entry.Checksum())
+
+	// same file and content should have the same checksum
+	same, err := newConfigEntry(boot)
+	assert.Nil(t, err)
+	assert.Equal(t, entry.Checksum(), same.Checksum())
+
+	// changed file should have different checksum
+	assert.Nil(t, os.WriteFile(path, []byte("key: changed"), os.ModePerm))
+	changed, err := newConfigEntry(boot)
+	assert.Nil(t, err)
+	assert.NotEqual(t, entry.Checksum(), changed.Checksum())
+}
+
 func TestRegisterConfigEntry_WithNonExistPath(t *testing.T) {
 	defer assertNotPanic(t)
 
@@ -292,3 +321,43 @@ func TestConfigEntry_Interrupt(t *testing.T) {
 	})
 	entry[0].Interrupt(context.Background())
 }
+
+func TestConfigEntry_Validate(t *testing.T) {
+	boot := &BootConfig{
+		Config: []*BootConfigE{
+			{
+				Name:         "ut-config",
+				RequiredKeys: []string{"db.host"},
+				Content: map[string]interface{}{
+					"db": map[string]interface{}{
+						"host": "localhost",
+						"port": "invalid",
+					},
+				},
+			},
+		},
+	}
+
+	// with required keys
+	entry := RegisterConfigEntry(boot)[0]
+	assert.Nil(t, entry.Validate())
+
+	// with missing keys
+	entry = RegisterConfigEntry(boot, WithRequiredKeysConfigEntry("db.port", "db.user"))[0]
+	assert.Contains(t, entry.Validate().Error(), "db.user")
+	assert.Panics(t, func() {
+		entry.Bootstrap(context.Background())
+	})
+
+	// with validator
+	entry = RegisterConfigEntry(boot, WithValidatorConfigEntry(func(v *viper.Viper) error {
+		if _, err := strconv.Atoi(v.GetString("db.port")); err != nil {
+			return err
+		}
+		return nil
+	}))[0]
+	assert.NotNil(t, entry.Validate())
+	assert.Panics(t, func() {
+		entry.Bootstrap(context.Background())
+	})
+}
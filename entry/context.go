@@ -8,9 +8,12 @@ package rkentry
 import (
 	"context"
 	"embed"
+	"go.uber.org/zap"
 	"net/http"
 	"os"
 	"os/signal"
+	"sort"
+	"sync"
 	"syscall"
 	"time"
 )
@@ -27,7 +30,8 @@ var (
 		embedFS:       map[string]map[string]*embed.FS{},
 		appInfoEntry:  appInfoEntryDefault(),
 		shutdownSig:   make(chan os.Signal),
-		shutdownHooks: make(map[string]ShutdownHook),
+		shutdownHooks: make(map[string]ShutdownHookWithContext),
+		startupHooks:  make(map[string]StartupHook),
 		userValues:    make(map[string]interface{}),
 	}
 
@@ -51,6 +55,12 @@ var (
 // ShutdownHook defines interface of shutdown hook
 type ShutdownHook func()
 
+// ShutdownHookWithContext defines interface of shutdown hook which respects deadline of context passed to Shutdown()
+type ShutdownHookWithContext func(context.Context)
+
+// StartupHook defines interface of startup hook
+type StartupHook func()
+
 type ReadinessCheck func(req *http.Request, resp http.ResponseWriter) bool
 type LivenessCheck func(req *http.Request, resp http.ResponseWriter) bool
 
@@ -67,15 +77,50 @@ func init() {
 // It is not recommended override this value since StartTime would be assigned to current time
 // at beginning of go process in init() function.
 type appContext struct {
-	startTime      time.Time                       `json:"-" yaml:"-"`
-	appInfoEntry   *appInfoEntry                   `json:"-" yaml:"-"`
-	readinessCheck ReadinessCheck                  `json:"-" yaml:"-"`
-	livenessCheck  LivenessCheck                   `json:"-" yaml:"-"`
-	entries        map[string]map[string]Entry     `json:"-" yaml:"-"`
-	embedFS        map[string]map[string]*embed.FS `json:"-" yaml:"-"`
-	userValues     map[string]interface{}          `json:"-" yaml:"-"`
-	shutdownSig    chan os.Signal                  `json:"-" yaml:"-"`
-	shutdownHooks  map[string]ShutdownHook         `json:"-" yaml:"-"`
+	startTime      time.Time                          `json:"-" yaml:"-"`
+	appInfoEntry   *appInfoEntry                      `json:"-" yaml:"-"`
+	readinessCheck ReadinessCheck                     `json:"-" yaml:"-"`
+	livenessCheck  LivenessCheck                      `json:"-" yaml:"-"`
+	entries        map[string]map[string]Entry        `json:"-" yaml:"-"`
+	embedFS        map[string]map[string]*embed.FS    `json:"-" yaml:"-"`
+	userValues     map[string]interface{}             `json:"-" yaml:"-"`
+	shutdownSig    chan os.Signal                     `json:"-" yaml:"-"`
+	shutdownHooks  map[string]ShutdownHookWithContext `json:"-" yaml:"-"`
+	startupHooks   map[string]StartupHook             `json:"-" yaml:"-"`
+	// names of hooks in order of registration
+	shutdownOrder []string   `json:"-" yaml:"-"`
+	startupOrder  []string   `json:"-" yaml:"-"`
+	hookLock      sync.Mutex `json:"-" yaml:"-"`
+	// timings of entries bootstrapped from YAML in order of bootstrap
+	bootstrapTimings []*BootstrapTiming `json:"-" yaml:"-"`
+	timingLock       sync.Mutex         `json:"-" yaml:"-"`
+}
+
+// BootstrapTiming is elapsed time of Bootstrap() of entry
+type BootstrapTiming struct {
+	EntryName string        `json:"entryName" yaml:"entryName" example:"greeter"`
+	EntryType string        `json:"entryType" yaml:"entryType" example:"GinEntry"`
+	Elapsed   time.Duration `json:"-" yaml:"-"`
+	ElapsedMs float64       `json:"elapsedMs" yaml:"elapsedMs" example:"1.2"`
+}
+
+// bootstrapEntry bootstraps entry, elapsed time will be logged with LoggerEntryStdout and recorded in GlobalAppCtx
+func bootstrapEntry(ctx context.Context, entry Entry) {
+	start := time.Now()
+	entry.Bootstrap(ctx)
+	elapsed := time.Since(start)
+
+	LoggerEntryStdout.Info("Bootstrapped entry",
+		zap.String("entryName", entry.GetName()),
+		zap.String("entryType", entry.GetType()),
+		zap.Duration("elapsed", elapsed))
+
+	GlobalAppCtx.addBootstrapTiming(&BootstrapTiming{
+		EntryName: entry.GetName(),
+		EntryType: entry.GetType(),
+		Elapsed:   elapsed,
+		ElapsedMs: float64(elapsed) / float64(time.Millisecond),
+	})
 }
 
 // RegisterPluginRegFunc register rk plugins registration function.
@@ -145,7 +190,7 @@ func BootstrapBuiltInEntryFromYAML(raw []byte) {
 	for i := range builtinRegFuncList {
 		entries := builtinRegFuncList[i](raw)
 		for _, v := range entries {
-			v.Bootstrap(ctx)
+			bootstrapEntry(ctx, v)
 		}
 	}
 }
@@ -157,7 +202,7 @@ func BootstrapPluginEntryFromYAML(raw []byte) {
 	for i := range pluginRegFuncList {
 		entries := pluginRegFuncList[i](raw)
 		for _, v := range entries {
-			v.Bootstrap(ctx)
+			bootstrapEntry(ctx, v)
 		}
 	}
 }
@@ -169,7 +214,7 @@ func BootstrapWebFrameEntryFromYAML(raw []byte) {
 	for i := range webFrameRegFuncList {
 		entries := webFrameRegFuncList[i](raw)
 		for _, v := range entries {
-			v.Bootstrap(ctx)
+			bootstrapEntry(ctx, v)
 		}
 	}
 }
@@ -181,11 +226,29 @@ func BootstrapUserEntryFromYAML(raw []byte) {
 	for i := range userDefRegFuncList {
 		entries := userDefRegFuncList[i](raw)
 		for _, v := range entries {
-			v.Bootstrap(ctx)
+			bootstrapEntry(ctx, v)
 		}
 	}
 }
 
+func (ctx *appContext) addBootstrapTiming(timing *BootstrapTiming) {
+	ctx.timingLock.Lock()
+	defer ctx.timingLock.Unlock()
+
+	ctx.bootstrapTimings = append(ctx.bootstrapTimings, timing)
+}
+
+// ListBootstrapTimings returns elapsed time of Bootstrap() of entries bootstrapped from YAML in order of bootstrap
+func (ctx *appContext) ListBootstrapTimings() []*BootstrapTiming {
+	ctx.timingLock.Lock()
+	defer ctx.timingLock.Unlock()
+
+	res := make([]*BootstrapTiming, len(ctx.bootstrapTimings))
+	copy(res, ctx.bootstrapTimings)
+
+	return res
+}
+
 // AddEmbedFS add embed.FS based on name and type of Entry
 func (ctx *appContext) AddEmbedFS(entryType, entryName string, fs *embed.FS) {
 	if len(entryType) < 1 || len(entryName) < 1 || fs == nil {
@@ -331,6 +394,15 @@ func (ctx *appContext) GetCertEntry(entryName string) *CertEntry {
 	return nil
 }
 
+// GetPProfEntry returns PProfEntry with name, nil will be returned if not found
+func (ctx *appContext) GetPProfEntry(entryName string) *PProfEntry {
+	if v, ok := ctx.GetEntry(PProfEntryType, entryName).(*PProfEntry); ok {
+		return v
+	}
+
+	return nil
+}
+
 func (ctx *appContext) AddEntry(entry Entry) {
 	if entry == nil {
 		return
@@ -403,6 +475,27 @@ func (ctx *appContext) GetCryptoEntry(entryName string) Crypto {
 	return nil
 }
 
+// CheckHealth run HealthCheck of entries which implements HealthReporter.
+// Unhealthy entries will be returned with key of <entryType>/<entryName>.
+func (ctx *appContext) CheckHealth(reqCtx context.Context) map[string]error {
+	res := make(map[string]error)
+
+	for entryType, entries := range ctx.entries {
+		for entryName, entry := range entries {
+			reporter, ok := entry.(HealthReporter)
+			if !ok {
+				continue
+			}
+
+			if err := reporter.HealthCheck(reqCtx); err != nil {
+				res[entryType+"/"+entryName] = err
+			}
+		}
+	}
+
+	return res
+}
+
 // ***********************************
 // ****** Shutdown hook related ******
 // ***********************************
@@ -417,39 +510,302 @@ func (ctx *appContext) GetStartTime() time.Time {
 	return ctx.startTime
 }
 
+// AddStartupHook add startup hook with name.
+// Hook with the same name will be replaced and keeps its original order.
+func (ctx *appContext) AddStartupHook(name string, f StartupHook) {
+	if f == nil {
+		return
+	}
+
+	ctx.hookLock.Lock()
+	defer ctx.hookLock.Unlock()
+
+	if _, ok := ctx.startupHooks[name]; !ok {
+		ctx.startupOrder = append(ctx.startupOrder, name)
+	}
+	ctx.startupHooks[name] = f
+}
+
+// GetStartupHook returns startup hook with name.
+func (ctx *appContext) GetStartupHook(name string) StartupHook {
+	ctx.hookLock.Lock()
+	defer ctx.hookLock.Unlock()
+
+	return ctx.startupHooks[name]
+}
+
+// ListStartupHooks list startup hooks.
+func (ctx *appContext) ListStartupHooks() map[string]StartupHook {
+	ctx.hookLock.Lock()
+	defer ctx.hookLock.Unlock()
+
+	res := make(map[string]StartupHook)
+	for k, v := range ctx.startupHooks {
+		res[k] = v
+	}
+
+	return res
+}
+
+// ListStartupHookNames list names of startup hooks in order of execution.
+func (ctx *appContext) ListStartupHookNames() []string {
+	ctx.hookLock.Lock()
+	defer ctx.hookLock.Unlock()
+
+	return append([]string{}, ctx.startupOrder...)
+}
+
+// RemoveStartupHook remove startup hook.
+func (ctx *appContext) RemoveStartupHook(name string) bool {
+	ctx.hookLock.Lock()
+	defer ctx.hookLock.Unlock()
+
+	if _, ok := ctx.startupHooks[name]; ok {
+		delete(ctx.startupHooks, name)
+		ctx.startupOrder = removeString(ctx.startupOrder, name)
+		return true
+	}
+
+	return false
+}
+
+// RunStartupHooks run startup hooks in order of registration.
+// It should be called after entries bootstrapped.
+func (ctx *appContext) RunStartupHooks() {
+	for _, name := range ctx.ListStartupHookNames() {
+		if f := ctx.GetStartupHook(name); f != nil {
+			f()
+		}
+	}
+}
+
 // AddShutdownHook add shutdown hook with name.
+// Hook with the same name will be replaced and keeps its original order.
 func (ctx *appContext) AddShutdownHook(name string, f ShutdownHook) {
 	if f == nil {
 		return
 	}
+
+	ctx.AddShutdownHookWithContext(name, func(context.Context) {
+		f()
+	})
+}
+
+// AddShutdownHookWithContext add shutdown hook with name, context passed to Shutdown() will be passed to hook,
+// so that hook could stop flushing once deadline exceeded.
+// Hook with the same name will be replaced and keeps its original order.
+func (ctx *appContext) AddShutdownHookWithContext(name string, f ShutdownHookWithContext) {
+	if f == nil {
+		return
+	}
+
+	ctx.hookLock.Lock()
+	defer ctx.hookLock.Unlock()
+
+	if _, ok := ctx.shutdownHooks[name]; !ok {
+		ctx.shutdownOrder = append(ctx.shutdownOrder, name)
+	}
 	ctx.shutdownHooks[name] = f
 }
 
-// GetShutdownHook returns shutdown hook with name.
+// GetShutdownHook returns shutdown hook with name, context.Background() will be passed to context-aware hook.
 func (ctx *appContext) GetShutdownHook(name string) ShutdownHook {
-	return ctx.shutdownHooks[name]
+	ctx.hookLock.Lock()
+	defer ctx.hookLock.Unlock()
+
+	return toShutdownHook(ctx.shutdownHooks[name])
 }
 
 // ListShutdownHooks list shutdown hooks.
 func (ctx *appContext) ListShutdownHooks() map[string]ShutdownHook {
-	return ctx.shutdownHooks
+	ctx.hookLock.Lock()
+	defer ctx.hookLock.Unlock()
+
+	res := make(map[string]ShutdownHook)
+	for k, v := range ctx.shutdownHooks {
+		res[k] = toShutdownHook(v)
+	}
+
+	return res
+}
+
+// getShutdownHookWithContext returns context-aware shutdown hook with name
+func (ctx *appContext) getShutdownHookWithContext(name string) ShutdownHookWithContext {
+	ctx.hookLock.Lock()
+	defer ctx.hookLock.Unlock()
+
+	return ctx.shutdownHooks[name]
+}
+
+// convert context-aware hook into ShutdownHook with context.Background()
+func toShutdownHook(f ShutdownHookWithContext) ShutdownHook {
+	if f == nil {
+		return nil
+	}
+
+	return func() {
+		f(context.Background())
+	}
+}
+
+// ListShutdownHookNames list names of shutdown hooks in order of execution,
+// which is reverse order of registration.
+func (ctx *appContext) ListShutdownHookNames() []string {
+	ctx.hookLock.Lock()
+	defer ctx.hookLock.Unlock()
+
+	res := make([]string, 0, len(ctx.shutdownOrder))
+	for i := len(ctx.shutdownOrder) - 1; i >= 0; i-- {
+		res = append(res, ctx.shutdownOrder[i])
+	}
+
+	return res
 }
 
 // RemoveShutdownHook remove shutdown hook.
 func (ctx *appContext) RemoveShutdownHook(name string) bool {
-	if _, ok := GlobalAppCtx.shutdownHooks[name]; ok {
-		delete(GlobalAppCtx.shutdownHooks, name)
+	ctx.hookLock.Lock()
+	defer ctx.hookLock.Unlock()
+
+	if _, ok := ctx.shutdownHooks[name]; ok {
+		delete(ctx.shutdownHooks, name)
+		ctx.shutdownOrder = removeString(ctx.shutdownOrder, name)
 		return true
 	}
 
 	return false
 }
 
+// RunShutdownHooks run shutdown hooks in reverse order of registration,
+// so that resources registered later, like middlewares, will be released before resources they depend on.
+func (ctx *appContext) RunShutdownHooks() {
+	for _, name := range ctx.ListShutdownHookNames() {
+		if f := ctx.getShutdownHookWithContext(name); f != nil {
+			f(context.Background())
+		}
+	}
+}
+
+// Shutdown runs shutdown hooks and interrupts every entry within deadline of shutdownCtx,
+// so that buffered telemetry, like async events of logging middleware, spans of tracing middleware
+// and logs of loki syncer, could be flushed within a bounded window.
+//
+// Shutdown hooks run first in the same order as RunShutdownHooks, then entries are interrupted,
+// LoggerEntry and EventEntry are interrupted at last, so that logs of other entries could be flushed.
+//
+// Once deadline exceeded, Shutdown returns error of shutdownCtx without waiting for remaining hooks and entries,
+// buffered telemetry which is not flushed yet will be dropped.
+func (ctx *appContext) Shutdown(shutdownCtx context.Context) error {
+	for _, name := range ctx.ListShutdownHookNames() {
+		f := ctx.getShutdownHookWithContext(name)
+		if f == nil {
+			continue
+		}
+
+		if err := runWithContext(shutdownCtx, func() { f(shutdownCtx) }); err != nil {
+			LoggerEntryStdout.Warn("Shutdown deadline exceeded, remaining buffered telemetry will be dropped",
+				zap.String("shutdownHook", name), zap.Error(err))
+			return err
+		}
+	}
+
+	for _, entry := range ctx.listEntriesToInterrupt() {
+		e := entry
+		if err := runWithContext(shutdownCtx, func() { e.Interrupt(shutdownCtx) }); err != nil {
+			LoggerEntryStdout.Warn("Shutdown deadline exceeded, remaining buffered telemetry will be dropped",
+				zap.String("entryName", e.GetName()),
+				zap.String("entryType", e.GetType()),
+				zap.Error(err))
+			return err
+		}
+	}
+
+	return nil
+}
+
+// list entries in order of interruption, LoggerEntry and EventEntry will be at the end
+func (ctx *appContext) listEntriesToInterrupt() []Entry {
+	res := make([]Entry, 0)
+	last := make([]Entry, 0)
+
+	types := make([]string, 0, len(ctx.entries))
+	for entryType := range ctx.entries {
+		types = append(types, entryType)
+	}
+	sort.Strings(types)
+
+	for _, entryType := range types {
+		names := make([]string, 0, len(ctx.entries[entryType]))
+		for name := range ctx.entries[entryType] {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			entry := ctx.entries[entryType][name]
+			if entry == nil {
+				continue
+			}
+
+			if entryType == LoggerEntryType || entryType == EventEntryType {
+				last = append(last, entry)
+			} else {
+				res = append(res, entry)
+			}
+		}
+	}
+
+	return append(res, last...)
+}
+
+// run f in background and wait until it returns or ctx done
+func runWithContext(ctx context.Context, f func()) error {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f()
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 // Internal use only.
 func (ctx *appContext) clearShutdownHooks() {
+	ctx.hookLock.Lock()
+	defer ctx.hookLock.Unlock()
+
 	for k := range ctx.shutdownHooks {
 		delete(ctx.shutdownHooks, k)
 	}
+	ctx.shutdownOrder = ctx.shutdownOrder[:0]
+}
+
+// Internal use only.
+func (ctx *appContext) clearStartupHooks() {
+	ctx.hookLock.Lock()
+	defer ctx.hookLock.Unlock()
+
+	for k := range ctx.startupHooks {
+		delete(ctx.startupHooks, k)
+	}
+	ctx.startupOrder = ctx.startupOrder[:0]
+}
+
+// remove element from slice and keep order
+func removeString(src []string, target string) []string {
+	res := make([]string, 0, len(src))
+	for i := range src {
+		if src[i] != target {
+			res = append(res, src[i])
+		}
+	}
+
+	return res
 }
 
 // *************************************
@@ -457,6 +813,7 @@ func (ctx *appContext) clearShutdownHooks() {
 // *************************************
 
 // WaitForShutdownSig waits for shutdown signal.
+// Shutdown hooks are not executed here, call RunShutdownHooks() after signal received.
 func (ctx *appContext) WaitForShutdownSig() {
 	<-ctx.shutdownSig
 }
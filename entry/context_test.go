@@ -8,9 +8,11 @@ package rkentry
 import (
 	"context"
 	"embed"
+	"errors"
 	"github.com/stretchr/testify/assert"
 	"net/http"
 	"os"
+	"sync"
 	"syscall"
 	"testing"
 	"time"
@@ -126,6 +128,26 @@ func TestListEntryRegFunc_HappyCase(t *testing.T) {
 	pluginRegFuncList = pluginRegFuncList[:0]
 }
 
+func TestBootstrapUserEntryFromYAML(t *testing.T) {
+	defer func() {
+		userDefRegFuncList = userDefRegFuncList[:0]
+		GlobalAppCtx.bootstrapTimings = nil
+	}()
+
+	RegisterUserEntryRegFunc(func([]byte) map[string]Entry {
+		return map[string]Entry{
+			"ut-entry": &EntryMock{Name: "ut-entry"},
+		}
+	})
+	BootstrapUserEntryFromYAML(nil)
+
+	timings := GlobalAppCtx.ListBootstrapTimings()
+	assert.Len(t, timings, 1)
+	assert.Equal(t, "ut-entry", timings[0].EntryName)
+	assert.Equal(t, "mock", timings[0].EntryType)
+	assert.Equal(t, timings, NewProcessInfo().Bootstrap)
+}
+
 // value related
 func TestAppContext_AddValue_WithEmptyKey(t *testing.T) {
 	key := ""
@@ -366,6 +388,119 @@ func TestAppContext_RemoveShutdownHook(t *testing.T) {
 	assert.True(t, GlobalAppCtx.RemoveShutdownHook("ut-shutdownhook"))
 }
 
+func TestAppContext_StartupHooks(t *testing.T) {
+	defer GlobalAppCtx.clearStartupHooks()
+
+	// with nil func
+	GlobalAppCtx.AddStartupHook("ut-nil", nil)
+	assert.Empty(t, GlobalAppCtx.ListStartupHooks())
+
+	calls := make([]string, 0)
+	GlobalAppCtx.AddStartupHook("ut-first", func() { calls = append(calls, "ut-first") })
+	GlobalAppCtx.AddStartupHook("ut-second", func() { calls = append(calls, "ut-second") })
+	GlobalAppCtx.AddStartupHook("ut-third", func() { calls = append(calls, "ut-third") })
+	// replace hook with same name should keep order
+	GlobalAppCtx.AddStartupHook("ut-first", func() { calls = append(calls, "ut-first-replaced") })
+	assert.Len(t, GlobalAppCtx.ListStartupHooks(), 3)
+	assert.NotNil(t, GlobalAppCtx.GetStartupHook("ut-first"))
+
+	assert.False(t, GlobalAppCtx.RemoveStartupHook("non-exist"))
+	assert.True(t, GlobalAppCtx.RemoveStartupHook("ut-second"))
+	assert.Equal(t, []string{"ut-first", "ut-third"}, GlobalAppCtx.ListStartupHookNames())
+
+	GlobalAppCtx.RunStartupHooks()
+	assert.Equal(t, []string{"ut-first-replaced", "ut-third"}, calls)
+}
+
+func TestAppContext_RunShutdownHooks(t *testing.T) {
+	defer GlobalAppCtx.clearShutdownHooks()
+
+	calls := make([]string, 0)
+	GlobalAppCtx.AddShutdownHook("ut-first", func() { calls = append(calls, "ut-first") })
+	GlobalAppCtx.AddShutdownHook("ut-second", func() { calls = append(calls, "ut-second") })
+	GlobalAppCtx.AddShutdownHook("ut-third", func() { calls = append(calls, "ut-third") })
+	assert.True(t, GlobalAppCtx.RemoveShutdownHook("ut-second"))
+	assert.Equal(t, []string{"ut-third", "ut-first"}, GlobalAppCtx.ListShutdownHookNames())
+
+	GlobalAppCtx.RunShutdownHooks()
+	assert.Equal(t, []string{"ut-third", "ut-first"}, calls)
+}
+
+func TestAppContext_Shutdown(t *testing.T) {
+	defer GlobalAppCtx.clearShutdownHooks()
+
+	calls := make([]string, 0)
+	lock := sync.Mutex{}
+	record := func(name string) {
+		lock.Lock()
+		defer lock.Unlock()
+		calls = append(calls, name)
+	}
+
+	// isolate entries registered by other tests
+	entries := GlobalAppCtx.entries
+	GlobalAppCtx.clearEntries()
+	defer func() {
+		GlobalAppCtx.entries = entries
+	}()
+
+	GlobalAppCtx.AddShutdownHook("ut-hook", func() { record("ut-hook") })
+	var hookCtx context.Context
+	GlobalAppCtx.AddShutdownHookWithContext("ut-hook-ctx", func(ctx context.Context) { hookCtx = ctx })
+	GlobalAppCtx.AddShutdownHookWithContext("ut-hook-nil", nil)
+	assert.Nil(t, GlobalAppCtx.GetShutdownHook("ut-hook-nil"))
+	logger := &shutdownEntry{name: "ut-shutdown-logger", entryType: LoggerEntryType, record: record}
+	other := &shutdownEntry{name: "ut-shutdown-other", entryType: "ut-shutdown-type", record: record}
+	GlobalAppCtx.AddEntry(logger)
+	GlobalAppCtx.AddEntry(other)
+
+	// hooks first, then entries, logger entries at last
+	shutdownCtx := context.WithValue(context.Background(), "ut-key", "ut-value")
+	assert.Nil(t, GlobalAppCtx.Shutdown(shutdownCtx))
+	assert.Equal(t, []string{"ut-hook", "ut-shutdown-other", "ut-shutdown-logger"}, calls)
+	assert.Equal(t, shutdownCtx, hookCtx)
+
+	// with deadline exceeded
+	calls = make([]string, 0)
+	other.delay = time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, GlobalAppCtx.Shutdown(ctx), context.DeadlineExceeded)
+	lock.Lock()
+	assert.Equal(t, []string{"ut-hook"}, calls)
+	lock.Unlock()
+}
+
+type shutdownEntry struct {
+	name      string
+	entryType string
+	delay     time.Duration
+	record    func(string)
+}
+
+func (e *shutdownEntry) Bootstrap(context.Context) {}
+
+func (e *shutdownEntry) Interrupt(context.Context) {
+	time.Sleep(e.delay)
+	e.record(e.name)
+}
+
+func (e *shutdownEntry) GetName() string {
+	return e.name
+}
+
+func (e *shutdownEntry) GetType() string {
+	return e.entryType
+}
+
+func (e *shutdownEntry) GetDescription() string {
+	return ""
+}
+
+func (e *shutdownEntry) String() string {
+	return ""
+}
+
 func TestAppContext_WaitForShutdownSig(t *testing.T) {
 	go func() {
 		time.Sleep(1 * time.Second)
@@ -404,6 +539,33 @@ func TestAppContext_SetReadinessCheck(t *testing.T) {
 	assert.NotNil(t, GlobalAppCtx.livenessCheck)
 }
 
+func TestAppContext_CheckHealth(t *testing.T) {
+	defer GlobalAppCtx.clearEntries()
+
+	// without reporter
+	GlobalAppCtx.AddEntry(&EntryMock{Name: "ut-entry"})
+	assert.Empty(t, GlobalAppCtx.CheckHealth(context.TODO()))
+
+	// with healthy reporter
+	GlobalAppCtx.AddEntry(&healthReporterMock{EntryMock: EntryMock{Name: "ut-healthy"}})
+	assert.Empty(t, GlobalAppCtx.CheckHealth(context.TODO()))
+
+	// with unhealthy reporter
+	GlobalAppCtx.AddEntry(&healthReporterMock{EntryMock: EntryMock{Name: "ut-unhealthy"}, err: errors.New("ut-error")})
+	res := GlobalAppCtx.CheckHealth(context.TODO())
+	assert.Len(t, res, 1)
+	assert.EqualError(t, res["mock/ut-unhealthy"], "ut-error")
+}
+
+type healthReporterMock struct {
+	EntryMock
+	err error
+}
+
+func (entry *healthReporterMock) HealthCheck(context.Context) error {
+	return entry.err
+}
+
 type EntryMock struct {
 	Name string
 }
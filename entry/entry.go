@@ -90,6 +90,13 @@ type Entry interface {
 	String() string
 }
 
+// HealthReporter interface which could be implemented by entries in order to report readiness.
+// Entries implementing it in GlobalAppCtx will be aggregated by CommonServiceEntry while serving /ready.
+type HealthReporter interface {
+	// HealthCheck returns error if entry is not healthy
+	HealthCheck(ctx context.Context) error
+}
+
 // SignerJwt interface which must be implemented for JWT signer
 type SignerJwt interface {
 	Entry
@@ -107,6 +114,20 @@ type SignerJwt interface {
 	Algorithms() []string
 }
 
+// SignerJwtWithContext is implemented by SignerJwt which may block while verifying, like fetching keys of JWKS.
+// Middlewares prefer VerifyJwtWithContext, so that verification will be cancelled with request.
+type SignerJwtWithContext interface {
+	// VerifyJwtWithContext verify jwt.Token, blocking operations should return once ctx done
+	VerifyJwtWithContext(ctx context.Context, token string) (*jwt.Token, error)
+}
+
+// SignerJwtKeyFunc is implemented by SignerJwt which could provide key to verify signature of token,
+// so that middlewares could parse token with their own options, like validating time-based claims with leeway.
+type SignerJwtKeyFunc interface {
+	// KeyFunc returns jwt.Keyfunc which returns key of token, blocking operations should return once ctx done
+	KeyFunc(ctx context.Context) jwt.Keyfunc
+}
+
 type Crypto interface {
 	Entry
 
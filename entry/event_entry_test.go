@@ -63,6 +63,18 @@ func TestRegisterEventEntry(t *testing.T) {
 	assert.NotEmpty(t, entries[0].String())
 }
 
+func TestEventEntry_MarshalJSON(t *testing.T) {
+	entry := NewEventEntryStdout()
+
+	expected, err := entry.MarshalJSON()
+	assert.Nil(t, err)
+	for i := 0; i < 10; i++ {
+		bytes, err := entry.MarshalJSON()
+		assert.Nil(t, err)
+		assert.Equal(t, string(expected), string(bytes))
+	}
+}
+
 func TestEventEntry_UnmarshalJSON(t *testing.T) {
 	assert.Nil(t, NewEventEntryNoop().UnmarshalJSON(nil))
 }
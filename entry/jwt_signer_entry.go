@@ -2,11 +2,22 @@ package rkentry
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/tls"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/golang-jwt/jwt/v4"
+	"go.uber.org/zap"
+	"io"
+	"math/big"
+	"net/http"
 	"strings"
+	"sync"
+	"time"
 )
 
 // validAlgorithm a simple function which will check input string in slice
@@ -95,13 +106,7 @@ func (s *symmetricJwtSigner) SignJwt(claim jwt.Claims) (string, error) {
 
 // VerifyJwt verify jwt with key
 func (s *symmetricJwtSigner) VerifyJwt(raw string) (*jwt.Token, error) {
-	token, err := jwt.Parse(raw, func(t *jwt.Token) (interface{}, error) {
-		if t.Method.Alg() != s.Algorithm {
-			return nil, fmt.Errorf("unexpected jwt signing algorithm=%v", t.Header["alg"])
-		}
-
-		return s.PubKey(), nil
-	})
+	token, err := jwt.Parse(raw, s.KeyFunc(context.Background()))
 
 	// return error
 	if err != nil {
@@ -116,6 +121,17 @@ func (s *symmetricJwtSigner) VerifyJwt(raw string) (*jwt.Token, error) {
 	return token, nil
 }
 
+// KeyFunc returns key if algorithm of token matches with signer
+func (s *symmetricJwtSigner) KeyFunc(context.Context) jwt.Keyfunc {
+	return func(t *jwt.Token) (interface{}, error) {
+		if t.Method.Alg() != s.Algorithm {
+			return nil, fmt.Errorf("unexpected jwt signing algorithm=%v", t.Header["alg"])
+		}
+
+		return s.PubKey(), nil
+	}
+}
+
 // PubKey return raw token
 func (s *symmetricJwtSigner) PubKey() []byte {
 	return s.key
@@ -242,13 +258,195 @@ func (s *asymmetricJwtSigner) SignJwt(claim jwt.Claims) (string, error) {
 
 // VerifyJwt verify jwt with key
 func (s *asymmetricJwtSigner) VerifyJwt(raw string) (*jwt.Token, error) {
-	token, err := jwt.Parse(raw, func(t *jwt.Token) (interface{}, error) {
+	token, err := jwt.Parse(raw, s.KeyFunc(context.Background()))
+
+	// return error
+	if err != nil {
+		return nil, err
+	}
+
+	// invalid token
+	if !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+
+	return token, nil
+}
+
+// KeyFunc returns public key if algorithm of token matches with signer
+func (s *asymmetricJwtSigner) KeyFunc(context.Context) jwt.Keyfunc {
+	return func(t *jwt.Token) (interface{}, error) {
 		if t.Method.Alg() != s.Algorithm {
 			return nil, fmt.Errorf("unexpected jwt signing algorithm=%v", t.Header["alg"])
 		}
 
 		return s.pubKey, nil
+	}
+}
+
+// PubKey return public key
+func (s *asymmetricJwtSigner) PubKey() []byte {
+	return nil
+}
+
+// Algorithms supported algorithms
+func (s *asymmetricJwtSigner) Algorithms() []string {
+	return []string{
+		jwt.SigningMethodRS256.Name,
+		jwt.SigningMethodRS384.Name,
+		jwt.SigningMethodRS512.Name,
+		jwt.SigningMethodES256.Name,
+		jwt.SigningMethodES384.Name,
+		jwt.SigningMethodES512.Name,
+	}
+}
+
+// JwksSignerOption options for jwksJwtSigner
+type JwksSignerOption func(*jwksJwtSigner)
+
+// WithRefreshIntervalJwksSigner provide interval of refreshing keys in background, default is 10 minutes
+func WithRefreshIntervalJwksSigner(interval time.Duration) JwksSignerOption {
+	return func(s *jwksJwtSigner) {
+		if interval > 0 {
+			s.refreshInterval = interval
+		}
+	}
+}
+
+// WithInsecureSkipVerifyJwksSigner skip verification of certificate of JWKS url
+func WithInsecureSkipVerifyJwksSigner(skip bool) JwksSignerOption {
+	return func(s *jwksJwtSigner) {
+		if skip {
+			s.client.Transport = &http.Transport{
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+			}
+		}
+	}
+}
+
+// RegisterJwksSigner create jwksJwtSigner which verifies jwt with public keys fetched from JWKS url of IdP.
+//
+// Key will be matched with kid of token. Keys are fetched and refreshed in background, verification waits
+// for the first fetch. Key set fetched last time will be kept if refresh failed. Unknown kid triggers a refresh,
+// which is throttled by minJwksRefreshInterval, so that rotated keys could be used before next refresh.
+// Background refresh will be stopped by Interrupt().
+//
+// Registered signer with the same name and url will be returned as it is, signer with the same name
+// and different url will be interrupted and replaced.
+func RegisterJwksSigner(entryName, url string, opts ...JwksSignerOption) *jwksJwtSigner {
+	if v, ok := GlobalAppCtx.GetEntry(SignerJwtEntryType, entryName).(*jwksJwtSigner); ok {
+		if v.url == url {
+			return v
+		}
+		v.Interrupt(context.Background())
+	}
+
+	res := &jwksJwtSigner{
+		entryName:       entryName,
+		url:             url,
+		refreshInterval: 10 * time.Minute,
+		client:          &http.Client{Timeout: 10 * time.Second},
+		keys:            make(map[string]*jwk),
+		readyCh:         make(chan struct{}),
+		stopCh:          make(chan struct{}),
+	}
+
+	for i := range opts {
+		opts[i](res)
+	}
+
+	go res.run()
+
+	GlobalAppCtx.AddEntry(res)
+
+	return res
+}
+
+const (
+	// minJwksRefreshInterval throttles refreshing triggered by unknown kid
+	minJwksRefreshInterval = 5 * time.Second
+	// maxJwksBytes limits size of JWKS response body
+	maxJwksBytes = int64(1 << 20)
+)
+
+// jwk is a public key in JWKS
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	key interface{}
+}
+
+// jwksJwtSigner a signer which verifies jwt with public keys fetched from JWKS url
+type jwksJwtSigner struct {
+	entryName       string          `yaml:"-" json:"-"`
+	url             string          `yaml:"-" json:"-"`
+	refreshInterval time.Duration   `yaml:"-" json:"-"`
+	client          *http.Client    `yaml:"-" json:"-"`
+	lock            sync.RWMutex    `yaml:"-" json:"-"`
+	keys            map[string]*jwk `yaml:"-" json:"-"`
+	lastRefresh     time.Time       `yaml:"-" json:"-"`
+	readyCh         chan struct{}   `yaml:"-" json:"-"`
+	stopCh          chan struct{}   `yaml:"-" json:"-"`
+	stopOnce        sync.Once       `yaml:"-" json:"-"`
+}
+
+func (s *jwksJwtSigner) Bootstrap(ctx context.Context) {}
+
+// Interrupt stops background refresh
+func (s *jwksJwtSigner) Interrupt(ctx context.Context) {
+	s.stopOnce.Do(func() {
+		close(s.stopCh)
 	})
+}
+
+func (s *jwksJwtSigner) GetName() string {
+	return s.entryName
+}
+
+func (s *jwksJwtSigner) GetType() string {
+	return SignerJwtEntryType
+}
+
+func (s *jwksJwtSigner) GetDescription() string {
+	return "JWKS jwt signer"
+}
+
+func (s *jwksJwtSigner) String() string {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	m := map[string]interface{}{
+		"name":                s.entryName,
+		"url":                 s.url,
+		"refreshInterval":     s.refreshInterval.String(),
+		"keys":                len(s.keys),
+		"supportedAlgorithms": strings.Join(s.Algorithms(), ","),
+	}
+
+	bytes, _ := json.Marshal(m)
+	return string(bytes)
+}
+
+// SignJwt is not supported since private keys are owned by IdP
+func (s *jwksJwtSigner) SignJwt(jwt.Claims) (string, error) {
+	return "", errors.New("jwks signer could not sign jwt")
+}
+
+// VerifyJwt verify jwt with key matched with kid of token
+func (s *jwksJwtSigner) VerifyJwt(raw string) (*jwt.Token, error) {
+	return s.VerifyJwtWithContext(context.Background(), raw)
+}
+
+// VerifyJwtWithContext verify jwt with key matched with kid of token, refreshing of keys will be cancelled with ctx
+func (s *jwksJwtSigner) VerifyJwtWithContext(ctx context.Context, raw string) (*jwt.Token, error) {
+	token, err := jwt.Parse(raw, s.KeyFunc(ctx))
 
 	// return error
 	if err != nil {
@@ -263,19 +461,202 @@ func (s *asymmetricJwtSigner) VerifyJwt(raw string) (*jwt.Token, error) {
 	return token, nil
 }
 
-// PubKey return public key
-func (s *asymmetricJwtSigner) PubKey() []byte {
+// KeyFunc returns key matched with kid of token, refreshing of keys will be cancelled with ctx
+func (s *jwksJwtSigner) KeyFunc(ctx context.Context) jwt.Keyfunc {
+	return func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		key := s.getKey(ctx, kid)
+		if key == nil {
+			return nil, fmt.Errorf("unknown jwt kid=%s", kid)
+		}
+
+		if len(key.Alg) > 0 && key.Alg != t.Method.Alg() {
+			return nil, fmt.Errorf("unexpected jwt signing algorithm=%v", t.Header["alg"])
+		}
+
+		// make sure key of one family will never be used to verify token of another family
+		switch t.Method.(type) {
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+			if _, ok := key.key.(*rsa.PublicKey); ok {
+				return key.key, nil
+			}
+		case *jwt.SigningMethodECDSA:
+			if _, ok := key.key.(*ecdsa.PublicKey); ok {
+				return key.key, nil
+			}
+		}
+
+		return nil, fmt.Errorf("unexpected jwt signing algorithm=%v", t.Header["alg"])
+	}
+}
+
+// PubKey returns nil since there are multiple keys
+func (s *jwksJwtSigner) PubKey() []byte {
 	return nil
 }
 
 // Algorithms supported algorithms
-func (s *asymmetricJwtSigner) Algorithms() []string {
+func (s *jwksJwtSigner) Algorithms() []string {
 	return []string{
 		jwt.SigningMethodRS256.Name,
 		jwt.SigningMethodRS384.Name,
 		jwt.SigningMethodRS512.Name,
+		jwt.SigningMethodPS256.Name,
+		jwt.SigningMethodPS384.Name,
+		jwt.SigningMethodPS512.Name,
 		jwt.SigningMethodES256.Name,
 		jwt.SigningMethodES384.Name,
 		jwt.SigningMethodES512.Name,
 	}
 }
+
+// getKey returns key with kid, keys will be refreshed if not found
+func (s *jwksJwtSigner) getKey(ctx context.Context, kid string) *jwk {
+	// wait for the first fetch
+	select {
+	case <-s.readyCh:
+	default:
+		select {
+		case <-s.readyCh:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+
+	s.lock.RLock()
+	key, lastRefresh := s.keys[kid], s.lastRefresh
+	s.lock.RUnlock()
+
+	if key != nil || time.Since(lastRefresh) < minJwksRefreshInterval {
+		return key
+	}
+
+	if err := s.refresh(ctx); err != nil {
+		return nil
+	}
+
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.keys[kid]
+}
+
+// run fetches keys and refreshes them periodically until interrupted
+func (s *jwksJwtSigner) run() {
+	if err := s.refresh(context.Background()); err != nil {
+		LoggerEntryStdout.Warn("Failed to fetch JWKS", zap.String("url", s.url), zap.Error(err))
+	}
+	close(s.readyCh)
+
+	ticker := time.NewTicker(s.refreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.refresh(context.Background()); err != nil {
+				LoggerEntryStdout.Warn("Failed to refresh JWKS, keep last key set", zap.String("url", s.url), zap.Error(err))
+			}
+		case <-s.stopCh:
+			return
+		}
+	}
+}
+
+// refresh fetches keys from url, keys will not be replaced if failed
+func (s *jwksJwtSigner) refresh(ctx context.Context) error {
+	s.lock.Lock()
+	s.lastRefresh = time.Now()
+	s.lock.Unlock()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch jwks, status:%s", resp.Status)
+	}
+
+	set := struct {
+		Keys []*jwk `json:"keys"`
+	}{}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxJwksBytes)).Decode(&set); err != nil {
+		return err
+	}
+
+	keys := make(map[string]*jwk)
+	for _, key := range set.Keys {
+		// skip keys not for signature or with unsupported type
+		if key.Use == "enc" {
+			continue
+		}
+		if key.key, err = key.publicKey(); err == nil {
+			keys[key.Kid] = key
+		}
+	}
+
+	if len(keys) < 1 {
+		return errors.New("no valid key found in jwks")
+	}
+
+	s.lock.Lock()
+	s.keys = keys
+	s.lock.Unlock()
+
+	return nil
+}
+
+// publicKey parse public key of RSA or EC
+func (k *jwk) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve=%s", k.Crv)
+		}
+
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+
+		return &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported key type=%s", k.Kty)
+}
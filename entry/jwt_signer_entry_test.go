@@ -0,0 +1,126 @@
+package rkentry
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRegisterJwksSigner(t *testing.T) {
+	defer GlobalAppCtx.RemoveEntryByType(SignerJwtEntryType)
+
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	encode := func(b []byte) string {
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+
+	var failed, oversized int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&failed) > 0 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if atomic.LoadInt32(&oversized) > 0 {
+			w.Write([]byte(`{"keys":[{"kid":"` + strings.Repeat("k", int(maxJwksBytes)) + `"}]}`))
+			return
+		}
+
+		bytes, _ := json.Marshal(map[string]interface{}{
+			"keys": []map[string]string{
+				{"kid": "ut-rsa", "kty": "RSA", "alg": "RS256", "n": encode(rsaKey.N.Bytes()), "e": encode(big.NewInt(int64(rsaKey.E)).Bytes())},
+				{"kid": "ut-ec", "kty": "EC", "crv": "P-256", "x": encode(ecKey.X.Bytes()), "y": encode(ecKey.Y.Bytes())},
+				{"kid": "ut-enc", "kty": "RSA", "use": "enc", "n": encode(rsaKey.N.Bytes()), "e": "AQAB"},
+				{"kid": "ut-unknown", "kty": "oct"},
+			},
+		})
+		w.Write(bytes)
+	}))
+	defer server.Close()
+
+	signer := RegisterJwksSigner("ut-jwks", server.URL,
+		WithRefreshIntervalJwksSigner(time.Hour),
+		WithInsecureSkipVerifyJwksSigner(true))
+	defer signer.Interrupt(context.Background())
+	<-signer.readyCh
+
+	assert.Equal(t, signer, GlobalAppCtx.GetSignerJwtEntry("ut-jwks"))
+	// signer with the same name and url should be reused
+	assert.Equal(t, signer, RegisterJwksSigner("ut-jwks", server.URL))
+	assert.NotEmpty(t, signer.String())
+	assert.Nil(t, signer.PubKey())
+	assert.Len(t, signer.keys, 2)
+	_, err := signer.SignJwt(jwt.MapClaims{})
+	assert.NotNil(t, err)
+
+	sign := func(method jwt.SigningMethod, kid string, key interface{}) string {
+		token := jwt.NewWithClaims(method, jwt.MapClaims{"sub": "ut-user"})
+		token.Header["kid"] = kid
+		raw, _ := token.SignedString(key)
+		return raw
+	}
+
+	// with RSA key
+	token, err := signer.VerifyJwt(sign(jwt.SigningMethodRS256, "ut-rsa", rsaKey))
+	assert.Nil(t, err)
+	assert.True(t, token.Valid)
+
+	// with EC key
+	_, err = signer.VerifyJwt(sign(jwt.SigningMethodES256, "ut-ec", ecKey))
+	assert.Nil(t, err)
+
+	// with algorithm not matched with key
+	_, err = signer.VerifyJwt(sign(jwt.SigningMethodRS512, "ut-rsa", rsaKey))
+	assert.NotNil(t, err)
+
+	// with HMAC token signed with public key
+	_, err = signer.VerifyJwt(sign(jwt.SigningMethodHS256, "ut-ec", []byte("ut-key")))
+	assert.NotNil(t, err)
+
+	// with unknown kid
+	_, err = signer.VerifyJwt(sign(jwt.SigningMethodRS256, "ut-missing", rsaKey))
+	assert.NotNil(t, err)
+
+	// keep last key set if refresh failed
+	atomic.StoreInt32(&failed, 1)
+	assert.NotNil(t, signer.refresh(context.Background()))
+	_, err = signer.VerifyJwt(sign(jwt.SigningMethodRS256, "ut-rsa", rsaKey))
+	assert.Nil(t, err)
+
+	// refresh should be cancelled with context
+	atomic.StoreInt32(&failed, 0)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, signer.refresh(ctx), context.Canceled)
+	_, err = signer.VerifyJwtWithContext(ctx, sign(jwt.SigningMethodRS256, "ut-rsa", rsaKey))
+	assert.Nil(t, err)
+
+	// with oversized body
+	atomic.StoreInt32(&oversized, 1)
+	assert.NotNil(t, signer.refresh(context.Background()))
+	assert.Len(t, signer.keys, 2)
+
+	// signer with the same name and different url should be interrupted and replaced
+	replaced := RegisterJwksSigner("ut-jwks", server.URL+"/replaced")
+	defer replaced.Interrupt(context.Background())
+	assert.NotEqual(t, signer, replaced)
+	assert.Equal(t, replaced, GlobalAppCtx.GetSignerJwtEntry("ut-jwks"))
+	select {
+	case <-signer.stopCh:
+	default:
+		assert.Fail(t, "replaced signer should be interrupted")
+	}
+}
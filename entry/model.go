@@ -19,7 +19,8 @@ type aliveResp struct {
 
 // readyResp response of /ready
 type readyResp struct {
-	Ready bool `json:"ready" yaml:"ready" example:"true"`
+	Ready     bool              `json:"ready" yaml:"ready" example:"true"`
+	Unhealthy map[string]string `json:"unhealthy,omitempty" yaml:"unhealthy,omitempty"`
 }
 
 // gcResp response of /gc
@@ -52,6 +53,8 @@ type ProcessInfo struct {
 	NetInfo     *rkos.NetInfo   `json:"netInfo" yaml:"netInfo"`
 	OsInfo      *rkos.OsInfo    `json:"osInfo" yaml:"osInfo"`
 	GoEnvInfo   *rkos.GoEnvInfo `json:"goEnvInfo" yaml:"goEnvInfo"`
+	// Bootstrap timings of entries in order of bootstrap
+	Bootstrap []*BootstrapTiming `json:"bootstrap" yaml:"bootstrap"`
 }
 
 // NewProcessInfo creates a new ProcessInfo instance
@@ -88,5 +91,6 @@ func NewProcessInfo() *ProcessInfo {
 		NetInfo:     rkos.NewNetInfo(),
 		OsInfo:      rkos.NewOsInfo(),
 		GoEnvInfo:   rkos.NewGoEnvInfo(),
+		Bootstrap:   GlobalAppCtx.ListBootstrapTimings(),
 	}
 }
@@ -3,15 +3,21 @@ package rkentry
 import (
 	"context"
 	"encoding/json"
+	"net/http"
+	"net/http/pprof"
 	"path"
 	"strings"
 )
 
+// BootPProf bootstrap config of pprof entry.
 type BootPProf struct {
 	Enabled bool   `yaml:"enabled" json:"enabled"`
 	Path    string `yaml:"path" json:"path"`
 }
 
+// PProfEntry exposes handlers of net/http/pprof under Path.
+//
+// Path is not ignored by middlewares, so that it could be protected by auth or secure middleware.
 type PProfEntry struct {
 	entryName        string `json:"-" yaml:"-"`
 	entryType        string `json:"-" yaml:"-"`
@@ -57,15 +63,43 @@ func (entry *PProfEntry) UnmarshalJSON([]byte) error {
 	return nil
 }
 
+// GetHandler returns http.Handler which serves pprof handlers under Path,
+// like /pprof/ for index page and /pprof/heap for heap profile.
+func (entry *PProfEntry) GetHandler() http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if !strings.HasPrefix(request.URL.Path, entry.Path) {
+			http.NotFound(writer, request)
+			return
+		}
+
+		switch name := strings.TrimPrefix(request.URL.Path, entry.Path); name {
+		case "":
+			pprof.Index(writer, request)
+		case "cmdline":
+			pprof.Cmdline(writer, request)
+		case "profile":
+			pprof.Profile(writer, request)
+		case "symbol":
+			pprof.Symbol(writer, request)
+		case "trace":
+			pprof.Trace(writer, request)
+		default:
+			pprof.Handler(name).ServeHTTP(writer, request)
+		}
+	})
+}
+
+// PProfEntryOption options for PProfEntry
 type PProfEntryOption func(entry *PProfEntry)
 
+// WithNamePProfEntry provide entry name
 func WithNamePProfEntry(name string) PProfEntryOption {
 	return func(entry *PProfEntry) {
 		entry.entryName = name
 	}
 }
 
-// RegisterPProfEntry Create new pprof entry with config
+// RegisterPProfEntry Create new pprof entry with config and add it to GlobalAppCtx, nil will be returned if disabled
 func RegisterPProfEntry(boot *BootPProf, opts ...PProfEntryOption) *PProfEntry {
 	if !boot.Enabled {
 		return nil
@@ -91,5 +125,7 @@ func RegisterPProfEntry(boot *BootPProf, opts ...PProfEntryOption) *PProfEntry {
 		entry.Path = entry.Path + "/"
 	}
 
+	GlobalAppCtx.AddEntry(entry)
+
 	return entry
 }
@@ -3,6 +3,8 @@ package rkentry
 import (
 	"context"
 	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 )
 
@@ -38,3 +40,45 @@ func TestPProfEntry_UnmarshalJSON(t *testing.T) {
 	})
 	assert.Nil(t, entry.UnmarshalJSON(nil))
 }
+
+func TestPProfEntry_GetHandler(t *testing.T) {
+	defer GlobalAppCtx.RemoveEntryByType(PProfEntryType)
+
+	// with disabled entry
+	assert.Nil(t, RegisterPProfEntry(&BootPProf{}))
+
+	entry := RegisterPProfEntry(&BootPProf{
+		Enabled: true,
+	}, WithNamePProfEntry("ut-pprof"))
+	assert.Equal(t, "/pprof/", entry.Path)
+	assert.Equal(t, entry, GlobalAppCtx.GetPProfEntry("ut-pprof"))
+	assert.Nil(t, GlobalAppCtx.GetPProfEntry("not-exist"))
+
+	handler := entry.GetHandler()
+
+	// with index page
+	writer := httptest.NewRecorder()
+	handler.ServeHTTP(writer, httptest.NewRequest(http.MethodGet, "/pprof/", nil))
+	assert.Equal(t, http.StatusOK, writer.Code)
+	assert.Contains(t, writer.Body.String(), "goroutine")
+
+	// with named profile
+	writer = httptest.NewRecorder()
+	handler.ServeHTTP(writer, httptest.NewRequest(http.MethodGet, "/pprof/goroutine?debug=1", nil))
+	assert.Equal(t, http.StatusOK, writer.Code)
+
+	// with cmdline
+	writer = httptest.NewRecorder()
+	handler.ServeHTTP(writer, httptest.NewRequest(http.MethodGet, "/pprof/cmdline", nil))
+	assert.Equal(t, http.StatusOK, writer.Code)
+
+	// with unknown profile
+	writer = httptest.NewRecorder()
+	handler.ServeHTTP(writer, httptest.NewRequest(http.MethodGet, "/pprof/unknown", nil))
+	assert.Equal(t, http.StatusNotFound, writer.Code)
+
+	// with path out of prefix
+	writer = httptest.NewRecorder()
+	handler.ServeHTTP(writer, httptest.NewRequest(http.MethodGet, "/other", nil))
+	assert.Equal(t, http.StatusNotFound, writer.Code)
+}
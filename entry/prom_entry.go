@@ -11,10 +11,59 @@ import (
 	"go.uber.org/atomic"
 	"go.uber.org/zap"
 	"net/http"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 )
 
+var (
+	namedRegistries     = make(map[string]*prometheus.Registry)
+	namedRegistriesLock = sync.Mutex{}
+)
+
+// GetNamedRegistry returns prometheus.Registry with name, registry will be created if missing.
+//
+// Named registries segregate metrics in one process, for example, business metrics could be exposed on public
+// endpoint while internal metrics are exposed on another one. Expose it with PromEntry on distinct path.
+// nil will be returned if name is empty.
+func GetNamedRegistry(name string) *prometheus.Registry {
+	if len(name) < 1 {
+		return nil
+	}
+
+	namedRegistriesLock.Lock()
+	defer namedRegistriesLock.Unlock()
+
+	if _, ok := namedRegistries[name]; !ok {
+		namedRegistries[name] = prometheus.NewRegistry()
+	}
+
+	return namedRegistries[name]
+}
+
+// ListNamedRegistries returns names of registries created with GetNamedRegistry in ascending order
+func ListNamedRegistries() []string {
+	namedRegistriesLock.Lock()
+	defer namedRegistriesLock.Unlock()
+
+	res := make([]string, 0, len(namedRegistries))
+	for k := range namedRegistries {
+		res = append(res, k)
+	}
+	sort.Strings(res)
+
+	return res
+}
+
+// RemoveNamedRegistry removes registry with name, metrics registered in it will not be unregistered
+func RemoveNamedRegistry(name string) {
+	namedRegistriesLock.Lock()
+	defer namedRegistriesLock.Unlock()
+
+	delete(namedRegistries, name)
+}
+
 // WithRegistryPromEntry provide prometheus.Registry
 func WithRegistryPromEntry(registry *prometheus.Registry) PromEntryOption {
 	return func(entry *PromEntry) {
@@ -22,6 +71,17 @@ func WithRegistryPromEntry(registry *prometheus.Registry) PromEntryOption {
 	}
 }
 
+// WithNamedRegistryPromEntry provide name of registry created with GetNamedRegistry,
+// metrics registered in named registry will be exposed by this entry.
+func WithNamedRegistryPromEntry(name string) PromEntryOption {
+	return func(entry *PromEntry) {
+		if len(name) > 0 {
+			entry.registryName = name
+			entry.Registry = GetNamedRegistry(name)
+		}
+	}
+}
+
 // RegisterPromEntry Create a prom entry with options and add prom entry to rkentry.GlobalAppCtx
 func RegisterPromEntry(boot *BootProm, opts ...PromEntryOption) *PromEntry {
 	if !boot.Enabled {
@@ -37,6 +97,11 @@ func RegisterPromEntry(boot *BootProm, opts ...PromEntryOption) *PromEntry {
 		Gatherer:         prometheus.DefaultGatherer,
 	}
 
+	// expose named registry on its own path
+	if len(boot.Registry) > 0 {
+		WithNamedRegistryPromEntry(boot.Registry)(entry)
+	}
+
 	for i := range opts {
 		opts[i](entry)
 	}
@@ -44,7 +109,10 @@ func RegisterPromEntry(boot *BootProm, opts ...PromEntryOption) *PromEntry {
 	if entry.Registry == nil {
 		entry.Registry = prometheus.NewRegistry()
 	}
-	entry.Registry.Register(collectors.NewGoCollector())
+	// named registries are used for segregated metrics, collect Go runtime metrics in default one only
+	if len(entry.registryName) < 1 {
+		entry.Registry.Register(collectors.NewGoCollector())
+	}
 
 	if entry.Registry != nil {
 		entry.Registerer = entry.Registry
@@ -72,7 +140,9 @@ func RegisterPromEntry(boot *BootProm, opts ...PromEntryOption) *PromEntry {
 type BootProm struct {
 	Enabled bool   `yaml:"enabled" json:"enabled"`
 	Path    string `yaml:"path" json:"path"`
-	Pusher  struct {
+	// Registry is name of registry created with GetNamedRegistry, a new registry will be used if empty
+	Registry string `yaml:"registry" json:"registry"`
+	Pusher   struct {
 		Enabled       bool   `yaml:"enabled" json:"enabled"`
 		IntervalMs    int64  `yaml:"IntervalMs" json:"IntervalMs"`
 		JobName       string `yaml:"jobName" json:"jobName"`
@@ -80,6 +150,8 @@ type BootProm struct {
 		BasicAuth     string `yaml:"basicAuth" json:"basicAuth"`
 		CertEntry     string `yaml:"certEntry" json:"certEntry"`
 		LoggerEntry   string `yaml:"loggerEntry" json:"loggerEntry"`
+		// Grouping labels added to pushed metrics, like instance
+		Grouping map[string]string `yaml:"grouping" json:"grouping"`
 	} `yaml:"pusher" json:"pusher"`
 }
 
@@ -93,6 +165,7 @@ type PromEntry struct {
 	entryType        string             `json:"-" yaml:"-"`
 	entryDescription string             `json:"-" yaml:"-"`
 	Path             string             `json:"-" yaml:"-"`
+	registryName     string             `json:"-" yaml:"-"`
 	Pusher           *PushGatewayPusher `json:"-" yaml:"-"`
 }
 
@@ -140,6 +213,8 @@ func (entry *PromEntry) MarshalJSON() ([]byte, error) {
 		"name":              entry.entryName,
 		"type":              entry.entryType,
 		"description":       entry.entryDescription,
+		"path":              entry.Path,
+		"registry":          entry.registryName,
 		"pushGateWayPusher": entry.Pusher,
 	}
 
@@ -160,13 +235,14 @@ func (entry *PromEntry) RegisterCollectors(collectors ...prometheus.Collector) {
 
 // PushGatewayPusher is a pusher which contains bellow instances
 type PushGatewayPusher struct {
-	loggerEntry   *LoggerEntry  `json:"-" yaml:"-"`
-	Pusher        *push.Pusher  `json:"-" yaml:"-"`
-	IntervalMs    time.Duration `json:"-" yaml:"-"`
-	RemoteAddress string        `json:"-" yaml:"-"`
-	JobName       string        `json:"-" yaml:"-"`
-	running       *atomic.Bool  `json:"-" yaml:"-"`
-	certEntry     *CertEntry    `json:"-" yaml:"-"`
+	loggerEntry   *LoggerEntry      `json:"-" yaml:"-"`
+	Pusher        *push.Pusher      `json:"-" yaml:"-"`
+	IntervalMs    time.Duration     `json:"-" yaml:"-"`
+	RemoteAddress string            `json:"-" yaml:"-"`
+	JobName       string            `json:"-" yaml:"-"`
+	Grouping      map[string]string `json:"-" yaml:"-"`
+	running       *atomic.Bool      `json:"-" yaml:"-"`
+	certEntry     *CertEntry        `json:"-" yaml:"-"`
 }
 
 // newPushGatewayPusher creates a new pushGateway periodic job instances with intervalMS, remote URL and job name
@@ -181,6 +257,7 @@ func newPushGatewayPusher(boot *BootProm, gatherer prometheus.Gatherer) *PushGat
 		IntervalMs:    time.Duration(boot.Pusher.IntervalMs) * time.Millisecond,
 		JobName:       boot.Pusher.JobName,
 		RemoteAddress: boot.Pusher.RemoteAddress,
+		Grouping:      map[string]string{},
 		running:       atomic.NewBool(false),
 		certEntry:     certEntry,
 	}
@@ -213,6 +290,18 @@ func newPushGatewayPusher(boot *BootProm, gatherer prometheus.Gatherer) *PushGat
 		}
 	}
 
+	// assign grouping labels in order
+	keys := make([]string, 0, len(boot.Pusher.Grouping))
+	for k := range boot.Pusher.Grouping {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		pg.Grouping[k] = boot.Pusher.Grouping[k]
+		pg.Pusher = pg.Pusher.Grouping(k, boot.Pusher.Grouping[k])
+	}
+
 	pg.Pusher.Gatherer(gatherer)
 
 	return pg
@@ -256,7 +345,8 @@ func (pub *PushGatewayPusher) Bootstrap(ctx context.Context) {
 
 	pub.loggerEntry.Info("Starting pushGateway publisher",
 		zap.String("remoteAddress", pub.RemoteAddress),
-		zap.String("jobName", pub.JobName))
+		zap.String("jobName", pub.JobName),
+		zap.Any("grouping", pub.Grouping))
 
 	go pub.push()
 }
@@ -275,6 +365,7 @@ func (pub *PushGatewayPusher) push() {
 			pub.loggerEntry.Warn("Failed to push metrics to PushGateway",
 				zap.String("remoteAddress", pub.RemoteAddress),
 				zap.String("jobName", pub.JobName),
+				zap.Any("grouping", pub.Grouping),
 				zap.Error(err))
 		}
 
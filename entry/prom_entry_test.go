@@ -5,6 +5,8 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"testing"
@@ -48,6 +50,36 @@ func TestRegisterPromEntry(t *testing.T) {
 	assert.Nil(t, entry.Pusher)
 }
 
+func TestRegisterPromEntry_WithNamedRegistry(t *testing.T) {
+	defer RemoveNamedRegistry("ut-public")
+	defer RemoveNamedRegistry("ut-internal")
+
+	// with registry in boot config
+	boot := &BootProm{
+		Enabled:  true,
+		Path:     "/internal/metrics",
+		Registry: "ut-internal",
+	}
+	internal := RegisterPromEntry(boot)
+	assert.Equal(t, GetNamedRegistry("ut-internal"), internal.Registry)
+	assert.Equal(t, "/internal/metrics", internal.Path)
+	assert.Contains(t, internal.String(), "ut-internal")
+
+	// with option
+	public := RegisterPromEntry(&BootProm{Enabled: true}, WithNamedRegistryPromEntry("ut-public"))
+	assert.Equal(t, GetNamedRegistry("ut-public"), public.Registry)
+	assert.Equal(t, "/metrics", public.Path)
+	assert.NotSame(t, internal.Registry, public.Registry)
+	assert.Equal(t, []string{"ut-internal", "ut-public"}, ListNamedRegistries())
+	assert.Nil(t, GetNamedRegistry(""))
+
+	// Go collector should not be registered in named registries
+	families, _ := internal.Gather()
+	assert.Empty(t, families)
+	families, _ = RegisterPromEntry(&BootProm{Enabled: true}).Gather()
+	assert.NotEmpty(t, families)
+}
+
 func TestPromEntry_Bootstrap(t *testing.T) {
 	defer assertNotPanic(t)
 
@@ -91,3 +123,37 @@ func TestPromEntry_Bootstrap(t *testing.T) {
 
 	entry.Interrupt(context.TODO())
 }
+
+func TestPromEntry_PusherWithGrouping(t *testing.T) {
+	paths := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case paths <- r.URL.Path:
+		default:
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	boot := &BootProm{
+		Enabled: true,
+	}
+	boot.Pusher.Enabled = true
+	boot.Pusher.IntervalMs = 10
+	boot.Pusher.JobName = "ut-job"
+	boot.Pusher.RemoteAddress = server.URL
+	boot.Pusher.Grouping = map[string]string{"instance": "ut-instance"}
+
+	entry := RegisterPromEntry(boot)
+	assert.Equal(t, map[string]string{"instance": "ut-instance"}, entry.Pusher.Grouping)
+
+	entry.Bootstrap(context.TODO())
+	defer entry.Interrupt(context.TODO())
+
+	select {
+	case path := <-paths:
+		assert.Equal(t, "/metrics/job/ut-job/instance/ut-instance", path)
+	case <-time.After(3 * time.Second):
+		assert.Fail(t, "metrics were not pushed")
+	}
+}
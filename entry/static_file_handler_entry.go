@@ -16,6 +16,7 @@ import (
 	"html/template"
 	"io/fs"
 	"math"
+	"mime"
 	"net/http"
 	"os"
 	"path"
@@ -66,10 +67,12 @@ var exToIcon = map[string]string{
 
 // BootStaticFileHandler bootstrap config of StaticHandler.
 type BootStaticFileHandler struct {
-	Enabled    bool   `yaml:"enabled" json:"enabled"`
-	Path       string `yaml:"path" json:"path"`
-	SourceType string `yaml:"sourceType" json:"sourceType"`
-	SourcePath string `yaml:"sourcePath" json:"sourcePath"`
+	Enabled                bool   `yaml:"enabled" json:"enabled"`
+	Path                   string `yaml:"path" json:"path"`
+	SourceType             string `yaml:"sourceType" json:"sourceType"`
+	SourcePath             string `yaml:"sourcePath" json:"sourcePath"`
+	ContentTypeByExtension bool   `yaml:"contentTypeByExtension" json:"contentTypeByExtension"`
+	IgnoreMiddleware       bool   `yaml:"ignoreMiddleware" json:"ignoreMiddleware"`
 }
 
 // StaticFileHandlerEntry Static file handler entry supports web UI for downloading static files.
@@ -80,6 +83,10 @@ type StaticFileHandlerEntry struct {
 	Path             string             `yaml:"-" json:"-"`
 	Template         *template.Template `json:"-" yaml:"-"`
 	httpFS           http.FileSystem    `yaml:"-" json:"-"`
+	// serve files inline with content type resolved by extension instead of downloading
+	contentTypeByExt bool              `yaml:"-" json:"-"`
+	contentTypes     map[string]string `yaml:"-" json:"-"`
+	ignoreMiddleware bool              `yaml:"-" json:"-"`
 }
 
 // StaticFileHandlerEntryOption options for StaticFileHandlerEntry
@@ -92,6 +99,27 @@ func WithNameStaticFileHandlerEntry(name string) StaticFileHandlerEntryOption {
 	}
 }
 
+// WithContentTypeByExtensionStaticFileHandlerEntry serve files inline with content type resolved by file extension.
+// Provided types will override default mime types, e.g. ".map" => "application/json"
+func WithContentTypeByExtensionStaticFileHandlerEntry(types map[string]string) StaticFileHandlerEntryOption {
+	return func(entry *StaticFileHandlerEntry) {
+		entry.contentTypeByExt = true
+		for k, v := range types {
+			if !strings.HasPrefix(k, ".") {
+				k = "." + k
+			}
+			entry.contentTypes[strings.ToLower(k)] = v
+		}
+	}
+}
+
+// WithIgnoreMiddlewareStaticFileHandlerEntry make middlewares ignore path of entry, like auth or jwt
+func WithIgnoreMiddlewareStaticFileHandlerEntry() StaticFileHandlerEntryOption {
+	return func(entry *StaticFileHandlerEntry) {
+		entry.ignoreMiddleware = true
+	}
+}
+
 // RegisterStaticFileHandlerEntry Create new static file handler entry with config
 func RegisterStaticFileHandlerEntry(boot *BootStaticFileHandler, opts ...StaticFileHandlerEntryOption) *StaticFileHandlerEntry {
 	if !boot.Enabled {
@@ -105,6 +133,9 @@ func RegisterStaticFileHandlerEntry(boot *BootStaticFileHandler, opts ...StaticF
 		Template:         template.New("rk-static"),
 		Path:             boot.Path,
 		httpFS:           http.Dir(""),
+		contentTypeByExt: boot.ContentTypeByExtension,
+		contentTypes:     make(map[string]string),
+		ignoreMiddleware: boot.IgnoreMiddleware,
 	}
 
 	for i := range opts {
@@ -147,6 +178,11 @@ func (entry *StaticFileHandlerEntry) Bootstrap(context.Context) {
 	if _, err := entry.Template.Parse(string(readFile("assets/static/index.tmpl", &rkembed.AssetsFS, true))); err != nil {
 		ShutdownWithError(err)
 	}
+
+	// ignore static files for middleware
+	if entry.ignoreMiddleware {
+		rkmid.AddPathToIgnoreGlobal(entry.Path)
+	}
 }
 
 // Interrupt entry.
@@ -260,6 +296,10 @@ func (entry *StaticFileHandlerEntry) GetFileHandler() http.HandlerFunc {
 
 			writer.WriteHeader(http.StatusOK)
 			writer.Write(buf.Bytes())
+		} else if entry.contentTypeByExt {
+			// serve file inline with content type
+			writer.Header().Set("Content-Type", entry.getContentType(fileInfo.Name()))
+			http.ServeContent(writer, request, filepath.Base(p), fileInfo.ModTime(), file)
 		} else {
 			// make browser download file
 			writer.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", fileInfo.Name()))
@@ -288,6 +328,21 @@ func (entry *StaticFileHandlerEntry) sortFiles(res []*fileResp) {
 	})
 }
 
+// get content type based on file extension, user provided types take precedence
+func (entry *StaticFileHandlerEntry) getContentType(name string) string {
+	ext := strings.ToLower(filepath.Ext(name))
+
+	if v, ok := entry.contentTypes[ext]; ok {
+		return v
+	}
+
+	if v := mime.TypeByExtension(ext); len(v) > 0 {
+		return v
+	}
+
+	return "application/octet-stream"
+}
+
 // get icon path based on file information
 func (entry *StaticFileHandlerEntry) getIconPath(info fs.FileInfo) string {
 	if info.IsDir() {
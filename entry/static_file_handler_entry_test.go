@@ -2,6 +2,7 @@ package rkentry
 
 import (
 	"context"
+	"github.com/rookie-ninja/rk-entry/v2/middleware"
 	"github.com/stretchr/testify/assert"
 	"net/http"
 	"net/http/httptest"
@@ -88,3 +89,43 @@ func TestStaticFileHandlerEntry_GetFileHandler(t *testing.T) {
 	assert.NotEmpty(t, writer.Header().Get("Content-Type"))
 	assert.Contains(t, writer.Body.String(), "ut content")
 }
+
+func TestStaticFileHandlerEntry_WithContentTypeByExtension(t *testing.T) {
+	currDir := t.TempDir()
+	os.WriteFile(filepath.ToSlash(filepath.Join(currDir, "ut.css")), []byte("body {}"), os.ModePerm)
+	os.WriteFile(filepath.ToSlash(filepath.Join(currDir, "ut.map")), []byte("{}"), os.ModePerm)
+
+	entry := RegisterStaticFileHandlerEntry(&BootStaticFileHandler{
+		Enabled: true,
+	}, WithContentTypeByExtensionStaticFileHandlerEntry(map[string]string{
+		"map": "application/json",
+	}))
+	entry.httpFS = http.Dir(currDir)
+	entry.Bootstrap(context.TODO())
+	handler := entry.GetFileHandler()
+
+	// with mime type
+	writer := httptest.NewRecorder()
+	handler(writer, &http.Request{URL: &url.URL{Path: "/static/ut.css"}})
+	assert.Equal(t, http.StatusOK, writer.Code)
+	assert.Empty(t, writer.Header().Get("Content-Disposition"))
+	assert.Contains(t, writer.Header().Get("Content-Type"), "text/css")
+
+	// with user provided type
+	writer = httptest.NewRecorder()
+	handler(writer, &http.Request{URL: &url.URL{Path: "/static/ut.map"}})
+	assert.Equal(t, "application/json", writer.Header().Get("Content-Type"))
+
+	// with unknown type
+	assert.Equal(t, "application/octet-stream", entry.getContentType("ut.unknown-ext"))
+}
+
+func TestStaticFileHandlerEntry_WithIgnoreMiddleware(t *testing.T) {
+	entry := RegisterStaticFileHandlerEntry(&BootStaticFileHandler{
+		Enabled: true,
+		Path:    "/ut-static-ignore/",
+	}, WithIgnoreMiddlewareStaticFileHandlerEntry())
+	entry.Bootstrap(context.TODO())
+
+	assert.True(t, rkmid.ShouldIgnoreGlobal("/ut-static-ignore/file"))
+}
@@ -13,11 +13,21 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
 )
 
+const (
+	// BootSourceFile value was read from boot config file
+	BootSourceFile = "file"
+	// BootSourceEnv value was overridden by environment variable
+	BootSourceEnv = "env"
+	// BootSourceFlag value was overridden by --rkset flag
+	BootSourceFlag = "flag"
+)
+
 var (
 	envLogOnce  sync.Once
 	flagLogOnce sync.Once
@@ -79,11 +89,36 @@ var (
 // Important! Please make sure the type of value keeps the same, otherwise, it won't override.
 // For example, os.Setenv("RK_GIN_0_PORT", "invalid-port") won't success, but keep original value.
 func UnmarshalBootYAML(raw []byte, config interface{}) {
+	unmarshalBootYAML(raw, config, nil)
+}
+
+// UnmarshalBootYAMLWithTrace is the same as UnmarshalBootYAML, and returns source of each value in addition.
+//
+// Keys of returned map are paths of values in lower case, joined with dot(.), array index is represented as number.
+// Values of returned map are one of BootSourceFile, BootSourceEnv and BootSourceFlag.
+//
+// example:
+// "gin.0.port" => "env"
+func UnmarshalBootYAMLWithTrace(raw []byte, config interface{}) map[string]string {
+	trace := make(map[string]string)
+	unmarshalBootYAML(raw, config, trace)
+	return trace
+}
+
+// unmarshalBootYAML parse boot config with overrides, source of values will be recorded if trace is not nil
+func unmarshalBootYAML(raw []byte, config interface{}, trace map[string]string) {
+	if err := parseBootYAML(raw, config, trace); err != nil {
+		ShutdownWithError(err)
+	}
+}
+
+// parseBootYAML parse boot config with overrides, error will be returned instead of shutdown
+func parseBootYAML(raw []byte, config interface{}, trace map[string]string) error {
 	// 1: unmarshal original
 	originalBootM := map[interface{}]interface{}{}
 	// unmarshal with yaml
 	if err := yaml.Unmarshal(raw, &originalBootM); err != nil {
-		ShutdownWithError(err)
+		return err
 	}
 
 	// lower key
@@ -103,11 +138,134 @@ func UnmarshalBootYAML(raw []byte, config interface{}) {
 	overrideMap(originalBootM, envOverridesBootM)
 	overrideMap(originalBootM, flagOverridesBootM)
 
-	// 5: unmarshal to struct
-	if err := mapstructure.Decode(originalBootM, config); err != nil {
+	// 5: record source of values if needed
+	traceBootValues(originalBootM, envOverridesBootM, flagOverridesBootM, trace)
+
+	// 6: unmarshal to struct
+	return mapstructure.Decode(originalBootM, config)
+}
+
+// traceBootValues record source of each leaf value in final boot config map into trace.
+// Value is treated as overridden only if the same path and value exists in overrides.
+func traceBootValues(final, envOverrides, flagOverrides map[interface{}]interface{}, trace map[string]string) {
+	if trace == nil {
+		return
+	}
+
+	finalLeaves := make(map[string]interface{})
+	envLeaves := make(map[string]interface{})
+	flagLeaves := make(map[string]interface{})
+	flattenBootValues(final, "", finalLeaves)
+	flattenBootValues(envOverrides, "", envLeaves)
+	flattenBootValues(flagOverrides, "", flagLeaves)
+
+	for k, v := range finalLeaves {
+		if flagV, ok := flagLeaves[k]; ok && reflect.DeepEqual(flagV, v) {
+			trace[k] = BootSourceFlag
+		} else if envV, ok := envLeaves[k]; ok && reflect.DeepEqual(envV, v) {
+			trace[k] = BootSourceEnv
+		} else {
+			trace[k] = BootSourceFile
+		}
+	}
+}
+
+// flattenBootValues flatten maps and slices into leaf values with paths as keys
+func flattenBootValues(src interface{}, path string, res map[string]interface{}) {
+	switch v := src.(type) {
+	case map[interface{}]interface{}:
+		for k := range v {
+			flattenBootValues(v[k], joinBootPath(path, fmt.Sprintf("%v", k)), res)
+		}
+	case []interface{}:
+		for i := range v {
+			flattenBootValues(v[i], joinBootPath(path, strconv.Itoa(i)), res)
+		}
+	default:
+		if src != nil && len(path) > 0 {
+			res[path] = src
+		}
+	}
+}
+
+// joinBootPath join parent path and key with dot(.)
+func joinBootPath(parent, key string) string {
+	if len(parent) < 1 {
+		return key
+	}
+
+	return parent + "." + key
+}
+
+// ReadBootConfigDir read all YAML files in directory and merge them into one boot config.
+//
+// Mainly used for Kubernetes ConfigMap which is mounted as directory of files.
+// Files with extension of .yaml or .yml are merged in sorted order of file names, values in latter files
+// override the same keys in former ones. Maps in lists are merged by name, other items by index,
+// unmatched items in latter files are appended.
+// Returned bytes could be passed to UnmarshalBootYAML or RegisterXXX functions
+// as single boot config file, overrides of ENV and flags will be applied there.
+//
+// Hidden entries are skipped, including ..data symlink and timestamped directories Kubernetes uses for atomic updates,
+// since files projected into directory are symlinks into ..data already.
+//
+// Application will shutdown if directory or any of files could not be read.
+func ReadBootConfigDir(dir string) []byte {
+	files, err := listBootConfigFiles(dir)
+	if err != nil {
+		ShutdownWithError(err)
+	}
+
+	mergedBootM := map[interface{}]interface{}{}
+	for i := range files {
+		bootM := map[interface{}]interface{}{}
+		if err := yaml.Unmarshal(readFile(files[i], nil, true), &bootM); err != nil {
+			ShutdownWithError(fmt.Errorf("failed to unmarshal boot config %s, %v", files[i], err))
+		}
+
+		mergedBootM = mergeMap(mergedBootM, lowerKeyMap(bootM))
+	}
+
+	res, err := yaml.Marshal(mergedBootM)
+	if err != nil {
 		ShutdownWithError(err)
 	}
 
+	return res
+}
+
+// listBootConfigFiles list YAML files in directory in sorted order, symlinks are followed
+func listBootConfigFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]string, 0)
+	for i := range entries {
+		name := entries[i].Name()
+		if strings.HasPrefix(name, ".") {
+			continue
+		}
+
+		ext := strings.ToLower(filepath.Ext(name))
+		if ext != ".yaml" && ext != ".yml" {
+			continue
+		}
+
+		// stat follows symlinks which are used by ConfigMap projection
+		path := filepath.Join(dir, name)
+		if info, err := os.Stat(path); err != nil || info.IsDir() {
+			continue
+		}
+
+		res = append(res, path)
+	}
+
+	// os.ReadDir returns entries sorted by file name already, keep it explicit
+	sort.Strings(res)
+
+	return res, nil
 }
 
 // ShutdownWithError shuts down and panic.
@@ -271,6 +429,89 @@ func overrideSlice(src []interface{}, override []interface{}) {
 	}
 }
 
+// mergeMap merges values of latter map into former one, used while merging boot config files.
+//
+// Unlike overrideMap, items of lists in latter map which could not be matched by name or index will be appended,
+// so that latter files could add entries.
+func mergeMap(src map[interface{}]interface{}, override map[interface{}]interface{}) map[interface{}]interface{} {
+	if src == nil {
+		return override
+	}
+
+	for k, overrideItem := range override {
+		src[k] = mergeItem(src[k], overrideItem)
+	}
+
+	return src
+}
+
+// mergeSlice merges items of latter list into former one.
+//
+// Maps with name are merged into the item with the same name in former list, or appended if no item matches.
+// Other items are merged by index, extra items of latter list will be appended.
+func mergeSlice(src []interface{}, override []interface{}) []interface{} {
+	for i := range override {
+		if name, ok := nameOfItem(override[i]); ok {
+			if j := indexOfName(src, name); j >= 0 {
+				src[j] = mergeItem(src[j], override[i])
+			} else {
+				src = append(src, override[i])
+			}
+			continue
+		}
+
+		if i < len(src) {
+			src[i] = mergeItem(src[i], override[i])
+		} else {
+			src = append(src, override[i])
+		}
+	}
+
+	return src
+}
+
+// nameOfItem returns value of name key if item is a map with non-empty name
+func nameOfItem(item interface{}) (string, bool) {
+	if m, ok := item.(map[interface{}]interface{}); ok {
+		if name, ok := m["name"].(string); ok && len(name) > 0 {
+			return name, true
+		}
+	}
+
+	return "", false
+}
+
+// indexOfName returns index of map in list with the same name, -1 will be returned if not found
+func indexOfName(list []interface{}, name string) int {
+	for i := range list {
+		if itemName, ok := nameOfItem(list[i]); ok && itemName == name {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// mergeItem merges maps and lists recursively, other types are replaced by latter value
+func mergeItem(src, override interface{}) interface{} {
+	if override == nil {
+		return src
+	}
+
+	switch overrideItem := override.(type) {
+	case []interface{}:
+		if originalItem, ok := src.([]interface{}); ok {
+			return mergeSlice(originalItem, overrideItem)
+		}
+	case map[interface{}]interface{}:
+		if originalItem, ok := src.(map[interface{}]interface{}); ok {
+			return mergeMap(originalItem, overrideItem)
+		}
+	}
+
+	return override
+}
+
 // reformatEnvKey will try to reformat array element
 // Example:
 // gin:
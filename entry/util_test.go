@@ -519,6 +519,52 @@ func TestParseEnvOverrides(t *testing.T) {
 	assert.Nil(t, os.Setenv("RK_GIN_NAME", ""))
 }
 
+func TestUnmarshalBootYAMLWithTrace(t *testing.T) {
+	bootStr := `
+echo:
+  - name: greeter
+    port: 1949
+    commonService:
+      enabled: true
+`
+	assert.Nil(t, os.Setenv("RK_ECHO_0_PORT", "2008"))
+	defer os.Unsetenv("RK_ECHO_0_PORT")
+
+	config := map[string]interface{}{}
+	trace := UnmarshalBootYAMLWithTrace([]byte(bootStr), &config)
+
+	assert.Equal(t, BootSourceFile, trace["echo.0.name"])
+	assert.Equal(t, BootSourceEnv, trace["echo.0.port"])
+	assert.Equal(t, BootSourceFile, trace["echo.0.commonservice.enabled"])
+}
+
+func TestTraceBootValues(t *testing.T) {
+	final := map[interface{}]interface{}{
+		"a": map[interface{}]interface{}{"b": 1, "c": 2},
+		"d": []interface{}{"e", "f"},
+		"g": "h",
+	}
+	env := map[interface{}]interface{}{
+		"a": map[interface{}]interface{}{"b": 1, "c": 3},
+	}
+	flag := map[interface{}]interface{}{
+		"d": []interface{}{nil, "f"},
+	}
+
+	trace := map[string]string{}
+	traceBootValues(final, env, flag, trace)
+	assert.Equal(t, map[string]string{
+		"a.b": BootSourceEnv,
+		"a.c": BootSourceFile,
+		"d.0": BootSourceFile,
+		"d.1": BootSourceFlag,
+		"g":   BootSourceFile,
+	}, trace)
+
+	// with nil trace
+	traceBootValues(final, env, flag, nil)
+}
+
 func TestLowerKeyMap(t *testing.T) {
 	defer func() {
 		if r := recover(); r != nil {
@@ -574,3 +620,94 @@ func isLower(s string) bool {
 	}
 	return true
 }
+
+func TestReadBootConfigDir(t *testing.T) {
+	// with non-exist directory
+	assert.Panics(t, func() {
+		ReadBootConfigDir(filepath.Join(t.TempDir(), "non-exist"))
+	})
+
+	// with layout of Kubernetes ConfigMap
+	dir := t.TempDir()
+	dataDir := filepath.Join(dir, "..2022_01_01_00_00_00.000000000")
+	assert.Nil(t, os.Mkdir(dataDir, os.ModePerm))
+	assert.Nil(t, os.WriteFile(filepath.Join(dataDir, "a.yaml"), []byte(`
+echo:
+  - name: greeter
+    port: 1949
+logger:
+  - name: my-logger
+`), os.ModePerm))
+	assert.Nil(t, os.WriteFile(filepath.Join(dataDir, "b.yml"), []byte(`
+echo:
+  - Port: 2008
+`), os.ModePerm))
+	assert.Nil(t, os.WriteFile(filepath.Join(dataDir, "c.txt"), []byte(`invalid`), os.ModePerm))
+	assert.Nil(t, os.Symlink(filepath.Base(dataDir), filepath.Join(dir, "..data")))
+	for _, name := range []string{"a.yaml", "b.yml", "c.txt"} {
+		assert.Nil(t, os.Symlink(filepath.Join("..data", name), filepath.Join(dir, name)))
+	}
+
+	config := map[string]interface{}{}
+	UnmarshalBootYAML(ReadBootConfigDir(dir), &config)
+
+	echo := config["echo"].([]interface{})
+	assert.Len(t, echo, 1)
+	assert.Equal(t, "greeter", echo[0].(map[interface{}]interface{})["name"])
+	assert.Equal(t, 2008, echo[0].(map[interface{}]interface{})["port"])
+	assert.Len(t, config["logger"], 1)
+
+	// with longer list in latter file
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "c.yaml"), []byte(`
+echo:
+  - name: greeter
+  - name: greeter-2
+    port: 2022
+logger:
+  - name: my-logger
+  - name: my-logger-2
+`), os.ModePerm))
+	config = map[string]interface{}{}
+	UnmarshalBootYAML(ReadBootConfigDir(dir), &config)
+	echo = config["echo"].([]interface{})
+	assert.Len(t, echo, 2)
+	assert.Equal(t, 2008, echo[0].(map[interface{}]interface{})["port"])
+	assert.Equal(t, "greeter-2", echo[1].(map[interface{}]interface{})["name"])
+	assert.Len(t, config["logger"], 2)
+	assert.Nil(t, os.Remove(filepath.Join(dir, "c.yaml")))
+
+	// with named items in different order
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "c.yaml"), []byte(`
+echo:
+  - name: greeter-2
+    port: 2022
+  - name: greeter
+    port: 2023
+`), os.ModePerm))
+	config = map[string]interface{}{}
+	UnmarshalBootYAML(ReadBootConfigDir(dir), &config)
+	echo = config["echo"].([]interface{})
+	assert.Len(t, echo, 2)
+	assert.Equal(t, "greeter", echo[0].(map[interface{}]interface{})["name"])
+	assert.Equal(t, 2023, echo[0].(map[interface{}]interface{})["port"])
+	assert.Equal(t, "greeter-2", echo[1].(map[interface{}]interface{})["name"])
+	assert.Equal(t, 2022, echo[1].(map[interface{}]interface{})["port"])
+	assert.Nil(t, os.Remove(filepath.Join(dir, "c.yaml")))
+
+	// with empty list in former file
+	emptyDir := t.TempDir()
+	assert.Nil(t, os.WriteFile(filepath.Join(emptyDir, "a.yaml"), []byte(`echo: []`), os.ModePerm))
+	assert.Nil(t, os.WriteFile(filepath.Join(emptyDir, "b.yaml"), []byte(`
+echo:
+  - name: greeter
+`), os.ModePerm))
+	config = map[string]interface{}{}
+	UnmarshalBootYAML(ReadBootConfigDir(emptyDir), &config)
+	assert.Len(t, config["echo"], 1)
+
+	// with invalid YAML
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "d.yaml"), []byte(`invalid: [`), os.ModePerm))
+	assert.Panics(t, func() {
+		ReadBootConfigDir(dir)
+	})
+}
@@ -0,0 +1,109 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkentry
+
+import (
+	"fmt"
+)
+
+// BootValidateFunc validates boot config without side effects and returns all errors found
+type BootValidateFunc func(raw []byte) []error
+
+var bootValidateFuncList = make([]BootValidateFunc, 0)
+
+// RegisterBootValidateFunc register validation function of plugin, web framework or user defined entries,
+// which will be invoked by ValidateBootConfig after built-in entries validated.
+//
+// Validation function must not register entries into GlobalAppCtx or start anything.
+func RegisterBootValidateFunc(f BootValidateFunc) {
+	if f == nil {
+		return
+	}
+	bootValidateFuncList = append(bootValidateFuncList, f)
+}
+
+// bootValidate is boot config of built-in entries which will be validated
+type bootValidate struct {
+	BootLogger `yaml:",inline" mapstructure:",squash"`
+	BootEvent  `yaml:",inline" mapstructure:",squash"`
+	BootConfig `yaml:",inline" mapstructure:",squash"`
+	BootCert   `yaml:",inline" mapstructure:",squash"`
+}
+
+// ValidateBootConfig parses boot config with the same overrides as UnmarshalBootYAML and validates built-in entries,
+// then runs functions registered with RegisterBootValidateFunc. All errors will be returned instead of shutdown.
+//
+// It has no side effects, entries will neither be registered into GlobalAppCtx nor bootstrapped,
+// which is useful for validating boot config in CI pipelines.
+//
+// Checks of built-in entries:
+// 1: name of logger, event, config and cert entries is required
+// 2: config entries could be read and pass required keys checks
+// 3: files of cert entries exist
+func ValidateBootConfig(raw []byte) []error {
+	errs := make([]error, 0)
+
+	boot := &bootValidate{}
+	if err := parseBootYAML(raw, boot, nil); err != nil {
+		return append(errs, fmt.Errorf("invalid boot config, %v", err))
+	}
+
+	for i, v := range boot.Logger {
+		if len(v.Name) < 1 {
+			errs = append(errs, fmt.Errorf("missing name of logger[%d]", i))
+		}
+	}
+
+	for i, v := range boot.Event {
+		if len(v.Name) < 1 {
+			errs = append(errs, fmt.Errorf("missing name of event[%d]", i))
+		}
+	}
+
+	for i, v := range boot.Config {
+		if len(v.Name) < 1 {
+			errs = append(errs, fmt.Errorf("missing name of config[%d]", i))
+			continue
+		}
+
+		if !IsValidDomain(v.Domain) {
+			continue
+		}
+
+		entry, err := newConfigEntry(v)
+		if err == nil {
+			err = entry.Validate()
+		}
+
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	for i, v := range boot.Cert {
+		if len(v.Name) < 1 {
+			errs = append(errs, fmt.Errorf("missing name of cert[%d]", i))
+			continue
+		}
+
+		// files in embed.FS could not be checked before it was provided
+		if !IsValidDomain(v.Domain) || GlobalAppCtx.GetEmbedFS(CertEntryType, v.Name) != nil {
+			continue
+		}
+
+		for _, path := range []string{v.CAPath, v.CertPemPath, v.KeyPemPath} {
+			if len(path) > 0 && !fileExists(path) {
+				errs = append(errs, fmt.Errorf("missing file of cert entry %s, path:%s", v.Name, path))
+			}
+		}
+	}
+
+	for i := range bootValidateFuncList {
+		errs = append(errs, bootValidateFuncList[i](raw)...)
+	}
+
+	return errs
+}
@@ -0,0 +1,67 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+package rkentry
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestValidateBootConfig(t *testing.T) {
+	defer func() {
+		bootValidateFuncList = make([]BootValidateFunc, 0)
+	}()
+
+	// with invalid YAML
+	errs := ValidateBootConfig([]byte("logger: ["))
+	assert.Len(t, errs, 1)
+
+	// happy case
+	raw := []byte(`
+logger:
+  - name: ut-logger
+event:
+  - name: ut-event
+config:
+  - name: ut-validate-config
+    requiredKeys: ["key"]
+    content:
+      key: value
+cert:
+  - name: ut-validate-cert
+`)
+	assert.Empty(t, ValidateBootConfig(raw))
+	// no entries should be registered
+	assert.Nil(t, GlobalAppCtx.GetConfigEntry("ut-validate-config"))
+	assert.Nil(t, GlobalAppCtx.GetCertEntry("ut-validate-cert"))
+
+	// with all errors collected
+	raw = []byte(`
+logger:
+  - description: missing name
+event:
+  - description: missing name
+config:
+  - name: ut-validate-config
+    requiredKeys: ["missing"]
+cert:
+  - name: ut-validate-cert
+    caPath: /non-exist/ca.pem
+    certPemPath: /non-exist/cert.pem
+`)
+	errs = ValidateBootConfig(raw)
+	assert.Len(t, errs, 5)
+	assert.Nil(t, GlobalAppCtx.GetConfigEntry("ut-validate-config"))
+
+	// with registered validate function
+	RegisterBootValidateFunc(nil)
+	RegisterBootValidateFunc(func(raw []byte) []error {
+		return []error{errors.New("ut-error")}
+	})
+	errs = ValidateBootConfig([]byte(""))
+	assert.Len(t, errs, 1)
+	assert.EqualError(t, errs[0], "ut-error")
+}
@@ -89,13 +89,18 @@ func (err *ErrorAMZN) Details() []interface{} {
 	return res
 }
 
-// Error returns string of error
-func (err *ErrorAMZN) Error() string {
-	res := "{}"
+// MarshalJSON render with ErrorRenderer if provided, otherwise default schema will be used
+func (err *ErrorAMZN) MarshalJSON() ([]byte, error) {
+	return renderError(err, err)
+}
 
-	if bytes, marshalErr := json.Marshal(err); marshalErr == nil {
-		res = string(bytes)
-	}
+// marshalDefault marshal with default schema
+func (err *ErrorAMZN) marshalDefault() ([]byte, error) {
+	type alias ErrorAMZN
+	return json.Marshal((*alias)(err))
+}
 
-	return res
+// Error returns string of error
+func (err *ErrorAMZN) Error() string {
+	return errorString(err)
 }
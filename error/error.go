@@ -6,6 +6,13 @@
 // Package rkerror defines RK style API errors.
 package rkerror
 
+import (
+	"encoding/json"
+	"github.com/rookie-ninja/rk-logger"
+	"go.uber.org/zap"
+	"sync"
+)
+
 type ErrorInterface interface {
 	Error() string
 
@@ -21,3 +28,147 @@ type ErrorBuilder interface {
 
 	NewCustom() ErrorInterface
 }
+
+// ErrorRenderer renders ErrorInterface as JSON bytes, which allows customizing schema of error body.
+type ErrorRenderer func(err ErrorInterface) []byte
+
+// TraceIdCarrier is implemented by errors which carry trace id of request.
+// ErrorRenderer could check it in order to include trace id in error body.
+type TraceIdCarrier interface {
+	TraceId() string
+}
+
+var (
+	errRendererLock sync.RWMutex
+	errRenderer     ErrorRenderer
+)
+
+// SetErrorRenderer set global ErrorRenderer used while marshalling errors as JSON.
+// Errors will be rendered with default schema of builder if renderer is nil.
+//
+// Error passed to renderer is marshalled with default schema, so renderer could marshal it without recursion.
+func SetErrorRenderer(renderer ErrorRenderer) {
+	errRendererLock.Lock()
+	defer errRendererLock.Unlock()
+	errRenderer = renderer
+}
+
+// GetErrorRenderer returns global ErrorRenderer, nil will be returned if not set.
+func GetErrorRenderer() ErrorRenderer {
+	errRendererLock.RLock()
+	defer errRendererLock.RUnlock()
+	return errRenderer
+}
+
+// defaultMarshaler is implemented by errors of this package, which marshal with default schema without ErrorRenderer
+type defaultMarshaler interface {
+	marshalDefault() ([]byte, error)
+}
+
+// renderError renders error with global ErrorRenderer.
+// Default schema will be used if renderer is not set or renderer returns invalid JSON.
+func renderError(err ErrorInterface, def defaultMarshaler) ([]byte, error) {
+	renderer := GetErrorRenderer()
+	if renderer == nil {
+		return def.marshalDefault()
+	}
+
+	base := &renderingError{ErrorInterface: err, def: def}
+	var rendering ErrorInterface = base
+	if carrier, ok := err.(TraceIdCarrier); ok {
+		rendering = &renderingErrorWithTraceId{
+			renderingError: base,
+			traceId:        carrier.TraceId(),
+		}
+	}
+
+	bytes := renderer(rendering)
+	if !json.Valid(bytes) {
+		rklogger.StdoutLogger.Warn("ErrorRenderer returned invalid JSON, rendering with default schema",
+			zap.ByteString("rendered", bytes))
+		return def.marshalDefault()
+	}
+
+	return bytes, nil
+}
+
+// errorString returns JSON string of error, marshal error will be logged and "{}" will be returned
+func errorString(err ErrorInterface) string {
+	bytes, marshalErr := json.Marshal(err)
+	if marshalErr != nil {
+		rklogger.StdoutLogger.Warn("Failed to marshal error", zap.Error(marshalErr))
+		return "{}"
+	}
+
+	return string(bytes)
+}
+
+// renderingError is passed to ErrorRenderer, it always marshals with default schema
+// in order to avoid rendering recursively while renderer marshals the error.
+type renderingError struct {
+	ErrorInterface
+	def defaultMarshaler
+}
+
+// MarshalJSON marshal with default schema
+func (err *renderingError) MarshalJSON() ([]byte, error) {
+	return err.def.marshalDefault()
+}
+
+// Error returns string of error with default schema
+func (err *renderingError) Error() string {
+	return errorString(err)
+}
+
+// renderingErrorWithTraceId is renderingError of errors which carry trace id
+type renderingErrorWithTraceId struct {
+	*renderingError
+	traceId string
+}
+
+// TraceId returns trace id of error
+func (err *renderingErrorWithTraceId) TraceId() string {
+	return err.traceId
+}
+
+// WithTraceId attach trace id to error, it will be rendered by ErrorRenderer if provided.
+func WithTraceId(err ErrorInterface, traceId string) ErrorInterface {
+	if err == nil || len(traceId) < 1 {
+		return err
+	}
+
+	return &errorWithTraceId{
+		ErrorInterface: err,
+		traceId:        traceId,
+	}
+}
+
+// errorWithTraceId wraps ErrorInterface with trace id
+type errorWithTraceId struct {
+	ErrorInterface
+	traceId string
+}
+
+// TraceId returns trace id of error
+func (err *errorWithTraceId) TraceId() string {
+	return err.traceId
+}
+
+// MarshalJSON render with ErrorRenderer, default schema of wrapped error will be used if not set
+func (err *errorWithTraceId) MarshalJSON() ([]byte, error) {
+	return renderError(err, err)
+}
+
+// marshalDefault marshal wrapped error with default schema
+func (err *errorWithTraceId) marshalDefault() ([]byte, error) {
+	if def, ok := err.ErrorInterface.(defaultMarshaler); ok {
+		return def.marshalDefault()
+	}
+
+	return json.Marshal(err.ErrorInterface)
+}
+
+// Error returns string of error
+func (err *errorWithTraceId) Error() string {
+	return errorString(err)
+}
@@ -0,0 +1,149 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkerror
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/structpb"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+func TestErrorRenderer(t *testing.T) {
+	defer SetErrorRenderer(nil)
+
+	// with default schema
+	err := NewErrorBuilderGoogle().New(http.StatusUnauthorized, "ut-msg")
+	bytes, _ := json.Marshal(err)
+	assert.Equal(t, `{"error":{"code":401,"status":"Unauthorized","message":"ut-msg","details":[]}}`, string(bytes))
+
+	bytes, _ = json.Marshal(NewErrorBuilderAMZN().New(http.StatusUnauthorized, "ut-msg"))
+	assert.Equal(t, `{"response":{"errors":[{"error":{"code":401,"status":"Unauthorized","message":"ut-msg","details":[]}}]}}`, string(bytes))
+
+	// with custom renderer
+	SetErrorRenderer(func(err ErrorInterface) []byte {
+		traceId := ""
+		if v, ok := err.(TraceIdCarrier); ok {
+			traceId = v.TraceId()
+		}
+		return []byte(fmt.Sprintf(`{"status":%d,"detail":"%s","traceId":"%s"}`, err.Code(), err.Message(), traceId))
+	})
+	assert.NotNil(t, GetErrorRenderer())
+
+	bytes, _ = json.Marshal(err)
+	assert.Equal(t, `{"status":401,"detail":"ut-msg","traceId":""}`, string(bytes))
+	assert.Equal(t, `{"status":401,"detail":"ut-msg","traceId":""}`, err.Error())
+
+	bytes, _ = json.Marshal(NewErrorBuilderAMZN().New(http.StatusForbidden, "ut-msg"))
+	assert.Equal(t, `{"status":403,"detail":"ut-msg","traceId":""}`, string(bytes))
+
+	// with trace id
+	bytes, _ = json.Marshal(WithTraceId(err, "ut-trace"))
+	assert.Equal(t, `{"status":401,"detail":"ut-msg","traceId":"ut-trace"}`, string(bytes))
+	assert.Equal(t, err, WithTraceId(err, ""))
+}
+
+func TestErrorRenderer_Recursive(t *testing.T) {
+	defer SetErrorRenderer(nil)
+
+	// renderer marshals error with default schema
+	SetErrorRenderer(func(err ErrorInterface) []byte {
+		inner, _ := json.Marshal(err)
+		traceId := ""
+		if v, ok := err.(TraceIdCarrier); ok {
+			traceId = v.TraceId()
+		}
+		return []byte(fmt.Sprintf(`{"inner":%s,"error":"%d","traceId":"%s"}`, inner, len(err.Error()), traceId))
+	})
+
+	err := NewErrorBuilderGoogle().New(http.StatusUnauthorized, "ut-msg")
+	def := `{"error":{"code":401,"status":"Unauthorized","message":"ut-msg","details":[]}}`
+	expected := fmt.Sprintf(`{"inner":%s,"error":"%d","traceId":""}`, def, len(def))
+	assert.Equal(t, expected, err.Error())
+
+	expected = fmt.Sprintf(`{"inner":%s,"error":"%d","traceId":"ut-trace"}`, def, len(def))
+	assert.Equal(t, expected, WithTraceId(err, "ut-trace").Error())
+}
+
+func TestErrorRenderer_InvalidJSON(t *testing.T) {
+	defer SetErrorRenderer(nil)
+
+	// fallback to default schema
+	SetErrorRenderer(func(err ErrorInterface) []byte {
+		return []byte("invalid")
+	})
+
+	err := NewErrorBuilderAMZN().New(http.StatusUnauthorized, "ut-msg")
+	assert.Equal(t, `{"response":{"errors":[{"error":{"code":401,"status":"Unauthorized","message":"ut-msg","details":[]}}]}}`, err.Error())
+	assert.Equal(t, `{"response":{"errors":[{"error":{"code":401,"status":"Unauthorized","message":"ut-msg","details":[]}}]}}`, WithTraceId(err, "ut-trace").Error())
+}
+
+func TestErrorRenderer_Concurrent(t *testing.T) {
+	defer SetErrorRenderer(nil)
+
+	err := NewErrorBuilderGoogle().New(http.StatusUnauthorized, "ut-msg")
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			SetErrorRenderer(func(err ErrorInterface) []byte {
+				return []byte(`{}`)
+			})
+		}()
+		go func() {
+			defer wg.Done()
+			assert.NotEmpty(t, err.Error())
+		}()
+	}
+	wg.Wait()
+}
+
+func TestGrpcStatus(t *testing.T) {
+	// with nil error
+	assert.Equal(t, codes.OK, GrpcStatus(nil).Code())
+
+	// without details
+	st := GrpcStatus(NewErrorBuilderGoogle().New(http.StatusNotFound, "ut-msg"))
+	assert.Equal(t, codes.NotFound, st.Code())
+	assert.Equal(t, "ut-msg", st.Message())
+	assert.Empty(t, st.Details())
+
+	// with proto, error and struct details
+	info := &errdetails.ErrorInfo{Reason: "ut-reason", Domain: "ut-domain"}
+	err := NewErrorBuilderAMZN().New(http.StatusForbidden, "ut-msg",
+		info, fmt.Errorf("ut-error"), map[string]string{"key": "value"}, func() {})
+	st = GrpcStatus(WithTraceId(err, "ut-trace"))
+	assert.Equal(t, codes.PermissionDenied, st.Code())
+
+	details := st.Details()
+	assert.Len(t, details, 4)
+	assert.Equal(t, "ut-reason", details[0].(*errdetails.ErrorInfo).Reason)
+	assert.Equal(t, "ut-error", details[1].(*structpb.Value).GetStringValue())
+	assert.Equal(t, "value", details[2].(*structpb.Value).GetStructValue().AsMap()["key"])
+	assert.NotEmpty(t, details[3].(*structpb.Value).GetStringValue())
+
+	// with unmapped code
+	assert.Equal(t, codes.Unknown, GrpcCode(http.StatusTeapot))
+}
+
+func TestHttpCode(t *testing.T) {
+	assert.Equal(t, http.StatusOK, HttpCode(codes.OK))
+	assert.Equal(t, http.StatusNotFound, HttpCode(codes.NotFound))
+	assert.Equal(t, http.StatusBadRequest, HttpCode(codes.FailedPrecondition))
+	assert.Equal(t, http.StatusInternalServerError, HttpCode(codes.DataLoss))
+
+	// should be consistent with GrpcCode
+	for _, code := range []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusTooManyRequests,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout, http.StatusNotImplemented} {
+		assert.Equal(t, code, HttpCode(GrpcCode(code)))
+	}
+}
@@ -63,13 +63,18 @@ func (err *ErrorGoogle) Details() []interface{} {
 	return err.Err.Details
 }
 
-// Error returns string of error
-func (err *ErrorGoogle) Error() string {
-	res := "{}"
+// MarshalJSON render with ErrorRenderer if provided, otherwise default schema will be used
+func (err *ErrorGoogle) MarshalJSON() ([]byte, error) {
+	return renderError(err, err)
+}
 
-	if bytes, marshalErr := json.Marshal(err); marshalErr == nil {
-		res = string(bytes)
-	}
+// marshalDefault marshal with default schema
+func (err *ErrorGoogle) marshalDefault() ([]byte, error) {
+	type alias ErrorGoogle
+	return json.Marshal((*alias)(err))
+}
 
-	return res
+// Error returns string of error
+func (err *ErrorGoogle) Error() string {
+	return errorString(err)
 }
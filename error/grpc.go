@@ -0,0 +1,134 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkerror
+
+import (
+	"encoding/json"
+	"fmt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+	"google.golang.org/protobuf/types/known/structpb"
+	"net/http"
+)
+
+// GrpcCode maps HTTP status code into gRPC code, codes.Unknown will be returned for unmapped codes.
+//
+// Mapping follows https://github.com/googleapis/googleapis/blob/master/google/rpc/code.proto
+func GrpcCode(httpCode int) codes.Code {
+	switch httpCode {
+	case http.StatusOK, http.StatusCreated, http.StatusAccepted, http.StatusNoContent:
+		return codes.OK
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.Aborted
+	case http.StatusPreconditionFailed:
+		return codes.FailedPrecondition
+	case http.StatusRequestedRangeNotSatisfiable:
+		return codes.OutOfRange
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case 499:
+		return codes.Canceled
+	case http.StatusInternalServerError:
+		return codes.Internal
+	case http.StatusNotImplemented:
+		return codes.Unimplemented
+	case http.StatusServiceUnavailable:
+		return codes.Unavailable
+	case http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	}
+
+	return codes.Unknown
+}
+
+// HttpCode maps gRPC code into HTTP status code, http.StatusInternalServerError will be returned for unmapped codes.
+//
+// Mapping follows https://github.com/googleapis/googleapis/blob/master/google/rpc/code.proto
+func HttpCode(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return 499
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	}
+
+	return http.StatusInternalServerError
+}
+
+// GrpcStatus converts ErrorInterface into gRPC status, so that gRPC clients get structured error info.
+//
+// Details which are proto messages, like errdetails.ErrorInfo, will be attached as they are.
+// Other details will be converted into structpb.Value through JSON, or string if failed.
+func GrpcStatus(err ErrorInterface) *status.Status {
+	if err == nil {
+		return status.New(codes.OK, "")
+	}
+
+	st := status.New(GrpcCode(err.Code()), err.Message())
+
+	details := make([]protoadapt.MessageV1, 0)
+	for _, detail := range err.Details() {
+		details = append(details, toProtoDetail(detail))
+	}
+
+	if len(details) < 1 {
+		return st
+	}
+
+	if withDetails, e := st.WithDetails(details...); e == nil {
+		return withDetails
+	}
+
+	return st
+}
+
+// toProtoDetail converts detail into proto message
+func toProtoDetail(detail interface{}) protoadapt.MessageV1 {
+	if v, ok := detail.(protoadapt.MessageV1); ok {
+		return v
+	}
+
+	if v, ok := detail.(protoadapt.MessageV2); ok {
+		return protoadapt.MessageV1Of(v)
+	}
+
+	// normalize into JSON types which structpb supports
+	var normalized interface{}
+	if bytes, err := json.Marshal(detail); err == nil && json.Unmarshal(bytes, &normalized) == nil {
+		if value, err := structpb.NewValue(normalized); err == nil {
+			return value
+		}
+	}
+
+	return structpb.NewStringValue(fmt.Sprintf("%v", detail))
+}
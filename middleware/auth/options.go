@@ -32,6 +32,8 @@ type OptionSetInterface interface {
 	BeforeCtx(*http.Request) *BeforeCtx
 
 	ShouldIgnore(string) bool
+
+	Config() map[string]interface{}
 }
 
 // ***************** OptionSet Implementation *****************
@@ -79,6 +81,19 @@ func (set *optionSet) GetEntryType() string {
 	return set.entryType
 }
 
+// Config returns effective configuration, secrets are redacted
+func (set *optionSet) Config() map[string]interface{} {
+	// credentials are redacted, only number of them will be returned
+	return map[string]interface{}{
+		"entryName":     set.entryName,
+		"entryType":     set.entryType,
+		"basicRealm":    set.basicRealm,
+		"basicAccounts": len(set.basicAccounts),
+		"apiKeys":       len(set.apiKey),
+		"pathToIgnore":  set.pathToIgnore,
+	}
+}
+
 // BeforeCtx should be created before Before()
 func (set *optionSet) BeforeCtx(req *http.Request) *BeforeCtx {
 	ctx := NewBeforeCtx()
@@ -228,6 +243,11 @@ func (mock *optionSetMock) GetEntryType() string {
 	return "mock"
 }
 
+// Config returns empty map
+func (mock *optionSetMock) Config() map[string]interface{} {
+	return map[string]interface{}{}
+}
+
 // BeforeCtx should be created before Before()
 func (mock *optionSetMock) BeforeCtx(request *http.Request) *BeforeCtx {
 	return mock.before
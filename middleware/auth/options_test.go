@@ -217,3 +217,18 @@ func TestNewOptionSetMock(t *testing.T) {
 	assert.NotNil(t, mock.BeforeCtx(nil))
 	mock.Before(nil)
 }
+
+func TestOptionSet_Config(t *testing.T) {
+	set := NewOptionSet(WithEntryNameAndType("ut-config", "ut-type"))
+	config := set.Config()
+	assert.Equal(t, "ut-config", config["entryName"])
+	assert.Equal(t, "ut-type", config["entryType"])
+	// with credentials, should be redacted
+	set = NewOptionSet(
+		WithBasicAuth("ut-realm", "user:pass"),
+		WithApiKeyAuth("ut-api-key"))
+	config = set.Config()
+	assert.Equal(t, 1, config["basicAccounts"])
+	assert.Equal(t, 1, config["apiKeys"])
+	assert.NotContains(t, fmt.Sprintf("%v", config), "ut-api-key")
+}
@@ -0,0 +1,385 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+// Package rkmidbulkhead provide options
+package rkmidbulkhead
+
+import (
+	"github.com/rookie-ninja/rk-entry/v2/error"
+	"github.com/rookie-ninja/rk-entry/v2/middleware"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultMaxConcurrent = 1000
+	DefaultRetryAfter    = time.Second
+	GlobalBulkhead       = "rk-bulkhead"
+)
+
+// ***************** OptionSet Interface *****************
+
+// OptionSetInterface mainly for testing purpose
+type OptionSetInterface interface {
+	GetEntryName() string
+
+	GetEntryType() string
+
+	Before(*BeforeCtx)
+
+	BeforeCtx(*http.Request) *BeforeCtx
+
+	After(*BeforeCtx)
+
+	ShouldIgnore(string) bool
+
+	Config() map[string]interface{}
+}
+
+// ***************** OptionSet Implementation *****************
+
+// optionSet which is used for middleware implementation
+type optionSet struct {
+	entryName           string
+	entryType           string
+	maxConcurrent       int
+	maxConcurrentByPath map[string]int
+	// waitTimeout is max duration to wait for a free slot, requests will be rejected immediately if zero
+	waitTimeout  time.Duration
+	retryAfter   time.Duration
+	pathToIgnore []string
+	semaphores   map[string]chan struct{}
+	mock         OptionSetInterface
+}
+
+// NewOptionSet Create new optionSet with options.
+func NewOptionSet(opts ...Option) OptionSetInterface {
+	set := &optionSet{
+		entryName:           "fake-entry",
+		entryType:           "",
+		maxConcurrent:       DefaultMaxConcurrent,
+		maxConcurrentByPath: make(map[string]int),
+		retryAfter:          DefaultRetryAfter,
+		pathToIgnore:        []string{},
+		semaphores:          make(map[string]chan struct{}),
+	}
+
+	for i := range opts {
+		opts[i](set)
+	}
+
+	if set.mock != nil {
+		return set.mock
+	}
+
+	set.semaphores[GlobalBulkhead] = make(chan struct{}, set.maxConcurrent)
+	for k, v := range set.maxConcurrentByPath {
+		set.semaphores[k] = make(chan struct{}, v)
+	}
+
+	return set
+}
+
+// GetEntryName returns entry name
+func (set *optionSet) GetEntryName() string {
+	return set.entryName
+}
+
+// GetEntryType returns entry type
+func (set *optionSet) GetEntryType() string {
+	return set.entryType
+}
+
+// Config returns effective configuration, secrets are redacted
+func (set *optionSet) Config() map[string]interface{} {
+	return map[string]interface{}{
+		"entryName":           set.entryName,
+		"entryType":           set.entryType,
+		"maxConcurrent":       set.maxConcurrent,
+		"maxConcurrentByPath": set.maxConcurrentByPath,
+		"waitTimeout":         set.waitTimeout.String(),
+		"retryAfter":          set.retryAfter.String(),
+		"pathToIgnore":        set.pathToIgnore,
+	}
+}
+
+// BeforeCtx should be created before Before()
+func (set *optionSet) BeforeCtx(req *http.Request) *BeforeCtx {
+	ctx := NewBeforeCtx()
+
+	if req != nil && req.URL != nil {
+		ctx.Input.UrlPath = req.URL.Path
+	}
+
+	return ctx
+}
+
+// Before acquires a slot of semaphore, 503 with Retry-After will be returned if no slot is free.
+//
+// Output.DeferFunc will be assigned if slot was acquired, adapters should defer it right after Before()
+// so that slot is released even if handler panics, no matter panic middleware is placed before or after.
+func (set *optionSet) Before(ctx *BeforeCtx) {
+	if ctx == nil {
+		return
+	}
+
+	// case 0: ignore path
+	if set.ShouldIgnore(ctx.Input.UrlPath) {
+		return
+	}
+
+	sem := set.getSemaphore(ctx.Input.UrlPath)
+	if !set.acquire(sem) {
+		seconds := int(set.retryAfter.Seconds())
+		if seconds < 1 {
+			seconds = 1
+		}
+		ctx.Output.HeadersToReturn[rkmid.HeaderRetryAfter] = strconv.Itoa(seconds)
+		ctx.Output.ErrResp = rkmid.GetErrorBuilder().New(http.StatusServiceUnavailable, "too many concurrent requests")
+		return
+	}
+
+	once := sync.Once{}
+	ctx.Output.DeferFunc = func() {
+		once.Do(func() {
+			<-sem
+		})
+	}
+}
+
+// After releases slot acquired in Before(), it is safe to call it together with Output.DeferFunc
+func (set *optionSet) After(ctx *BeforeCtx) {
+	if ctx == nil || ctx.Output.DeferFunc == nil {
+		return
+	}
+
+	ctx.Output.DeferFunc()
+}
+
+// acquire slot without blocking, or wait until waitTimeout
+func (set *optionSet) acquire(sem chan struct{}) bool {
+	select {
+	case sem <- struct{}{}:
+		return true
+	default:
+	}
+
+	if set.waitTimeout <= 0 {
+		return false
+	}
+
+	timer := time.NewTimer(set.waitTimeout)
+	defer timer.Stop()
+
+	select {
+	case sem <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+func (set *optionSet) getSemaphore(path string) chan struct{} {
+	if v, ok := set.semaphores[path]; ok {
+		return v
+	}
+
+	return set.semaphores[GlobalBulkhead]
+}
+
+// ShouldIgnore determine whether bulkhead should be ignored based on path
+func (set *optionSet) ShouldIgnore(path string) bool {
+	for i := range set.pathToIgnore {
+		if strings.HasPrefix(path, set.pathToIgnore[i]) {
+			return true
+		}
+	}
+
+	return rkmid.ShouldIgnoreGlobal(path)
+}
+
+// ***************** OptionSet Mock *****************
+
+// NewOptionSetMock for testing purpose
+func NewOptionSetMock(before *BeforeCtx) OptionSetInterface {
+	return &optionSetMock{
+		before: before,
+	}
+}
+
+type optionSetMock struct {
+	before *BeforeCtx
+}
+
+// GetEntryName returns entry name
+func (mock *optionSetMock) GetEntryName() string {
+	return "mock"
+}
+
+// GetEntryType returns entry type
+func (mock *optionSetMock) GetEntryType() string {
+	return "mock"
+}
+
+// Config returns empty map
+func (mock *optionSetMock) Config() map[string]interface{} {
+	return map[string]interface{}{}
+}
+
+// BeforeCtx should be created before Before()
+func (mock *optionSetMock) BeforeCtx(request *http.Request) *BeforeCtx {
+	return mock.before
+}
+
+// Before should run before user handler
+func (mock *optionSetMock) Before(ctx *BeforeCtx) {
+	return
+}
+
+// After should run after user handler
+func (mock *optionSetMock) After(ctx *BeforeCtx) {
+	return
+}
+
+// ShouldIgnore should run before user handler
+func (mock *optionSetMock) ShouldIgnore(string) bool {
+	return false
+}
+
+// ***************** Context *****************
+
+// NewBeforeCtx create new BeforeCtx with fields initialized
+func NewBeforeCtx() *BeforeCtx {
+	ctx := &BeforeCtx{}
+	ctx.Output.HeadersToReturn = make(map[string]string)
+	return ctx
+}
+
+// BeforeCtx context for Before() function
+type BeforeCtx struct {
+	Input struct {
+		UrlPath string
+	}
+	Output struct {
+		// HeadersToReturn should be written into response, Retry-After will be set if request was rejected
+		HeadersToReturn map[string]string
+		ErrResp         rkerror.ErrorInterface
+		// DeferFunc releases acquired slot, nil if request was rejected or ignored
+		DeferFunc func()
+	}
+}
+
+// ***************** BootConfig *****************
+
+// BootConfig for YAML
+type BootConfig struct {
+	Enabled       bool     `yaml:"enabled" json:"enabled"`
+	Ignore        []string `yaml:"ignore" json:"ignore"`
+	MaxConcurrent int      `yaml:"maxConcurrent" json:"maxConcurrent"`
+	WaitTimeoutMs int      `yaml:"waitTimeoutMs" json:"waitTimeoutMs"`
+	Paths         []struct {
+		Path          string `yaml:"path" json:"path"`
+		MaxConcurrent int    `yaml:"maxConcurrent" json:"maxConcurrent"`
+	} `yaml:"paths" json:"paths"`
+}
+
+// ToOptions convert BootConfig into Option list
+func ToOptions(config *BootConfig, entryName, entryType string) []Option {
+	opts := make([]Option, 0)
+
+	if config.Enabled {
+		opts = append(opts,
+			WithEntryNameAndType(entryName, entryType),
+			WithMaxConcurrent(config.MaxConcurrent),
+			WithWaitTimeout(time.Duration(config.WaitTimeoutMs)*time.Millisecond))
+
+		for i := range config.Paths {
+			e := config.Paths[i]
+			opts = append(opts, WithMaxConcurrentByPath(e.Path, e.MaxConcurrent))
+		}
+
+		opts = append(opts, WithPathToIgnore(config.Ignore...))
+	}
+
+	return opts
+}
+
+// ***************** Option *****************
+
+// Option if for middleware options while creating middleware
+type Option func(*optionSet)
+
+// WithEntryNameAndType provide entry name and entry type.
+func WithEntryNameAndType(entryName, entryType string) Option {
+	return func(opt *optionSet) {
+		opt.entryName = entryName
+		opt.entryType = entryType
+	}
+}
+
+// WithMaxConcurrent provide max number of requests served concurrently.
+// Optional. Default value DefaultMaxConcurrent.
+func WithMaxConcurrent(n int) Option {
+	return func(opt *optionSet) {
+		if n > 0 {
+			opt.maxConcurrent = n
+		}
+	}
+}
+
+// WithMaxConcurrentByPath provide max number of requests served concurrently by path.
+// Requests of path will use its own semaphore instead of global one.
+func WithMaxConcurrentByPath(path string, n int) Option {
+	return func(opt *optionSet) {
+		if n < 1 {
+			return
+		}
+		if !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
+		opt.maxConcurrentByPath[path] = n
+	}
+}
+
+// WithWaitTimeout provide max duration to wait for a free slot.
+// Optional. Default value 0, which means requests will be rejected immediately once max concurrency reached.
+func WithWaitTimeout(timeout time.Duration) Option {
+	return func(opt *optionSet) {
+		if timeout > 0 {
+			opt.waitTimeout = timeout
+		}
+	}
+}
+
+// WithRetryAfter provide value of Retry-After header of rejected requests, rounded down to seconds.
+// Optional. Default value DefaultRetryAfter.
+func WithRetryAfter(retryAfter time.Duration) Option {
+	return func(opt *optionSet) {
+		if retryAfter > 0 {
+			opt.retryAfter = retryAfter
+		}
+	}
+}
+
+// WithPathToIgnore provide paths prefix that will ignore.
+func WithPathToIgnore(paths ...string) Option {
+	return func(set *optionSet) {
+		for i := range paths {
+			if len(paths[i]) > 0 {
+				set.pathToIgnore = append(set.pathToIgnore, paths[i])
+			}
+		}
+	}
+}
+
+// WithMockOptionSet provide mock OptionSetInterface
+func WithMockOptionSet(mock OptionSetInterface) Option {
+	return func(set *optionSet) {
+		set.mock = mock
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkmidbulkhead
+
+import (
+	"github.com/rookie-ninja/rk-entry/v2/middleware"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNewOptionSet(t *testing.T) {
+	// without options
+	set := NewOptionSet().(*optionSet)
+	assert.NotEmpty(t, set.GetEntryName())
+	assert.Equal(t, DefaultMaxConcurrent, set.maxConcurrent)
+	assert.Empty(t, set.maxConcurrentByPath)
+	assert.Equal(t, DefaultMaxConcurrent, cap(set.semaphores[GlobalBulkhead]))
+
+	// with option
+	set = NewOptionSet(
+		WithEntryNameAndType("name", "type"),
+		WithMaxConcurrent(2),
+		WithMaxConcurrentByPath("ut", 1),
+		WithWaitTimeout(time.Millisecond),
+		WithRetryAfter(3*time.Second),
+		WithPathToIgnore("/ut-ignore"),
+	).(*optionSet)
+
+	assert.Equal(t, "name", set.GetEntryName())
+	assert.Equal(t, "type", set.GetEntryType())
+	assert.Equal(t, 2, cap(set.semaphores[GlobalBulkhead]))
+	assert.Equal(t, 1, cap(set.semaphores["/ut"]))
+	assert.Equal(t, time.Millisecond, set.waitTimeout)
+	assert.True(t, set.ShouldIgnore("/ut-ignore"))
+	assert.NotEmpty(t, set.Config())
+
+	// with mock
+	mock := NewOptionSetMock(NewBeforeCtx())
+	assert.Equal(t, mock, NewOptionSet(WithMockOptionSet(mock)))
+}
+
+func TestOptionSet_Before(t *testing.T) {
+	set := NewOptionSet(
+		WithMaxConcurrent(1),
+		WithMaxConcurrentByPath("/ut-path", 1),
+		WithRetryAfter(2*time.Second)).(*optionSet)
+
+	// acquire the only slot of global semaphore
+	first := set.BeforeCtx(httptest.NewRequest(http.MethodGet, "/", nil))
+	set.Before(first)
+	assert.Nil(t, first.Output.ErrResp)
+	assert.NotNil(t, first.Output.DeferFunc)
+
+	// rejected with 503 and Retry-After
+	second := set.BeforeCtx(httptest.NewRequest(http.MethodGet, "/", nil))
+	set.Before(second)
+	assert.Equal(t, http.StatusServiceUnavailable, second.Output.ErrResp.Code())
+	assert.Equal(t, "2", second.Output.HeadersToReturn[rkmid.HeaderRetryAfter])
+	assert.Nil(t, second.Output.DeferFunc)
+
+	// path uses its own semaphore
+	path := set.BeforeCtx(httptest.NewRequest(http.MethodGet, "/ut-path", nil))
+	set.Before(path)
+	assert.Nil(t, path.Output.ErrResp)
+	set.After(path)
+
+	// release twice should not release others' slot
+	set.After(first)
+	first.Output.DeferFunc()
+	assert.Len(t, set.semaphores[GlobalBulkhead], 0)
+
+	third := set.BeforeCtx(httptest.NewRequest(http.MethodGet, "/", nil))
+	set.Before(third)
+	assert.Nil(t, third.Output.ErrResp)
+	set.After(third)
+}
+
+func TestOptionSet_Before_WithWaitTimeout(t *testing.T) {
+	set := NewOptionSet(
+		WithMaxConcurrent(1),
+		WithWaitTimeout(time.Second)).(*optionSet)
+
+	first := set.BeforeCtx(httptest.NewRequest(http.MethodGet, "/", nil))
+	set.Before(first)
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		set.After(first)
+	}()
+
+	// wait until slot released
+	second := set.BeforeCtx(httptest.NewRequest(http.MethodGet, "/", nil))
+	set.Before(second)
+	assert.Nil(t, second.Output.ErrResp)
+	set.After(second)
+}
+
+func TestOptionSet_Before_WithPanic(t *testing.T) {
+	set := NewOptionSet(WithMaxConcurrent(1)).(*optionSet)
+
+	handler := func() {
+		defer func() {
+			recover()
+		}()
+
+		ctx := set.BeforeCtx(httptest.NewRequest(http.MethodGet, "/", nil))
+		set.Before(ctx)
+		defer ctx.Output.DeferFunc()
+
+		panic("ut-panic")
+	}
+
+	handler()
+	assert.Len(t, set.semaphores[GlobalBulkhead], 0)
+}
+
+func TestOptionSet_Before_WithIgnore(t *testing.T) {
+	set := NewOptionSet(WithMaxConcurrent(1), WithPathToIgnore("/ut-ignore")).(*optionSet)
+
+	ctx := set.BeforeCtx(httptest.NewRequest(http.MethodGet, "/ut-ignore", nil))
+	set.Before(ctx)
+	assert.Nil(t, ctx.Output.ErrResp)
+	assert.Nil(t, ctx.Output.DeferFunc)
+	assert.Len(t, set.semaphores[GlobalBulkhead], 0)
+}
+
+func TestToOptions(t *testing.T) {
+	config := &BootConfig{
+		Enabled:       false,
+		MaxConcurrent: 2,
+	}
+
+	// with disabled
+	assert.Empty(t, ToOptions(config, "", ""))
+
+	// with enabled
+	config.Enabled = true
+	config.Paths = append(config.Paths, struct {
+		Path          string `yaml:"path" json:"path"`
+		MaxConcurrent int    `yaml:"maxConcurrent" json:"maxConcurrent"`
+	}{Path: "/ut", MaxConcurrent: 1})
+
+	set := NewOptionSet(ToOptions(config, "ut-entry", "ut-type")...).(*optionSet)
+	assert.Equal(t, 2, set.maxConcurrent)
+	assert.Equal(t, 1, set.maxConcurrentByPath["/ut"])
+}
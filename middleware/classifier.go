@@ -0,0 +1,80 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkmid
+
+import (
+	"time"
+)
+
+const (
+	// SamplingDecisionDefault leaves decision to sampler of each middleware
+	SamplingDecisionDefault SamplingDecision = iota
+	// SamplingDecisionKeep request should be fully traced and logged
+	SamplingDecisionKeep
+	// SamplingDecisionDrop request should be neither traced nor logged
+	SamplingDecisionDrop
+)
+
+// SamplingDecision returned by RequestClassifier
+type SamplingDecision int
+
+// String returns name of decision
+func (d SamplingDecision) String() string {
+	switch d {
+	case SamplingDecisionKeep:
+		return "keep"
+	case SamplingDecisionDrop:
+		return "drop"
+	}
+
+	return "default"
+}
+
+// RequestInfo describes finished request which will be classified
+type RequestInfo struct {
+	UrlPath string
+	Method  string
+	// ResCode is HTTP status code of response, 0 if unknown
+	ResCode int
+	Latency time.Duration
+}
+
+// RequestClassifier classifies finished requests, so that a single policy governs sampling of both traces and events.
+//
+// Tracing and logging middleware consult classifier after request finished,
+// requests classified as SamplingDecisionKeep bypass samplers, SamplingDecisionDrop ones are discarded.
+type RequestClassifier interface {
+	Classify(info *RequestInfo) SamplingDecision
+}
+
+// RequestClassifierFunc is an adapter to use function as RequestClassifier
+type RequestClassifierFunc func(info *RequestInfo) SamplingDecision
+
+// Classify calls f(info)
+func (f RequestClassifierFunc) Classify(info *RequestInfo) SamplingDecision {
+	return f(info)
+}
+
+// NewDefaultRequestClassifier create RequestClassifier which keeps requests with 5xx response code
+// or latency over threshold, decision of other requests is left to samplers.
+// Latency will not be checked if threshold is not positive.
+func NewDefaultRequestClassifier(latencyThreshold time.Duration) RequestClassifier {
+	return RequestClassifierFunc(func(info *RequestInfo) SamplingDecision {
+		if info == nil {
+			return SamplingDecisionDefault
+		}
+
+		if info.ResCode >= 500 {
+			return SamplingDecisionKeep
+		}
+
+		if latencyThreshold > 0 && info.Latency > latencyThreshold {
+			return SamplingDecisionKeep
+		}
+
+		return SamplingDecisionDefault
+	})
+}
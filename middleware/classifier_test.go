@@ -0,0 +1,38 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkmid
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestNewDefaultRequestClassifier(t *testing.T) {
+	classifier := NewDefaultRequestClassifier(time.Second)
+
+	// with nil info
+	assert.Equal(t, SamplingDecisionDefault, classifier.Classify(nil))
+
+	// with server error
+	assert.Equal(t, SamplingDecisionKeep, classifier.Classify(&RequestInfo{ResCode: 503}))
+
+	// with slow request
+	assert.Equal(t, SamplingDecisionKeep, classifier.Classify(&RequestInfo{ResCode: 200, Latency: 2 * time.Second}))
+
+	// with fast and successful request
+	assert.Equal(t, SamplingDecisionDefault, classifier.Classify(&RequestInfo{ResCode: 200, Latency: time.Millisecond}))
+
+	// without latency threshold
+	classifier = NewDefaultRequestClassifier(0)
+	assert.Equal(t, SamplingDecisionDefault, classifier.Classify(&RequestInfo{ResCode: 404, Latency: time.Hour}))
+}
+
+func TestSamplingDecision_String(t *testing.T) {
+	assert.Equal(t, "default", SamplingDecisionDefault.String())
+	assert.Equal(t, "keep", SamplingDecisionKeep.String())
+	assert.Equal(t, "drop", SamplingDecisionDrop.String())
+}
@@ -1,14 +1,23 @@
 package rkmid
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
 	"net"
 	"net/http"
 	"os"
 	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/google/uuid"
 	rkerror "github.com/rookie-ninja/rk-entry/v2/error"
+	oteltrace "go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
@@ -18,6 +27,7 @@ const (
 	HeaderRequestId                       = "X-Request-Id"
 	HeaderTraceId                         = "X-Trace-Id"
 	HeaderOrigin                          = "Origin"
+	HeaderReferer                         = "Referer"
 	HeaderAccessControlAllowOrigin        = "Access-Control-Allow-Origin"
 	HeaderAccessControlAllowCredentials   = "Access-Control-Allow-Credentials"
 	HeaderAccessControlExposeHeaders      = "Access-Control-Expose-Headers"
@@ -27,20 +37,31 @@ const (
 	HeaderAccessControlAllowMethods       = "Access-Control-Allow-Methods"
 	HeaderAccessControlAllowHeaders       = "Access-Control-Allow-Headers"
 	HeaderAccessControlMaxAge             = "Access-Control-Max-Age"
+	HeaderAccessControlRequestPrivateNet  = "Access-Control-Request-Private-Network"
+	HeaderAccessControlAllowPrivateNet    = "Access-Control-Allow-Private-Network"
 	HeaderContentEncoding                 = "Content-Encoding"
 	HeaderContentLength                   = "Content-Length"
+	HeaderContentRange                    = "Content-Range"
 	HeaderContentType                     = "Content-Type"
 	HeaderAcceptEncoding                  = "Accept-Encoding"
 	HeaderXXSSProtection                  = "X-Xss-Protection"
 	HeaderXContentTypeOptions             = "X-Content-Type-Options"
 	HeaderXFrameOptions                   = "X-Frame-Options"
 	HeaderXForwardedProto                 = "X-Forwarded-Proto"
+	HeaderXForwardedFor                   = "X-Forwarded-For"
+	HeaderXForwardedHost                  = "X-Forwarded-Host"
 	HeaderStrictTransportSecurity         = "Strict-Transport-Security"
 	HeaderContentSecurityPolicyReportOnly = "Content-Security-Policy-Report-Only"
 	HeaderContentSecurityPolicy           = "Content-Security-Policy"
 	HeaderReferrerPolicy                  = "Referrer-Policy"
+	HeaderPermissionsPolicy               = "Permissions-Policy"
+	HeaderCrossOriginOpenerPolicy         = "Cross-Origin-Opener-Policy"
+	HeaderCrossOriginEmbedderPolicy       = "Cross-Origin-Embedder-Policy"
+	HeaderCrossOriginResourcePolicy       = "Cross-Origin-Resource-Policy"
 	HeaderXCSRFToken                      = "X-CSRF-Token"
 	HeaderCookie                          = "Cookie"
+	HeaderRetryCount                      = "X-Retry-Count"
+	HeaderRetryAfter                      = "Retry-After"
 )
 
 var (
@@ -54,6 +75,8 @@ var (
 	PropagatorKey     = &propagatorKey{}
 	JwtTokenKey       = &jwtTokenKey{}
 	CsrfTokenKey      = &csrfTokenKey{}
+	ForwardedKey      = &forwardedKey{}
+	ResponseWriterKey = &responseWriterKey{}
 
 	// Domain environment variable
 	Domain = zap.String("domain", getEnvValueOrDefault("DOMAIN", "*"))
@@ -85,6 +108,30 @@ func GetErrorBuilder() rkerror.ErrorBuilder {
 	return errBuilder
 }
 
+// WithTraceIdFromContext attach trace id of span in context to error, which was started by tracing middleware.
+// Error will be returned as it is if there is no valid span in context.
+func WithTraceIdFromContext(ctx context.Context, err rkerror.ErrorInterface) rkerror.ErrorInterface {
+	if ctx == nil || err == nil {
+		return err
+	}
+
+	if traceId := oteltrace.SpanContextFromContext(ctx).TraceID(); traceId.IsValid() {
+		return rkerror.WithTraceId(err, traceId.String())
+	}
+
+	return err
+}
+
+// AttachTraceId attach trace id of request to error response, so that it could be rendered into error body.
+// Context of request will be used if request is not nil, otherwise user context will be used.
+func AttachTraceId(req *http.Request, userCtx context.Context, err rkerror.ErrorInterface) rkerror.ErrorInterface {
+	if req != nil {
+		return WithTraceIdFromContext(req.Context(), err)
+	}
+
+	return WithTraceIdFromContext(userCtx, err)
+}
+
 type entryNameKey struct{}
 
 func (key *entryNameKey) String() string {
@@ -145,6 +192,40 @@ func (key *csrfTokenKey) String() string {
 	return "csrfTokenKeyRk"
 }
 
+type forwardedKey struct{}
+
+func (key *forwardedKey) String() string {
+	return "forwardedKeyRk"
+}
+
+type responseWriterKey struct{}
+
+func (key *responseWriterKey) String() string {
+	return "responseWriterKeyRk"
+}
+
+// Forwarded contains normalized values of X-Forwarded-* headers.
+// It is stored in context of request with ForwardedKey by forwarded middleware.
+type Forwarded struct {
+	ClientIp string
+	Proto    string
+	Host     string
+}
+
+// GetForwarded returns normalized forwarded values from context of request.
+// Nil will be returned if forwarded middleware is not enabled.
+func GetForwarded(req *http.Request) *Forwarded {
+	if req == nil {
+		return nil
+	}
+
+	if v, ok := req.Context().Value(ForwardedKey).(*Forwarded); ok {
+		return v
+	}
+
+	return nil
+}
+
 // GetRemoteAddressSet returns remote endpoint information set including IP, Port.
 // We will do as best as we can to determine it.
 // If fails, then just return default ones.
@@ -162,6 +243,11 @@ func GetRemoteAddressSet(req *http.Request) (remoteIp, remotePort string) {
 
 	forwardedRemoteIp := req.Header.Get("x-forwarded-for")
 
+	// prefer normalized client ip if forwarded middleware is enabled
+	if forwarded := GetForwarded(req); forwarded != nil {
+		forwardedRemoteIp = forwarded.ClientIp
+	}
+
 	// Deal with forwarded remote ip
 	if len(forwardedRemoteIp) > 0 {
 		if forwardedRemoteIp == "::1" {
@@ -178,14 +264,352 @@ func GetRemoteAddressSet(req *http.Request) (remoteIp, remotePort string) {
 	return remoteIp, remotePort
 }
 
+// GetRetryAttempt returns retry attempt from request header.
+// Zero will be returned if header is missing or invalid, which means first attempt.
+func GetRetryAttempt(req *http.Request, header string) int {
+	if req == nil || len(header) < 1 {
+		return 0
+	}
+
+	attempt, err := strconv.Atoi(strings.TrimSpace(req.Header.Get(header)))
+	if err != nil || attempt < 0 {
+		return 0
+	}
+
+	return attempt
+}
+
+// NegotiateEncoding returns the best content encoding from supported list based on Accept-Encoding header.
+// Quality values are respected, encodings with q=0 are refused and wildcard(*) matches any encoding
+// not listed explicitly. Order of supported list breaks ties.
+// Empty string will be returned if no supported encoding is acceptable.
+//
+// Example:
+//
+//	NegotiateEncoding("gzip;q=0.5, br;q=1.0", []string{"gzip", "br"}) => "br"
+func NegotiateEncoding(acceptEncoding string, supported []string) string {
+	qualities := make(map[string]float64)
+	wildcard := -1.0
+
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		part = strings.TrimSpace(part)
+		if len(part) < 1 {
+			continue
+		}
+
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		q := 1.0
+
+		for _, param := range strings.Split(params, ";") {
+			key, value, found := strings.Cut(strings.TrimSpace(param), "=")
+			if !found || strings.ToLower(strings.TrimSpace(key)) != "q" {
+				continue
+			}
+
+			if v, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+				q = v
+			} else {
+				q = 0
+			}
+		}
+
+		if name == "*" {
+			wildcard = q
+		} else {
+			qualities[name] = q
+		}
+	}
+
+	res, best := "", 0.0
+	for _, encoding := range supported {
+		q, ok := qualities[strings.ToLower(encoding)]
+		if !ok {
+			q = wildcard
+		}
+
+		if q > best {
+			res, best = encoding, q
+		}
+	}
+
+	return res
+}
+
+// HashRequestBody returns hex encoded SHA-256 of request body which could be used as fingerprint of request.
+// Body will be re-buffered, so that it could be read again by user handler.
+// False will be returned if body is larger than maxBytes or failed to read.
+func HashRequestBody(req *http.Request, maxBytes int64) (string, bool) {
+	if req == nil || maxBytes < 1 {
+		return "", false
+	}
+
+	if req.Body == nil || req.Body == http.NoBody {
+		sum := sha256.Sum256(nil)
+		return hex.EncodeToString(sum[:]), true
+	}
+
+	// read one more byte in order to find out whether body exceeds limit
+	buf, err := io.ReadAll(io.LimitReader(req.Body, maxBytes+1))
+	if err != nil || int64(len(buf)) > maxBytes {
+		req.Body = readCloser{
+			Reader: io.MultiReader(bytes.NewReader(buf), req.Body),
+			Closer: req.Body,
+		}
+		return "", false
+	}
+
+	req.Body.Close()
+	req.Body = io.NopCloser(bytes.NewReader(buf))
+
+	sum := sha256.Sum256(buf)
+	return hex.EncodeToString(sum[:]), true
+}
+
+// readCloser combines reader of re-buffered body with closer of original body
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// CountingResponseWriter wraps http.ResponseWriter and records status code and bytes written.
+//
+// Adapters should wrap http.ResponseWriter once and feed Status() and Size() into AfterCtx of each middleware.
+//
+// Response body could be captured with CaptureBody() and shared between middlewares, like logging and idempotency,
+// so that body is captured only once. Adapters should store writer into context of request with
+// SetCountingResponseWriter, middlewares could get it back with GetCountingResponseWriter.
+type CountingResponseWriter struct {
+	http.ResponseWriter
+	status      int
+	size        int64
+	wroteHeader bool
+
+	// body capture is disabled by default, bytes beyond captureMaxBytes will be dropped
+	captureMaxBytes  int64
+	captured         []byte
+	captureTruncated bool
+
+	// response buffering is disabled by default, status and body are held until ReleaseBuffer() called
+	buffering      bool
+	bufferMaxBytes int64
+	buffered       []byte
+}
+
+// NewCountingResponseWriter wraps http.ResponseWriter, writer will be returned as it is if already wrapped.
+func NewCountingResponseWriter(w http.ResponseWriter) *CountingResponseWriter {
+	if v, ok := w.(*CountingResponseWriter); ok {
+		return v
+	}
+
+	return &CountingResponseWriter{
+		ResponseWriter: w,
+		status:         http.StatusOK,
+	}
+}
+
+// WriteHeader records status code, only the first call takes effect as the same as http.ResponseWriter
+func (w *CountingResponseWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+
+	w.status = code
+	w.wroteHeader = true
+	if !w.buffering {
+		w.ResponseWriter.WriteHeader(code)
+	}
+}
+
+// Write records bytes written, status code will be 200 if WriteHeader was not called
+func (w *CountingResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	// release buffer and write through if response is larger than buffer
+	if w.buffering && int64(len(w.buffered)+len(b)) > w.bufferMaxBytes {
+		w.ReleaseBuffer()
+	}
+
+	if w.buffering {
+		w.buffered = append(w.buffered, b...)
+		w.size += int64(len(b))
+		w.capture(b)
+		return len(b), nil
+	}
+
+	n, err := w.ResponseWriter.Write(b)
+	w.size += int64(n)
+	w.capture(b[:n])
+	return n, err
+}
+
+// BufferBody holds status and body of response up to maxBytes in memory instead of writing them,
+// so that response could be replaced with DiscardBuffer() before ReleaseBuffer() called.
+// It should be called before anything written, non-positive value will be ignored.
+//
+// Buffer will be released once response exceeds maxBytes or Flush() called, so streaming responses are never held.
+// Caller must call ReleaseBuffer() after handler, even if handler panics, otherwise response will be lost.
+func (w *CountingResponseWriter) BufferBody(maxBytes int64) {
+	if maxBytes < 1 || w.wroteHeader {
+		return
+	}
+
+	w.buffering = true
+	if maxBytes > w.bufferMaxBytes {
+		w.bufferMaxBytes = maxBytes
+	}
+}
+
+// Buffering returns true if response is being held in memory
+func (w *CountingResponseWriter) Buffering() bool {
+	return w.buffering
+}
+
+// ReleaseBuffer writes status and body held in memory into underlying writer, and stops buffering
+func (w *CountingResponseWriter) ReleaseBuffer() {
+	if !w.buffering {
+		return
+	}
+
+	w.buffering = false
+	if w.wroteHeader {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+	if len(w.buffered) > 0 {
+		w.ResponseWriter.Write(w.buffered)
+	}
+	w.buffered = nil
+}
+
+// DiscardBuffer drops status and body held in memory and stops buffering, so that another response could be written.
+// Headers are kept, caller should reset them if needed. False will be returned if response is not buffered.
+func (w *CountingResponseWriter) DiscardBuffer() bool {
+	if !w.buffering {
+		return false
+	}
+
+	w.buffering = false
+	w.buffered = nil
+	w.status = http.StatusOK
+	w.size = 0
+	w.wroteHeader = false
+	w.captured = nil
+	w.captureTruncated = false
+
+	return true
+}
+
+// CaptureBody enables capturing of response body up to maxBytes, it should be called before body written.
+//
+// Memory cost is up to maxBytes per in-flight request. Middlewares share the same buffer,
+// the largest maxBytes requested takes effect, non-positive value will be ignored.
+func (w *CountingResponseWriter) CaptureBody(maxBytes int64) {
+	if maxBytes > w.captureMaxBytes {
+		w.captureMaxBytes = maxBytes
+	}
+}
+
+// CapturedBody returns captured response body, false will be returned if body was truncated or capture is disabled.
+// Returned bytes should not be modified.
+func (w *CountingResponseWriter) CapturedBody() ([]byte, bool) {
+	return w.captured, w.captureMaxBytes > 0 && !w.captureTruncated
+}
+
+// capture bytes written into buffer until limit reached
+func (w *CountingResponseWriter) capture(b []byte) {
+	if w.captureMaxBytes < 1 || len(b) < 1 {
+		return
+	}
+
+	remaining := w.captureMaxBytes - int64(len(w.captured))
+	if int64(len(b)) > remaining {
+		b = b[:remaining]
+		w.captureTruncated = true
+	}
+
+	w.captured = append(w.captured, b...)
+}
+
+// Flush passes through to http.Flusher if underlying writer implements it
+func (w *CountingResponseWriter) Flush() {
+	w.ReleaseBuffer()
+
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		// headers will be sent with status of 200 if not written
+		w.wroteHeader = true
+		flusher.Flush()
+	}
+}
+
+// Hijack passes through to http.Hijacker if underlying writer implements it
+func (w *CountingResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hijacker, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return hijacker.Hijack()
+	}
+
+	return nil, nil, errors.New("http.Hijacker is not implemented by underlying http.ResponseWriter")
+}
+
+// Unwrap returns underlying http.ResponseWriter, used by http.ResponseController
+func (w *CountingResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Status returns status code written, 200 will be returned if nothing was written
+func (w *CountingResponseWriter) Status() int {
+	return w.status
+}
+
+// Size returns number of bytes written into body
+func (w *CountingResponseWriter) Size() int64 {
+	return w.size
+}
+
+// Written returns true if status code was written
+func (w *CountingResponseWriter) Written() bool {
+	return w.wroteHeader
+}
+
+// SetCountingResponseWriter stores writer into context of request, new request will be returned.
+func SetCountingResponseWriter(req *http.Request, w *CountingResponseWriter) *http.Request {
+	if req == nil || w == nil {
+		return req
+	}
+
+	return req.WithContext(context.WithValue(req.Context(), ResponseWriterKey, w))
+}
+
+// GetCountingResponseWriter returns CountingResponseWriter from context of request.
+// Nil will be returned if adapter did not store it.
+func GetCountingResponseWriter(req *http.Request) *CountingResponseWriter {
+	if req == nil {
+		return nil
+	}
+
+	if v, ok := req.Context().Value(ResponseWriterKey).(*CountingResponseWriter); ok {
+		return v
+	}
+
+	return nil
+}
+
+// ShouldIgnoreGlobal determine whether path should be ignored based on global ignore list
 func ShouldIgnoreGlobal(urlPath string) bool {
+	_, ok := MatchIgnoreGlobal(urlPath)
+	return ok
+}
+
+// MatchIgnoreGlobal returns prefix in global ignore list which matches path
+func MatchIgnoreGlobal(urlPath string) (string, bool) {
 	for i := range pathToIgnore {
 		if strings.HasPrefix(urlPath, pathToIgnore[i]) {
-			return true
+			return pathToIgnore[i], true
 		}
 	}
 
-	return false
+	return "", false
 }
 
 // GenerateRequestId generate request id based on google/uuid.
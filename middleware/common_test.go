@@ -0,0 +1,241 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkmid
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/rookie-ninja/rk-entry/v2/error"
+	"github.com/stretchr/testify/assert"
+	oteltrace "go.opentelemetry.io/otel/trace"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	supported := []string{"gzip", "br"}
+
+	// with empty header
+	assert.Empty(t, NegotiateEncoding("", supported))
+
+	// with quality values
+	assert.Equal(t, "br", NegotiateEncoding("gzip;q=0.5, br;q=1.0", supported))
+	assert.Equal(t, "gzip", NegotiateEncoding("gzip;q=0.8, br;q=0.2", supported))
+
+	// with same quality, order of supported list wins
+	assert.Equal(t, "gzip", NegotiateEncoding("br, gzip", supported))
+
+	// with refused encoding
+	assert.Equal(t, "gzip", NegotiateEncoding("gzip, br;q=0", supported))
+	assert.Empty(t, NegotiateEncoding("identity;q=0", []string{"identity"}))
+	assert.Equal(t, "gzip", NegotiateEncoding("identity;q=0, gzip", []string{"identity", "gzip"}))
+
+	// with wildcard
+	assert.Equal(t, "br", NegotiateEncoding("gzip;q=0, *", supported))
+	assert.Empty(t, NegotiateEncoding("*;q=0", supported))
+
+	// with unsupported encoding
+	assert.Empty(t, NegotiateEncoding("deflate", supported))
+}
+
+func TestHashRequestBody(t *testing.T) {
+	// with nil request
+	hash, ok := HashRequestBody(nil, 10)
+	assert.False(t, ok)
+	assert.Empty(t, hash)
+
+	// with empty body
+	hash, ok = HashRequestBody(httptest.NewRequest(http.MethodGet, "/", nil), 10)
+	assert.True(t, ok)
+	assert.Equal(t, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", hash)
+
+	// with body, body should be re-buffered
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("ut-body"))
+	hash, ok = HashRequestBody(req, 10)
+	assert.True(t, ok)
+	sum := sha256.Sum256([]byte("ut-body"))
+	assert.Equal(t, hex.EncodeToString(sum[:]), hash)
+	body, _ := io.ReadAll(req.Body)
+	assert.Equal(t, "ut-body", string(body))
+
+	// with body exceeds limit, body should be kept
+	req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader("ut-large-body"))
+	hash, ok = HashRequestBody(req, 5)
+	assert.False(t, ok)
+	assert.Empty(t, hash)
+	body, _ = io.ReadAll(req.Body)
+	assert.Equal(t, "ut-large-body", string(body))
+}
+
+func TestCountingResponseWriter(t *testing.T) {
+	// without writing
+	recorder := httptest.NewRecorder()
+	w := NewCountingResponseWriter(recorder)
+	assert.Equal(t, w, NewCountingResponseWriter(w))
+	assert.Equal(t, http.StatusOK, w.Status())
+	assert.False(t, w.Written())
+
+	// with status code and body
+	w.WriteHeader(http.StatusCreated)
+	w.WriteHeader(http.StatusInternalServerError)
+	n, err := w.Write([]byte("ut-body"))
+	assert.Nil(t, err)
+	assert.Equal(t, 7, n)
+	w.Write([]byte("!"))
+	assert.Equal(t, http.StatusCreated, w.Status())
+	assert.Equal(t, http.StatusCreated, recorder.Code)
+	assert.Equal(t, int64(8), w.Size())
+	assert.True(t, w.Written())
+	assert.Equal(t, recorder, w.Unwrap())
+
+	// with implicit status code
+	w = NewCountingResponseWriter(httptest.NewRecorder())
+	w.Write([]byte("ut-body"))
+	assert.Equal(t, http.StatusOK, w.Status())
+
+	// with flush
+	recorder = httptest.NewRecorder()
+	w = NewCountingResponseWriter(recorder)
+	w.Flush()
+	assert.True(t, w.Written())
+	assert.True(t, recorder.Flushed)
+
+	// without hijacker
+	_, _, err = w.Hijack()
+	assert.NotNil(t, err)
+}
+
+func TestCountingResponseWriter_CaptureBody(t *testing.T) {
+	// without capture
+	w := NewCountingResponseWriter(httptest.NewRecorder())
+	w.Write([]byte("ut-body"))
+	body, ok := w.CapturedBody()
+	assert.Empty(t, body)
+	assert.False(t, ok)
+
+	// largest limit takes effect
+	w = NewCountingResponseWriter(httptest.NewRecorder())
+	w.CaptureBody(4)
+	w.CaptureBody(10)
+	w.CaptureBody(-1)
+	w.Write([]byte("ut-body"))
+	body, ok = w.CapturedBody()
+	assert.Equal(t, "ut-body", string(body))
+	assert.True(t, ok)
+
+	// with truncated body
+	w.Write([]byte("-truncated"))
+	body, ok = w.CapturedBody()
+	assert.Equal(t, "ut-body-tr", string(body))
+	assert.False(t, ok)
+	assert.Equal(t, int64(17), w.Size())
+
+	// with context of request
+	req := httptest.NewRequest(http.MethodGet, "/ut", nil)
+	assert.Nil(t, GetCountingResponseWriter(req))
+	assert.Nil(t, GetCountingResponseWriter(nil))
+	assert.Equal(t, req, SetCountingResponseWriter(req, nil))
+	req = SetCountingResponseWriter(req, w)
+	assert.Equal(t, w, GetCountingResponseWriter(req))
+}
+
+func TestCountingResponseWriter_BufferBody(t *testing.T) {
+	// with buffered response released
+	recorder := httptest.NewRecorder()
+	w := NewCountingResponseWriter(recorder)
+	w.BufferBody(-1)
+	assert.False(t, w.Buffering())
+	w.BufferBody(10)
+	assert.True(t, w.Buffering())
+	w.WriteHeader(http.StatusCreated)
+	w.Write([]byte("ut-body"))
+	assert.False(t, recorder.Flushed)
+	assert.Empty(t, recorder.Body.String())
+	assert.Equal(t, http.StatusCreated, w.Status())
+	assert.Equal(t, int64(7), w.Size())
+	w.ReleaseBuffer()
+	assert.False(t, w.Buffering())
+	assert.Equal(t, http.StatusCreated, recorder.Code)
+	assert.Equal(t, "ut-body", recorder.Body.String())
+	assert.False(t, w.DiscardBuffer())
+
+	// with buffered response discarded
+	recorder = httptest.NewRecorder()
+	w = NewCountingResponseWriter(recorder)
+	w.BufferBody(10)
+	w.CaptureBody(10)
+	w.Write([]byte("ut-body"))
+	assert.True(t, w.DiscardBuffer())
+	assert.False(t, w.Written())
+	body, _ := w.CapturedBody()
+	assert.Empty(t, body)
+	w.WriteHeader(http.StatusInternalServerError)
+	w.Write([]byte("ut-error"))
+	assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+	assert.Equal(t, "ut-error", recorder.Body.String())
+
+	// with response larger than buffer, it should be written through
+	recorder = httptest.NewRecorder()
+	w = NewCountingResponseWriter(recorder)
+	w.BufferBody(4)
+	w.Write([]byte("ut"))
+	w.Write([]byte("-body"))
+	assert.False(t, w.Buffering())
+	assert.Equal(t, "ut-body", recorder.Body.String())
+
+	// with flush, streaming response should not be held
+	recorder = httptest.NewRecorder()
+	w = NewCountingResponseWriter(recorder)
+	w.BufferBody(10)
+	w.Write([]byte("ut"))
+	w.Flush()
+	assert.False(t, w.Buffering())
+	assert.Equal(t, "ut", recorder.Body.String())
+	assert.True(t, recorder.Flushed)
+}
+
+func TestWithTraceIdFromContext(t *testing.T) {
+	err := GetErrorBuilder().New(http.StatusBadRequest, "ut-error")
+
+	// with nil error
+	assert.Nil(t, WithTraceIdFromContext(context.Background(), nil))
+
+	// without span
+	assert.Equal(t, err, WithTraceIdFromContext(context.Background(), err))
+
+	// with span
+	spanCtx := oteltrace.NewSpanContext(oteltrace.SpanContextConfig{
+		TraceID: oteltrace.TraceID{1},
+		SpanID:  oteltrace.SpanID{1},
+	})
+	res := WithTraceIdFromContext(oteltrace.ContextWithSpanContext(context.Background(), spanCtx), err)
+	assert.Equal(t, http.StatusBadRequest, res.Code())
+	assert.Equal(t, spanCtx.TraceID().String(), res.(rkerror.TraceIdCarrier).TraceId())
+}
+
+func TestAttachTraceId(t *testing.T) {
+	err := GetErrorBuilder().New(http.StatusBadRequest, "ut-error")
+	spanCtx := oteltrace.NewSpanContext(oteltrace.SpanContextConfig{
+		TraceID: oteltrace.TraceID{1},
+		SpanID:  oteltrace.SpanID{1},
+	})
+	traceCtx := oteltrace.ContextWithSpanContext(context.Background(), spanCtx)
+
+	// with user context
+	res := AttachTraceId(nil, traceCtx, err)
+	assert.Equal(t, spanCtx.TraceID().String(), res.(rkerror.TraceIdCarrier).TraceId())
+
+	// with request, context of request takes precedence
+	req := httptest.NewRequest(http.MethodGet, "/ut", nil)
+	assert.Equal(t, err, AttachTraceId(req, traceCtx, err))
+
+	res = AttachTraceId(req.WithContext(traceCtx), context.Background(), err)
+	assert.Equal(t, spanCtx.TraceID().String(), res.(rkerror.TraceIdCarrier).TraceId())
+}
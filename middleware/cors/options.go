@@ -7,11 +7,20 @@
 package rkmidcors
 
 import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"github.com/rookie-ninja/rk-entry/v2/entry"
 	"github.com/rookie-ninja/rk-entry/v2/middleware"
+	"go.uber.org/zap"
 	"net/http"
+	"net/url"
+	"os"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
 // ***************** OptionSet Interface *****************
@@ -27,6 +36,8 @@ type OptionSetInterface interface {
 	BeforeCtx(*http.Request) *BeforeCtx
 
 	ShouldIgnore(string) bool
+
+	Config() map[string]interface{}
 }
 
 // ***************** OptionSet Implementation *****************
@@ -41,14 +52,37 @@ type optionSet struct {
 	// Optional. Default value []string{"*"}.
 	allowOrigins []string
 	// allowPatterns derived from AllowOrigins by parsing regex fields
-	// auto generated when creating new optionSet was created
-	allowPatterns []string
+	// auto generated and compiled when creating new optionSet was created
+	allowPatterns []*regexp.Regexp
+	// allowOriginFunc returns true if origin is allowed, it takes precedence over allowOrigins and allowOriginsFile.
+	// Optional. Default value nil.
+	allowOriginFunc func(origin string) bool
+	// allowOriginsFile is a newline-delimited file which contains allowed origins.
+	// Origins in file will be appended to AllowOrigins.
+	// Optional. Default value "".
+	allowOriginsFile string
+	// allowOriginsFileReload indicates how often allowOriginsFile will be reloaded.
+	// Optional. Default value 0, which means never reload.
+	allowOriginsFileReload time.Duration
+	// fileOrigins loaded from allowOriginsFile
+	fileOrigins []string
+	// reloadStop stops reloading of allowOriginsFile, reloadDone is closed once reloading stopped
+	reloadStop      chan struct{}
+	reloadDone      chan struct{}
+	reloadCloseOnce sync.Once
+	// lock for allowPatterns and fileOrigins
+	lock sync.RWMutex
 	// AllowMethods defines a list methods allowed when accessing the resource.
 	// This is used in response to a preflight request.
 	// Optional. Default value DefaultCORSConfig.AllowMethods.
 	allowMethods []string
+	// reflectRequestMethod returns only the method in Access-Control-Request-Method
+	// in response to a preflight request if it is allowed.
+	// Optional. Default value false, which means full list of allowMethods will be returned.
+	reflectRequestMethod bool
 	// AllowHeaders defines a list of request headers that can be used when
 	// making the actual request. This is in response to a preflight request.
+	// If "*" was provided, headers in Access-Control-Request-Headers will be returned.
 	// Optional. Default value []string{}.
 	allowHeaders []string
 	// AllowCredentials indicates whether or not the response to the request
@@ -65,6 +99,13 @@ type optionSet struct {
 	// can be cached.
 	// Optional. Default value 0.
 	maxAge int
+	// maxAgeFunc returns max age per request, negative value will be ignored and maxAge will be used.
+	// Optional. Default value nil.
+	maxAgeFunc func(*http.Request) int
+	// allowPrivateNetwork indicates whether preflight request with Access-Control-Request-Private-Network
+	// will be responded with Access-Control-Allow-Private-Network, which is required by Private Network Access.
+	// Optional. Default value false.
+	allowPrivateNetwork bool
 }
 
 // NewOptionSet Create new optionSet with options.
@@ -89,10 +130,26 @@ func NewOptionSet(opts ...Option) OptionSetInterface {
 		return set.mock
 	}
 
-	if len(set.allowOrigins) < 1 {
+	if len(set.allowOrigins) < 1 && len(set.allowOriginsFile) < 1 {
 		set.allowOrigins = append(set.allowOrigins, "*")
 	}
 
+	// load origins from file and reload periodically
+	if len(set.allowOriginsFile) > 0 {
+		origins, err := readOriginsFile(set.allowOriginsFile)
+		if err != nil {
+			rkentry.ShutdownWithError(err)
+		}
+		set.fileOrigins = origins
+
+		if set.allowOriginsFileReload > 0 {
+			set.reloadStop = make(chan struct{})
+			set.reloadDone = make(chan struct{})
+			go set.reloadOriginsFile()
+			rkentry.GlobalAppCtx.AddShutdownHook("rk-cors-reload-"+set.entryName, set.Close)
+		}
+	}
+
 	if len(set.allowMethods) < 1 {
 		set.allowMethods = append(set.allowMethods,
 			http.MethodGet,
@@ -119,6 +176,29 @@ func (set *optionSet) GetEntryType() string {
 	return set.entryType
 }
 
+// Config returns effective configuration, secrets are redacted
+func (set *optionSet) Config() map[string]interface{} {
+	set.lock.RLock()
+	defer set.lock.RUnlock()
+
+	return map[string]interface{}{
+		"entryName":            set.entryName,
+		"entryType":            set.entryType,
+		"allowOrigins":         set.allowOrigins,
+		"allowOriginsFile":     set.allowOriginsFile,
+		"fileOrigins":          set.fileOrigins,
+		"allowOriginFunc":      set.allowOriginFunc != nil,
+		"allowMethods":         set.allowMethods,
+		"reflectRequestMethod": set.reflectRequestMethod,
+		"allowHeaders":         set.allowHeaders,
+		"allowCredentials":     set.allowCredentials,
+		"exposeHeaders":        set.exposeHeaders,
+		"maxAge":               set.maxAge,
+		"allowPrivateNetwork":  set.allowPrivateNetwork,
+		"pathToIgnore":         set.pathToIgnore,
+	}
+}
+
 // BeforeCtx should be created before Before()
 func (set *optionSet) BeforeCtx(req *http.Request) *BeforeCtx {
 	ctx := NewBeforeCtx()
@@ -126,8 +206,12 @@ func (set *optionSet) BeforeCtx(req *http.Request) *BeforeCtx {
 	if req != nil && req.URL != nil && req.Header != nil {
 		ctx.Input.UrlPath = req.URL.Path
 		ctx.Input.OriginHeader = req.Header.Get(rkmid.HeaderOrigin)
+		ctx.Input.AccessControlRequestMethod = req.Header.Get(rkmid.HeaderAccessControlRequestMethod)
 		ctx.Input.AccessControlRequestHeaders = req.Header.Get(rkmid.HeaderAccessControlRequestHeaders)
+		ctx.Input.AccessControlRequestPrivateNetwork = strings.EqualFold(
+			strings.TrimSpace(req.Header.Get(rkmid.HeaderAccessControlRequestPrivateNet)), "true")
 		ctx.Input.IsPreflight = req.Method == http.MethodOptions
+		ctx.Input.Request = req
 	}
 
 	return ctx
@@ -139,6 +223,12 @@ func (set *optionSet) Before(ctx *BeforeCtx) {
 		return
 	}
 
+	// Access-Control-Allow-Origin depends on Origin header unless all origins are allowed,
+	// add Vary on all responses including the ones without Origin, so that caches will not serve wrong response
+	if set.varyByOrigin() {
+		ctx.Output.HeaderVary = append(ctx.Output.HeaderVary, rkmid.HeaderOrigin)
+	}
+
 	// case 1: if no origin header was provided, we will return 204 if request is not a OPTION method
 	if ctx.Input.OriginHeader == "" {
 		// 1.1: if not a preflight request, then pass through
@@ -152,14 +242,14 @@ func (set *optionSet) Before(ctx *BeforeCtx) {
 	}
 
 	// case 2: origin not allowed, we will return 204 if request is not a OPTION method
-	if !set.isOriginAllowed(ctx.Input.OriginHeader) {
+	if !set.isOriginAllowed(ctx.Input.OriginHeader) && !set.isSameOriginAllowed(ctx.Input.Request, ctx.Input.OriginHeader) {
 		ctx.Output.Abort = true
 		return
 	}
 
 	// case 3: not a OPTION method
 	if !ctx.Input.IsPreflight {
-		ctx.Output.HeadersToReturn[rkmid.HeaderAccessControlAllowOrigin] = ctx.Input.OriginHeader
+		set.setAllowOrigin(ctx)
 
 		// 3.1: add Access-Control-Allow-Credentials
 		if set.allowCredentials {
@@ -184,8 +274,8 @@ func (set *optionSet) Before(ctx *BeforeCtx) {
 	ctx.Output.HeaderVary = append(ctx.Output.HeaderVary,
 		rkmid.HeaderAccessControlRequestMethod,
 		rkmid.HeaderAccessControlRequestHeaders)
-	ctx.Output.HeadersToReturn[rkmid.HeaderAccessControlAllowOrigin] = ctx.Input.OriginHeader
-	ctx.Output.HeadersToReturn[rkmid.HeaderAccessControlAllowMethods] = strings.Join(set.allowMethods, ",")
+	set.setAllowOrigin(ctx)
+	ctx.Output.HeadersToReturn[rkmid.HeaderAccessControlAllowMethods] = set.getAllowMethods(ctx.Input.AccessControlRequestMethod)
 
 	// 4.1: Access-Control-Allow-Credentials
 	if set.allowCredentials {
@@ -193,7 +283,8 @@ func (set *optionSet) Before(ctx *BeforeCtx) {
 	}
 
 	// 4.2: Access-Control-Allow-Headers
-	if len(set.allowHeaders) > 0 {
+	// wildcard will be treated as the same as empty allowHeaders, since browsers reject literal * with credentials
+	if len(set.allowHeaders) > 0 && !set.isAllowAllHeaders() {
 		ctx.Output.HeadersToReturn[rkmid.HeaderAccessControlAllowHeaders] = strings.Join(set.allowHeaders, ",")
 	} else {
 		if ctx.Input.AccessControlRequestHeaders != "" {
@@ -201,19 +292,139 @@ func (set *optionSet) Before(ctx *BeforeCtx) {
 		}
 	}
 
-	if set.maxAge > 0 {
-		// 4.3: Access-Control-Max-Age
-		ctx.Output.HeadersToReturn[rkmid.HeaderAccessControlMaxAge] = strconv.Itoa(set.maxAge)
+	// 4.3: Access-Control-Max-Age
+	if maxAge := set.getMaxAge(ctx.Input.Request); maxAge > 0 {
+		ctx.Output.HeadersToReturn[rkmid.HeaderAccessControlMaxAge] = strconv.Itoa(maxAge)
+	}
+
+	// 4.4: Access-Control-Allow-Private-Network
+	if set.allowPrivateNetwork {
+		ctx.Output.HeaderVary = append(ctx.Output.HeaderVary, rkmid.HeaderAccessControlRequestPrivateNet)
+		if ctx.Input.AccessControlRequestPrivateNetwork {
+			ctx.Output.HeadersToReturn[rkmid.HeaderAccessControlAllowPrivateNet] = "true"
+		}
 	}
 
 	ctx.Output.Abort = true
 }
 
+// Get max age of request, fallback to static maxAge if maxAgeFunc missing or returns negative value
+func (set *optionSet) getMaxAge(req *http.Request) int {
+	if set.maxAgeFunc != nil && req != nil {
+		if maxAge := set.maxAgeFunc(req); maxAge >= 0 {
+			return maxAge
+		}
+	}
+
+	return set.maxAge
+}
+
+// Get allowed methods returned to preflight request.
+// Only requested method will be returned if reflectRequestMethod enabled and method is allowed,
+// otherwise, fallback to full list of allowMethods.
+func (set *optionSet) getAllowMethods(requestMethod string) string {
+	requestMethod = strings.TrimSpace(requestMethod)
+	if set.reflectRequestMethod && len(requestMethod) > 0 {
+		for i := range set.allowMethods {
+			if strings.EqualFold(set.allowMethods[i], requestMethod) {
+				return set.allowMethods[i]
+			}
+		}
+	}
+
+	return strings.Join(set.allowMethods, ",")
+}
+
+// Check whether wildcard was provided in allowHeaders
+func (set *optionSet) isAllowAllHeaders() bool {
+	for i := range set.allowHeaders {
+		if strings.TrimSpace(set.allowHeaders[i]) == "*" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Close stops reloading of allowOriginsFile, it is registered as shutdown hook if reloading enabled
+func (set *optionSet) Close() {
+	if set.reloadStop == nil {
+		return
+	}
+
+	set.reloadCloseOnce.Do(func() {
+		close(set.reloadStop)
+		<-set.reloadDone
+	})
+}
+
+// Reload origins from file periodically until Close() called, previous origins will be kept if failed to read file
+func (set *optionSet) reloadOriginsFile() {
+	defer close(set.reloadDone)
+
+	ticker := time.NewTicker(set.allowOriginsFileReload)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-set.reloadStop:
+			return
+		}
+
+		origins, err := readOriginsFile(set.allowOriginsFile)
+		if err != nil {
+			rkentry.LoggerEntryStdout.Warn("Failed to reload allowOrigins file",
+				zap.String("path", set.allowOriginsFile),
+				zap.Error(err))
+			continue
+		}
+
+		set.lock.Lock()
+		set.fileOrigins = origins
+		set.lock.Unlock()
+
+		set.toPatterns()
+	}
+}
+
+// Read newline-delimited origins from file, empty lines and lines start with # will be ignored
+func readOriginsFile(path string) ([]string, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]string, 0)
+	scanner := bufio.NewScanner(bytes.NewReader(raw))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) < 1 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		res = append(res, line)
+	}
+
+	return res, scanner.Err()
+}
+
 // Convert allowed origins to patterns
 func (set *optionSet) toPatterns() {
-	set.allowPatterns = []string{}
+	set.lock.Lock()
+	defer set.lock.Unlock()
+
+	set.allowPatterns = []*regexp.Regexp{}
+
+	origins := make([]string, 0, len(set.allowOrigins)+len(set.fileOrigins))
+	origins = append(origins, set.allowOrigins...)
+	origins = append(origins, set.fileOrigins...)
+
+	for _, raw := range origins {
+		// same-origin is checked against request, see isSameOriginAllowed
+		if raw == rkmid.AllowOriginSameOrigin {
+			continue
+		}
 
-	for _, raw := range set.allowOrigins {
 		var result strings.Builder
 		result.WriteString("^")
 		for i, literal := range strings.Split(raw, "*") {
@@ -226,22 +437,84 @@ func (set *optionSet) toPatterns() {
 			result.WriteString(literal)
 		}
 		result.WriteString("$")
-		set.allowPatterns = append(set.allowPatterns, result.String())
+
+		// invalid pattern would never match, skip it
+		if pattern, err := regexp.Compile(result.String()); err == nil {
+			set.allowPatterns = append(set.allowPatterns, pattern)
+		}
 	}
 }
 
 // Check based on origin header
 func (set *optionSet) isOriginAllowed(originHeader string) bool {
-	res := false
+	if set.allowOriginFunc != nil {
+		return set.allowOriginFunc(originHeader)
+	}
+
+	set.lock.RLock()
+	defer set.lock.RUnlock()
 
 	for _, pattern := range set.allowPatterns {
-		res, _ = regexp.MatchString(pattern, originHeader)
-		if res {
+		if pattern.MatchString(originHeader) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isSameOriginAllowed returns true if rkmid.AllowOriginSameOrigin was provided and host of origin equals to host
+// of request. Scheme is not compared since TLS is usually terminated by proxies.
+func (set *optionSet) isSameOriginAllowed(req *http.Request, originHeader string) bool {
+	if set.allowOriginFunc != nil || req == nil {
+		return false
+	}
+
+	set.lock.RLock()
+	allowed := false
+	for _, origin := range append(append([]string{}, set.allowOrigins...), set.fileOrigins...) {
+		if origin == rkmid.AllowOriginSameOrigin {
+			allowed = true
 			break
 		}
 	}
+	set.lock.RUnlock()
 
-	return res
+	if !allowed {
+		return false
+	}
+
+	origin, err := url.Parse(originHeader)
+	if err != nil || len(origin.Host) < 1 {
+		return false
+	}
+
+	return strings.EqualFold(origin.Host, req.Host)
+}
+
+// setAllowOrigin echoes Origin header into Access-Control-Allow-Origin.
+// Vary: Origin is added if missing, since the echoed value differs among origins even if all origins are allowed.
+func (set *optionSet) setAllowOrigin(ctx *BeforeCtx) {
+	ctx.Output.HeadersToReturn[rkmid.HeaderAccessControlAllowOrigin] = ctx.Input.OriginHeader
+
+	for i := range ctx.Output.HeaderVary {
+		if ctx.Output.HeaderVary[i] == rkmid.HeaderOrigin {
+			return
+		}
+	}
+	ctx.Output.HeaderVary = append(ctx.Output.HeaderVary, rkmid.HeaderOrigin)
+}
+
+// varyByOrigin returns false only if allowOrigins is a bare wildcard and allowOriginFunc is not set
+func (set *optionSet) varyByOrigin() bool {
+	if set.allowOriginFunc != nil {
+		return true
+	}
+
+	set.lock.RLock()
+	defer set.lock.RUnlock()
+
+	return len(set.fileOrigins) > 0 || len(set.allowOrigins) != 1 || strings.TrimSpace(set.allowOrigins[0]) != "*"
 }
 
 // ShouldIgnore determine whether auth should be ignored based on path
@@ -278,6 +551,11 @@ func (mock *optionSetMock) GetEntryType() string {
 	return "mock"
 }
 
+// Config returns empty map
+func (mock *optionSetMock) Config() map[string]interface{} {
+	return map[string]interface{}{}
+}
+
 // BeforeCtx should be created before Before()
 func (mock *optionSetMock) BeforeCtx(request *http.Request) *BeforeCtx {
 	return mock.before
@@ -309,7 +587,11 @@ type BeforeCtx struct {
 		UrlPath                     string
 		OriginHeader                string
 		IsPreflight                 bool
+		AccessControlRequestMethod  string
 		AccessControlRequestHeaders string
+		// AccessControlRequestPrivateNetwork is true if Access-Control-Request-Private-Network is true
+		AccessControlRequestPrivateNetwork bool
+		Request                            *http.Request
 	}
 	Output struct {
 		HeadersToReturn map[string]string
@@ -322,21 +604,32 @@ type BeforeCtx struct {
 
 // BootConfig for YAML
 type BootConfig struct {
-	Enabled          bool     `yaml:"enabled" json:"enabled"`
-	AllowOrigins     []string `yaml:"allowOrigins" json:"allowOrigins"`
-	AllowCredentials bool     `yaml:"allowCredentials" json:"allowCredentials"`
-	AllowHeaders     []string `yaml:"allowHeaders" json:"allowHeaders"`
-	AllowMethods     []string `yaml:"allowMethods" json:"allowMethods"`
-	ExposeHeaders    []string `yaml:"exposeHeaders" json:"exposeHeaders"`
-	MaxAge           int      `yaml:"maxAge" json:"maxAge"`
-	Ignore           []string `yaml:"ignore" json:"ignore"`
+	Enabled              bool     `yaml:"enabled" json:"enabled"`
+	Profile              string   `yaml:"profile" json:"profile"`
+	AllowOrigins         []string `yaml:"allowOrigins" json:"allowOrigins"`
+	AllowCredentials     bool     `yaml:"allowCredentials" json:"allowCredentials"`
+	AllowHeaders         []string `yaml:"allowHeaders" json:"allowHeaders"`
+	AllowMethods         []string `yaml:"allowMethods" json:"allowMethods"`
+	ReflectRequestMethod bool     `yaml:"reflectRequestMethod" json:"reflectRequestMethod"`
+	ExposeHeaders        []string `yaml:"exposeHeaders" json:"exposeHeaders"`
+	MaxAge               int      `yaml:"maxAge" json:"maxAge"`
+	AllowPrivateNetwork  bool     `yaml:"allowPrivateNetwork" json:"allowPrivateNetwork"`
+	Ignore               []string `yaml:"ignore" json:"ignore"`
+	AllowOriginsFile     struct {
+		Path     string `yaml:"path" json:"path"`
+		ReloadMs int    `yaml:"reloadMs" json:"reloadMs"`
+	} `yaml:"allowOriginsFile" json:"allowOriginsFile"`
 }
 
 // ToOptions convert BootConfig into Option list
+//
+// If Profile provided, values of rkmid.SecurityProfile will be used as defaults of empty fields.
 func ToOptions(config *BootConfig, entryName, entryType string) []Option {
 	opts := make([]Option, 0)
 
 	if config.Enabled {
+		config = withSecurityProfile(config)
+
 		opts = append(opts,
 			WithEntryNameAndType(entryName, entryType),
 			WithAllowOrigins(config.AllowOrigins...),
@@ -345,12 +638,56 @@ func ToOptions(config *BootConfig, entryName, entryType string) []Option {
 			WithMaxAge(config.MaxAge),
 			WithAllowHeaders(config.AllowHeaders...),
 			WithAllowMethods(config.AllowMethods...),
+			WithReflectRequestMethod(config.ReflectRequestMethod),
+			WithAllowPrivateNetwork(config.AllowPrivateNetwork),
 			WithPathToIgnore(config.Ignore...))
+
+		if len(config.AllowOriginsFile.Path) > 0 {
+			opts = append(opts, WithAllowOriginsFile(
+				config.AllowOriginsFile.Path,
+				time.Duration(config.AllowOriginsFile.ReloadMs)*time.Millisecond))
+		}
 	}
 
 	return opts
 }
 
+// Fill empty fields of BootConfig with security profile, application will shutdown if profile not found.
+// Boolean fields enabled in profile could not be disabled by BootConfig, register a custom profile instead.
+func withSecurityProfile(config *BootConfig) *BootConfig {
+	if len(config.Profile) < 1 {
+		return config
+	}
+
+	profile := rkmid.GetSecurityProfile(config.Profile)
+	if profile == nil {
+		rkentry.ShutdownWithError(fmt.Errorf("security profile not found:%s", config.Profile))
+		return config
+	}
+
+	res := *config
+	p := profile.Cors
+
+	if len(res.AllowOrigins) < 1 {
+		res.AllowOrigins = p.AllowOrigins
+	}
+	if len(res.AllowHeaders) < 1 {
+		res.AllowHeaders = p.AllowHeaders
+	}
+	if len(res.AllowMethods) < 1 {
+		res.AllowMethods = p.AllowMethods
+	}
+	if len(res.ExposeHeaders) < 1 {
+		res.ExposeHeaders = p.ExposeHeaders
+	}
+	if res.MaxAge == 0 {
+		res.MaxAge = p.MaxAge
+	}
+	res.AllowCredentials = res.AllowCredentials || p.AllowCredentials
+
+	return &res
+}
+
 // ***************** Option *****************
 
 // Option
@@ -371,6 +708,25 @@ func WithAllowOrigins(origins ...string) Option {
 	}
 }
 
+// WithAllowOriginFunc provide function which decides whether origin is allowed per request,
+// which is useful for dynamic allow lists. It takes precedence over static origins.
+func WithAllowOriginFunc(f func(origin string) bool) Option {
+	return func(opt *optionSet) {
+		if f != nil {
+			opt.allowOriginFunc = f
+		}
+	}
+}
+
+// WithAllowOriginsFile provide a newline-delimited file which contains allowed origins.
+// File will be reloaded with interval of reload if reload is larger than zero.
+func WithAllowOriginsFile(path string, reload time.Duration) Option {
+	return func(opt *optionSet) {
+		opt.allowOriginsFile = path
+		opt.allowOriginsFileReload = reload
+	}
+}
+
 // WithAllowMethods provide allowed http methods
 func WithAllowMethods(methods ...string) Option {
 	return func(opt *optionSet) {
@@ -378,6 +734,14 @@ func WithAllowMethods(methods ...string) Option {
 	}
 }
 
+// WithReflectRequestMethod returns only the method requested via Access-Control-Request-Method
+// in response to a preflight request if it is in allowed methods.
+func WithReflectRequestMethod(reflect bool) Option {
+	return func(opt *optionSet) {
+		opt.reflectRequestMethod = reflect
+	}
+}
+
 // WithAllowHeaders provide allowed headers
 func WithAllowHeaders(headers ...string) Option {
 	return func(opt *optionSet) {
@@ -406,6 +770,24 @@ func WithMaxAge(age int) Option {
 	}
 }
 
+// WithMaxAgeFunc provide function which returns max age per request.
+// Negative value returned will be ignored and static max age will be used.
+func WithMaxAgeFunc(f func(*http.Request) int) Option {
+	return func(opt *optionSet) {
+		if f != nil {
+			opt.maxAgeFunc = f
+		}
+	}
+}
+
+// WithAllowPrivateNetwork provide whether to respond Access-Control-Allow-Private-Network to preflight requests
+// with Access-Control-Request-Private-Network of true, which is required by Private Network Access.
+func WithAllowPrivateNetwork(allow bool) Option {
+	return func(opt *optionSet) {
+		opt.allowPrivateNetwork = allow
+	}
+}
+
 // WithPathToIgnore provide paths prefix that will ignore.
 func WithPathToIgnore(paths ...string) Option {
 	return func(set *optionSet) {
@@ -1,11 +1,16 @@
 package rkmidcors
 
 import (
+	"github.com/rookie-ninja/rk-entry/v2/entry"
 	"github.com/rookie-ninja/rk-entry/v2/middleware"
 	"github.com/stretchr/testify/assert"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestToOptions(t *testing.T) {
@@ -21,6 +26,57 @@ func TestToOptions(t *testing.T) {
 	assert.NotEmpty(t, ToOptions(config, "", ""))
 }
 
+func TestToOptions_WithProfile(t *testing.T) {
+	// with profile, explicit values should override profile
+	config := &BootConfig{
+		Enabled:      true,
+		Profile:      rkmid.SecurityProfileStrict,
+		AllowOrigins: []string{"http://ut.com"},
+	}
+	set := NewOptionSet(ToOptions(config, "", "")...).(*optionSet)
+	assert.Equal(t, []string{"http://ut.com"}, set.allowOrigins)
+	assert.Equal(t, []string{"GET", "HEAD", "POST"}, set.allowMethods)
+	assert.Equal(t, 600, set.maxAge)
+
+	// with unknown profile
+	config.Profile = "ut-non-exist"
+	assert.Panics(t, func() {
+		ToOptions(config, "", "")
+	})
+
+	// with strict profile, cross-origin request should be refused
+	config = &BootConfig{
+		Enabled: true,
+		Profile: rkmid.SecurityProfileStrict,
+	}
+	set = NewOptionSet(ToOptions(config, "", "")...).(*optionSet)
+
+	req := httptest.NewRequest(http.MethodGet, "http://ut.com/ut-path", nil)
+	req.Header.Set(rkmid.HeaderOrigin, "http://evil.com")
+	ctx := set.BeforeCtx(req)
+	set.Before(ctx)
+	assert.True(t, ctx.Output.Abort)
+	assert.Empty(t, ctx.Output.HeadersToReturn[rkmid.HeaderAccessControlAllowOrigin])
+
+	// same-origin request is allowed
+	req.Header.Set(rkmid.HeaderOrigin, "https://ut.com")
+	ctx = set.BeforeCtx(req)
+	set.Before(ctx)
+	assert.False(t, ctx.Output.Abort)
+	assert.Equal(t, "https://ut.com", ctx.Output.HeadersToReturn[rkmid.HeaderAccessControlAllowOrigin])
+
+	// with boolean enabled in profile
+	profile := &rkmid.SecurityProfile{}
+	profile.Cors.AllowCredentials = true
+	rkmid.RegisterSecurityProfile("ut-cors-profile", profile)
+	config = &BootConfig{
+		Enabled: true,
+		Profile: "ut-cors-profile",
+	}
+	set = NewOptionSet(ToOptions(config, "", "")...).(*optionSet)
+	assert.True(t, set.allowCredentials)
+}
+
 func TestNewOptionSet(t *testing.T) {
 	// without options
 	set := NewOptionSet().(*optionSet)
@@ -55,6 +111,46 @@ func TestNewOptionSet(t *testing.T) {
 	assert.Equal(t, 1, set.maxAge)
 }
 
+func TestWithAllowOriginsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "origins")
+	assert.Nil(t, os.WriteFile(path, []byte("# comment\nhttp://ut-one.com\n\nhttp://*.ut-two.com\n"), 0644))
+
+	set := NewOptionSet(
+		WithEntryNameAndType("ut-reload", "ut-type"),
+		WithAllowOriginsFile(path, 10*time.Millisecond)).(*optionSet)
+	defer rkentry.GlobalAppCtx.RemoveShutdownHook("rk-cors-reload-ut-reload")
+	assert.Empty(t, set.allowOrigins)
+	assert.True(t, set.isOriginAllowed("http://ut-one.com"))
+	assert.True(t, set.isOriginAllowed("http://sub.ut-two.com"))
+	assert.False(t, set.isOriginAllowed("http://ut-three.com"))
+
+	// update file and wait for reload
+	assert.Nil(t, os.WriteFile(path, []byte("http://ut-three.com"), 0644))
+	assert.Eventually(t, func() bool {
+		return set.isOriginAllowed("http://ut-three.com")
+	}, time.Second, 10*time.Millisecond)
+	assert.False(t, set.isOriginAllowed("http://ut-one.com"))
+
+	// with shutdown hook, reloading should be stopped
+	hook := rkentry.GlobalAppCtx.GetShutdownHook("rk-cors-reload-ut-reload")
+	assert.NotNil(t, hook)
+	hook()
+	select {
+	case <-set.reloadDone:
+	case <-time.After(time.Second):
+		assert.Fail(t, "reloading not stopped")
+	}
+
+	// close twice should be no-op
+	set.Close()
+}
+
+func TestReadOriginsFile(t *testing.T) {
+	// with non exist file
+	_, err := readOriginsFile(filepath.Join(t.TempDir(), "not-exist"))
+	assert.NotNil(t, err)
+}
+
 func TestOptionSet_BeforeCtx(t *testing.T) {
 	// with nil req
 	set := NewOptionSet()
@@ -108,6 +204,41 @@ func TestOptionSet_isOriginAllowed(t *testing.T) {
 	assert.True(t, set.isOriginAllowed("http://ut.domain.sub.sub"))
 	assert.False(t, set.isOriginAllowed("http://ut.domain"))
 	assert.False(t, set.isOriginAllowed("http://ut.another"))
+
+	// 6: invalid pattern should be skipped
+	set.allowOrigins = []string{"http://ut.(domain", "http://ut.another"}
+	set.toPatterns()
+	assert.Len(t, set.allowPatterns, 1)
+	assert.False(t, set.isOriginAllowed("http://ut.(domain"))
+	assert.True(t, set.isOriginAllowed("http://ut.another"))
+}
+
+func TestWithAllowOriginFunc(t *testing.T) {
+	set := NewOptionSet(
+		WithAllowOrigins("http://ut.static"),
+		WithAllowOriginFunc(func(origin string) bool {
+			return strings.HasSuffix(origin, ".tenant.domain")
+		})).(*optionSet)
+	assert.True(t, set.Config()["allowOriginFunc"].(bool))
+
+	// function takes precedence over static origins
+	assert.True(t, set.isOriginAllowed("http://ut.tenant.domain"))
+	assert.False(t, set.isOriginAllowed("http://ut.static"))
+
+	ctx := set.BeforeCtx(newReq(http.MethodGet, header{Key: rkmid.HeaderOrigin, Value: "http://ut.tenant.domain"}))
+	set.Before(ctx)
+	assert.False(t, ctx.Output.Abort)
+	assert.Equal(t, "http://ut.tenant.domain", ctx.Output.HeadersToReturn[rkmid.HeaderAccessControlAllowOrigin])
+	assert.Contains(t, ctx.Output.HeaderVary, rkmid.HeaderOrigin)
+
+	ctx = set.BeforeCtx(newReq(http.MethodGet, header{Key: rkmid.HeaderOrigin, Value: "http://ut.static"}))
+	set.Before(ctx)
+	assert.True(t, ctx.Output.Abort)
+
+	// nil function should be ignored
+	set = NewOptionSet(WithAllowOriginFunc(nil)).(*optionSet)
+	assert.Nil(t, set.allowOriginFunc)
+	assert.True(t, set.isOriginAllowed("http://ut.any"))
 }
 
 func newReq(method string, headers ...header) *http.Request {
@@ -186,7 +317,7 @@ func TestOptionSet_Before(t *testing.T) {
 	ctx = set.BeforeCtx(req)
 	set.Before(ctx)
 	assert.True(t, ctx.Output.Abort)
-	assert.Len(t, ctx.Output.HeaderVary, 2)
+	assert.Len(t, ctx.Output.HeaderVary, 3)
 	assert.Equal(t, originHeaderValue, ctx.Output.HeadersToReturn[rkmid.HeaderAccessControlAllowOrigin])
 
 	// match 4.1
@@ -195,7 +326,7 @@ func TestOptionSet_Before(t *testing.T) {
 	ctx = set.BeforeCtx(req)
 	set.Before(ctx)
 	assert.True(t, ctx.Output.Abort)
-	assert.Len(t, ctx.Output.HeaderVary, 2)
+	assert.Len(t, ctx.Output.HeaderVary, 3)
 	assert.Equal(t, originHeaderValue, ctx.Output.HeadersToReturn[rkmid.HeaderAccessControlAllowOrigin])
 	assert.NotEmpty(t, originHeaderValue, ctx.Output.HeadersToReturn[rkmid.HeaderAccessControlAllowMethods])
 	assert.Equal(t, "true", ctx.Output.HeadersToReturn[rkmid.HeaderAccessControlAllowCredentials])
@@ -206,23 +337,191 @@ func TestOptionSet_Before(t *testing.T) {
 	ctx = set.BeforeCtx(req)
 	set.Before(ctx)
 	assert.True(t, ctx.Output.Abort)
-	assert.Len(t, ctx.Output.HeaderVary, 2)
+	assert.Len(t, ctx.Output.HeaderVary, 3)
 	assert.Equal(t, originHeaderValue, ctx.Output.HeadersToReturn[rkmid.HeaderAccessControlAllowOrigin])
 	assert.NotEmpty(t, originHeaderValue, ctx.Output.HeadersToReturn[rkmid.HeaderAccessControlAllowMethods])
 	assert.Equal(t, "ut-header", ctx.Output.HeadersToReturn[rkmid.HeaderAccessControlAllowHeaders])
 
+	// match 4.2 with wildcard
+	set = NewOptionSet(WithAllowHeaders("*"), WithAllowCredentials(true))
+	req = newReq(http.MethodOptions,
+		header{rkmid.HeaderOrigin, originHeaderValue},
+		header{rkmid.HeaderAccessControlRequestHeaders, "X-Ut-One,X-Ut-Two"})
+	ctx = set.BeforeCtx(req)
+	set.Before(ctx)
+	assert.True(t, ctx.Output.Abort)
+	assert.Equal(t, "X-Ut-One,X-Ut-Two", ctx.Output.HeadersToReturn[rkmid.HeaderAccessControlAllowHeaders])
+
 	// match 4.3
 	set = NewOptionSet(WithMaxAge(1))
 	req = newReq(http.MethodOptions, header{rkmid.HeaderOrigin, originHeaderValue})
 	ctx = set.BeforeCtx(req)
 	set.Before(ctx)
 	assert.True(t, ctx.Output.Abort)
-	assert.Len(t, ctx.Output.HeaderVary, 2)
+	assert.Len(t, ctx.Output.HeaderVary, 3)
 	assert.Equal(t, originHeaderValue, ctx.Output.HeadersToReturn[rkmid.HeaderAccessControlAllowOrigin])
 	assert.NotEmpty(t, originHeaderValue, ctx.Output.HeadersToReturn[rkmid.HeaderAccessControlAllowMethods])
 	assert.Equal(t, "1", ctx.Output.HeadersToReturn[rkmid.HeaderAccessControlMaxAge])
 }
 
+func TestOptionSet_Before_VaryOrigin(t *testing.T) {
+	// with bare wildcard
+	set := NewOptionSet()
+	ctx := set.BeforeCtx(newReq(http.MethodGet, header{rkmid.HeaderOrigin, "http://ut-origin"}))
+	set.Before(ctx)
+	assert.Equal(t, []string{rkmid.HeaderOrigin}, ctx.Output.HeaderVary)
+	assert.Equal(t, "http://ut-origin", ctx.Output.HeadersToReturn[rkmid.HeaderAccessControlAllowOrigin])
+
+	// with bare wildcard and without origin
+	ctx = set.BeforeCtx(newReq(http.MethodGet))
+	set.Before(ctx)
+	assert.Empty(t, ctx.Output.HeaderVary)
+
+	// with bare wildcard and preflight
+	ctx = set.BeforeCtx(newReq(http.MethodOptions, header{rkmid.HeaderOrigin, "http://ut-origin"}))
+	set.Before(ctx)
+	assert.Contains(t, ctx.Output.HeaderVary, rkmid.HeaderOrigin)
+	assert.Len(t, ctx.Output.HeaderVary, 3)
+
+	set = NewOptionSet(WithAllowOrigins("http://ut-origin", "http://ut-other"))
+
+	// with matched origin
+	ctx = set.BeforeCtx(newReq(http.MethodGet, header{rkmid.HeaderOrigin, "http://ut-other"}))
+	set.Before(ctx)
+	assert.Equal(t, []string{rkmid.HeaderOrigin}, ctx.Output.HeaderVary)
+	assert.Equal(t, "http://ut-other", ctx.Output.HeadersToReturn[rkmid.HeaderAccessControlAllowOrigin])
+
+	// without origin, response may be cached and served to cross-origin requests
+	ctx = set.BeforeCtx(newReq(http.MethodGet))
+	set.Before(ctx)
+	assert.Equal(t, []string{rkmid.HeaderOrigin}, ctx.Output.HeaderVary)
+
+	// with origin not allowed
+	ctx = set.BeforeCtx(newReq(http.MethodGet, header{rkmid.HeaderOrigin, "http://ut-evil"}))
+	set.Before(ctx)
+	assert.Equal(t, []string{rkmid.HeaderOrigin}, ctx.Output.HeaderVary)
+
+	// with preflight
+	ctx = set.BeforeCtx(newReq(http.MethodOptions, header{rkmid.HeaderOrigin, "http://ut-origin"}))
+	set.Before(ctx)
+	assert.Contains(t, ctx.Output.HeaderVary, rkmid.HeaderOrigin)
+	assert.Len(t, ctx.Output.HeaderVary, 3)
+
+	// with ignored path
+	set = NewOptionSet(WithAllowOrigins("http://ut-origin"), WithPathToIgnore("/ut"))
+	ctx = set.BeforeCtx(newReq(http.MethodGet, header{rkmid.HeaderOrigin, "http://ut-origin"}))
+	set.Before(ctx)
+	assert.Empty(t, ctx.Output.HeaderVary)
+}
+
+func TestWithMaxAgeFunc(t *testing.T) {
+	originHeaderValue := "http://ut-origin"
+	set := NewOptionSet(
+		WithMaxAge(10),
+		WithMaxAgeFunc(func(req *http.Request) int {
+			switch req.Header.Get("X-Ut-Case") {
+			case "short":
+				return 1
+			case "negative":
+				return -1
+			}
+			return 0
+		}))
+
+	// with dynamic max age
+	req := newReq(http.MethodOptions, header{rkmid.HeaderOrigin, originHeaderValue}, header{"X-Ut-Case", "short"})
+	ctx := set.BeforeCtx(req)
+	set.Before(ctx)
+	assert.Equal(t, "1", ctx.Output.HeadersToReturn[rkmid.HeaderAccessControlMaxAge])
+
+	// with negative max age, fallback to static one
+	req = newReq(http.MethodOptions, header{rkmid.HeaderOrigin, originHeaderValue}, header{"X-Ut-Case", "negative"})
+	ctx = set.BeforeCtx(req)
+	set.Before(ctx)
+	assert.Equal(t, "10", ctx.Output.HeadersToReturn[rkmid.HeaderAccessControlMaxAge])
+
+	// with zero max age, header should be omitted
+	req = newReq(http.MethodOptions, header{rkmid.HeaderOrigin, originHeaderValue})
+	ctx = set.BeforeCtx(req)
+	set.Before(ctx)
+	assert.NotContains(t, ctx.Output.HeadersToReturn, rkmid.HeaderAccessControlMaxAge)
+}
+
+func TestWithReflectRequestMethod(t *testing.T) {
+	originHeaderValue := "http://ut-origin"
+
+	// without option, full list should be returned
+	set := NewOptionSet(WithAllowMethods(http.MethodGet, http.MethodPost))
+	req := newReq(http.MethodOptions,
+		header{rkmid.HeaderOrigin, originHeaderValue},
+		header{rkmid.HeaderAccessControlRequestMethod, http.MethodPost})
+	ctx := set.BeforeCtx(req)
+	set.Before(ctx)
+	assert.Equal(t, "GET,POST", ctx.Output.HeadersToReturn[rkmid.HeaderAccessControlAllowMethods])
+
+	// with allowed method requested
+	set = NewOptionSet(
+		WithAllowMethods(http.MethodGet, http.MethodPost),
+		WithReflectRequestMethod(true))
+	ctx = set.BeforeCtx(req)
+	set.Before(ctx)
+	assert.Equal(t, http.MethodPost, ctx.Output.HeadersToReturn[rkmid.HeaderAccessControlAllowMethods])
+
+	// with not allowed method requested, fallback to full list
+	req = newReq(http.MethodOptions,
+		header{rkmid.HeaderOrigin, originHeaderValue},
+		header{rkmid.HeaderAccessControlRequestMethod, http.MethodDelete})
+	ctx = set.BeforeCtx(req)
+	set.Before(ctx)
+	assert.Equal(t, "GET,POST", ctx.Output.HeadersToReturn[rkmid.HeaderAccessControlAllowMethods])
+
+	// without requested method, fallback to full list
+	req = newReq(http.MethodOptions, header{rkmid.HeaderOrigin, originHeaderValue})
+	ctx = set.BeforeCtx(req)
+	set.Before(ctx)
+	assert.Equal(t, "GET,POST", ctx.Output.HeadersToReturn[rkmid.HeaderAccessControlAllowMethods])
+}
+
+func TestWithAllowPrivateNetwork(t *testing.T) {
+	originHeaderValue := "http://ut-origin"
+	req := newReq(http.MethodOptions,
+		header{rkmid.HeaderOrigin, originHeaderValue},
+		header{rkmid.HeaderAccessControlRequestMethod, http.MethodGet},
+		header{rkmid.HeaderAccessControlRequestPrivateNet, "true"})
+
+	// without option
+	set := NewOptionSet()
+	ctx := set.BeforeCtx(req)
+	assert.True(t, ctx.Input.AccessControlRequestPrivateNetwork)
+	set.Before(ctx)
+	assert.True(t, ctx.Output.Abort)
+	assert.NotContains(t, ctx.Output.HeadersToReturn, rkmid.HeaderAccessControlAllowPrivateNet)
+
+	// with option from boot config
+	set = NewOptionSet(ToOptions(&BootConfig{Enabled: true, AllowPrivateNetwork: true}, "", "")...)
+	assert.True(t, set.Config()["allowPrivateNetwork"].(bool))
+	ctx = set.BeforeCtx(req)
+	set.Before(ctx)
+	assert.True(t, ctx.Output.Abort)
+	assert.Equal(t, "true", ctx.Output.HeadersToReturn[rkmid.HeaderAccessControlAllowPrivateNet])
+	assert.Contains(t, ctx.Output.HeaderVary, rkmid.HeaderAccessControlRequestPrivateNet)
+
+	// preflight without request header
+	ctx = set.BeforeCtx(newReq(http.MethodOptions,
+		header{rkmid.HeaderOrigin, originHeaderValue},
+		header{rkmid.HeaderAccessControlRequestMethod, http.MethodGet}))
+	set.Before(ctx)
+	assert.NotContains(t, ctx.Output.HeadersToReturn, rkmid.HeaderAccessControlAllowPrivateNet)
+
+	// not a preflight request
+	ctx = set.BeforeCtx(newReq(http.MethodGet,
+		header{rkmid.HeaderOrigin, originHeaderValue},
+		header{rkmid.HeaderAccessControlRequestPrivateNet, "true"}))
+	set.Before(ctx)
+	assert.False(t, ctx.Output.Abort)
+	assert.NotContains(t, ctx.Output.HeadersToReturn, rkmid.HeaderAccessControlAllowPrivateNet)
+}
+
 func TestNewOptionSetMock(t *testing.T) {
 	mock := NewOptionSetMock(NewBeforeCtx())
 	assert.NotEmpty(t, mock.GetEntryName())
@@ -230,3 +529,11 @@ func TestNewOptionSetMock(t *testing.T) {
 	assert.NotNil(t, mock.BeforeCtx(nil))
 	mock.Before(nil)
 }
+
+func TestOptionSet_Config(t *testing.T) {
+	set := NewOptionSet(WithEntryNameAndType("ut-config", "ut-type"))
+	config := set.Config()
+	assert.Equal(t, "ut-config", config["entryName"])
+	assert.Equal(t, "ut-type", config["entryType"])
+	assert.Contains(t, config["allowOrigins"], "*")
+}
@@ -8,19 +8,28 @@ package rkmidcsrf
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
 	"crypto/subtle"
+	"encoding/base64"
 	"errors"
+	"fmt"
+	"github.com/rookie-ninja/rk-entry/v2/entry"
 	"github.com/rookie-ninja/rk-entry/v2/error"
 	"github.com/rookie-ninja/rk-entry/v2/middleware"
 	"math/rand"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 )
 
 const letterBytes = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
 
+// CookiePrefixHost is prefix of cookie name which requires cookie to be secure, host only and scoped to root path
+const CookiePrefixHost = "__Host-"
+
 // RandString generate random string.
 func randString(n int) string {
 	b := make([]byte, n)
@@ -44,6 +53,10 @@ type OptionSetInterface interface {
 	BeforeCtx(*http.Request) *BeforeCtx
 
 	ShouldIgnore(string) bool
+
+	ClearCookie() *http.Cookie
+
+	Config() map[string]interface{}
 }
 
 // ***************** OptionSet Implementation *****************
@@ -84,6 +97,8 @@ type optionSet struct {
 	cookiePath string
 
 	// CookieMaxAge Max age (in seconds) of the CSRF cookie.
+	// CSRF cookie is always persistent, zero or negative value will be ignored and default value will be used.
+	// Use ClearCookie() to delete CSRF cookie.
 	// Optional. Default value 86400 (24hr).
 	cookieMaxAge int
 
@@ -91,14 +106,50 @@ type optionSet struct {
 	// Optional. Default value false.
 	cookieHTTPOnly bool
 
+	// CookieSecure Indicates if CSRF cookie is sent over HTTPS only.
+	// It is always true if SameSite is None or cookie name begins with CookiePrefixHost.
+	// Optional. Default value false.
+	cookieSecure bool
+
 	// CookieSameSite Indicates SameSite mode of the CSRF cookie.
 	// Optional. Default value SameSiteDefaultMode.
 	cookieSameSite http.SameSite
 
+	// CookieTokenHeader Name of response header which carries the token stored in CSRF cookie,
+	// so that clients could echo it back without parsing cookie.
+	// Header carries decoded token which differs from cookie value if cookieTransformer is set.
+	// Optional. Default value empty, which means token will not be written into response header.
+	cookieTokenHeader string
+
 	extractor csrfHttpExtractor
 
 	userExtractor CsrfExtractor
 
+	// CookieTransformer transforms token while writing and reading CSRF cookie.
+	// Optional. Default value nil, which means token will be stored in cookie as it is.
+	cookieTransformer CookieValueTransformer
+
+	// key of built-in HMAC transformer, token older than cookieMaxAge will be rejected
+	cookieHmacKey []byte
+
+	// TrustedOrigins is a list of origins in the form of "<scheme>://<host>[:port]".
+	// Unsafe requests whose Origin or Referer header is not in the list will be rejected.
+	// Optional. Default value empty, which means Origin and Referer will not be checked.
+	trustedOrigins []string
+
+	// Skipper returns true if token of request should not be validated, in addition to safe methods.
+	// Optional. Default value nil.
+	skipper func(*http.Request) bool
+
+	// SkipMethods are http methods whose token will not be validated, in addition to safe methods.
+	// Optional. Default value empty.
+	skipMethods []string
+
+	// SkipPaths are path prefixes whose token will not be validated.
+	// Unlike pathToIgnore, CSRF cookie will still be set for requests of them.
+	// Optional. Default value empty.
+	skipPaths []string
+
 	mock OptionSetInterface
 }
 
@@ -123,6 +174,27 @@ func NewOptionSet(opts ...Option) OptionSetInterface {
 		return set.mock
 	}
 
+	// browsers reject __Host- cookie unless it is secure, host only and scoped to root path
+	if strings.HasPrefix(set.cookieName, CookiePrefixHost) {
+		if len(set.cookieDomain) > 0 {
+			rkentry.ShutdownWithError(fmt.Errorf("domain of cookie with %s prefix must be empty, domain:%s",
+				CookiePrefixHost, set.cookieDomain))
+		}
+
+		if len(set.cookiePath) > 0 && set.cookiePath != "/" {
+			rkentry.ShutdownWithError(fmt.Errorf("path of cookie with %s prefix must be /, path:%s",
+				CookiePrefixHost, set.cookiePath))
+		}
+
+		set.cookiePath = "/"
+		set.cookieSecure = true
+	}
+
+	// user provided transformer takes precedence
+	if set.cookieTransformer == nil && len(set.cookieHmacKey) > 0 {
+		set.cookieTransformer = NewHmacCookieValueTransformer(set.cookieHmacKey, time.Duration(set.cookieMaxAge)*time.Second)
+	}
+
 	// initialize extractor
 	parts := strings.Split(set.tokenLookup, ":")
 	set.extractor = csrfTokenFromHeader(parts[1])
@@ -148,6 +220,30 @@ func (set *optionSet) GetEntryType() string {
 	return set.entryType
 }
 
+// Config returns effective configuration, secrets are redacted
+func (set *optionSet) Config() map[string]interface{} {
+	return map[string]interface{}{
+		"entryName":         set.entryName,
+		"entryType":         set.entryType,
+		"tokenLength":       set.tokenLength,
+		"tokenLookup":       set.tokenLookup,
+		"cookieName":        set.cookieName,
+		"cookieDomain":      set.cookieDomain,
+		"cookiePath":        set.cookiePath,
+		"cookieMaxAge":      set.cookieMaxAge,
+		"cookieHttpOnly":    set.cookieHTTPOnly,
+		"cookieSecure":      set.cookieSecure,
+		"cookieSameSite":    set.cookieSameSite,
+		"cookieTokenHeader": set.cookieTokenHeader,
+		"cookieTransformer": set.cookieTransformer != nil,
+		"trustedOrigins":    set.trustedOrigins,
+		"skipper":           set.skipper != nil,
+		"skipMethods":       set.skipMethods,
+		"skipPaths":         set.skipPaths,
+		"pathToIgnore":      set.pathToIgnore,
+	}
+}
+
 // BeforeCtx should be created before Before()
 func (set *optionSet) BeforeCtx(req *http.Request) *BeforeCtx {
 	ctx := NewBeforeCtx()
@@ -160,6 +256,15 @@ func (set *optionSet) BeforeCtx(req *http.Request) *BeforeCtx {
 		} else {
 			ctx.Input.Token, _ = url.QueryUnescape(cookie.Value)
 		}
+
+		// invalid or expired cookie will be treated as missing
+		if set.cookieTransformer != nil && ctx.Input.Token != "" {
+			if token, err := set.cookieTransformer.Decode(ctx.Input.Token); err != nil {
+				ctx.Input.Token = randString(set.tokenLength)
+			} else {
+				ctx.Input.Token = token
+			}
+		}
 		ctx.Input.Request = req
 	}
 
@@ -173,13 +278,22 @@ func (set *optionSet) Before(ctx *BeforeCtx) {
 		return
 	}
 
-	// 3.1: do not check http methods of GET, HEAD, OPTIONS and TRACE
-	switch ctx.Input.Method {
-	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
-	default:
+	defer func() {
+		ctx.Output.ErrResp = rkmid.AttachTraceId(ctx.Input.Request, ctx.Input.UserCtx, ctx.Output.ErrResp)
+	}()
+
+	// 3.1: do not check http methods of GET, HEAD, OPTIONS and TRACE, and requests exempted by user
+	if !set.isExempted(ctx) {
+		// 3.2: reject requests from untrusted origin, before checking token
+		if origin, ok := set.isTrustedOrigin(ctx.Input.Request); !ok {
+			ctx.Output.ErrResp = rkmid.GetErrorBuilder().New(http.StatusForbidden, "Untrusted request origin",
+				fmt.Errorf("untrusted origin:%s", origin))
+			return
+		}
+
 		var clientToken string
 		var err error
-		// 3.2: validate token only for requests which are not defined as 'safe' by RFC7231
+		// 3.3: validate token only for requests which are not defined as 'safe' by RFC7231
 		if set.userExtractor != nil {
 			clientToken, err = set.userExtractor(ctx.Input.UserCtx)
 		} else {
@@ -191,7 +305,7 @@ func (set *optionSet) Before(ctx *BeforeCtx) {
 			return
 		}
 
-		// 3.3: return 403 to client if token is not matched
+		// 3.4: return 403 to client if token is not matched
 		if !set.isValidToken(ctx.Input.Token, clientToken) {
 			ctx.Output.ErrResp = rkmid.GetErrorBuilder().New(http.StatusForbidden, "Invalid csrf token")
 			return
@@ -199,9 +313,72 @@ func (set *optionSet) Before(ctx *BeforeCtx) {
 	}
 
 	// set CSRF cookie
+	value := ctx.Input.Token
+	if set.cookieTransformer != nil {
+		var err error
+		if value, err = set.cookieTransformer.Encode(ctx.Input.Token); err != nil {
+			ctx.Output.ErrResp = rkmid.GetErrorBuilder().New(http.StatusInternalServerError, "Failed to encode csrf cookie", err)
+			return
+		}
+	}
+
+	cookie := set.newCookie(value)
+	// set both Expires and Max-Age, otherwise, browser will treat it as session cookie
+	cookie.Expires = time.Now().Add(time.Duration(set.cookieMaxAge) * time.Second)
+	cookie.MaxAge = set.cookieMaxAge
+	ctx.Output.Cookie = cookie
+
+	ctx.Output.VaryHeaders = append(ctx.Output.VaryHeaders, rkmid.HeaderCookie)
+
+	// surface token for clients who echo it back, decoded token is used instead of cookie value
+	// since extracted token is compared with decoded cookie, see WithCookieTokenHeader for details
+	if len(set.cookieTokenHeader) > 0 {
+		ctx.Output.HeadersToReturn[set.cookieTokenHeader] = ctx.Input.Token
+		ctx.Output.VaryHeaders = append(ctx.Output.VaryHeaders, set.cookieTokenHeader)
+	}
+}
+
+// isExempted returns true if token of request should not be validated.
+// Requests with safe methods defined by RFC7231 are always exempted, CSRF cookie will be set for exempted requests as well.
+func (set *optionSet) isExempted(ctx *BeforeCtx) bool {
+	switch ctx.Input.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+
+	for i := range set.skipMethods {
+		if strings.EqualFold(ctx.Input.Method, set.skipMethods[i]) {
+			return true
+		}
+	}
+
+	for i := range set.skipPaths {
+		if strings.HasPrefix(ctx.Input.UrlPath, set.skipPaths[i]) {
+			return true
+		}
+	}
+
+	return set.skipper != nil && ctx.Input.Request != nil && set.skipper(ctx.Input.Request)
+}
+
+// ClearCookie returns CSRF cookie which instructs browser to delete it.
+// Handlers could set it into response, for example, while user logout.
+//
+// Notice: MaxAge of http.Cookie with negative value means "Max-Age=0", which deletes cookie immediately,
+// while zero value means no Max-Age attribute, which results in session cookie.
+func (set *optionSet) ClearCookie() *http.Cookie {
+	cookie := set.newCookie("")
+	cookie.Expires = time.Unix(0, 0)
+	cookie.MaxAge = -1
+
+	return cookie
+}
+
+// newCookie creates CSRF cookie with configured attributes except expiration
+func (set *optionSet) newCookie(value string) *http.Cookie {
 	cookie := new(http.Cookie)
 	cookie.Name = set.cookieName
-	cookie.Value = ctx.Input.Token
+	cookie.Value = value
 	// 4.1
 	if set.cookiePath != "" {
 		cookie.Path = set.cookiePath
@@ -214,12 +391,10 @@ func (set *optionSet) Before(ctx *BeforeCtx) {
 	if set.cookieSameSite != http.SameSiteDefaultMode {
 		cookie.SameSite = set.cookieSameSite
 	}
-	cookie.Expires = time.Now().Add(time.Duration(set.cookieMaxAge) * time.Second)
-	cookie.Secure = set.cookieSameSite == http.SameSiteNoneMode
+	cookie.Secure = set.cookieSecure || set.cookieSameSite == http.SameSiteNoneMode
 	cookie.HttpOnly = set.cookieHTTPOnly
-	ctx.Output.Cookie = cookie
 
-	ctx.Output.VaryHeaders = append(ctx.Output.VaryHeaders, rkmid.HeaderCookie)
+	return cookie
 }
 
 // ShouldIgnore determine whether auth should be ignored based on path
@@ -233,10 +408,116 @@ func (set *optionSet) ShouldIgnore(path string) bool {
 	return rkmid.ShouldIgnoreGlobal(path)
 }
 
+// isTrustedOrigin checks Origin header, or Referer header if Origin is missing, against trusted origins.
+// Requests without both headers are treated as trusted, token validation still applies.
+// Origin of request will be returned as well.
+func (set *optionSet) isTrustedOrigin(req *http.Request) (string, bool) {
+	if len(set.trustedOrigins) < 1 || req == nil {
+		return "", true
+	}
+
+	origin := req.Header.Get(rkmid.HeaderOrigin)
+	if len(origin) < 1 {
+		referer := req.Header.Get(rkmid.HeaderReferer)
+		if len(referer) < 1 {
+			return "", true
+		}
+
+		u, err := url.Parse(referer)
+		if err != nil || len(u.Scheme) < 1 || len(u.Host) < 1 {
+			return referer, false
+		}
+		origin = u.Scheme + "://" + u.Host
+	}
+
+	origin = normalizeOrigin(origin)
+	for i := range set.trustedOrigins {
+		if set.trustedOrigins[i] == origin {
+			return origin, true
+		}
+	}
+
+	return origin, false
+}
+
+// normalizeOrigin converts origin into lower case without trailing slash
+func normalizeOrigin(origin string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(origin)), "/")
+}
+
 func (set *optionSet) isValidToken(token, clientToken string) bool {
 	return subtle.ConstantTimeCompare([]byte(token), []byte(clientToken)) == 1
 }
 
+// ***************** Cookie Transformer *****************
+
+// CookieValueTransformer transforms CSRF token while writing and reading CSRF cookie,
+// which makes it possible to verify token without server side state.
+type CookieValueTransformer interface {
+	// Encode token into cookie value
+	Encode(token string) (string, error)
+
+	// Decode cookie value into token, error will be returned if value is invalid or expired
+	Decode(value string) (string, error)
+}
+
+// NewHmacCookieValueTransformer create built-in transformer which signs token with HMAC-SHA256 and timestamp.
+// Cookie value is in format of <token>.<unix seconds>.<signature>, token older than maxAge will be rejected.
+func NewHmacCookieValueTransformer(key []byte, maxAge time.Duration) CookieValueTransformer {
+	return &hmacCookieValueTransformer{
+		key:    key,
+		maxAge: maxAge,
+	}
+}
+
+// hmacCookieValueTransformer signs token with HMAC-SHA256 and timestamp
+type hmacCookieValueTransformer struct {
+	key    []byte
+	maxAge time.Duration
+}
+
+// Encode token with timestamp and signature
+func (t *hmacCookieValueTransformer) Encode(token string) (string, error) {
+	payload := token + "." + strconv.FormatInt(time.Now().Unix(), 10)
+	return payload + "." + t.sign(payload), nil
+}
+
+// Decode verify signature and timestamp of cookie value
+func (t *hmacCookieValueTransformer) Decode(value string) (string, error) {
+	idx := strings.LastIndex(value, ".")
+	if idx < 0 {
+		return "", errors.New("malformed csrf cookie")
+	}
+
+	payload, signature := value[:idx], value[idx+1:]
+	if !hmac.Equal([]byte(signature), []byte(t.sign(payload))) {
+		return "", errors.New("invalid signature of csrf cookie")
+	}
+
+	idx = strings.LastIndex(payload, ".")
+	if idx < 0 {
+		return "", errors.New("malformed csrf cookie")
+	}
+
+	issuedAt, err := strconv.ParseInt(payload[idx+1:], 10, 64)
+	if err != nil {
+		return "", errors.New("malformed csrf cookie")
+	}
+
+	if t.maxAge > 0 && time.Since(time.Unix(issuedAt, 0)) > t.maxAge {
+		return "", errors.New("expired csrf cookie")
+	}
+
+	return payload[:idx], nil
+}
+
+// sign payload with HMAC-SHA256
+func (t *hmacCookieValueTransformer) sign(payload string) string {
+	mac := hmac.New(sha256.New, t.key)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
 // ***************** OptionSet Mock *****************
 
 // NewOptionSetMock for testing purpose
@@ -260,6 +541,11 @@ func (mock *optionSetMock) GetEntryType() string {
 	return "mock"
 }
 
+// Config returns empty map
+func (mock *optionSetMock) Config() map[string]interface{} {
+	return map[string]interface{}{}
+}
+
 // BeforeCtx should be created before Before()
 func (mock *optionSetMock) BeforeCtx(request *http.Request) *BeforeCtx {
 	return mock.before
@@ -275,12 +561,18 @@ func (mock *optionSetMock) ShouldIgnore(string) bool {
 	return false
 }
 
+// ClearCookie returns nil
+func (mock *optionSetMock) ClearCookie() *http.Cookie {
+	return nil
+}
+
 // ***************** Context *****************
 
 // NewBeforeCtx create new BeforeCtx with fields initialized
 func NewBeforeCtx() *BeforeCtx {
 	ctx := &BeforeCtx{}
 	ctx.Output.VaryHeaders = make([]string, 0)
+	ctx.Output.HeadersToReturn = make(map[string]string)
 	return ctx
 }
 
@@ -295,8 +587,10 @@ type BeforeCtx struct {
 	}
 	Output struct {
 		VaryHeaders []string
-		Cookie      *http.Cookie
-		ErrResp     rkerror.ErrorInterface
+		// HeadersToReturn should be written into response by adapters
+		HeadersToReturn map[string]string
+		Cookie          *http.Cookie
+		ErrResp         rkerror.ErrorInterface
 	}
 }
 
@@ -304,23 +598,33 @@ type BeforeCtx struct {
 
 // BootConfig for YAML
 type BootConfig struct {
-	Enabled        bool     `yaml:"enabled" json:"enabled"`
-	Ignore         []string `yaml:"ignore" json:"ignore"`
-	TokenLength    int      `yaml:"tokenLength" json:"tokenLength"`
-	TokenLookup    string   `yaml:"tokenLookup" json:"tokenLookup"`
-	CookieName     string   `yaml:"cookieName" json:"cookieName"`
-	CookieDomain   string   `yaml:"cookieDomain" json:"cookieDomain"`
-	CookiePath     string   `yaml:"cookiePath" json:"cookiePath"`
-	CookieMaxAge   int      `yaml:"cookieMaxAge" json:"cookieMaxAge"`
-	CookieHttpOnly bool     `yaml:"cookieHttpOnly" json:"cookieHttpOnly"`
-	CookieSameSite string   `yaml:"cookieSameSite" json:"cookieSameSite"`
+	Enabled           bool     `yaml:"enabled" json:"enabled"`
+	Profile           string   `yaml:"profile" json:"profile"`
+	Ignore            []string `yaml:"ignore" json:"ignore"`
+	TokenLength       int      `yaml:"tokenLength" json:"tokenLength"`
+	TokenLookup       string   `yaml:"tokenLookup" json:"tokenLookup"`
+	CookieName        string   `yaml:"cookieName" json:"cookieName"`
+	CookieDomain      string   `yaml:"cookieDomain" json:"cookieDomain"`
+	CookiePath        string   `yaml:"cookiePath" json:"cookiePath"`
+	CookieMaxAge      int      `yaml:"cookieMaxAge" json:"cookieMaxAge"`
+	CookieHttpOnly    bool     `yaml:"cookieHttpOnly" json:"cookieHttpOnly"`
+	CookieSecure      bool     `yaml:"cookieSecure" json:"cookieSecure"`
+	CookieSameSite    string   `yaml:"cookieSameSite" json:"cookieSameSite"`
+	CookieTokenHeader string   `yaml:"cookieTokenHeader" json:"cookieTokenHeader"`
+	TrustedOrigins    []string `yaml:"trustedOrigins" json:"trustedOrigins"`
+	SkipMethods       []string `yaml:"skipMethods" json:"skipMethods"`
+	SkipPaths         []string `yaml:"skipPaths" json:"skipPaths"`
 }
 
 // ToOptions convert BootConfig into Option list
+//
+// If Profile provided, values of rkmid.SecurityProfile will be used as defaults of empty fields.
 func ToOptions(config *BootConfig, entryName, entryType string) []Option {
 	opts := make([]Option, 0)
 
 	if config.Enabled {
+		config = withSecurityProfile(config)
+
 		opts = append(opts,
 			WithEntryNameAndType(entryName, entryType),
 			WithTokenLength(config.TokenLength),
@@ -330,6 +634,11 @@ func ToOptions(config *BootConfig, entryName, entryType string) []Option {
 			WithCookiePath(config.CookiePath),
 			WithCookieMaxAge(config.CookieMaxAge),
 			WithCookieHTTPOnly(config.CookieHttpOnly),
+			WithCookieSecure(config.CookieSecure),
+			WithCookieTokenHeader(config.CookieTokenHeader),
+			WithTrustedOrigins(config.TrustedOrigins...),
+			WithSkipMethods(config.SkipMethods...),
+			WithSkipPaths(config.SkipPaths...),
 			WithPathToIgnore(config.Ignore...))
 
 		// convert to string to cookie same sites
@@ -352,6 +661,36 @@ func ToOptions(config *BootConfig, entryName, entryType string) []Option {
 	return opts
 }
 
+// Fill empty fields of BootConfig with security profile, application will shutdown if profile not found.
+// Boolean fields enabled in profile could not be disabled by BootConfig, register a custom profile instead.
+func withSecurityProfile(config *BootConfig) *BootConfig {
+	if len(config.Profile) < 1 {
+		return config
+	}
+
+	profile := rkmid.GetSecurityProfile(config.Profile)
+	if profile == nil {
+		rkentry.ShutdownWithError(fmt.Errorf("security profile not found:%s", config.Profile))
+		return config
+	}
+
+	res := *config
+	p := profile.Csrf
+
+	if res.TokenLength == 0 {
+		res.TokenLength = p.TokenLength
+	}
+	if res.CookieMaxAge == 0 {
+		res.CookieMaxAge = p.CookieMaxAge
+	}
+	if len(res.CookieSameSite) < 1 {
+		res.CookieSameSite = p.CookieSameSite
+	}
+	res.CookieHttpOnly = res.CookieHttpOnly || p.CookieHttpOnly
+
+	return &res
+}
+
 // ***************** Option *****************
 
 // Option
@@ -422,6 +761,8 @@ func WithCookiePath(val string) Option {
 }
 
 // WithCookieMaxAge provide max age (in seconds) of the CSRF cookie.
+// Zero or negative value will be ignored, since CSRF cookie should be persistent,
+// use ClearCookie() to delete CSRF cookie instead.
 // Optional. Default value 86400 (24hr).
 func WithCookieMaxAge(val int) Option {
 	return func(opt *optionSet) {
@@ -439,6 +780,15 @@ func WithCookieHTTPOnly(val bool) Option {
 	}
 }
 
+// WithCookieSecure indicates if CSRF cookie is sent over HTTPS only.
+// Cookie with SameSite of None or name with CookiePrefixHost will always be secure.
+// Optional. Default value false.
+func WithCookieSecure(val bool) Option {
+	return func(opt *optionSet) {
+		opt.cookieSecure = val
+	}
+}
+
 // WithCookieSameSite indicates SameSite mode of the CSRF cookie.
 // Optional. Default value SameSiteDefaultMode.
 func WithCookieSameSite(val http.SameSite) Option {
@@ -447,6 +797,92 @@ func WithCookieSameSite(val http.SameSite) Option {
 	}
 }
 
+// WithCookieTokenHeader provide name of response header which carries the token stored in CSRF cookie.
+// Header will be added to Output.HeadersToReturn of BeforeCtx, adapters should write it into response.
+//
+// If cookie transformer is set, header carries decoded token instead of encoded cookie value,
+// since token extracted from request is compared with decoded cookie. Clients should echo the header value,
+// echoing the raw cookie value will be rejected.
+// Optional. Default value empty.
+func WithCookieTokenHeader(name string) Option {
+	return func(opt *optionSet) {
+		if len(name) > 0 {
+			opt.cookieTokenHeader = name
+		}
+	}
+}
+
+// WithCookieValueTransformer provide transformer applied while writing and reading CSRF cookie.
+// Optional. Default value nil.
+func WithCookieValueTransformer(transformer CookieValueTransformer) Option {
+	return func(opt *optionSet) {
+		if transformer != nil {
+			opt.cookieTransformer = transformer
+		}
+	}
+}
+
+// WithCookieHmacKey enables built-in HMAC transformer with key, token older than cookieMaxAge will be rejected.
+// Transformer provided by WithCookieValueTransformer takes precedence.
+// Optional. Default value nil.
+func WithCookieHmacKey(key []byte) Option {
+	return func(opt *optionSet) {
+		if len(key) > 0 {
+			opt.cookieHmacKey = key
+		}
+	}
+}
+
+// WithTrustedOrigins provide origins in the form of "<scheme>://<host>[:port]", like https://example.com.
+//
+// For unsafe methods, requests whose Origin header, or Referer header if Origin is missing,
+// is not trusted will be rejected with 403 in addition to token validation.
+// Requests without both headers will not be rejected by this check.
+// Optional. Default value empty, which means Origin and Referer will not be checked.
+func WithTrustedOrigins(origins ...string) Option {
+	return func(opt *optionSet) {
+		for i := range origins {
+			if origin := normalizeOrigin(origins[i]); len(origin) > 0 {
+				opt.trustedOrigins = append(opt.trustedOrigins, origin)
+			}
+		}
+	}
+}
+
+// WithSkipper provide function which returns true if token of request should not be validated,
+// in addition to requests with safe methods. CSRF cookie will still be set for skipped requests.
+func WithSkipper(skipper func(*http.Request) bool) Option {
+	return func(opt *optionSet) {
+		if skipper != nil {
+			opt.skipper = skipper
+		}
+	}
+}
+
+// WithSkipMethods provide http methods whose token will not be validated, in addition to GET, HEAD, OPTIONS and TRACE.
+// For example, POST of legacy API which uses it for idempotent reads.
+func WithSkipMethods(methods ...string) Option {
+	return func(opt *optionSet) {
+		for i := range methods {
+			if method := strings.ToUpper(strings.TrimSpace(methods[i])); len(method) > 0 {
+				opt.skipMethods = append(opt.skipMethods, method)
+			}
+		}
+	}
+}
+
+// WithSkipPaths provide path prefixes whose token will not be validated.
+// Unlike WithPathToIgnore, CSRF cookie will still be set for requests of them.
+func WithSkipPaths(paths ...string) Option {
+	return func(opt *optionSet) {
+		for i := range paths {
+			if len(paths[i]) > 0 {
+				opt.skipPaths = append(opt.skipPaths, paths[i])
+			}
+		}
+	}
+}
+
 // WithExtractor provide user extractor
 func WithExtractor(ex CsrfExtractor) Option {
 	return func(opt *optionSet) {
@@ -7,12 +7,18 @@ package rkmidcsrf
 
 import (
 	"context"
+	"github.com/rookie-ninja/rk-entry/v2/error"
 	"github.com/rookie-ninja/rk-entry/v2/middleware"
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/structpb"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestNewOptionSet(t *testing.T) {
@@ -94,7 +100,7 @@ func TestOptionSet_Before(t *testing.T) {
 	assert.Nil(t, ctx.Output.ErrResp)
 	assert.NotNil(t, ctx.Output.Cookie)
 
-	// match 3.2
+	// match 3.3
 	req = httptest.NewRequest(http.MethodPost, "/ut", nil)
 	ctx = set.BeforeCtx(req)
 	set.Before(ctx)
@@ -102,7 +108,7 @@ func TestOptionSet_Before(t *testing.T) {
 	assert.Contains(t, ctx.Output.ErrResp.Error(), http.StatusText(http.StatusBadRequest))
 	assert.Nil(t, ctx.Output.Cookie)
 
-	// match 3.3
+	// match 3.4
 	req = httptest.NewRequest(http.MethodPost, "/ut", nil)
 	req.Header.Set(rkmid.HeaderXCSRFToken, "ut-csrf-token")
 	ctx = set.BeforeCtx(req)
@@ -132,6 +138,92 @@ func TestOptionSet_Before(t *testing.T) {
 	assert.Equal(t, http.SameSiteStrictMode, ctx.Output.Cookie.SameSite)
 }
 
+func TestOptionSet_ClearCookie(t *testing.T) {
+	set := NewOptionSet(
+		WithCookieName("ut-cookie"),
+		WithCookiePath("/ut"),
+		WithCookieDomain("ut-domain"))
+
+	cookie := set.ClearCookie()
+	assert.Equal(t, "ut-cookie", cookie.Name)
+	assert.Empty(t, cookie.Value)
+	assert.Equal(t, "/ut", cookie.Path)
+	assert.Equal(t, "ut-domain", cookie.Domain)
+	assert.Equal(t, -1, cookie.MaxAge)
+	assert.Contains(t, cookie.String(), "Max-Age=0")
+}
+
+func TestWithCookieMaxAge(t *testing.T) {
+	// with zero, default value should be kept
+	set := NewOptionSet(WithCookieMaxAge(0)).(*optionSet)
+	assert.Equal(t, 86400, set.cookieMaxAge)
+
+	// with negative, default value should be kept
+	set = NewOptionSet(WithCookieMaxAge(-1)).(*optionSet)
+	assert.Equal(t, 86400, set.cookieMaxAge)
+
+	// cookie should be persistent
+	ctx := set.BeforeCtx(httptest.NewRequest(http.MethodGet, "/ut", nil))
+	set.Before(ctx)
+	assert.Equal(t, 86400, ctx.Output.Cookie.MaxAge)
+}
+
+func TestHmacCookieValueTransformer(t *testing.T) {
+	transformer := NewHmacCookieValueTransformer([]byte("ut-key"), time.Hour)
+
+	// happy case
+	value, err := transformer.Encode("ut-token")
+	assert.Nil(t, err)
+	token, err := transformer.Decode(value)
+	assert.Nil(t, err)
+	assert.Equal(t, "ut-token", token)
+
+	// with tampered value
+	_, err = transformer.Decode("ut-other" + value[len("ut-token"):])
+	assert.NotNil(t, err)
+
+	// with different key
+	_, err = NewHmacCookieValueTransformer([]byte("ut-other"), time.Hour).Decode(value)
+	assert.NotNil(t, err)
+
+	// with malformed value
+	_, err = transformer.Decode("ut-token")
+	assert.NotNil(t, err)
+
+	// with expired value
+	expired := transformer.(*hmacCookieValueTransformer)
+	payload := "ut-token." + strconv.FormatInt(time.Now().Add(-2*time.Hour).Unix(), 10)
+	_, err = transformer.Decode(payload + "." + expired.sign(payload))
+	assert.NotNil(t, err)
+}
+
+func TestWithCookieHmacKey(t *testing.T) {
+	set := NewOptionSet(WithCookieHmacKey([]byte("ut-key")))
+
+	// cookie should be signed
+	ctx := set.BeforeCtx(httptest.NewRequest(http.MethodGet, "/ut", nil))
+	set.Before(ctx)
+	token := ctx.Input.Token
+	cookie := ctx.Output.Cookie
+	assert.True(t, strings.HasPrefix(cookie.Value, token+"."))
+
+	// with signed cookie
+	req := httptest.NewRequest(http.MethodPost, "/ut", nil)
+	req.AddCookie(cookie)
+	req.Header.Set(rkmid.HeaderXCSRFToken, token)
+	ctx = set.BeforeCtx(req)
+	set.Before(ctx)
+	assert.Nil(t, ctx.Output.ErrResp)
+
+	// with unsigned cookie, token should be rejected
+	req = httptest.NewRequest(http.MethodPost, "/ut", nil)
+	req.AddCookie(&http.Cookie{Name: "_csrf", Value: token})
+	req.Header.Set(rkmid.HeaderXCSRFToken, token)
+	ctx = set.BeforeCtx(req)
+	set.Before(ctx)
+	assert.Contains(t, ctx.Output.ErrResp.Error(), http.StatusText(http.StatusForbidden))
+}
+
 func TestOptionSet_IsValidToken(t *testing.T) {
 	set := NewOptionSet().(*optionSet)
 
@@ -217,4 +309,237 @@ func TestNewOptionSetMock(t *testing.T) {
 	assert.NotEmpty(t, mock.GetEntryType())
 	assert.NotNil(t, mock.BeforeCtx(nil))
 	mock.Before(nil)
+	assert.Nil(t, mock.ClearCookie())
+}
+
+func TestOptionSet_Config(t *testing.T) {
+	set := NewOptionSet(WithEntryNameAndType("ut-config", "ut-type"))
+	config := set.Config()
+	assert.Equal(t, "ut-config", config["entryName"])
+	assert.Equal(t, "ut-type", config["entryType"])
+	assert.Equal(t, "_csrf", config["cookieName"])
+}
+
+func TestToOptions_WithProfile(t *testing.T) {
+	// with profile, explicit values should override profile
+	config := &BootConfig{
+		Enabled:        true,
+		Profile:        rkmid.SecurityProfileStrict,
+		CookieSameSite: "lax",
+	}
+	set := NewOptionSet(ToOptions(config, "", "")...).(*optionSet)
+	assert.Equal(t, http.SameSiteLaxMode, set.cookieSameSite)
+	assert.True(t, set.cookieHTTPOnly)
+
+	// with unknown profile
+	config.Profile = "ut-non-exist"
+	assert.Panics(t, func() {
+		ToOptions(config, "", "")
+	})
+}
+
+func TestWithCookieSecure(t *testing.T) {
+	// default
+	set := NewOptionSet()
+	ctx := set.BeforeCtx(httptest.NewRequest(http.MethodGet, "/ut", nil))
+	set.Before(ctx)
+	assert.False(t, ctx.Output.Cookie.Secure)
+
+	// with secure
+	set = NewOptionSet(ToOptions(&BootConfig{Enabled: true, CookieSecure: true}, "", "")...)
+	assert.True(t, set.Config()["cookieSecure"].(bool))
+	ctx = set.BeforeCtx(httptest.NewRequest(http.MethodGet, "/ut", nil))
+	set.Before(ctx)
+	assert.True(t, ctx.Output.Cookie.Secure)
+	assert.True(t, set.ClearCookie().Secure)
+
+	// with __Host- prefix, cookie should be secure and scoped to root path
+	set = NewOptionSet(WithCookieName(CookiePrefixHost + "csrf"))
+	ctx = set.BeforeCtx(httptest.NewRequest(http.MethodGet, "/ut", nil))
+	set.Before(ctx)
+	assert.True(t, ctx.Output.Cookie.Secure)
+	assert.Equal(t, "/", ctx.Output.Cookie.Path)
+	assert.Empty(t, ctx.Output.Cookie.Domain)
+
+	// with __Host- prefix and root path
+	assert.NotPanics(t, func() {
+		NewOptionSet(WithCookieName(CookiePrefixHost+"csrf"), WithCookiePath("/"))
+	})
+
+	// with __Host- prefix and domain
+	assert.Panics(t, func() {
+		NewOptionSet(WithCookieName(CookiePrefixHost+"csrf"), WithCookieDomain("ut.com"))
+	})
+
+	// with __Host- prefix and sub path
+	assert.Panics(t, func() {
+		NewOptionSet(WithCookieName(CookiePrefixHost+"csrf"), WithCookiePath("/ut"))
+	})
+}
+
+func TestWithCookieTokenHeader(t *testing.T) {
+	// default
+	set := NewOptionSet()
+	ctx := set.BeforeCtx(httptest.NewRequest(http.MethodGet, "/ut", nil))
+	set.Before(ctx)
+	assert.Empty(t, ctx.Output.HeadersToReturn)
+	assert.NotContains(t, ctx.Output.VaryHeaders, "X-Csrf-Token")
+
+	// with header
+	set = NewOptionSet(ToOptions(&BootConfig{Enabled: true, CookieTokenHeader: "X-Csrf-Token"}, "", "")...)
+	assert.Equal(t, "X-Csrf-Token", set.Config()["cookieTokenHeader"])
+	ctx = set.BeforeCtx(httptest.NewRequest(http.MethodGet, "/ut", nil))
+	set.Before(ctx)
+	assert.Nil(t, ctx.Output.ErrResp)
+	assert.NotEmpty(t, ctx.Output.HeadersToReturn["X-Csrf-Token"])
+	assert.Equal(t, ctx.Output.Cookie.Value, ctx.Output.HeadersToReturn["X-Csrf-Token"])
+	assert.Contains(t, ctx.Output.VaryHeaders, "X-Csrf-Token")
+
+	// echo token from header back
+	req := httptest.NewRequest(http.MethodPost, "/ut", nil)
+	req.AddCookie(ctx.Output.Cookie)
+	req.Header.Set(rkmid.HeaderXCSRFToken, ctx.Output.HeadersToReturn["X-Csrf-Token"])
+	ctx = set.BeforeCtx(req)
+	set.Before(ctx)
+	assert.Nil(t, ctx.Output.ErrResp)
+
+	// with cookie transformer, header carries decoded token instead of cookie value
+	set = NewOptionSet(WithCookieTokenHeader("X-Csrf-Token"), WithCookieHmacKey([]byte("ut-key")))
+	ctx = set.BeforeCtx(httptest.NewRequest(http.MethodGet, "/ut", nil))
+	set.Before(ctx)
+	assert.Nil(t, ctx.Output.ErrResp)
+	token := ctx.Output.HeadersToReturn["X-Csrf-Token"]
+	cookie := ctx.Output.Cookie
+	assert.NotEmpty(t, token)
+	assert.NotEqual(t, cookie.Value, token)
+	assert.True(t, strings.HasPrefix(cookie.Value, token))
+
+	// echo header value back
+	req = httptest.NewRequest(http.MethodPost, "/ut", nil)
+	req.AddCookie(cookie)
+	req.Header.Set(rkmid.HeaderXCSRFToken, token)
+	ctx = set.BeforeCtx(req)
+	set.Before(ctx)
+	assert.Nil(t, ctx.Output.ErrResp)
+
+	// echo cookie value back
+	req = httptest.NewRequest(http.MethodPost, "/ut", nil)
+	req.AddCookie(cookie)
+	req.Header.Set(rkmid.HeaderXCSRFToken, cookie.Value)
+	ctx = set.BeforeCtx(req)
+	set.Before(ctx)
+	assert.Equal(t, http.StatusForbidden, ctx.Output.ErrResp.Code())
+}
+
+func TestWithTrustedOrigins(t *testing.T) {
+	set := NewOptionSet(WithTrustedOrigins("https://UT.com/", "", "http://ut.com:8080")).(*optionSet)
+	assert.Equal(t, []string{"https://ut.com", "http://ut.com:8080"}, set.trustedOrigins)
+
+	newReq := func(method string, headers map[string]string) *http.Request {
+		req := httptest.NewRequest(method, "/ut", nil)
+		req.AddCookie(&http.Cookie{Name: "_csrf", Value: "ut-csrf-token"})
+		req.Header.Set(rkmid.HeaderXCSRFToken, "ut-csrf-token")
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		return req
+	}
+
+	// with trusted origin
+	ctx := set.BeforeCtx(newReq(http.MethodPost, map[string]string{rkmid.HeaderOrigin: "https://ut.com"}))
+	set.Before(ctx)
+	assert.Nil(t, ctx.Output.ErrResp)
+
+	// with untrusted origin
+	ctx = set.BeforeCtx(newReq(http.MethodPost, map[string]string{rkmid.HeaderOrigin: "https://evil.com"}))
+	set.Before(ctx)
+	assert.Contains(t, ctx.Output.ErrResp.Error(), http.StatusText(http.StatusForbidden))
+
+	// with trusted referer
+	ctx = set.BeforeCtx(newReq(http.MethodPost, map[string]string{rkmid.HeaderReferer: "http://ut.com:8080/ut?q=1"}))
+	set.Before(ctx)
+	assert.Nil(t, ctx.Output.ErrResp)
+
+	// with untrusted referer
+	ctx = set.BeforeCtx(newReq(http.MethodPost, map[string]string{rkmid.HeaderReferer: "http://ut.com/ut"}))
+	set.Before(ctx)
+	assert.Contains(t, ctx.Output.ErrResp.Error(), http.StatusText(http.StatusForbidden))
+
+	// without origin and referer
+	ctx = set.BeforeCtx(newReq(http.MethodPost, nil))
+	set.Before(ctx)
+	assert.Nil(t, ctx.Output.ErrResp)
+
+	// with safe method
+	ctx = set.BeforeCtx(newReq(http.MethodGet, map[string]string{rkmid.HeaderOrigin: "https://evil.com"}))
+	set.Before(ctx)
+	assert.Nil(t, ctx.Output.ErrResp)
+}
+
+func TestWithSkipper(t *testing.T) {
+	newReq := func(method, path string) *http.Request {
+		// token in header mismatches cookie
+		req := httptest.NewRequest(method, path, nil)
+		req.AddCookie(&http.Cookie{Name: "_csrf", Value: "ut-csrf-token"})
+		req.Header.Set(rkmid.HeaderXCSRFToken, "other-token")
+		return req
+	}
+
+	// without exemption
+	set := NewOptionSet()
+	ctx := set.BeforeCtx(newReq(http.MethodPost, "/ut"))
+	set.Before(ctx)
+	assert.NotNil(t, ctx.Output.ErrResp)
+
+	set = NewOptionSet(append(ToOptions(&BootConfig{
+		Enabled:     true,
+		SkipMethods: []string{" patch ", ""},
+		SkipPaths:   []string{"/legacy", ""},
+	}, "ut-entry", "ut-type"), WithSkipper(func(req *http.Request) bool {
+		return req.Header.Get("X-Ut-Skip") == "true"
+	}))...)
+	assert.Equal(t, []string{"PATCH"}, set.Config()["skipMethods"])
+	assert.Equal(t, []string{"/legacy"}, set.Config()["skipPaths"])
+	assert.True(t, set.Config()["skipper"].(bool))
+
+	// with skipped method, cookie should be set
+	ctx = set.BeforeCtx(newReq(http.MethodPatch, "/ut"))
+	set.Before(ctx)
+	assert.Nil(t, ctx.Output.ErrResp)
+	assert.Equal(t, "ut-csrf-token", ctx.Output.Cookie.Value)
+
+	// with skipped path
+	ctx = set.BeforeCtx(newReq(http.MethodPost, "/legacy/query"))
+	set.Before(ctx)
+	assert.Nil(t, ctx.Output.ErrResp)
+	assert.NotNil(t, ctx.Output.Cookie)
+
+	// with skipper
+	req := newReq(http.MethodPost, "/ut")
+	req.Header.Set("X-Ut-Skip", "true")
+	ctx = set.BeforeCtx(req)
+	set.Before(ctx)
+	assert.Nil(t, ctx.Output.ErrResp)
+	assert.NotNil(t, ctx.Output.Cookie)
+
+	// with request not exempted
+	ctx = set.BeforeCtx(newReq(http.MethodDelete, "/ut"))
+	set.Before(ctx)
+	assert.NotNil(t, ctx.Output.ErrResp)
+}
+
+func TestTrustedOrigins_GrpcStatus(t *testing.T) {
+	set := NewOptionSet(WithTrustedOrigins("https://ut.com"))
+
+	req := httptest.NewRequest(http.MethodPost, "/ut", nil)
+	req.Header.Set(rkmid.HeaderOrigin, "https://evil.com")
+	ctx := set.BeforeCtx(req)
+	set.Before(ctx)
+
+	// details should survive HTTP to gRPC translation
+	st := rkerror.GrpcStatus(ctx.Output.ErrResp)
+	assert.Equal(t, codes.PermissionDenied, st.Code())
+	assert.Equal(t, "Untrusted request origin", st.Message())
+	assert.Len(t, st.Details(), 1)
+	assert.Equal(t, "untrusted origin:https://evil.com", st.Details()[0].(*structpb.Value).GetStringValue())
 }
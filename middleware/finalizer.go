@@ -0,0 +1,58 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkmid
+
+import "sync"
+
+// Finalizer is embedded in AfterCtx of middlewares which support deferred response code,
+// like logging and tracing, for streaming or async handlers whose response code is not known while After() runs.
+//
+// Adapter calls Defer() before After(), middleware stores finalization with DeferFinalize() in After(),
+// and adapter calls Finalize() once response completes.
+type Finalizer struct {
+	lock      sync.Mutex
+	deferred  bool
+	finalized bool
+	finalizer func()
+}
+
+// Defer finalization until Finalize() called
+func (f *Finalizer) Defer() {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.deferred = true
+}
+
+// Finalize runs deferred finalization, it is a no-op if called more than once
+func (f *Finalizer) Finalize() {
+	f.lock.Lock()
+	if f.finalized {
+		f.lock.Unlock()
+		return
+	}
+	f.finalized = true
+	finalizer := f.finalizer
+	f.finalizer = nil
+	f.lock.Unlock()
+
+	if finalizer != nil {
+		finalizer()
+	}
+}
+
+// DeferFinalize stores finalizer and returns true if finalization should be deferred.
+// Caller should finalize directly if false returned.
+func (f *Finalizer) DeferFinalize(finalizer func()) bool {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if !f.deferred || f.finalized {
+		return false
+	}
+
+	f.finalizer = finalizer
+	return true
+}
@@ -0,0 +1,37 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkmid
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestFinalizer(t *testing.T) {
+	calls := 0
+
+	// without Defer(), finalization should not be deferred
+	f := &Finalizer{}
+	assert.False(t, f.DeferFinalize(func() { calls++ }))
+
+	// with Defer()
+	f = &Finalizer{}
+	f.Defer()
+	assert.True(t, f.DeferFinalize(func() { calls++ }))
+	assert.Zero(t, calls)
+	f.Finalize()
+	assert.Equal(t, 1, calls)
+
+	// finalize twice should be no-op
+	f.Finalize()
+	assert.Equal(t, 1, calls)
+
+	// with Finalize() called before DeferFinalize()
+	f = &Finalizer{}
+	f.Defer()
+	f.Finalize()
+	assert.False(t, f.DeferFinalize(func() { calls++ }))
+}
@@ -0,0 +1,366 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+// Package rkmidforwarded is a middleware which normalizes X-Forwarded-* headers
+package rkmidforwarded
+
+import (
+	"context"
+	"fmt"
+	"github.com/rookie-ninja/rk-entry/v2/entry"
+	"github.com/rookie-ninja/rk-entry/v2/middleware"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ***************** OptionSet Interface *****************
+
+// OptionSetInterface mainly for testing purpose
+type OptionSetInterface interface {
+	GetEntryName() string
+
+	GetEntryType() string
+
+	BeforeCtx(*http.Request) *BeforeCtx
+
+	Before(*BeforeCtx)
+
+	ShouldIgnore(string) bool
+
+	Config() map[string]interface{}
+}
+
+// ***************** OptionSet Implementation *****************
+
+// optionSet which is used for middleware implementation
+type optionSet struct {
+	entryName string
+	entryType string
+
+	// TrustedProxies is a list of CIDR of proxies whose X-Forwarded-* headers will be trusted.
+	// Headers will be ignored if remote address of request is not in the list.
+	// Optional. Default value empty, which means no proxies will be trusted.
+	trustedProxies []*net.IPNet
+
+	pathToIgnore []string
+	mock         OptionSetInterface
+}
+
+// NewOptionSet Create new optionSet with options.
+func NewOptionSet(opts ...Option) OptionSetInterface {
+	set := &optionSet{
+		entryName:      "fake-entry",
+		entryType:      "",
+		trustedProxies: make([]*net.IPNet, 0),
+		pathToIgnore:   make([]string, 0),
+	}
+
+	for i := range opts {
+		opts[i](set)
+	}
+
+	if set.mock != nil {
+		return set.mock
+	}
+
+	return set
+}
+
+// GetEntryName returns entry name
+func (set *optionSet) GetEntryName() string {
+	return set.entryName
+}
+
+// GetEntryType returns entry type
+func (set *optionSet) GetEntryType() string {
+	return set.entryType
+}
+
+// Config returns effective configuration, secrets are redacted
+func (set *optionSet) Config() map[string]interface{} {
+	proxies := make([]string, 0)
+	for i := range set.trustedProxies {
+		proxies = append(proxies, set.trustedProxies[i].String())
+	}
+
+	return map[string]interface{}{
+		"entryName":      set.entryName,
+		"entryType":      set.entryType,
+		"trustedProxies": proxies,
+		"pathToIgnore":   set.pathToIgnore,
+	}
+}
+
+// BeforeCtx should be created before Before()
+func (set *optionSet) BeforeCtx(req *http.Request) *BeforeCtx {
+	ctx := NewBeforeCtx()
+
+	ctx.Input.Request = req
+	if req != nil && req.URL != nil {
+		ctx.Input.UrlPath = req.URL.Path
+	}
+
+	return ctx
+}
+
+// Before should run before user handler
+//
+// Normalized values will be stored in context of Output.Request with key of rkmid.ForwardedKey,
+// framework adapters should pass Output.Request to downstream handlers.
+func (set *optionSet) Before(ctx *BeforeCtx) {
+	if ctx == nil || ctx.Input.Request == nil || set.ShouldIgnore(ctx.Input.UrlPath) {
+		return
+	}
+
+	req := ctx.Input.Request
+	remoteIp, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		remoteIp = req.RemoteAddr
+	}
+
+	forwarded := &rkmid.Forwarded{
+		ClientIp: remoteIp,
+		Proto:    "http",
+		Host:     req.Host,
+	}
+
+	if req.TLS != nil {
+		forwarded.Proto = "https"
+	}
+
+	// trust X-Forwarded-* headers only if request was sent from trusted proxy
+	if set.isTrusted(remoteIp) {
+		// walk X-Forwarded-For from right to left, the first untrusted address is the client
+		ips := splitHeader(req.Header.Values(rkmid.HeaderXForwardedFor))
+		clientIdx := 0
+		for i := len(ips) - 1; i >= 0; i-- {
+			if !set.isTrusted(ips[i]) {
+				clientIdx = i
+				break
+			}
+		}
+		if len(ips) > 0 {
+			forwarded.ClientIp = ips[clientIdx]
+		}
+
+		// hops is number of trusted proxies from the one client connected to, each of them appended one value.
+		// Values beyond hops were provided by client and will be ignored.
+		hops := len(ips) - clientIdx
+		if hops < 1 {
+			hops = 1
+		}
+
+		if proto, ok := trustedHop(splitHeader(req.Header.Values(rkmid.HeaderXForwardedProto)), hops); ok {
+			forwarded.Proto = strings.ToLower(proto)
+		}
+
+		if host, ok := trustedHop(splitHeader(req.Header.Values(rkmid.HeaderXForwardedHost)), hops); ok {
+			forwarded.Host = host
+		}
+	}
+
+	ctx.Output.Forwarded = forwarded
+	ctx.Output.Request = req.WithContext(context.WithValue(req.Context(), rkmid.ForwardedKey, forwarded))
+}
+
+// trustedHop returns value appended by the proxy client connected to, values are counted from right to left
+func trustedHop(values []string, hops int) (string, bool) {
+	idx := len(values) - hops
+	if idx < 0 || idx >= len(values) {
+		return "", false
+	}
+
+	return values[idx], true
+}
+
+// ShouldIgnore determine whether auth should be ignored based on path
+func (set *optionSet) ShouldIgnore(path string) bool {
+	for i := range set.pathToIgnore {
+		if strings.HasPrefix(path, set.pathToIgnore[i]) {
+			return true
+		}
+	}
+
+	return rkmid.ShouldIgnoreGlobal(path)
+}
+
+// check whether ip is in trusted proxies
+func (set *optionSet) isTrusted(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+
+	for i := range set.trustedProxies {
+		if set.trustedProxies[i].Contains(parsed) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// split comma separated header values and trim spaces
+func splitHeader(values []string) []string {
+	res := make([]string, 0)
+
+	for i := range values {
+		for _, v := range strings.Split(values[i], ",") {
+			if v = strings.TrimSpace(v); len(v) > 0 {
+				res = append(res, v)
+			}
+		}
+	}
+
+	return res
+}
+
+// ***************** OptionSet Mock *****************
+
+// NewOptionSetMock for testing purpose
+func NewOptionSetMock(before *BeforeCtx) OptionSetInterface {
+	return &optionSetMock{
+		before: before,
+	}
+}
+
+type optionSetMock struct {
+	before *BeforeCtx
+}
+
+// GetEntryName returns entry name
+func (mock *optionSetMock) GetEntryName() string {
+	return "mock"
+}
+
+// GetEntryType returns entry type
+func (mock *optionSetMock) GetEntryType() string {
+	return "mock"
+}
+
+// Config returns empty map
+func (mock *optionSetMock) Config() map[string]interface{} {
+	return map[string]interface{}{}
+}
+
+// BeforeCtx should be created before Before()
+func (mock *optionSetMock) BeforeCtx(req *http.Request) *BeforeCtx {
+	return mock.before
+}
+
+// Before should run before user handler
+func (mock *optionSetMock) Before(ctx *BeforeCtx) {
+	return
+}
+
+// ShouldIgnore should run before user handler
+func (mock *optionSetMock) ShouldIgnore(string) bool {
+	return false
+}
+
+// ***************** Context *****************
+
+// NewBeforeCtx create new BeforeCtx with fields initialized
+func NewBeforeCtx() *BeforeCtx {
+	ctx := &BeforeCtx{}
+	return ctx
+}
+
+// BeforeCtx context for Before() function
+type BeforeCtx struct {
+	Input struct {
+		UrlPath string
+		Request *http.Request
+	}
+	Output struct {
+		Forwarded *rkmid.Forwarded
+		Request   *http.Request
+	}
+}
+
+// ***************** BootConfig *****************
+
+// BootConfig for YAML
+type BootConfig struct {
+	Enabled        bool     `yaml:"enabled" json:"enabled"`
+	TrustedProxies []string `yaml:"trustedProxies" json:"trustedProxies"`
+	Ignore         []string `yaml:"ignore" json:"ignore"`
+}
+
+// ToOptions convert BootConfig into Option list
+func ToOptions(config *BootConfig, entryName, entryType string) []Option {
+	opts := make([]Option, 0)
+
+	if config.Enabled {
+		opts = append(opts,
+			WithEntryNameAndType(entryName, entryType),
+			WithTrustedProxies(config.TrustedProxies...),
+			WithPathToIgnore(config.Ignore...))
+	}
+
+	return opts
+}
+
+// ***************** Option *****************
+
+// Option if for middleware options while creating middleware
+type Option func(*optionSet)
+
+// WithEntryNameAndType provide entry name and entry type.
+func WithEntryNameAndType(entryName, entryType string) Option {
+	return func(opt *optionSet) {
+		opt.entryName = entryName
+		opt.entryType = entryType
+	}
+}
+
+// WithTrustedProxies provide CIDR or IP of trusted proxies, like 10.0.0.0/8 or 127.0.0.1.
+// Application will shutdown if invalid value provided.
+func WithTrustedProxies(proxies ...string) Option {
+	return func(opt *optionSet) {
+		for i := range proxies {
+			proxy := strings.TrimSpace(proxies[i])
+			if len(proxy) < 1 {
+				continue
+			}
+
+			// treat plain IP as single host network
+			if !strings.Contains(proxy, "/") {
+				if ip := net.ParseIP(proxy); ip != nil && ip.To4() != nil {
+					proxy = proxy + "/32"
+				} else {
+					proxy = proxy + "/128"
+				}
+			}
+
+			_, network, err := net.ParseCIDR(proxy)
+			if err != nil {
+				rkentry.ShutdownWithError(fmt.Errorf("invalid trusted proxy:%s", proxies[i]))
+				continue
+			}
+
+			opt.trustedProxies = append(opt.trustedProxies, network)
+		}
+	}
+}
+
+// WithPathToIgnore provide paths prefix that will ignore.
+func WithPathToIgnore(paths ...string) Option {
+	return func(set *optionSet) {
+		for i := range paths {
+			if len(paths[i]) > 0 {
+				set.pathToIgnore = append(set.pathToIgnore, paths[i])
+			}
+		}
+	}
+}
+
+// WithMockOptionSet provide mock OptionSetInterface
+func WithMockOptionSet(mock OptionSetInterface) Option {
+	return func(set *optionSet) {
+		set.mock = mock
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkmidforwarded
+
+import (
+	"crypto/tls"
+	"github.com/rookie-ninja/rk-entry/v2/middleware"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestToOptions(t *testing.T) {
+	// with disabled
+	config := &BootConfig{
+		Enabled: false,
+	}
+	assert.Empty(t, ToOptions(config, "", ""))
+
+	// with enabled
+	config.Enabled = true
+	config.TrustedProxies = []string{"10.0.0.0/8"}
+	assert.NotEmpty(t, ToOptions(config, "", ""))
+}
+
+func TestWithTrustedProxies(t *testing.T) {
+	// with CIDR and IP
+	set := NewOptionSet(WithTrustedProxies("10.0.0.0/8", "", "127.0.0.1", "::1")).(*optionSet)
+	assert.Len(t, set.trustedProxies, 3)
+	assert.True(t, set.isTrusted("10.1.2.3"))
+	assert.True(t, set.isTrusted("127.0.0.1"))
+	assert.True(t, set.isTrusted("::1"))
+	assert.False(t, set.isTrusted("192.168.0.1"))
+	assert.False(t, set.isTrusted("invalid"))
+
+	// with invalid value
+	defer assertPanic(t)
+	NewOptionSet(WithTrustedProxies("invalid"))
+}
+
+func TestOptionSet_Before(t *testing.T) {
+	defer assertNotPanic(t)
+
+	// with nil ctx
+	set := NewOptionSet(WithTrustedProxies("10.0.0.0/8"))
+	set.Before(nil)
+
+	// with untrusted remote address, headers should be ignored
+	req := httptest.NewRequest(http.MethodGet, "/ut", nil)
+	req.RemoteAddr = "1.1.1.1:8080"
+	req.Header.Set(rkmid.HeaderXForwardedFor, "2.2.2.2")
+	req.Header.Set(rkmid.HeaderXForwardedProto, "https")
+	req.Header.Set(rkmid.HeaderXForwardedHost, "ut-host")
+	ctx := set.BeforeCtx(req)
+	set.Before(ctx)
+	assert.Equal(t, "1.1.1.1", ctx.Output.Forwarded.ClientIp)
+	assert.Equal(t, "http", ctx.Output.Forwarded.Proto)
+	assert.Equal(t, req.Host, ctx.Output.Forwarded.Host)
+
+	// with trusted proxies chain
+	req = httptest.NewRequest(http.MethodGet, "/ut", nil)
+	req.RemoteAddr = "10.0.0.2:8080"
+	req.Header.Add(rkmid.HeaderXForwardedFor, "3.3.3.3, 2.2.2.2")
+	req.Header.Add(rkmid.HeaderXForwardedFor, "10.0.0.1")
+	req.Header.Set(rkmid.HeaderXForwardedProto, "HTTPS, http")
+	req.Header.Set(rkmid.HeaderXForwardedHost, "ut-host, ut-proxy")
+	ctx = set.BeforeCtx(req)
+	set.Before(ctx)
+	assert.Equal(t, "2.2.2.2", ctx.Output.Forwarded.ClientIp)
+	assert.Equal(t, "https", ctx.Output.Forwarded.Proto)
+	assert.Equal(t, "ut-host", ctx.Output.Forwarded.Host)
+
+	// values should be stored in context of request
+	assert.Equal(t, ctx.Output.Forwarded, rkmid.GetForwarded(ctx.Output.Request))
+	remoteIp, _ := rkmid.GetRemoteAddressSet(ctx.Output.Request)
+	assert.Equal(t, "2.2.2.2", remoteIp)
+
+	// with spoofed values prepended by client, values of trusted hop should be used
+	req = httptest.NewRequest(http.MethodGet, "/ut", nil)
+	req.RemoteAddr = "10.0.0.2:8080"
+	req.Header.Set(rkmid.HeaderXForwardedFor, "9.9.9.9, 2.2.2.2, 10.0.0.1")
+	req.Header.Set(rkmid.HeaderXForwardedProto, "ftp, https, http, http")
+	req.Header.Set(rkmid.HeaderXForwardedHost, "evil-host, ut-host, ut-proxy")
+	ctx = set.BeforeCtx(req)
+	set.Before(ctx)
+	assert.Equal(t, "2.2.2.2", ctx.Output.Forwarded.ClientIp)
+	assert.Equal(t, "http", ctx.Output.Forwarded.Proto)
+	assert.Equal(t, "ut-host", ctx.Output.Forwarded.Host)
+
+	// with fewer values than trusted hops, values should be ignored
+	req = httptest.NewRequest(http.MethodGet, "/ut", nil)
+	req.RemoteAddr = "10.0.0.2:8080"
+	req.Header.Set(rkmid.HeaderXForwardedFor, "2.2.2.2, 10.0.0.1")
+	req.Header.Set(rkmid.HeaderXForwardedHost, "ut-host")
+	ctx = set.BeforeCtx(req)
+	set.Before(ctx)
+	assert.Equal(t, req.Host, ctx.Output.Forwarded.Host)
+
+	// with TLS and without headers
+	req = httptest.NewRequest(http.MethodGet, "/ut", nil)
+	req.TLS = &tls.ConnectionState{}
+	ctx = set.BeforeCtx(req)
+	set.Before(ctx)
+	assert.Equal(t, "https", ctx.Output.Forwarded.Proto)
+}
+
+func TestOptionSet_ShouldIgnore(t *testing.T) {
+	set := NewOptionSet(WithPathToIgnore("/ut-ignore"))
+	ctx := set.BeforeCtx(httptest.NewRequest(http.MethodGet, "/ut-ignore", nil))
+	set.Before(ctx)
+	assert.Nil(t, ctx.Output.Forwarded)
+	assert.Nil(t, ctx.Output.Request)
+}
+
+func TestNewOptionSetMock(t *testing.T) {
+	mock := NewOptionSetMock(NewBeforeCtx())
+	assert.NotEmpty(t, mock.GetEntryName())
+	assert.NotEmpty(t, mock.GetEntryType())
+	assert.NotNil(t, mock.BeforeCtx(nil))
+	assert.Empty(t, mock.Config())
+	mock.Before(nil)
+	assert.False(t, mock.ShouldIgnore(""))
+}
+
+func TestOptionSet_Config(t *testing.T) {
+	set := NewOptionSet(
+		WithEntryNameAndType("ut-config", "ut-type"),
+		WithTrustedProxies("10.0.0.0/8"))
+	config := set.Config()
+	assert.Equal(t, "ut-config", config["entryName"])
+	assert.Equal(t, "ut-type", config["entryType"])
+	assert.Equal(t, []string{"10.0.0.0/8"}, config["trustedProxies"])
+}
+
+func assertPanic(t *testing.T) {
+	if r := recover(); r != nil {
+		// expect panic to be called with non nil error
+		assert.True(t, true)
+	} else {
+		// this should never be called in case of a bug
+		assert.True(t, false)
+	}
+}
+
+func assertNotPanic(t *testing.T) {
+	if r := recover(); r != nil {
+		// Expect panic to be called with non nil error
+		assert.True(t, false)
+	} else {
+		// This should never be called in case of a bug
+		assert.True(t, true)
+	}
+}
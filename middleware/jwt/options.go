@@ -7,18 +7,31 @@
 package rkmidjwt
 
 import (
+	"bytes"
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/rookie-ninja/rk-entry/v2/entry"
 	"github.com/rookie-ninja/rk-entry/v2/error"
 	"github.com/rookie-ninja/rk-entry/v2/middleware"
+	"io"
 	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 )
 
+// CookieSignatureSuffix is suffix of name of cookie which contains signature of JWT cookie
+const CookieSignatureSuffix = ".sig"
+
 var (
 	errJwtMissing = rkmid.GetErrorBuilder().New(http.StatusBadRequest, "Missing or malformed jwt")
 	errJwtInvalid = rkmid.GetErrorBuilder().New(http.StatusUnauthorized, "Invalid or expired jwt")
@@ -37,6 +50,8 @@ type OptionSetInterface interface {
 	BeforeCtx(*http.Request, context.Context) *BeforeCtx
 
 	ShouldIgnore(string) bool
+
+	Config() map[string]interface{}
 }
 
 // ***************** OptionSet Implementation *****************
@@ -61,12 +76,23 @@ type optionSet struct {
 	// implementation of rkentry.SignerJwt
 	signer rkentry.SignerJwt
 
+	// additional signers used while verifying tokens signed with different algorithms, like migrating HS256 to RS256.
+	// Signer will be chosen by alg of token and supported algorithms of signer.
+	// Optional. Default value empty.
+	signers []rkentry.SignerJwt
+
+	// acceptable signing algorithms of token, alg of none will never be accepted.
+	// Optional. Default value empty, which means algorithm will be validated by signer only.
+	signingAlgorithms []string
+
 	// TokenLookup is a string in the form of "<source>:<name>" or "<source>:<name>,<source>:<name>" that is used
 	// to extract token from the request.
 	// Optional. Default value "header:Authorization".
 	// Possible values:
 	// - "header:<name>"
 	// - "query:<name>"
+	// - "cookie:<name>"
+	// - "form:<name>"
 	// Multiply sources example:
 	// - "header: Authorization,cookie: myowncookie"
 	tokenLookup string
@@ -79,6 +105,35 @@ type optionSet struct {
 	// Optional. Default value "false".
 	skipVerify bool
 
+	// custom claims validator, invoked after signature verification.
+	// Optional. Default value nil.
+	claimsValidator func(jwt.Claims) error
+
+	// claims which must be present in token with expected values, like iss and aud.
+	// Optional. Default value empty.
+	requiredClaims map[string]interface{}
+
+	// max duration of user provided functions and signer verification, like extractor and JWKS fetch.
+	// Optional. Default value 0 which means no limit.
+	operationTimeout time.Duration
+
+	// tolerance of clock skew while validating exp, nbf and iat claims.
+	// Optional. Default value 0.
+	leeway time.Duration
+
+	// url of JWKS, keys will be fetched and refreshed by signer registered with rkentry.RegisterJwksSigner.
+	// Optional. Default value empty.
+	jwksUrl  string
+	jwksOpts []rkentry.JwksSignerOption
+
+	// resolve path param of request with name, provided by framework adapters since http.Request carries no params.
+	// Optional. Default value nil.
+	paramExtractor func(*http.Request, string) string
+
+	// HMAC key of signature cookie, token in cookie will be trusted only if signature matches.
+	// Optional. Default value nil.
+	cookieSignatureKey []byte
+
 	mock OptionSetInterface
 }
 
@@ -96,6 +151,16 @@ func NewOptionSet(opts ...Option) OptionSetInterface {
 		opts[i](set)
 	}
 
+	// verify tokens issued by IdP with keys of JWKS, as additional signer if signer provided
+	if len(set.jwksUrl) > 0 {
+		jwks := rkentry.RegisterJwksSigner(set.entryName+"-jwks", set.jwksUrl, set.jwksOpts...)
+		if set.signer == nil {
+			set.signer = jwks
+		} else {
+			set.signers = append(set.signers, jwks)
+		}
+	}
+
 	if set.signer == nil && !set.skipVerify {
 		set.signer = rkentry.RegisterSymmetricJwtSigner(set.entryName, jwt.SigningMethodHS256.Name, []byte("rk jwt key"))
 	}
@@ -114,7 +179,11 @@ func NewOptionSet(opts ...Option) OptionSetInterface {
 		case "header":
 			set.extractors = append(set.extractors, jwtFromHeader(parts[1], set.authScheme))
 		case "cookie":
-			set.extractors = append(set.extractors, jwtFromCookie(parts[1]))
+			set.extractors = append(set.extractors, jwtFromCookie(parts[1], set.cookieSignatureKey))
+		case "form":
+			set.extractors = append(set.extractors, jwtFromForm(parts[1]))
+		case "param":
+			set.extractors = append(set.extractors, jwtFromParam(parts[1], set.paramExtractor))
 		}
 	}
 
@@ -131,6 +200,46 @@ func (set *optionSet) GetEntryType() string {
 	return set.entryType
 }
 
+// Config returns effective configuration, secrets are redacted
+func (set *optionSet) Config() map[string]interface{} {
+	// keys of signer are redacted, only name of signer will be returned
+	signerEntry := ""
+	if set.signer != nil {
+		signerEntry = set.signer.GetName()
+	}
+
+	signerEntries := make([]string, 0)
+	for i := range set.signers {
+		signerEntries = append(signerEntries, set.signers[i].GetName())
+	}
+
+	requiredClaimKeys := make([]string, 0)
+	for k := range set.requiredClaims {
+		requiredClaimKeys = append(requiredClaimKeys, k)
+	}
+	sort.Strings(requiredClaimKeys)
+
+	return map[string]interface{}{
+		"entryName":        set.entryName,
+		"entryType":        set.entryType,
+		"signerEntry":      signerEntry,
+		"signerEntries":    signerEntries,
+		"algorithms":       set.signingAlgorithms,
+		"tokenLookup":      set.tokenLookup,
+		"jwksUrl":          set.jwksUrl,
+		"authScheme":       set.authScheme,
+		"skipVerify":       set.skipVerify,
+		"extractor":        set.extractor != nil,
+		"paramExtractor":   set.paramExtractor != nil,
+		"claimsValidator":  set.claimsValidator != nil,
+		"requiredClaims":   requiredClaimKeys,
+		"leeway":           set.leeway.String(),
+		"operationTimeout": set.operationTimeout.String(),
+		"cookieSignature":  len(set.cookieSignatureKey) > 0,
+		"pathToIgnore":     set.pathToIgnore,
+	}
+}
+
 // BeforeCtx should be created before Before()
 func (set *optionSet) BeforeCtx(req *http.Request, userCtx context.Context) *BeforeCtx {
 	ctx := NewBeforeCtx()
@@ -150,18 +259,31 @@ func (set *optionSet) Before(ctx *BeforeCtx) {
 	if ctx == nil || set.ShouldIgnore(ctx.Input.UrlPath) {
 		return
 	}
+
+	defer func() {
+		ctx.Output.ErrResp = rkmid.AttachTraceId(ctx.Input.Request, ctx.Input.UserCtx, ctx.Output.ErrResp)
+	}()
+
 	var authRaw string
 	var err error
 	var token *jwt.Token
 
 	if set.extractor != nil { // case 1: if user extractor exists, use it!
-		authRaw, err = set.extractor(ctx.Input.UserCtx)
+		err = rkmid.CallWithTimeout(ctx.Input.UserCtx, set.operationTimeout, func(c context.Context) error {
+			raw, e := set.extractor(c)
+			if e == nil {
+				authRaw = raw
+			}
+			return e
+		})
 		if err != nil {
 			ctx.Output.ErrResp = errJwtInvalid
 			return
 		}
 
 	} else { // case 2: use default
+		// token is missing unless one of extractors found token but rejected it, like cookie with bad signature
+		errResp := errJwtMissing
 		for _, extractor := range set.extractors {
 			// Extract token from extractor, if it's not fail break the loop and
 			// set auth
@@ -169,9 +291,13 @@ func (set *optionSet) Before(ctx *BeforeCtx) {
 			if err == nil {
 				break
 			}
+
+			if err == error(errJwtInvalid) {
+				errResp = errJwtInvalid
+			}
 		}
 		if err != nil {
-			ctx.Output.ErrResp = errJwtInvalid
+			ctx.Output.ErrResp = errResp
 			return
 		}
 	}
@@ -181,8 +307,14 @@ func (set *optionSet) Before(ctx *BeforeCtx) {
 		parser := &jwt.Parser{}
 		token, _, err = parser.ParseUnverified(authRaw, claims)
 	} else {
-		// case 2: parse and validate token
-		token, err = set.signer.VerifyJwt(authRaw)
+		// case 2: parse and validate token, keys may be fetched by signer, like JWKS signer
+		err = rkmid.CallWithTimeout(ctx.Input.UserCtx, set.operationTimeout, func(c context.Context) error {
+			t, e := set.verify(c, authRaw)
+			if e == nil {
+				token = t
+			}
+			return e
+		})
 	}
 
 	if err != nil {
@@ -190,9 +322,108 @@ func (set *optionSet) Before(ctx *BeforeCtx) {
 		return
 	}
 
+	// case 3: validate required claims
+	if len(set.requiredClaims) > 0 {
+		if err = validateRequiredClaims(token.Claims, set.requiredClaims); err != nil {
+			ctx.Output.ErrResp = errJwtInvalid
+			return
+		}
+	}
+
+	// case 4: validate claims with user provided validator
+	if set.claimsValidator != nil {
+		err = rkmid.CallWithTimeout(ctx.Input.UserCtx, set.operationTimeout, func(context.Context) error {
+			return set.claimsValidator(token.Claims)
+		})
+		if err != nil {
+			ctx.Output.ErrResp = errJwtInvalid
+			return
+		}
+	}
+
 	ctx.Output.JwtToken = token
 }
 
+// Verify token with signer bound to alg of token.
+//
+// Signer will be chosen only if alg is one of algorithms supported by signer, so that key of one family,
+// like public key of RS256, will never be used to verify token of another family, like HS256.
+func (set *optionSet) verify(ctx context.Context, raw string) (*jwt.Token, error) {
+	// keep original behavior if neither algorithms nor additional signers provided
+	if len(set.signingAlgorithms) < 1 && len(set.signers) < 1 {
+		return set.verifyWithSigner(ctx, set.signer, raw)
+	}
+
+	unverified, _, err := jwt.NewParser().ParseUnverified(raw, jwt.MapClaims{})
+	if err != nil {
+		return nil, err
+	}
+
+	alg := unverified.Method.Alg()
+	if alg == jwt.SigningMethodNone.Alg() {
+		return nil, errors.New("jwt signing algorithm none is not allowed")
+	}
+
+	if len(set.signingAlgorithms) > 0 && !containsString(set.signingAlgorithms, alg) {
+		return nil, fmt.Errorf("unexpected jwt signing algorithm=%s", alg)
+	}
+
+	err = fmt.Errorf("no signer found for jwt signing algorithm=%s", alg)
+	for _, signer := range append([]rkentry.SignerJwt{set.signer}, set.signers...) {
+		if signer == nil || !containsString(signer.Algorithms(), alg) {
+			continue
+		}
+
+		var token *jwt.Token
+		if token, err = set.verifyWithSigner(ctx, signer, raw); err == nil {
+			return token, nil
+		}
+	}
+
+	return nil, err
+}
+
+// Verify token with signer, time-based claims will be validated with leeway if provided.
+//
+// Leeway requires signer implements rkentry.SignerJwtKeyFunc, signature will be verified by parser with key of signer
+// while claims validation skipped, then time-based claims of verified token will be validated with leeway.
+// Signer without key func will verify token without leeway.
+func (set *optionSet) verifyWithSigner(ctx context.Context, signer rkentry.SignerJwt, raw string) (*jwt.Token, error) {
+	keyFunc, ok := signer.(rkentry.SignerJwtKeyFunc)
+	if set.leeway <= 0 || !ok {
+		return verifyJwt(ctx, signer, raw)
+	}
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	claims := jwt.MapClaims{}
+	token, err := jwt.NewParser(jwt.WithoutClaimsValidation()).ParseWithClaims(raw, claims, keyFunc.KeyFunc(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	if !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+
+	if err = validateTimeClaimsWithLeeway(claims, set.leeway); err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// Verify token with context if supported by signer, like JWKS signer which fetches keys on demand
+func verifyJwt(ctx context.Context, signer rkentry.SignerJwt, raw string) (*jwt.Token, error) {
+	if v, ok := signer.(rkentry.SignerJwtWithContext); ok && ctx != nil {
+		return v.VerifyJwtWithContext(ctx, raw)
+	}
+
+	return signer.VerifyJwt(raw)
+}
+
 // ShouldIgnore determine whether auth should be ignored based on path
 func (set *optionSet) ShouldIgnore(path string) bool {
 	for i := range set.pathToIgnore {
@@ -227,6 +458,11 @@ func (mock *optionSetMock) GetEntryType() string {
 	return "mock"
 }
 
+// Config returns empty map
+func (mock *optionSetMock) Config() map[string]interface{} {
+	return map[string]interface{}{}
+}
+
 // BeforeCtx should be created before Before()
 func (mock *optionSetMock) BeforeCtx(request *http.Request, userCtx context.Context) *BeforeCtx {
 	return mock.before
@@ -267,14 +503,27 @@ type BeforeCtx struct {
 
 // BootConfig for YAML
 type BootConfig struct {
-	Enabled     bool              `yaml:"enabled" json:"enabled"`
-	Ignore      []string          `yaml:"ignore" json:"ignore"`
-	SignerEntry string            `yaml:"signerEntry" json:"signerEntry"`
-	Symmetric   *SymmetricConfig  `yaml:"symmetric" json:"symmetric"`
-	Asymmetric  *AsymmetricConfig `yaml:"asymmetric" json:"asymmetric"`
-	TokenLookup string            `yaml:"tokenLookup" json:"tokenLookup"`
-	AuthScheme  string            `yaml:"authScheme" json:"authScheme"`
-	SkipVerify  bool              `yaml:"skipVerify" json:"skipVerify"`
+	Enabled            bool              `yaml:"enabled" json:"enabled"`
+	Ignore             []string          `yaml:"ignore" json:"ignore"`
+	SignerEntry        string            `yaml:"signerEntry" json:"signerEntry"`
+	StrictSignerEntry  bool              `yaml:"strictSignerEntry" json:"strictSignerEntry"`
+	Symmetric          *SymmetricConfig  `yaml:"symmetric" json:"symmetric"`
+	Asymmetric         *AsymmetricConfig `yaml:"asymmetric" json:"asymmetric"`
+	TokenLookup        string            `yaml:"tokenLookup" json:"tokenLookup"`
+	AuthScheme         string            `yaml:"authScheme" json:"authScheme"`
+	SkipVerify         bool              `yaml:"skipVerify" json:"skipVerify"`
+	SigningAlgorithms  []string          `yaml:"signingAlgorithms" json:"signingAlgorithms"`
+	Jwks               *JwksConfig       `yaml:"jwks" json:"jwks"`
+	LeewaySeconds      int               `yaml:"leewaySeconds" json:"leewaySeconds"`
+	Issuer             string            `yaml:"issuer" json:"issuer"`
+	Audience           string            `yaml:"audience" json:"audience"`
+	OperationTimeoutMs int64             `yaml:"operationTimeoutMs" json:"operationTimeoutMs"`
+}
+
+type JwksConfig struct {
+	Url                string `yaml:"url" json:"url"`
+	RefreshIntervalMs  int64  `yaml:"refreshIntervalMs" json:"refreshIntervalMs"`
+	InsecureSkipVerify bool   `yaml:"insecureSkipVerify" json:"insecureSkipVerify"`
 }
 
 type SymmetricConfig struct {
@@ -292,14 +541,26 @@ type AsymmetricConfig struct {
 }
 
 // ToOptions convert BootConfig into Option list
+//
+// If StrictSignerEntry is true, it will shutdown if SignerEntry could not be found instead of falling back to
+// symmetric or asymmetric config, please make sure signer entry was registered before middleware.
+//
+// If SigningAlgorithms provided with both of asymmetric and symmetric config, symmetric signer will be used as
+// additional signer, which is useful while migrating algorithms.
 func ToOptions(config *BootConfig, entryName, entryType string) []Option {
 	opts := make([]Option, 0)
 
 	if config.Enabled {
 		var signerJwt rkentry.SignerJwt
 
+		// fail closed if signer entry was referenced but not registered in strict mode
+		signerEntry := rkentry.GlobalAppCtx.GetEntry(rkentry.SignerJwtEntryType, config.SignerEntry)
+		if config.StrictSignerEntry && len(config.SignerEntry) > 0 && signerEntry == nil {
+			rkentry.ShutdownWithError(fmt.Errorf("cannot find signer entry with name:%s", config.SignerEntry))
+		}
+
 		// check signer entry first
-		if v := rkentry.GlobalAppCtx.GetEntry(rkentry.SignerJwtEntryType, config.SignerEntry); v != nil {
+		if v := signerEntry; v != nil {
 			signer, ok := v.(rkentry.SignerJwt)
 			if !ok {
 				rkentry.ShutdownWithError(errors.New("invalid signer jwt entry"))
@@ -329,33 +590,70 @@ func ToOptions(config *BootConfig, entryName, entryType string) []Option {
 				rkentry.ShutdownWithError(errors.New("invalid asymmetric configuration"))
 			}
 		} else if config.Symmetric != nil {
-			var token []byte
-			if len(config.Symmetric.Token) > 0 {
-				token = []byte(config.Symmetric.Token)
-			} else {
-				token = mustRead(config.Symmetric.TokenPath)
-			}
+			signerJwt = registerSymmetricSigner(entryName, config.Symmetric)
+		} else if config.Jwks != nil && len(config.Jwks.Url) > 0 {
+			signerJwt = rkentry.RegisterJwksSigner(entryName, config.Jwks.Url,
+				rkentry.WithRefreshIntervalJwksSigner(time.Duration(config.Jwks.RefreshIntervalMs)*time.Millisecond),
+				rkentry.WithInsecureSkipVerifyJwksSigner(config.Jwks.InsecureSkipVerify))
+		}
 
-			signerJwt = rkentry.RegisterSymmetricJwtSigner(entryName, config.Symmetric.Algorithm, token)
-			if signerJwt == nil {
-				rkentry.ShutdownWithError(errors.New("invalid symmetric configuration"))
-			}
+		// register symmetric signer as additional signer during algorithm migration
+		if config.Asymmetric != nil && config.Symmetric != nil && len(config.SigningAlgorithms) > 0 {
+			opts = append(opts, WithSigners(registerSymmetricSigner(entryName+"-symmetric", config.Symmetric)))
 		}
 
-		opts = []Option{
+		// require iss and aud claims if provided
+		requiredClaims := make(map[string]interface{})
+		if len(config.Issuer) > 0 {
+			requiredClaims["iss"] = config.Issuer
+		}
+		if len(config.Audience) > 0 {
+			requiredClaims["aud"] = config.Audience
+		}
+
+		opts = append(opts,
 			WithEntryNameAndType(entryName, entryType),
+			WithRequiredClaims(requiredClaims),
 			WithTokenLookup(config.TokenLookup),
 			WithSigner(signerJwt),
+			WithSigningAlgorithms(config.SigningAlgorithms...),
+			WithLeeway(time.Duration(config.LeewaySeconds)*time.Second),
+			WithOperationTimeout(time.Duration(config.OperationTimeoutMs)*time.Millisecond),
 			WithAuthScheme(config.AuthScheme),
 			WithPathToIgnore(config.Ignore...),
-			WithSkipVerify(config.SkipVerify),
-		}
+			WithSkipVerify(config.SkipVerify))
 
 	}
 
 	return opts
 }
 
+func registerSymmetricSigner(entryName string, config *SymmetricConfig) rkentry.SignerJwt {
+	var token []byte
+	if len(config.Token) > 0 {
+		token = []byte(config.Token)
+	} else {
+		token = mustRead(config.TokenPath)
+	}
+
+	signerJwt := rkentry.RegisterSymmetricJwtSigner(entryName, config.Algorithm, token)
+	if signerJwt == nil {
+		rkentry.ShutdownWithError(errors.New("invalid symmetric configuration"))
+	}
+
+	return signerJwt
+}
+
+func containsString(list []string, e string) bool {
+	for i := range list {
+		if list[i] == e {
+			return true
+		}
+	}
+
+	return false
+}
+
 func mustRead(p string) []byte {
 	if !filepath.IsAbs(p) {
 		wd, _ := os.Getwd()
@@ -390,6 +688,42 @@ func WithSigner(signer rkentry.SignerJwt) Option {
 	}
 }
 
+// WithSigners provide additional rkentry.SignerJwt, like signer of new algorithm while migrating from HS256 to RS256.
+// Token will be verified by signer whose supported algorithms contains alg of token.
+func WithSigners(signers ...rkentry.SignerJwt) Option {
+	return func(opt *optionSet) {
+		for i := range signers {
+			if signers[i] != nil {
+				opt.signers = append(opt.signers, signers[i])
+			}
+		}
+	}
+}
+
+// WithJwks provide url of JWKS published by IdP, token will be verified with key matched with kid of token.
+// Keys will be refreshed in background, see rkentry.RegisterJwksSigner for details.
+// If signer provided with WithSigner, JWKS signer will be used as additional signer.
+func WithJwks(url string, opts ...rkentry.JwksSignerOption) Option {
+	return func(opt *optionSet) {
+		if len(url) > 0 {
+			opt.jwksUrl = url
+			opt.jwksOpts = opts
+		}
+	}
+}
+
+// WithSigningAlgorithms provide acceptable signing algorithms of token, like HS256 and RS256.
+// Token with alg not in the list will be rejected, alg of none will never be accepted.
+func WithSigningAlgorithms(algs ...string) Option {
+	return func(opt *optionSet) {
+		for i := range algs {
+			if len(algs[i]) > 0 && algs[i] != jwt.SigningMethodNone.Alg() {
+				opt.signingAlgorithms = append(opt.signingAlgorithms, algs[i])
+			}
+		}
+	}
+}
+
 // WithExtractor provide user extractor
 func WithExtractor(ex JwtExtractor) Option {
 	return func(opt *optionSet) {
@@ -397,6 +731,79 @@ func WithExtractor(ex JwtExtractor) Option {
 	}
 }
 
+// WithParamExtractor provide function which returns path param of request with name, like param getter of router.
+// It is required by token lookup source of param, since http.Request does not carry path params.
+func WithParamExtractor(f func(*http.Request, string) string) Option {
+	return func(opt *optionSet) {
+		if f != nil {
+			opt.paramExtractor = f
+		}
+	}
+}
+
+// WithClaimsValidator provide custom validator of claims, like checking scope or tenant.
+// Validator will be invoked after signature verification, errJwtInvalid will be returned if validator fails.
+func WithClaimsValidator(f func(jwt.Claims) error) Option {
+	return func(opt *optionSet) {
+		if f != nil {
+			opt.claimsValidator = f
+		}
+	}
+}
+
+// WithRequiredClaims provide claims which must be present in token with expected values, like iss and aud.
+// Array-valued claim, like aud with multiple audiences, matches if expected value is contained in it.
+// errJwtInvalid will be returned if any of required claims is absent or mismatched.
+func WithRequiredClaims(claims map[string]interface{}) Option {
+	return func(opt *optionSet) {
+		for k, v := range claims {
+			if len(k) < 1 {
+				continue
+			}
+
+			if opt.requiredClaims == nil {
+				opt.requiredClaims = make(map[string]interface{})
+			}
+			opt.requiredClaims[k] = v
+		}
+	}
+}
+
+// WithOperationTimeout provide max duration of user provided extractor, claims validator and signer verification,
+// which may fetch keys of JWKS on demand, so that misbehaving function could not block request indefinitely.
+// errJwtInvalid will be returned on timeout. No limit by default.
+func WithOperationTimeout(d time.Duration) Option {
+	return func(opt *optionSet) {
+		if d > 0 {
+			opt.operationTimeout = d
+		}
+	}
+}
+
+// WithLeeway provide tolerance of clock skew while validating exp, nbf and iat claims,
+// token expired or issued in the future within leeway will be accepted.
+// Leeway only loosens time-based claims, token with invalid signature will always be rejected.
+// Signer should implement rkentry.SignerJwtKeyFunc, like built-in signers, otherwise leeway will be ignored.
+func WithLeeway(leeway time.Duration) Option {
+	return func(opt *optionSet) {
+		if leeway > 0 {
+			opt.leeway = leeway
+		}
+	}
+}
+
+// WithCookieSignatureKey provide HMAC key of signature cookie, token extracted from cookie will be trusted only if
+// adjacent cookie named with CookieSignatureSuffix, like jwt.sig for cookie jwt, contains signature of token,
+// so that tampered cookie will be rejected cheaply before parsing JWT. Use SignCookieValue to sign token.
+// errJwtInvalid will be returned if signature is missing or mismatched.
+func WithCookieSignatureKey(key []byte) Option {
+	return func(opt *optionSet) {
+		if len(key) > 0 {
+			opt.cookieSignatureKey = key
+		}
+	}
+}
+
 // WithTokenLookup provide lookup configs.
 // TokenLookup is a string in the form of "<source>:<name>" or "<source>:<name>,<source>:<name>" that is used
 // to extract token from the request.
@@ -405,8 +812,13 @@ func WithExtractor(ex JwtExtractor) Option {
 // - "header:<name>"
 // - "query:<name>"
 // - "cookie:<name>"
+// - "form:<name>"
+// - "param:<name>", requires WithParamExtractor
 // Multiply sources example:
 // - "header: Authorization,cookie: myowncookie"
+//
+// Notice: form source needs to parse request body. The body will be restored after parsing,
+// however, adapters which wrap body with non-rewindable reader must re-buffer it before passing to user handler.
 func WithTokenLookup(lookup string) Option {
 	return func(opt *optionSet) {
 		if len(lookup) > 0 {
@@ -453,6 +865,91 @@ func WithMockOptionSet(mock OptionSetInterface) Option {
 
 // ***************** Types *****************
 
+// validateTimeClaimsWithLeeway validate exp, nbf and iat claims with tolerance of leeway
+func validateTimeClaimsWithLeeway(claims jwt.MapClaims, leeway time.Duration) error {
+	now := time.Now()
+
+	if !claims.VerifyExpiresAt(now.Add(-leeway).Unix(), false) {
+		return errors.New("token is expired")
+	}
+
+	if !claims.VerifyIssuedAt(now.Add(leeway).Unix(), false) {
+		return errors.New("token used before issued")
+	}
+
+	if !claims.VerifyNotBefore(now.Add(leeway).Unix(), false) {
+		return errors.New("token is not valid yet")
+	}
+
+	return nil
+}
+
+// validateRequiredClaims validate that claims contains all of required claims with expected values.
+//
+// Claims other than jwt.MapClaims, like jwt.RegisteredClaims or user defined struct, are converted with JSON encoding.
+func validateRequiredClaims(claims jwt.Claims, required map[string]interface{}) error {
+	mapClaims, err := toMapClaims(claims)
+	if err != nil {
+		return err
+	}
+
+	for k, expected := range required {
+		actual, ok := mapClaims[k]
+		if !ok {
+			return fmt.Errorf("missing required claim %s", k)
+		}
+
+		if !claimMatches(actual, expected) {
+			return fmt.Errorf("mismatched required claim %s", k)
+		}
+	}
+
+	return nil
+}
+
+// toMapClaims converts claims into jwt.MapClaims by JSON round trip, so that claims are keyed by JSON names
+func toMapClaims(claims jwt.Claims) (jwt.MapClaims, error) {
+	if mapClaims, ok := claims.(jwt.MapClaims); ok {
+		return mapClaims, nil
+	}
+
+	raw, err := json.Marshal(claims)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported jwt claims, %v", err)
+	}
+
+	mapClaims := jwt.MapClaims{}
+	if err = json.Unmarshal(raw, &mapClaims); err != nil {
+		return nil, fmt.Errorf("unsupported jwt claims, %v", err)
+	}
+
+	return mapClaims, nil
+}
+
+// claimMatches returns true if actual claim equals to expected value or contains it if actual claim is an array.
+// Values are compared with string representation, since numbers in claims are decoded as float64.
+func claimMatches(actual, expected interface{}) bool {
+	if values, ok := actual.([]interface{}); ok {
+		for i := range values {
+			if claimMatches(values[i], expected) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return claimString(actual) == claimString(expected)
+}
+
+// claimString returns string representation of claim value, float is formatted without exponent
+func claimString(v interface{}) string {
+	if f, ok := v.(float64); ok {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+
+	return fmt.Sprintf("%v", v)
+}
+
 // ParseTokenFunc parse token func
 type ParseTokenFunc func(auth string) (*jwt.Token, error)
 
@@ -493,7 +990,9 @@ func jwtFromQuery(name string) jwtHttpExtractor {
 }
 
 // jwtFromCookie returns a `jwtExtractor` that extracts token from the cookie.
-func jwtFromCookie(name string) jwtHttpExtractor {
+//
+// If signature key provided, token will be returned only if signature cookie matches.
+func jwtFromCookie(name string, signatureKey []byte) jwtHttpExtractor {
 	return func(req *http.Request) (string, error) {
 		if req == nil || req.URL == nil {
 			return "", errJwtMissing
@@ -504,6 +1003,100 @@ func jwtFromCookie(name string) jwtHttpExtractor {
 			return "", err
 		}
 
+		if len(signatureKey) < 1 {
+			return cookie.Value, nil
+		}
+
+		sig, err := req.Cookie(name + CookieSignatureSuffix)
+		if err != nil || !hmac.Equal([]byte(sig.Value), []byte(SignCookieValue(signatureKey, cookie.Value))) {
+			return "", errJwtInvalid
+		}
+
 		return cookie.Value, nil
 	}
 }
+
+// SignCookieValue returns base64 url encoded HMAC-SHA256 of value, which should be set as value of
+// signature cookie while issuing JWT cookie, if middleware created with WithCookieSignatureKey.
+func SignCookieValue(key []byte, value string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(value))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// jwtFromForm returns a `jwtExtractor` that extracts token from the form field.
+//
+// Request body will be read while parsing form, and restored afterwards.
+func jwtFromForm(name string) jwtHttpExtractor {
+	return func(req *http.Request) (string, error) {
+		if req == nil || req.URL == nil {
+			return "", errJwtMissing
+		}
+
+		if err := parseFormAndRestoreBody(req); err != nil {
+			return "", errJwtMissing
+		}
+
+		token := req.Form.Get(name)
+		if token == "" {
+			return "", errJwtMissing
+		}
+
+		return token, nil
+	}
+}
+
+// jwtFromParam returns a `jwtExtractor` that extracts token from the path param resolved by paramExtractor.
+func jwtFromParam(name string, paramExtractor func(*http.Request, string) string) jwtHttpExtractor {
+	return func(req *http.Request) (string, error) {
+		if req == nil || paramExtractor == nil {
+			return "", errJwtMissing
+		}
+
+		token := paramExtractor(req, name)
+		if token == "" {
+			return "", errJwtMissing
+		}
+
+		return token, nil
+	}
+}
+
+// maxFormBytes is max bytes of request body read while parsing form, which is the same as http.Request.ParseForm
+const maxFormBytes = int64(10 << 20)
+
+// parseFormAndRestoreBody parse form of request and restore request body so that it could be read again.
+//
+// At most maxFormBytes will be read, error will be returned if body is larger than that.
+func parseFormAndRestoreBody(req *http.Request) error {
+	if req.Body == nil || req.Body == http.NoBody {
+		return req.ParseForm()
+	}
+
+	body := req.Body
+	raw, err := io.ReadAll(io.LimitReader(body, maxFormBytes+1))
+	if err != nil {
+		body.Close()
+		return err
+	}
+
+	if int64(len(raw)) > maxFormBytes {
+		// keep unread bytes, so that body is still complete for following handlers
+		req.Body = &multiReadCloser{Reader: io.MultiReader(bytes.NewReader(raw), body), Closer: body}
+		return errors.New("request body too large")
+	}
+
+	body.Close()
+	req.Body = io.NopCloser(bytes.NewReader(raw))
+	err = req.ParseForm()
+	req.Body = io.NopCloser(bytes.NewReader(raw))
+
+	return err
+}
+
+// multiReadCloser reads bytes already consumed before remaining of original body, and closes original body
+type multiReadCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -7,23 +7,48 @@
 package rkmidlog
 
 import (
+	"fmt"
 	"github.com/rookie-ninja/rk-entry/v2/entry"
+	"github.com/rookie-ninja/rk-entry/v2/error"
 	"github.com/rookie-ninja/rk-entry/v2/middleware"
+	"github.com/rookie-ninja/rk-entry/v2/middleware/prom"
 	"github.com/rookie-ninja/rk-logger"
 	"github.com/rookie-ninja/rk-query"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/propagation"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc/codes"
+	"math/rand"
 	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
 )
 
 const (
+	// MetricsNameIgnoredRequests records number of requests whose path was ignored
+	MetricsNameIgnoredRequests = "ignoredRequests"
+
 	// Console console encoding style of logging
 	console = "console"
 	// Json console encoding style of logging
 	json = "json"
+	// auto resolves to console in dev/local environment and json otherwise
+	auto = "auto"
+
+	// truncatedMarker appended to payload truncated because of WithMaxEventPayloadBytes
+	truncatedMarker = "...[truncated]"
+
+	// default event keys of trace id and request id defined in rkquery
+	defaultTraceIdField   = "traceId"
+	defaultRequestIdField = "requestId"
 )
 
 // ***************** OptionSet Interface *****************
@@ -43,6 +68,21 @@ type OptionSetInterface interface {
 	After(before *BeforeCtx, after *AfterCtx)
 
 	ShouldIgnore(string) bool
+
+	Config() map[string]interface{}
+}
+
+// LevelInterface is implemented by optionSet and mock, levels could be changed at runtime by admin endpoint.
+//
+// It is separated from OptionSetInterface so that existing implementations of OptionSetInterface will not break.
+type LevelInterface interface {
+	GetLevel() zap.AtomicLevel
+
+	SetLevel(zapcore.Level)
+
+	GetEventLevel() zap.AtomicLevel
+
+	SetEventLevel(zapcore.Level)
 }
 
 // ***************** OptionSet Implementation *****************
@@ -60,7 +100,34 @@ type optionSet struct {
 	eventLoggerOutputPath []string
 	eventLoggerOverride   *zap.Logger
 	pathToIgnore          []string
-	mock                  OptionSetInterface
+	skipSuccessfulEvent   time.Duration
+	eventOnErrorOnly      bool
+	eventSampleRates      map[string]float64
+	maxEventPayloadBytes  int
+	responseHeadersToLog  []string
+	retryHeader           string
+	traceIdField          string
+	requestIdField        string
+	bodyHashMaxBytes      int64
+	resBodyMaxBytes       int64
+	baggageKeys           []string
+	eventDestinationFunc  func(*http.Request) string
+	eventDestinations     map[string]*rkentry.EventEntry
+	ignoredMetricsSet     *rkmidprom.MetricsSet
+	asyncQueue            chan rkquery.Event
+	// asyncLock guards asyncPending and asyncClosed, asyncCond is signaled once pending events are finished
+	asyncLock      sync.Mutex
+	asyncCond      *sync.Cond
+	asyncPending   int
+	asyncClosed    bool
+	asyncStop      chan struct{}
+	asyncDone      chan struct{}
+	asyncCloseOnce sync.Once
+	droppedEvents  uint64
+	classifier     rkmid.RequestClassifier
+	accessLevel    zap.AtomicLevel
+	eventLevel     zap.AtomicLevel
+	mock           OptionSetInterface
 }
 
 // NewOptionSet Create new optionSet with options.
@@ -73,7 +140,10 @@ func NewOptionSet(opts ...Option) OptionSetInterface {
 		zapLogger:             rkentry.LoggerEntryStdout.Logger,
 		zapLoggerOutputPath:   make([]string, 0),
 		eventLoggerOutputPath: make([]string, 0),
+		eventSampleRates:      make(map[string]float64),
 		pathToIgnore:          []string{},
+		traceIdField:          defaultTraceIdField,
+		requestIdField:        defaultRequestIdField,
 	}
 
 	for i := range opts {
@@ -84,37 +154,69 @@ func NewOptionSet(opts ...Option) OptionSetInterface {
 		return set.mock
 	}
 
+	if set.ignoredMetricsSet != nil {
+		// ignore error of duplicate registration
+		set.ignoredMetricsSet.RegisterCounter(MetricsNameIgnoredRequests, "entryName", "path")
+	}
+
+	// each option set owns its levels, initialized with levels of configs, so that LoggerEntry shared with
+	// other middlewares and entries will not be affected while changing levels
+	set.accessLevel = levelOf(set.loggerEntry.LoggerConfig)
+	set.eventLevel = levelOf(set.eventEntry.LoggerConfig)
+
+	// Build own zap logger from copy of config, encoding and output path will be overridden if provided by user
 	set.zapLogger = set.loggerEntry.Logger
+	if config := set.loggerEntry.LoggerConfig; config != nil {
+		own := *config
+		own.Level = set.accessLevel
+		lumberjack := set.loggerEntry.LumberjackConfig
 
-	// Override zap logger encoding and output path if provided by user
-	// Override encoding type
-	if set.zapLoggerEncoding == json || len(set.zapLoggerOutputPath) > 0 {
 		if set.zapLoggerEncoding == json {
-			set.loggerEntry.LoggerConfig.Encoding = "json"
+			own.Encoding = "json"
 		}
 
 		if len(set.zapLoggerOutputPath) > 0 {
-			set.loggerEntry.LoggerConfig.OutputPaths = toAbsPath(set.zapLoggerOutputPath...)
-		}
-
-		if set.loggerEntry.LumberjackConfig == nil {
-			set.loggerEntry.LumberjackConfig = rklogger.NewLumberjackConfigDefault()
+			own.OutputPaths = toAbsPath(set.zapLoggerOutputPath...)
+			if lumberjack == nil {
+				lumberjack = rklogger.NewLumberjackConfigDefault()
+			}
 		}
 
-		if logger, err := rklogger.NewZapLoggerWithConf(set.loggerEntry.LoggerConfig, set.loggerEntry.LumberjackConfig); err != nil {
+		if logger, err := rklogger.NewZapLoggerWithConf(&own, lumberjack); err != nil {
 			rkentry.ShutdownWithError(err)
 		} else {
 			set.zapLogger = logger.WithOptions(zap.WithCaller(true))
 		}
+	} else {
+		// level could only be raised without config, since logger of LoggerEntry could not be rebuilt
+		set.zapLogger = set.zapLogger.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+			return &levelCore{Core: core, level: set.accessLevel}
+		}))
 	}
 
-	// Override event logger output path if provided by user
-	if len(set.eventLoggerOutputPath) > 0 {
-		set.eventEntry.LoggerConfig.OutputPaths = toAbsPath(set.eventLoggerOutputPath...)
-		if set.eventEntry.LumberjackConfig == nil {
-			set.eventEntry.LumberjackConfig = rklogger.NewLumberjackConfigDefault()
+	// Start background flusher if async mode enabled, remaining events will be flushed while shutting down
+	set.asyncCond = sync.NewCond(&set.asyncLock)
+	if set.asyncQueue != nil {
+		set.asyncStop = make(chan struct{})
+		set.asyncDone = make(chan struct{})
+		go set.flushEvents()
+		rkentry.GlobalAppCtx.AddShutdownHook("rk-log-async-"+set.entryName, set.Close)
+	}
+
+	// Build own event logger from copy of config, output path will be overridden if provided by user
+	if config := set.eventEntry.LoggerConfig; config != nil {
+		own := *config
+		own.Level = set.eventLevel
+		lumberjack := set.eventEntry.LumberjackConfig
+
+		if len(set.eventLoggerOutputPath) > 0 {
+			own.OutputPaths = toAbsPath(set.eventLoggerOutputPath...)
+			if lumberjack == nil {
+				lumberjack = rklogger.NewLumberjackConfigDefault()
+			}
 		}
-		if logger, err := rklogger.NewZapLoggerWithConf(set.eventEntry.LoggerConfig, set.eventEntry.LumberjackConfig); err != nil {
+
+		if logger, err := rklogger.NewZapLoggerWithConf(&own, lumberjack); err != nil {
 			rkentry.ShutdownWithError(err)
 		} else {
 			set.eventLoggerOverride = logger
@@ -124,6 +226,40 @@ func NewOptionSet(opts ...Option) OptionSetInterface {
 	return set
 }
 
+// levelOf returns new AtomicLevel initialized with current level of zap config, info level if config is missing
+func levelOf(config *zap.Config) zap.AtomicLevel {
+	if config == nil || config.Level == (zap.AtomicLevel{}) {
+		return zap.NewAtomicLevel()
+	}
+
+	return zap.NewAtomicLevelAt(config.Level.Level())
+}
+
+// levelCore filters entries by level of option set before passing them to core of LoggerEntry without config
+type levelCore struct {
+	zapcore.Core
+	level zap.AtomicLevel
+}
+
+// Enabled returns true only if both level of option set and core are enabled
+func (c *levelCore) Enabled(lvl zapcore.Level) bool {
+	return c.level.Enabled(lvl) && c.Core.Enabled(lvl)
+}
+
+// With keeps level filter on child core
+func (c *levelCore) With(fields []zapcore.Field) zapcore.Core {
+	return &levelCore{Core: c.Core.With(fields), level: c.level}
+}
+
+// Check drops entry if level of option set is not enabled
+func (c *levelCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.level.Enabled(ent.Level) {
+		return ce
+	}
+
+	return c.Core.Check(ent, ce)
+}
+
 // GetEntryName returns entry name
 func (set *optionSet) GetEntryName() string {
 	return set.entryName
@@ -134,6 +270,39 @@ func (set *optionSet) GetEntryType() string {
 	return set.entryType
 }
 
+// Config returns effective configuration, secrets are redacted
+func (set *optionSet) Config() map[string]interface{} {
+	return map[string]interface{}{
+		"entryName":           set.entryName,
+		"entryType":           set.entryType,
+		"loggerEntry":         set.loggerEntry.GetName(),
+		"eventEntry":          set.eventEntry.GetName(),
+		"loggerEncoding":      set.zapLoggerEncoding,
+		"loggerOutputPaths":   set.zapLoggerOutputPath,
+		"eventEncoding":       set.eventLoggerEncoding.String(),
+		"eventOutputPaths":    set.eventLoggerOutputPath,
+		"skipSuccessfulEvent": set.skipSuccessfulEvent.String(),
+		"eventOnErrorOnly":    set.eventOnErrorOnly,
+		"eventSampleRates":    set.eventSampleRates,
+		"requestClassifier":   set.classifier != nil,
+		"maxPayloadBytes":     set.maxEventPayloadBytes,
+		"responseHeaders":     set.responseHeadersToLog,
+		"retryHeader":         set.retryHeader,
+		"traceIdField":        set.traceIdField,
+		"requestIdField":      set.requestIdField,
+		"bodyHashMaxBytes":    set.bodyHashMaxBytes,
+		"resBodyMaxBytes":     set.resBodyMaxBytes,
+		"baggageKeys":         set.baggageKeys,
+		"eventDestinations":   set.eventDestinationNames(),
+		"countIgnoredPaths":   set.ignoredMetricsSet != nil,
+		"asyncQueueSize":      cap(set.asyncQueue),
+		"droppedEvents":       set.DroppedEvents(),
+		"level":               set.accessLevel.String(),
+		"eventLevel":          set.eventLevel.String(),
+		"pathToIgnore":        set.pathToIgnore,
+	}
+}
+
 // BeforeCtx should be created before Before()
 func (set *optionSet) BeforeCtx(req *http.Request) *BeforeCtx {
 	ctx := NewBeforeCtx()
@@ -147,6 +316,28 @@ func (set *optionSet) BeforeCtx(req *http.Request) *BeforeCtx {
 		ctx.Input.RawQuery = req.URL.RawQuery
 		ctx.Input.Protocol = req.Proto
 		ctx.Input.UserAgent = req.UserAgent()
+		ctx.Input.RetryAttempt = rkmid.GetRetryAttempt(req, set.retryHeader)
+
+		if set.eventDestinationFunc != nil {
+			ctx.Input.EventDestination = set.eventDestinationFunc(req)
+		}
+
+		// fingerprint request body, body will be re-buffered for user handler
+		if set.bodyHashMaxBytes > 0 {
+			ctx.Output.BodyHash, _ = rkmid.HashRequestBody(req, set.bodyHashMaxBytes)
+		}
+
+		// share response body captured by writer stored in context of request
+		if set.resBodyMaxBytes > 0 {
+			if writer := rkmid.GetCountingResponseWriter(req); writer != nil {
+				writer.CaptureBody(set.resBodyMaxBytes)
+				ctx.Input.ResponseWriter = writer
+			}
+		}
+
+		if len(set.baggageKeys) > 0 {
+			ctx.Input.Baggage = set.readBaggage(req)
+		}
 	}
 
 	return ctx
@@ -158,7 +349,9 @@ func (set *optionSet) Before(ctx *BeforeCtx) {
 		return
 	}
 
-	ctx.Output.Event = set.createEvent(ctx.Input.UrlPath, true)
+	set.countIgnored(ctx.Input.UrlPath)
+
+	ctx.Output.Event = set.createEvent(ctx.Input.UrlPath, ctx.Input.EventDestination, true)
 	ctx.Output.Logger = set.zapLogger
 
 	ctx.Output.Event.SetRemoteAddr(ctx.Input.RemoteAddr)
@@ -171,6 +364,21 @@ func (set *optionSet) Before(ctx *BeforeCtx) {
 		zap.String("userAgent", ctx.Input.UserAgent),
 	}...)
 
+	if len(set.retryHeader) > 0 {
+		ctx.Output.Event.AddPayloads(zap.Int("retryAttempt", ctx.Input.RetryAttempt))
+	}
+
+	if len(ctx.Output.BodyHash) > 0 {
+		ctx.Output.Event.AddPayloads(zap.String("bodyHash", ctx.Output.BodyHash))
+	}
+
+	// keep order of keys provided with WithEventFieldsFromBaggage
+	for _, key := range set.baggageKeys {
+		if v, ok := ctx.Input.Baggage[key]; ok {
+			ctx.Output.Event.AddPayloads(zap.String(key, v))
+		}
+	}
+
 	ctx.Output.Event.AddPayloads(ctx.Input.Fields...)
 
 	ctx.Output.Event.SetOperation(ctx.Input.UrlPath)
@@ -188,25 +396,285 @@ func (set *optionSet) AfterCtx(reqId, traceId, resCode string) *AfterCtx {
 }
 
 // After should run after user handler
+//
+// If response code was deferred with AfterCtx.Defer(), event will be finished while AfterCtx.Finalize() called.
 func (set *optionSet) After(before *BeforeCtx, after *AfterCtx) {
 	if before == nil || after == nil {
 		return
 	}
 
+	if after.DeferFinalize(func() { set.finalize(before, after) }) {
+		return
+	}
+
+	set.finalize(before, after)
+}
+
+// finalize records response and finish event
+func (set *optionSet) finalize(before *BeforeCtx, after *AfterCtx) {
 	event := before.Output.Event
 
+	// decision of classifier takes precedence over samplers
+	decision := set.classify(before, after)
+	if decision == rkmid.SamplingDecisionDrop {
+		return
+	}
+
+	if decision != rkmid.SamplingDecisionKeep {
+		// discard event of request which did not fail
+		if set.eventOnErrorOnly && !isErrorResCode(after.Input.ResCode) {
+			return
+		}
+
+		// discard fast and successful event without finishing it
+		if set.skipSuccessfulEvent > 0 && isSuccessResCode(after.Input.ResCode) &&
+			time.Since(event.GetStartTime()) < set.skipSuccessfulEvent {
+			return
+		}
+
+		// discard event based on sample rate of path, errors are always sampled in
+		if !isErrorResCode(after.Input.ResCode) && !set.sampleEvent(before.Input.UrlPath) {
+			return
+		}
+	}
+
 	if len(after.Input.RequestId) > 0 {
 		event.SetEventId(after.Input.RequestId)
-		event.SetRequestId(after.Input.RequestId)
+		if set.requestIdField == defaultRequestIdField {
+			event.SetRequestId(after.Input.RequestId)
+		} else {
+			event.AddPayloads(zap.String(set.requestIdField, after.Input.RequestId))
+		}
 	}
 
 	if len(after.Input.TraceId) > 0 {
-		event.SetTraceId(after.Input.TraceId)
+		if set.traceIdField == defaultTraceIdField {
+			event.SetTraceId(after.Input.TraceId)
+		} else {
+			event.AddPayloads(zap.String(set.traceIdField, after.Input.TraceId))
+		}
+	}
+
+	// record response headers selected by user
+	if len(set.responseHeadersToLog) > 0 {
+		headers := make(map[string]string)
+		for _, name := range set.responseHeadersToLog {
+			if v := after.Input.Headers.Get(name); len(v) > 0 {
+				headers[name] = v
+			}
+		}
+
+		if len(headers) > 0 {
+			event.AddPayloads(zap.Any("resHeaders", headers))
+		}
+	}
+
+	// record served byte range for partial content instead of plain size
+	if after.Input.ResCode == strconv.Itoa(http.StatusPartialContent) {
+		if contentRange := after.Input.Headers.Get(rkmid.HeaderContentRange); len(contentRange) > 0 {
+			event.AddPayloads(zap.String("contentRange", contentRange))
+			if start, end, ok := parseContentRange(contentRange); ok {
+				event.AddPayloads(
+					zap.Int64("rangeStart", start),
+					zap.Int64("rangeEnd", end),
+					zap.Int64("bytesServed", end-start+1))
+			}
+		}
+	}
+
+	// record captured response body, buffer may be larger than limit of logging since it is shared
+	if writer := before.Input.ResponseWriter; writer != nil {
+		body, complete := writer.CapturedBody()
+		if int64(len(body)) > set.resBodyMaxBytes {
+			body, complete = body[:set.resBodyMaxBytes], false
+		}
+		event.AddPayloads(zap.String("resBody", string(body)))
+		if !complete {
+			event.AddPayloads(zap.Bool("resBodyTruncated", true))
+		}
 	}
 
 	event.SetResCode(after.Input.ResCode)
 	event.SetEndTime(time.Now())
+	set.finishEvent(event)
+}
+
+// classify finished request with RequestClassifier, SamplingDecisionDefault will be returned if classifier missing
+func (set *optionSet) classify(before *BeforeCtx, after *AfterCtx) rkmid.SamplingDecision {
+	if set.classifier == nil {
+		return rkmid.SamplingDecisionDefault
+	}
+
+	// name of gRPC code will be mapped to HTTP status code, unknown code will be treated as 0
+	resCode, _ := toHttpCode(after.Input.ResCode)
+
+	return set.classifier.Classify(&rkmid.RequestInfo{
+		UrlPath: before.Input.UrlPath,
+		Method:  before.Input.Method,
+		ResCode: resCode,
+		Latency: time.Since(before.Output.Event.GetStartTime()),
+	})
+}
+
+// readBaggage returns members of baggage with keys provided with WithEventFieldsFromBaggage.
+//
+// Baggage in context of request will be used, which was extracted by tracing middleware,
+// otherwise baggage header will be parsed, since tracing middleware may be disabled or run after logging middleware.
+func (set *optionSet) readBaggage(req *http.Request) map[string]string {
+	bag := baggage.FromContext(req.Context())
+	if bag.Len() < 1 {
+		bag = baggage.FromContext(propagation.Baggage{}.Extract(req.Context(), propagation.HeaderCarrier(req.Header)))
+	}
+
+	res := make(map[string]string)
+	for _, key := range set.baggageKeys {
+		if member := bag.Member(key); len(member.Key()) > 0 {
+			res[key] = member.Value()
+		}
+	}
+
+	return res
+}
+
+// sampleEvent returns true if event of path should be logged based on sample rate of the longest matched prefix.
+// Event will always be logged if no prefix matched.
+func (set *optionSet) sampleEvent(path string) bool {
+	rate, matched := 1.0, ""
+	for prefix, v := range set.eventSampleRates {
+		if strings.HasPrefix(path, prefix) && len(prefix) > len(matched) {
+			rate, matched = v, prefix
+		}
+	}
+
+	if rate >= 1 {
+		return true
+	}
+
+	return rand.Float64() < rate
+}
+
+// finishEvent finish event directly or enqueue it if async mode enabled.
+// Event will be dropped if queue is full, and finished directly if async mode was closed.
+func (set *optionSet) finishEvent(event rkquery.Event) {
+	if set.asyncQueue == nil {
+		event.Finish()
+		return
+	}
+
+	set.asyncLock.Lock()
+	if set.asyncClosed {
+		set.asyncLock.Unlock()
+		event.Finish()
+		return
+	}
+
+	select {
+	case set.asyncQueue <- event:
+		set.asyncPending++
+	default:
+		atomic.AddUint64(&set.droppedEvents, 1)
+	}
+	set.asyncLock.Unlock()
+}
+
+// flushEvents finish events in queue in background until Close() called
+func (set *optionSet) flushEvents() {
+	defer close(set.asyncDone)
+
+	for {
+		select {
+		case event := <-set.asyncQueue:
+			set.doneEvent(event)
+		case <-set.asyncStop:
+			return
+		}
+	}
+}
+
+// doneEvent finish event dequeued and wakes up Flush() if no pending events left
+func (set *optionSet) doneEvent(event rkquery.Event) {
 	event.Finish()
+
+	set.asyncLock.Lock()
+	set.asyncPending--
+	if set.asyncPending < 1 {
+		set.asyncCond.Broadcast()
+	}
+	set.asyncLock.Unlock()
+}
+
+// GetLevel returns zap.AtomicLevel of access logger, which could be changed at runtime by admin endpoint.
+//
+// Level is owned by logger of option set built from copy of LoggerConfig, so LoggerEntry is not changed.
+// If LoggerEntry has no LoggerConfig, level could only be raised above level of LoggerEntry.
+func (set *optionSet) GetLevel() zap.AtomicLevel {
+	return set.accessLevel
+}
+
+// SetLevel changes level of access logger at runtime
+func (set *optionSet) SetLevel(level zapcore.Level) {
+	set.accessLevel.SetLevel(level)
+}
+
+// GetEventLevel returns zap.AtomicLevel of event logger, which is controlled independently of access logger.
+//
+// Level is owned by logger of option set built from copy of LoggerConfig of EventEntry.
+// Events are logged at info level, they will be dropped if level is higher than info.
+func (set *optionSet) GetEventLevel() zap.AtomicLevel {
+	return set.eventLevel
+}
+
+// SetEventLevel changes level of event logger at runtime
+func (set *optionSet) SetEventLevel(level zapcore.Level) {
+	set.eventLevel.SetLevel(level)
+}
+
+// Flush finish all events remaining in queue and waits for events being finished by background flusher
+func (set *optionSet) Flush() {
+	if set.asyncQueue == nil {
+		return
+	}
+
+	for drained := false; !drained; {
+		select {
+		case event := <-set.asyncQueue:
+			set.doneEvent(event)
+		default:
+			drained = true
+		}
+	}
+
+	set.asyncLock.Lock()
+	for set.asyncPending > 0 {
+		set.asyncCond.Wait()
+	}
+	set.asyncLock.Unlock()
+}
+
+// Close stops background flusher and finish remaining events, events will be finished directly afterwards.
+// It is registered as shutdown hook if async mode enabled.
+func (set *optionSet) Close() {
+	if set.asyncQueue == nil {
+		return
+	}
+
+	set.asyncCloseOnce.Do(func() {
+		set.asyncLock.Lock()
+		set.asyncClosed = true
+		set.asyncLock.Unlock()
+
+		if set.asyncStop != nil {
+			close(set.asyncStop)
+			<-set.asyncDone
+		}
+	})
+
+	set.Flush()
+}
+
+// DroppedEvents returns number of events dropped because of full queue
+func (set *optionSet) DroppedEvents() uint64 {
+	return atomic.LoadUint64(&set.droppedEvents)
 }
 
 // EventEntry returns rkentry.EventEntry
@@ -219,24 +687,42 @@ func (set *optionSet) ZapEntry() *rkentry.LoggerEntry {
 	return set.loggerEntry
 }
 
-// CreateEvent create event based on urlPath
-func (set *optionSet) createEvent(urlPath string, threadSafe bool) rkquery.Event {
-	if set.ShouldIgnore(urlPath) {
+// Returns names of event destinations in sorted order
+func (set *optionSet) eventDestinationNames() []string {
+	res := make([]string, 0)
+	for k := range set.eventDestinations {
+		res = append(res, k)
+	}
+	sort.Strings(res)
+
+	return res
+}
+
+// CreateEvent create event based on urlPath and destination.
+// Default EventEntry will be used if destination was not registered.
+func (set *optionSet) createEvent(urlPath, destination string, threadSafe bool) rkquery.Event {
+	if set.ShouldIgnore(urlPath) || !set.eventLevel.Enabled(zapcore.InfoLevel) {
 		return set.EventEntry().EventFactory.CreateEventNoop()
 	}
 
+	eventEntry, override := set.eventEntry, set.eventLoggerOverride
+	if v, ok := set.eventDestinations[destination]; ok {
+		// output paths override applies to default EventEntry only
+		eventEntry, override = v, nil
+	}
+
 	var event rkquery.Event
 	if threadSafe {
-		event = set.eventEntry.EventFactory.CreateEventThreadSafe(
-			rkquery.WithZapLogger(set.eventLoggerOverride),
+		event = eventEntry.EventFactory.CreateEventThreadSafe(
+			rkquery.WithZapLogger(override),
 			rkquery.WithEncoding(set.eventLoggerEncoding),
 			rkquery.WithAppName(rkentry.GlobalAppCtx.GetAppInfoEntry().AppName),
 			rkquery.WithAppVersion(rkentry.GlobalAppCtx.GetAppInfoEntry().Version),
 			rkquery.WithEntryName(set.GetEntryName()),
 			rkquery.WithEntryType(set.GetEntryType()))
 	} else {
-		event = set.eventEntry.EventFactory.CreateEvent(
-			rkquery.WithZapLogger(set.eventLoggerOverride),
+		event = eventEntry.EventFactory.CreateEvent(
+			rkquery.WithZapLogger(override),
 			rkquery.WithEncoding(set.eventLoggerEncoding),
 			rkquery.WithAppName(rkentry.GlobalAppCtx.GetAppInfoEntry().AppName),
 			rkquery.WithAppVersion(rkentry.GlobalAppCtx.GetAppInfoEntry().Version),
@@ -246,18 +732,114 @@ func (set *optionSet) createEvent(urlPath string, threadSafe bool) rkquery.Event
 
 	event.SetStartTime(time.Now())
 
+	if set.maxEventPayloadBytes > 0 {
+		event = &limitedEvent{
+			Event:    event,
+			maxBytes: set.maxEventPayloadBytes,
+		}
+	}
+
 	return event
 }
 
+// limitedEvent wraps rkquery.Event and truncates payloads exceeding limit of bytes, per field and in aggregate.
+//
+// Fields other than string and bytes, like objects and errors, are rendered as string only if they exceed limit.
+// Numbers, bools, durations and times are counted in aggregate but never truncated.
+type limitedEvent struct {
+	rkquery.Event
+	maxBytes  int
+	usedBytes int
+	lock      sync.Mutex
+}
+
+// AddPayloads add payloads with values truncated if exceeds remaining bytes
+func (event *limitedEvent) AddPayloads(fields ...zap.Field) {
+	event.lock.Lock()
+	defer event.lock.Unlock()
+
+	// copy fields, since slice may be shared with caller
+	limited := make([]zap.Field, 0, len(fields))
+	for i := range fields {
+		limited = append(limited, event.limit(fields[i]))
+	}
+
+	event.Event.AddPayloads(limited...)
+}
+
+// limit returns field as it is or truncated string field with marker, value is truncated on boundary of UTF-8 rune
+func (event *limitedEvent) limit(field zap.Field) zap.Field {
+	remaining := event.maxBytes - event.usedBytes
+
+	var value string
+	switch field.Type {
+	case zapcore.StringType:
+		value = field.String
+	case zapcore.ByteStringType, zapcore.BinaryType:
+		v, _ := field.Interface.([]byte)
+		value = string(v)
+	case zapcore.StringerType, zapcore.ReflectType, zapcore.ErrorType,
+		zapcore.ArrayMarshalerType, zapcore.ObjectMarshalerType:
+		// no need to render value if nothing remaining
+		if remaining < 1 {
+			event.usedBytes = event.maxBytes
+			return zap.String(field.Key, truncatedMarker)
+		}
+		value = fmt.Sprintf("%v", field.Interface)
+	default:
+		// numbers, bools, durations and times are small enough to be counted as 8 bytes, they are never truncated
+		event.usedBytes += 8
+		return field
+	}
+
+	if len(value) <= remaining {
+		event.usedBytes += len(value)
+		return field
+	}
+
+	if remaining < 0 {
+		remaining = 0
+	}
+	event.usedBytes = event.maxBytes
+
+	if len(value) > remaining {
+		for remaining > 0 && !utf8.RuneStart(value[remaining]) {
+			remaining--
+		}
+		value = value[:remaining]
+	}
+
+	return zap.String(field.Key, value+truncatedMarker)
+}
+
 // ShouldIgnore determine whether auth should be ignored based on path
 func (set *optionSet) ShouldIgnore(path string) bool {
+	_, ok := set.matchIgnore(path)
+	return ok
+}
+
+// Returns ignore prefix which matches path
+func (set *optionSet) matchIgnore(path string) (string, bool) {
 	for i := range set.pathToIgnore {
 		if strings.HasPrefix(path, set.pathToIgnore[i]) {
-			return true
+			return set.pathToIgnore[i], true
 		}
 	}
 
-	return rkmid.ShouldIgnoreGlobal(path)
+	return rkmid.MatchIgnoreGlobal(path)
+}
+
+// Increase counter of ignored requests labeled with matched ignore prefix, instead of path, to keep cardinality bounded
+func (set *optionSet) countIgnored(path string) {
+	if set.ignoredMetricsSet == nil {
+		return
+	}
+
+	if prefix, ok := set.matchIgnore(path); ok {
+		if counter := set.ignoredMetricsSet.GetCounterWithValues(MetricsNameIgnoredRequests, set.entryName, prefix); counter != nil {
+			counter.Inc()
+		}
+	}
 }
 
 // ***************** OptionSet Mock *****************
@@ -265,14 +847,18 @@ func (set *optionSet) ShouldIgnore(path string) bool {
 // NewOptionSetMock for testing purpose
 func NewOptionSetMock(before *BeforeCtx, after *AfterCtx) OptionSetInterface {
 	return &optionSetMock{
-		before: before,
-		after:  after,
+		before:     before,
+		after:      after,
+		level:      zap.NewAtomicLevel(),
+		eventLevel: zap.NewAtomicLevel(),
 	}
 }
 
 type optionSetMock struct {
-	before *BeforeCtx
-	after  *AfterCtx
+	before     *BeforeCtx
+	after      *AfterCtx
+	level      zap.AtomicLevel
+	eventLevel zap.AtomicLevel
 }
 
 // GetEntryName returns entry name
@@ -285,6 +871,11 @@ func (mock *optionSetMock) GetEntryType() string {
 	return "mock"
 }
 
+// Config returns empty map
+func (mock *optionSetMock) Config() map[string]interface{} {
+	return map[string]interface{}{}
+}
+
 // BeforeCtx should be created before Before()
 func (mock *optionSetMock) BeforeCtx(request *http.Request) *BeforeCtx {
 	return mock.before
@@ -310,6 +901,26 @@ func (mock *optionSetMock) ShouldIgnore(string) bool {
 	return false
 }
 
+// GetLevel returns level of mock
+func (mock *optionSetMock) GetLevel() zap.AtomicLevel {
+	return mock.level
+}
+
+// SetLevel changes level of mock
+func (mock *optionSetMock) SetLevel(level zapcore.Level) {
+	mock.level.SetLevel(level)
+}
+
+// GetEventLevel returns event level of mock
+func (mock *optionSetMock) GetEventLevel() zap.AtomicLevel {
+	return mock.eventLevel
+}
+
+// SetEventLevel changes event level of mock
+func (mock *optionSetMock) SetEventLevel(level zapcore.Level) {
+	mock.eventLevel.SetLevel(level)
+}
+
 // ***************** Context *****************
 
 // NewBeforeCtx create new BeforeCtx with fields initialized
@@ -322,6 +933,7 @@ func NewBeforeCtx() *BeforeCtx {
 // NewAfterCtx create new AfterCtx with fields initialized
 func NewAfterCtx() *AfterCtx {
 	ctx := &AfterCtx{}
+	ctx.Input.Headers = make(http.Header)
 	return ctx
 }
 
@@ -335,33 +947,66 @@ type BeforeCtx struct {
 		Protocol   string
 		UserAgent  string
 		Fields     []zap.Field
+		// RetryAttempt parsed from retry header, 0 means first attempt
+		RetryAttempt int
+		// EventDestination returned by function provided with WithEventDestinations
+		EventDestination string
+		// ResponseWriter capturing response body, nil if capture disabled or writer missing in context of request
+		ResponseWriter *rkmid.CountingResponseWriter
+		// Baggage members with keys provided with WithEventFieldsFromBaggage
+		Baggage map[string]string
 	}
 	Output struct {
 		Event  rkquery.Event
 		Logger *zap.Logger
+		// BodyHash is SHA-256 of request body, empty if body hashing disabled or body exceeds limit
+		BodyHash string
 	}
 }
 
 // AfterCtx context for After() function
+//
+// For streaming or async handlers, response code may not be known while After() runs.
+// Lifecycle of deferred response code:
+//  1. Adapter calls Defer() before After(), After() will record nothing and return.
+//  2. Adapter fills Input.ResCode and Input.Headers once response completes.
+//  3. Adapter calls Finalize(), event will be finished with values in Input.
+//
+// Finalize() is safe to be called before After(), in that case After() finishes event directly.
 type AfterCtx struct {
 	Input struct {
 		RequestId string
 		TraceId   string
 		ResCode   string
+		// Headers of response, adapters should fill it before calling After()
+		Headers http.Header
 	}
 	Output struct{}
+
+	rkmid.Finalizer
 }
 
 // ***************** BootConfig *****************
 
 // BootConfig for YAML
 type BootConfig struct {
-	Enabled           bool     `yaml:"enabled" json:"enabled"`
-	LoggerEncoding    string   `yaml:"loggerEncoding" json:"loggerEncoding"`
-	LoggerOutputPaths []string `yaml:"loggerOutputPaths" json:"loggerOutputPaths"`
-	EventEncoding     string   `yaml:"eventEncoding" json:"eventEncoding"`
-	EventOutputPaths  []string `yaml:"eventOutputPaths" json:"eventOutputPaths"`
-	Ignore            []string `yaml:"ignore" json:"ignore"`
+	Enabled           bool               `yaml:"enabled" json:"enabled"`
+	LoggerEncoding    string             `yaml:"loggerEncoding" json:"loggerEncoding"`
+	LoggerOutputPaths []string           `yaml:"loggerOutputPaths" json:"loggerOutputPaths"`
+	EventEncoding     string             `yaml:"eventEncoding" json:"eventEncoding"`
+	EventOutputPaths  []string           `yaml:"eventOutputPaths" json:"eventOutputPaths"`
+	EventEntry        string             `yaml:"eventEntry" json:"eventEntry"`
+	ResponseHeaders   []string           `yaml:"responseHeaders" json:"responseHeaders"`
+	RetryHeader       string             `yaml:"retryHeader" json:"retryHeader"`
+	TraceIdField      string             `yaml:"traceIdField" json:"traceIdField"`
+	RequestIdField    string             `yaml:"requestIdField" json:"requestIdField"`
+	BodyHashMaxBytes  int64              `yaml:"bodyHashMaxBytes" json:"bodyHashMaxBytes"`
+	ResBodyMaxBytes   int64              `yaml:"resBodyMaxBytes" json:"resBodyMaxBytes"`
+	BaggageFields     []string           `yaml:"baggageFields" json:"baggageFields"`
+	EventSampleRates  map[string]float64 `yaml:"eventSampleRates" json:"eventSampleRates"`
+	MaxPayloadBytes   int                `yaml:"maxPayloadBytes" json:"maxPayloadBytes"`
+	EventOnErrorOnly  bool               `yaml:"eventOnErrorOnly" json:"eventOnErrorOnly"`
+	Ignore            []string           `yaml:"ignore" json:"ignore"`
 }
 
 // ToOptions convert BootConfig into Option list
@@ -380,7 +1025,24 @@ func ToOptions(config *BootConfig,
 			WithEventEncoding(config.EventEncoding),
 			WithLoggerOutputPaths(config.LoggerOutputPaths...),
 			WithEventOutputPaths(config.EventOutputPaths...),
+			WithResponseHeadersToLog(config.ResponseHeaders...),
+			WithRetryHeader(config.RetryHeader),
+			WithTraceIdField(config.TraceIdField),
+			WithRequestIdField(config.RequestIdField),
+			WithBodyHashing(config.BodyHashMaxBytes),
+			WithResponseBodyCapture(config.ResBodyMaxBytes),
+			WithEventFieldsFromBaggage(config.BaggageFields...),
+			WithMaxEventPayloadBytes(config.MaxPayloadBytes),
+			WithEventOnErrorOnly(config.EventOnErrorOnly),
 			WithPathToIgnore(config.Ignore...))
+
+		if len(config.EventEntry) > 0 {
+			opts = append(opts, WithEventEntryRef(config.EventEntry))
+		}
+
+		for prefix, rate := range config.EventSampleRates {
+			opts = append(opts, WithEventSampleRateByPath(prefix, rate))
+		}
 	}
 
 	return opts
@@ -417,11 +1079,77 @@ func WithEventEntry(eventEntry *rkentry.EventEntry) Option {
 	}
 }
 
+// WithEventEntryRef provide name of rkentry.EventEntry registered in rkentry.GlobalAppCtx.
+// Default rkentry.EventEntry will be used if entry with name was not found.
+func WithEventEntryRef(name string) Option {
+	return func(set *optionSet) {
+		if len(name) < 1 {
+			return
+		}
+
+		if eventEntry := rkentry.GlobalAppCtx.GetEventEntry(name); eventEntry != nil {
+			set.eventEntry = eventEntry
+			return
+		}
+
+		rkentry.LoggerEntryStdout.Warn("EventEntry not found, fallback to default",
+			zap.String("eventEntry", name))
+		set.eventEntry = rkentry.GlobalAppCtx.GetEventEntryDefault()
+	}
+}
+
+// resolveEncoding resolves auto encoding based on environment variable of DOMAIN or ENV.
+// DOMAIN takes precedence, console will be returned if value is one of dev, local, localhost or development,
+// otherwise json will be returned. Encodings other than auto will be returned as it is.
+func resolveEncoding(ec string) string {
+	ec = strings.ToLower(ec)
+	if ec != auto {
+		return ec
+	}
+
+	env := os.Getenv("DOMAIN")
+	if len(env) < 1 {
+		env = os.Getenv("ENV")
+	}
+
+	switch strings.ToLower(env) {
+	case "dev", "local", "localhost", "development":
+		return console
+	default:
+		return json
+	}
+}
+
+// WithEventDestinations route events of request to one of pre-built EventEntry, like EventEntry of each tenant
+// writing to different output paths or Loki labels.
+//
+// Function should return a key of destinations based on request, like tenant resolved from context.
+// Events will be written to default EventEntry if key was not found in destinations.
+//
+// Each destination holds its own logger, output files and Loki stream, keys should be a small and bounded set,
+// never use unbounded values like user id as key. EventEntry of destinations should be bootstrapped by user.
+func WithEventDestinations(f func(*http.Request) string, destinations map[string]*rkentry.EventEntry) Option {
+	return func(set *optionSet) {
+		if f == nil || len(destinations) < 1 {
+			return
+		}
+
+		set.eventDestinationFunc = f
+		set.eventDestinations = make(map[string]*rkentry.EventEntry)
+		for k, v := range destinations {
+			if v != nil {
+				set.eventDestinations[k] = v
+			}
+		}
+	}
+}
+
 // WithLoggerEncoding provide ZapLoggerEncodingType.
-// json or console is supported.
+// json, console or auto is supported.
+// auto resolves to console if DOMAIN or ENV is one of dev, local, localhost or development, otherwise json.
 func WithLoggerEncoding(ec string) Option {
 	return func(set *optionSet) {
-		set.zapLoggerEncoding = strings.ToLower(ec)
+		set.zapLoggerEncoding = resolveEncoding(ec)
 	}
 }
 
@@ -434,10 +1162,10 @@ func WithLoggerOutputPaths(path ...string) Option {
 }
 
 // WithEventEncoding provide ZapLoggerEncodingType.
-// Console or Json is supported.
+// Console, Json or auto is supported, auto resolves the same as WithLoggerEncoding.
 func WithEventEncoding(ec string) Option {
 	return func(set *optionSet) {
-		set.eventLoggerEncoding = rkquery.ToEncoding(ec)
+		set.eventLoggerEncoding = rkquery.ToEncoding(resolveEncoding(ec))
 	}
 }
 
@@ -449,6 +1177,167 @@ func WithEventOutputPaths(path ...string) Option {
 	}
 }
 
+// WithSkipSuccessfulEvent discard events of successful requests which finished faster than threshold.
+// Failed or slow requests will always be logged.
+func WithSkipSuccessfulEvent(thresholdLatency time.Duration) Option {
+	return func(set *optionSet) {
+		if thresholdLatency > 0 {
+			set.skipSuccessfulEvent = thresholdLatency
+		}
+	}
+}
+
+// WithEventOnErrorOnly discard events of requests which did not fail, like 2xx and 3xx responses,
+// events of failed requests will always be logged. Requests kept by RequestClassifier will be logged as well.
+func WithEventOnErrorOnly(enabled bool) Option {
+	return func(set *optionSet) {
+		set.eventOnErrorOnly = enabled
+	}
+}
+
+// WithEventSampleRateByPath provide sample rate of events whose path starts with prefix, like 0.01 for 1% of events.
+// Rate of the longest matched prefix will be used, events of other paths and errors will always be logged.
+func WithEventSampleRateByPath(prefix string, rate float64) Option {
+	return func(set *optionSet) {
+		if len(prefix) < 1 {
+			return
+		}
+
+		if rate < 0 {
+			rate = 0
+		}
+
+		if rate > 1 {
+			rate = 1
+		}
+
+		set.eventSampleRates[prefix] = rate
+	}
+}
+
+// WithRequestClassifier provide rkmid.RequestClassifier consulted after request finished.
+//
+// Events classified as rkmid.SamplingDecisionKeep bypass WithSkipSuccessfulEvent and WithEventSampleRateByPath,
+// rkmid.SamplingDecisionDrop ones are discarded. Share the same classifier with tracing middleware,
+// so that a single policy governs sampling of both traces and events.
+func WithRequestClassifier(classifier rkmid.RequestClassifier) Option {
+	return func(set *optionSet) {
+		if classifier != nil {
+			set.classifier = classifier
+		}
+	}
+}
+
+// WithMaxEventPayloadBytes provide max bytes of payloads added into event, per field and in aggregate.
+// Payloads exceeding the limit will be truncated and marked with suffix of "...[truncated]".
+func WithMaxEventPayloadBytes(n int) Option {
+	return func(set *optionSet) {
+		if n > 0 {
+			set.maxEventPayloadBytes = n
+		}
+	}
+}
+
+// WithResponseHeadersToLog provide names of response headers which will be attached into event.
+// Adapters should fill AfterCtx.Input.Headers with response headers.
+func WithResponseHeadersToLog(headers ...string) Option {
+	return func(set *optionSet) {
+		for i := range headers {
+			if len(headers[i]) > 0 {
+				set.responseHeadersToLog = append(set.responseHeadersToLog, headers[i])
+			}
+		}
+	}
+}
+
+// WithAsyncEvents enable async mode with bounded queue, events will be finished by background flusher.
+// Events will be dropped if queue is full.
+func WithAsyncEvents(queueSize int) Option {
+	return func(set *optionSet) {
+		if queueSize > 0 {
+			set.asyncQueue = make(chan rkquery.Event, queueSize)
+		}
+	}
+}
+
+// WithRetryHeader provide name of request header which contains retry count, like X-Retry-Count.
+// Field of retryAttempt will be added to event, missing header will be treated as attempt 0.
+func WithRetryHeader(name string) Option {
+	return func(set *optionSet) {
+		if len(name) > 0 {
+			set.retryHeader = name
+		}
+	}
+}
+
+// WithEventFieldsFromBaggage provide keys of tracing baggage members which will be added to event as payloads,
+// like tenant or experiment id, so that correlation ids flow into events without code in handlers.
+// Missing members will be skipped.
+func WithEventFieldsFromBaggage(keys ...string) Option {
+	return func(set *optionSet) {
+		for _, key := range keys {
+			if len(key) > 0 {
+				set.baggageKeys = append(set.baggageKeys, key)
+			}
+		}
+	}
+}
+
+// WithTraceIdField provide event key of trace id, like trace_id or dd.trace_id.
+// Default value is traceId.
+func WithTraceIdField(name string) Option {
+	return func(set *optionSet) {
+		if len(name) > 0 {
+			set.traceIdField = name
+		}
+	}
+}
+
+// WithRequestIdField provide event key of request id, like request_id.
+// Default value is requestId.
+func WithRequestIdField(name string) Option {
+	return func(set *optionSet) {
+		if len(name) > 0 {
+			set.requestIdField = name
+		}
+	}
+}
+
+// WithBodyHashing compute SHA-256 of request body as fingerprint of request, which could be used
+// by idempotency, caching and audit logging. Body larger than maxBytes will not be hashed.
+func WithBodyHashing(maxBytes int64) Option {
+	return func(set *optionSet) {
+		if maxBytes > 0 {
+			set.bodyHashMaxBytes = maxBytes
+		}
+	}
+}
+
+// WithResponseBodyCapture log response body up to maxBytes as payload of resBody.
+//
+// Body is captured by rkmid.CountingResponseWriter which adapters stored with rkmid.SetCountingResponseWriter,
+// the same buffer is shared with other middlewares, so that body is captured only once.
+// Memory cost is up to maxBytes per in-flight request.
+// Optional. Default value 0, which means response body will not be captured.
+func WithResponseBodyCapture(maxBytes int64) Option {
+	return func(set *optionSet) {
+		if maxBytes > 0 {
+			set.resBodyMaxBytes = maxBytes
+		}
+	}
+}
+
+// WithCountIgnoredPaths count requests of ignored paths, like probes, into metricsSet without creating events.
+//
+// Counter of MetricsNameIgnoredRequests will be registered with labels of entryName and matched ignore prefix.
+func WithCountIgnoredPaths(metricsSet *rkmidprom.MetricsSet) Option {
+	return func(set *optionSet) {
+		if metricsSet != nil {
+			set.ignoredMetricsSet = metricsSet
+		}
+	}
+}
+
 // WithPathToIgnore provide paths prefix that will ignore.
 func WithPathToIgnore(paths ...string) Option {
 	return func(set *optionSet) {
@@ -467,6 +1356,84 @@ func WithMockOptionSet(mock OptionSetInterface) Option {
 	}
 }
 
+// Check whether response code is 2xx, or OK in case of grpc
+func isSuccessResCode(resCode string) bool {
+	if resCode == "OK" {
+		return true
+	}
+
+	code, err := strconv.Atoi(resCode)
+	if err != nil {
+		return false
+	}
+
+	return code >= 200 && code < 300
+}
+
+// grpcCodes maps names of gRPC codes, like NotFound, to codes.Code
+var grpcCodes = func() map[string]codes.Code {
+	res := make(map[string]codes.Code)
+	for code := codes.OK; code <= codes.Unauthenticated; code++ {
+		res[code.String()] = code
+	}
+	return res
+}()
+
+// toHttpCode converts response code of HTTP or name of gRPC code into HTTP status code, false if code is unknown
+func toHttpCode(resCode string) (int, bool) {
+	if code, err := strconv.Atoi(resCode); err == nil {
+		return code, true
+	}
+
+	if code, ok := grpcCodes[resCode]; ok {
+		return rkerror.HttpCode(code), true
+	}
+
+	return 0, false
+}
+
+// isErrorResCode returns true for http code >= 400 and gRPC code other than OK.
+//
+// Empty or unknown response code is not treated as error, since it could not be classified.
+func isErrorResCode(resCode string) bool {
+	if code, ok := grpcCodes[resCode]; ok {
+		return code != codes.OK
+	}
+
+	code, err := strconv.Atoi(resCode)
+	if err != nil {
+		return false
+	}
+
+	return code >= 400
+}
+
+// Parse start and end of Content-Range header, like "bytes 0-1023/4096"
+func parseContentRange(contentRange string) (int64, int64, bool) {
+	tokens := strings.SplitN(strings.TrimSpace(contentRange), " ", 2)
+	if len(tokens) != 2 || tokens[0] != "bytes" {
+		return 0, 0, false
+	}
+
+	rangeStr := strings.SplitN(tokens[1], "/", 2)[0]
+	bounds := strings.SplitN(rangeStr, "-", 2)
+	if len(bounds) != 2 {
+		return 0, 0, false
+	}
+
+	start, err := strconv.ParseInt(bounds[0], 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+
+	end, err := strconv.ParseInt(bounds[1], 10, 64)
+	if err != nil || end < start {
+		return 0, 0, false
+	}
+
+	return start, end, true
+}
+
 // Make incoming paths to absolute path with current working directory attached as prefix
 func toAbsPath(p ...string) []string {
 	res := make([]string, 0)
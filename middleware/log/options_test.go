@@ -6,12 +6,23 @@
 package rkmidlog
 
 import (
+	"errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/rookie-ninja/rk-entry/v2/entry"
+	"github.com/rookie-ninja/rk-entry/v2/middleware"
+	"github.com/rookie-ninja/rk-entry/v2/middleware/prom"
 	"github.com/rookie-ninja/rk-query"
 	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/baggage"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestOptionSet_BeforeCtx(t *testing.T) {
@@ -48,13 +59,13 @@ func TestOptionSet_createEvent(t *testing.T) {
 
 	// with ignore url
 	set := NewOptionSet(WithPathToIgnore("/ut-ignore")).(*optionSet)
-	assert.NotNil(t, set.createEvent("/ut-ignore", true))
+	assert.NotNil(t, set.createEvent("/ut-ignore", "", true))
 
 	// with thread safe
-	assert.NotNil(t, set.createEvent("/", true))
+	assert.NotNil(t, set.createEvent("/", "", true))
 
 	// with non-thread safe
-	assert.NotNil(t, set.createEvent("/", false))
+	assert.NotNil(t, set.createEvent("/", "", false))
 }
 
 func TestOptionSet_Before(t *testing.T) {
@@ -86,6 +97,588 @@ func TestOptionSet_After(t *testing.T) {
 	set.After(before, after)
 }
 
+func TestOptionSet_After_PartialContent(t *testing.T) {
+	defer assertNotPanic(t)
+
+	set := NewOptionSet()
+	before := set.BeforeCtx(httptest.NewRequest(http.MethodGet, "/ut-path", nil))
+	set.Before(before)
+	after := set.AfterCtx("reqId", "traceId", "206")
+	after.Input.Headers.Set("Content-Range", "bytes 0-1023/4096")
+	set.After(before, after)
+
+	payloads := map[string]zap.Field{}
+	for _, field := range before.Output.Event.ListPayloads() {
+		payloads[field.Key] = field
+	}
+	assert.Equal(t, zap.String("contentRange", "bytes 0-1023/4096"), payloads["contentRange"])
+	assert.Equal(t, zap.Int64("rangeStart", 0), payloads["rangeStart"])
+	assert.Equal(t, zap.Int64("rangeEnd", 1023), payloads["rangeEnd"])
+	assert.Equal(t, zap.Int64("bytesServed", 1024), payloads["bytesServed"])
+
+	// with invalid Content-Range, only raw header is recorded
+	before = set.BeforeCtx(httptest.NewRequest(http.MethodGet, "/ut-path", nil))
+	set.Before(before)
+	after = set.AfterCtx("reqId", "traceId", "206")
+	after.Input.Headers.Set("Content-Range", "bytes */4096")
+	set.After(before, after)
+
+	payloads = map[string]zap.Field{}
+	for _, field := range before.Output.Event.ListPayloads() {
+		payloads[field.Key] = field
+	}
+	assert.Equal(t, zap.String("contentRange", "bytes */4096"), payloads["contentRange"])
+	assert.NotContains(t, payloads, "bytesServed")
+}
+
+func TestWithSkipSuccessfulEvent(t *testing.T) {
+	set := NewOptionSet(
+		WithSkipSuccessfulEvent(time.Minute)).(*optionSet)
+	assert.Equal(t, time.Minute, set.skipSuccessfulEvent)
+
+	// fast and successful event should not be finished
+	before := set.BeforeCtx(httptest.NewRequest(http.MethodGet, "/ut-path", nil))
+	set.Before(before)
+	set.After(before, set.AfterCtx("reqId", "traceId", "200"))
+	assert.True(t, before.Output.Event.GetEndTime().IsZero())
+
+	// failed event should be finished
+	before = set.BeforeCtx(httptest.NewRequest(http.MethodGet, "/ut-path", nil))
+	set.Before(before)
+	set.After(before, set.AfterCtx("reqId", "traceId", "500"))
+	assert.False(t, before.Output.Event.GetEndTime().IsZero())
+
+	// slow event should be finished
+	set = NewOptionSet(
+		WithSkipSuccessfulEvent(time.Nanosecond)).(*optionSet)
+	before = set.BeforeCtx(httptest.NewRequest(http.MethodGet, "/ut-path", nil))
+	set.Before(before)
+	time.Sleep(time.Millisecond)
+	set.After(before, set.AfterCtx("reqId", "traceId", "200"))
+	assert.False(t, before.Output.Event.GetEndTime().IsZero())
+}
+
+func TestWithEventOnErrorOnly(t *testing.T) {
+	set := NewOptionSet(ToOptions(&BootConfig{Enabled: true, EventOnErrorOnly: true}, "", "", nil, nil)...)
+	assert.True(t, set.Config()["eventOnErrorOnly"].(bool))
+
+	// successful and redirected events should not be finished
+	for _, code := range []string{"200", "302", "OK"} {
+		before := set.BeforeCtx(httptest.NewRequest(http.MethodGet, "/ut-path", nil))
+		set.Before(before)
+		set.After(before, set.AfterCtx("reqId", "traceId", code))
+		assert.True(t, before.Output.Event.GetEndTime().IsZero())
+	}
+
+	// failed events should be finished
+	for _, code := range []string{"404", "500", "Internal"} {
+		before := set.BeforeCtx(httptest.NewRequest(http.MethodGet, "/ut-path", nil))
+		set.Before(before)
+		set.After(before, set.AfterCtx("reqId", "traceId", code))
+		assert.False(t, before.Output.Event.GetEndTime().IsZero())
+	}
+
+	// event kept by classifier should be finished
+	set = NewOptionSet(WithEventOnErrorOnly(true), WithRequestClassifier(rkmid.RequestClassifierFunc(
+		func(info *rkmid.RequestInfo) rkmid.SamplingDecision {
+			return rkmid.SamplingDecisionKeep
+		})))
+	before := set.BeforeCtx(httptest.NewRequest(http.MethodGet, "/ut-path", nil))
+	set.Before(before)
+	set.After(before, set.AfterCtx("reqId", "traceId", "200"))
+	assert.False(t, before.Output.Event.GetEndTime().IsZero())
+}
+
+func TestWithResponseHeadersToLog(t *testing.T) {
+	set := NewOptionSet(
+		WithResponseHeadersToLog("X-Cache", "", "Location")).(*optionSet)
+	assert.Equal(t, []string{"X-Cache", "Location"}, set.responseHeadersToLog)
+
+	before := set.BeforeCtx(httptest.NewRequest(http.MethodGet, "/ut-path", nil))
+	set.Before(before)
+	after := set.AfterCtx("reqId", "traceId", "200")
+	after.Input.Headers.Set("X-Cache", "HIT")
+	after.Input.Headers.Set("X-Other", "ut-value")
+	set.After(before, after)
+
+	var found bool
+	for _, field := range before.Output.Event.ListPayloads() {
+		if field.Key == "resHeaders" {
+			found = true
+			assert.Equal(t, map[string]string{"X-Cache": "HIT"}, field.Interface)
+		}
+	}
+	assert.True(t, found)
+}
+
+func TestWithAsyncEvents(t *testing.T) {
+	defer rkentry.GlobalAppCtx.RemoveShutdownHook("rk-log-async-ut-async")
+
+	set := NewOptionSet(
+		WithEntryNameAndType("ut-async", "ut-type"),
+		WithAsyncEvents(1)).(*optionSet)
+	assert.Equal(t, 1, cap(set.asyncQueue))
+	assert.NotNil(t, rkentry.GlobalAppCtx.GetShutdownHook("rk-log-async-ut-async"))
+
+	before := set.BeforeCtx(httptest.NewRequest(http.MethodGet, "/ut-path", nil))
+	set.Before(before)
+	set.After(before, set.AfterCtx("reqId", "traceId", "200"))
+
+	set.Flush()
+	assert.Empty(t, set.asyncQueue)
+	assert.Zero(t, set.asyncPending)
+
+	// with closed, background flusher should exit and events should be finished directly
+	rkentry.GlobalAppCtx.GetShutdownHook("rk-log-async-ut-async")()
+	select {
+	case <-set.asyncDone:
+	case <-time.After(time.Second):
+		assert.Fail(t, "background flusher not stopped")
+	}
+	set.After(before, set.AfterCtx("reqId", "traceId", "200"))
+	assert.Empty(t, set.asyncQueue)
+	assert.Zero(t, set.asyncPending)
+
+	// close twice should be no-op
+	set.Close()
+}
+
+func TestWithEventDestinations(t *testing.T) {
+	newEventEntry := func() (*rkentry.EventEntry, *observer.ObservedLogs) {
+		core, logs := observer.New(zap.InfoLevel)
+		return &rkentry.EventEntry{
+			EventFactory: rkquery.NewEventFactory(rkquery.WithZapLogger(zap.New(core))),
+		}, logs
+	}
+	tenantA, logsA := newEventEntry()
+	tenantB, logsB := newEventEntry()
+
+	// with nil function, option should be ignored
+	set := NewOptionSet(WithEventDestinations(nil, map[string]*rkentry.EventEntry{"a": tenantA})).(*optionSet)
+	assert.Empty(t, set.eventDestinations)
+
+	set = NewOptionSet(WithEventDestinations(func(req *http.Request) string {
+		return req.Header.Get("X-Tenant")
+	}, map[string]*rkentry.EventEntry{
+		"a":   tenantA,
+		"b":   tenantB,
+		"nil": nil,
+	})).(*optionSet)
+	assert.Equal(t, []string{"a", "b"}, set.Config()["eventDestinations"])
+
+	for _, tenant := range []string{"a", "b", "b", "unknown"} {
+		req := httptest.NewRequest(http.MethodGet, "/ut-path", nil)
+		req.Header.Set("X-Tenant", tenant)
+		beforeCtx := set.BeforeCtx(req)
+		assert.Equal(t, tenant, beforeCtx.Input.EventDestination)
+		set.Before(beforeCtx)
+		set.After(beforeCtx, set.AfterCtx("", "", "200"))
+	}
+
+	assert.Equal(t, 1, logsA.Len())
+	assert.Equal(t, 2, logsB.Len())
+}
+
+func TestWithCountIgnoredPaths(t *testing.T) {
+	metricsSet := rkmidprom.NewMetricsSet("ut", "log", prometheus.NewRegistry())
+	set := NewOptionSet(
+		WithEntryNameAndType("ut-entry", "ut-type"),
+		WithPathToIgnore("/healthz"),
+		WithCountIgnoredPaths(metricsSet))
+	assert.Equal(t, true, set.Config()["countIgnoredPaths"])
+
+	for _, path := range []string{"/healthz", "/healthz/ready", "/ut-path"} {
+		ctx := set.BeforeCtx(httptest.NewRequest(http.MethodGet, path, nil))
+		set.Before(ctx)
+	}
+
+	counter := metricsSet.GetCounter(MetricsNameIgnoredRequests)
+	assert.Equal(t, 1, testutil.CollectAndCount(counter))
+	assert.Equal(t, float64(2), testutil.ToFloat64(counter.WithLabelValues("ut-entry", "/healthz")))
+}
+
+func TestWithEventSampleRateByPath(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	eventEntry := &rkentry.EventEntry{
+		EventFactory: rkquery.NewEventFactory(rkquery.WithZapLogger(zap.New(core))),
+	}
+
+	set := NewOptionSet(
+		WithEventEntry(eventEntry),
+		WithEventSampleRateByPath("", 0),
+		WithEventSampleRateByPath("/ut-heavy", -1),
+		WithEventSampleRateByPath("/ut-heavy/important", 2))
+	assert.Equal(t, map[string]float64{
+		"/ut-heavy":           0,
+		"/ut-heavy/important": 1,
+	}, set.Config()["eventSampleRates"])
+
+	send := func(path, resCode string) {
+		before := set.BeforeCtx(httptest.NewRequest(http.MethodGet, path, nil))
+		set.Before(before)
+		set.After(before, set.AfterCtx("", "", resCode))
+	}
+
+	// successful event of sampled out path should be discarded
+	send("/ut-heavy", "200")
+	assert.Equal(t, 0, logs.Len())
+
+	// errors are always sampled in
+	send("/ut-heavy", "500")
+	send("/ut-heavy", "Internal")
+	assert.Equal(t, 2, logs.Len())
+
+	// the longest prefix should be used
+	send("/ut-heavy/important", "200")
+	assert.Equal(t, 3, logs.Len())
+
+	// other paths are not sampled
+	send("/ut-path", "200")
+	assert.Equal(t, 4, logs.Len())
+}
+
+func TestWithRequestClassifier(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	eventEntry := &rkentry.EventEntry{
+		EventFactory: rkquery.NewEventFactory(rkquery.WithZapLogger(zap.New(core))),
+	}
+
+	set := NewOptionSet(
+		WithEventEntry(eventEntry),
+		WithEventSampleRateByPath("/ut-heavy", 0),
+		WithRequestClassifier(nil),
+		WithRequestClassifier(rkmid.RequestClassifierFunc(func(info *rkmid.RequestInfo) rkmid.SamplingDecision {
+			switch info.Method {
+			case http.MethodPost:
+				return rkmid.SamplingDecisionKeep
+			case http.MethodDelete:
+				return rkmid.SamplingDecisionDrop
+			}
+			if info.ResCode == http.StatusNotFound {
+				return rkmid.SamplingDecisionKeep
+			}
+			return rkmid.SamplingDecisionDefault
+		})))
+	assert.True(t, set.Config()["requestClassifier"].(bool))
+
+	send := func(method, resCode string) {
+		before := set.BeforeCtx(httptest.NewRequest(method, "/ut-heavy", nil))
+		set.Before(before)
+		set.After(before, set.AfterCtx("", "", resCode))
+	}
+
+	// with request dropped by classifier, even errors are discarded
+	send(http.MethodDelete, "500")
+	assert.Equal(t, 0, logs.Len())
+
+	// with request sampled out by path
+	send(http.MethodGet, "200")
+	assert.Equal(t, 0, logs.Len())
+
+	// with request kept by classifier, sample rate is bypassed
+	send(http.MethodPost, "200")
+	assert.Equal(t, 1, logs.Len())
+
+	// with name of gRPC code, it should be mapped to HTTP status code
+	send(http.MethodGet, "NotFound")
+	assert.Equal(t, 2, logs.Len())
+}
+
+func TestToHttpCode(t *testing.T) {
+	code, ok := toHttpCode("201")
+	assert.True(t, ok)
+	assert.Equal(t, http.StatusCreated, code)
+
+	code, ok = toHttpCode("ResourceExhausted")
+	assert.True(t, ok)
+	assert.Equal(t, http.StatusTooManyRequests, code)
+
+	_, ok = toHttpCode("")
+	assert.False(t, ok)
+
+	_, ok = toHttpCode("ut-unknown")
+	assert.False(t, ok)
+}
+
+func TestWithMaxEventPayloadBytes(t *testing.T) {
+	set := NewOptionSet(WithMaxEventPayloadBytes(20)).(*optionSet)
+	assert.Equal(t, 20, set.Config()["maxPayloadBytes"])
+
+	event := set.createEvent("/ut-path", "", true)
+	fields := []zap.Field{
+		zap.String("small", "12345"),
+		zap.Int("int", 1),
+		zap.String("large", "1234567890"),
+		zap.Any("exceed", map[string]string{"k": "v"}),
+		zap.Bool("bool", true),
+		zap.String("string", "value"),
+	}
+	event.AddPayloads(fields...)
+
+	payloads := event.ListPayloads()
+	assert.Equal(t, zap.String("small", "12345"), payloads[0])
+	assert.Equal(t, zap.Int("int", 1), payloads[1])
+	// remaining 7 bytes in aggregate
+	assert.Equal(t, zap.String("large", "1234567"+truncatedMarker), payloads[2])
+	// objects should be replaced with marker, numbers and bools should be kept after limit reached
+	assert.Equal(t, zap.String("exceed", truncatedMarker), payloads[3])
+	assert.Equal(t, zap.Bool("bool", true), payloads[4])
+	assert.Equal(t, zap.String("string", truncatedMarker), payloads[5])
+
+	// fields of caller should not be modified
+	assert.Equal(t, zap.String("large", "1234567890"), fields[2])
+
+	// per field limit
+	event = set.createEvent("/ut-path", "", false)
+	event.AddPayloads(zap.Binary("binary", []byte("123456789012345678901")))
+	assert.Equal(t, zap.String("binary", "12345678901234567890"+truncatedMarker), event.ListPayloads()[0])
+
+	// objects should be rendered and truncated as string
+	event = set.createEvent("/ut-path", "", false)
+	event.AddPayloads(
+		zap.Any("small", map[string]string{"k": "v"}),
+		zap.Error(errors.New("123456789012345678901")))
+	assert.Equal(t, zap.Any("small", map[string]string{"k": "v"}), event.ListPayloads()[0])
+	assert.Equal(t, zap.String("error", "123456789012"+truncatedMarker), event.ListPayloads()[1])
+
+	// multi-byte runes should not be split
+	event = set.createEvent("/ut-path", "", false)
+	event.AddPayloads(zap.String("utf8", "1234567890123456789世界"))
+	assert.Equal(t, zap.String("utf8", "1234567890123456789"+truncatedMarker), event.ListPayloads()[0])
+
+	// without limit
+	set = NewOptionSet(WithMaxEventPayloadBytes(0)).(*optionSet)
+	event = set.createEvent("/ut-path", "", true)
+	event.AddPayloads(zap.String("large", "1234567890"))
+	assert.Equal(t, zap.String("large", "1234567890"), event.ListPayloads()[0])
+}
+
+func TestOptionSet_finishEvent(t *testing.T) {
+	// queue is full, event should be dropped
+	set := NewOptionSet().(*optionSet)
+	set.asyncQueue = make(chan rkquery.Event, 1)
+	set.finishEvent(set.createEvent("/ut-path", "", true))
+	set.finishEvent(set.createEvent("/ut-path", "", true))
+	assert.Equal(t, uint64(1), set.DroppedEvents())
+
+	set.Flush()
+	assert.Empty(t, set.asyncQueue)
+}
+
+func TestWithRetryHeader(t *testing.T) {
+	set := NewOptionSet(WithRetryHeader("X-Retry-Count"))
+
+	// without header
+	ctx := set.BeforeCtx(httptest.NewRequest(http.MethodGet, "/ut-path", nil))
+	set.Before(ctx)
+	assert.Equal(t, 0, ctx.Input.RetryAttempt)
+
+	// with header
+	req := httptest.NewRequest(http.MethodGet, "/ut-path", nil)
+	req.Header.Set("X-Retry-Count", "3")
+	ctx = set.BeforeCtx(req)
+	set.Before(ctx)
+	assert.Equal(t, 3, ctx.Input.RetryAttempt)
+
+	// with invalid header
+	req.Header.Set("X-Retry-Count", "invalid")
+	ctx = set.BeforeCtx(req)
+	assert.Equal(t, 0, ctx.Input.RetryAttempt)
+}
+
+func TestWithEventFieldsFromBaggage(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	eventEntry := &rkentry.EventEntry{
+		EventFactory: rkquery.NewEventFactory(rkquery.WithZapLogger(zap.New(core))),
+	}
+
+	set := NewOptionSet(
+		WithEventEntry(eventEntry),
+		WithEventFieldsFromBaggage("", "tenant", "experiment"))
+	assert.Equal(t, []string{"tenant", "experiment"}, set.Config()["baggageKeys"])
+
+	// with baggage header
+	req := httptest.NewRequest(http.MethodGet, "/ut-path", nil)
+	req.Header.Set("baggage", "tenant=ut-tenant,other=ut-other")
+	ctx := set.BeforeCtx(req)
+	assert.Equal(t, map[string]string{"tenant": "ut-tenant"}, ctx.Input.Baggage)
+	set.Before(ctx)
+	assert.Contains(t, ctx.Output.Event.ListPayloads(), zap.String("tenant", "ut-tenant"))
+	set.After(ctx, set.AfterCtx("", "", "200"))
+	assert.Equal(t, 1, logs.Len())
+
+	// with baggage in context of request
+	member, _ := baggage.NewMember("experiment", "ut-experiment")
+	bag, _ := baggage.New(member)
+	req = httptest.NewRequest(http.MethodGet, "/ut-path", nil)
+	req.Header.Set("baggage", "tenant=ut-tenant")
+	req = req.WithContext(baggage.ContextWithBaggage(req.Context(), bag))
+	ctx = set.BeforeCtx(req)
+	assert.Equal(t, map[string]string{"experiment": "ut-experiment"}, ctx.Input.Baggage)
+
+	// without baggage
+	ctx = set.BeforeCtx(httptest.NewRequest(http.MethodGet, "/ut-path", nil))
+	assert.Empty(t, ctx.Input.Baggage)
+}
+
+func TestWithTraceIdField(t *testing.T) {
+	// with default fields
+	set := NewOptionSet(WithTraceIdField(""), WithRequestIdField("")).(*optionSet)
+	assert.Equal(t, defaultTraceIdField, set.traceIdField)
+	assert.Equal(t, defaultRequestIdField, set.requestIdField)
+
+	before := set.BeforeCtx(httptest.NewRequest(http.MethodGet, "/ut-path", nil))
+	set.Before(before)
+	set.After(before, set.AfterCtx("ut-req", "ut-trace", "200"))
+	assert.Equal(t, "ut-trace", before.Output.Event.GetTraceId())
+	assert.Equal(t, "ut-req", before.Output.Event.GetRequestId())
+
+	// with custom fields
+	set = NewOptionSet(WithTraceIdField("dd.trace_id"), WithRequestIdField("request_id")).(*optionSet)
+	before = set.BeforeCtx(httptest.NewRequest(http.MethodGet, "/ut-path", nil))
+	set.Before(before)
+	set.After(before, set.AfterCtx("ut-req", "ut-trace", "200"))
+	assert.Empty(t, before.Output.Event.GetTraceId())
+	assert.Empty(t, before.Output.Event.GetRequestId())
+
+	fields := make(map[string]string)
+	for _, field := range before.Output.Event.ListPayloads() {
+		fields[field.Key] = field.String
+	}
+	assert.Equal(t, "ut-trace", fields["dd.trace_id"])
+	assert.Equal(t, "ut-req", fields["request_id"])
+}
+
+func TestAfterCtx_Finalize(t *testing.T) {
+	set := NewOptionSet()
+
+	// with deferred response code
+	before := set.BeforeCtx(httptest.NewRequest(http.MethodGet, "/ut-path", nil))
+	set.Before(before)
+	after := set.AfterCtx("reqId", "traceId", "")
+	after.Defer()
+	set.After(before, after)
+	assert.True(t, before.Output.Event.GetEndTime().IsZero())
+
+	after.Input.ResCode = "200"
+	after.Finalize()
+	assert.False(t, before.Output.Event.GetEndTime().IsZero())
+	assert.Equal(t, "200", before.Output.Event.GetResCode())
+
+	// finalize twice should be no-op
+	after.Finalize()
+
+	// with finalize before After()
+	before = set.BeforeCtx(httptest.NewRequest(http.MethodGet, "/ut-path", nil))
+	set.Before(before)
+	after = set.AfterCtx("reqId", "traceId", "")
+	after.Defer()
+	after.Input.ResCode = "500"
+	after.Finalize()
+	set.After(before, after)
+	assert.Equal(t, "500", before.Output.Event.GetResCode())
+}
+
+func TestWithBodyHashing(t *testing.T) {
+	set := NewOptionSet(WithBodyHashing(1024))
+
+	req := httptest.NewRequest(http.MethodPost, "/ut-path", strings.NewReader("ut-body"))
+	ctx := set.BeforeCtx(req)
+	set.Before(ctx)
+	assert.Len(t, ctx.Output.BodyHash, 64)
+
+	var found bool
+	for _, field := range ctx.Output.Event.ListPayloads() {
+		if field.Key == "bodyHash" {
+			found = true
+			assert.Equal(t, ctx.Output.BodyHash, field.String)
+		}
+	}
+	assert.True(t, found)
+
+	// without body hashing
+	set = NewOptionSet()
+	ctx = set.BeforeCtx(httptest.NewRequest(http.MethodPost, "/ut-path", strings.NewReader("ut-body")))
+	assert.Empty(t, ctx.Output.BodyHash)
+}
+
+func TestWithResponseBodyCapture(t *testing.T) {
+	set := NewOptionSet(WithResponseBodyCapture(4))
+
+	findPayload := func(ctx *BeforeCtx, key string) (zap.Field, bool) {
+		for _, field := range ctx.Output.Event.ListPayloads() {
+			if field.Key == key {
+				return field, true
+			}
+		}
+		return zap.Field{}, false
+	}
+
+	// writer shared with other middleware which requires larger buffer
+	writer := rkmid.NewCountingResponseWriter(httptest.NewRecorder())
+	writer.CaptureBody(1024)
+	req := rkmid.SetCountingResponseWriter(httptest.NewRequest(http.MethodGet, "/ut-path", nil), writer)
+	before := set.BeforeCtx(req)
+	set.Before(before)
+	writer.Write([]byte("ut-body"))
+	set.After(before, set.AfterCtx("reqId", "traceId", "200"))
+
+	field, ok := findPayload(before, "resBody")
+	assert.True(t, ok)
+	assert.Equal(t, "ut-b", field.String)
+	_, ok = findPayload(before, "resBodyTruncated")
+	assert.True(t, ok)
+
+	// without writer in context
+	before = set.BeforeCtx(httptest.NewRequest(http.MethodGet, "/ut-path", nil))
+	set.Before(before)
+	set.After(before, set.AfterCtx("reqId", "traceId", "200"))
+	_, ok = findPayload(before, "resBody")
+	assert.False(t, ok)
+}
+
+func TestIsSuccessResCode(t *testing.T) {
+	assert.True(t, isSuccessResCode("200"))
+	assert.True(t, isSuccessResCode("OK"))
+	assert.False(t, isSuccessResCode("500"))
+	assert.False(t, isSuccessResCode("Internal"))
+}
+
+func TestIsErrorResCode(t *testing.T) {
+	assert.True(t, isErrorResCode("404"))
+	assert.True(t, isErrorResCode("500"))
+	assert.True(t, isErrorResCode("Internal"))
+	assert.True(t, isErrorResCode("Unauthenticated"))
+	assert.False(t, isErrorResCode("200"))
+	assert.False(t, isErrorResCode("OK"))
+
+	// with empty or unknown code
+	assert.False(t, isErrorResCode(""))
+	assert.False(t, isErrorResCode("unknown"))
+}
+
+func TestParseContentRange(t *testing.T) {
+	// happy case
+	start, end, ok := parseContentRange("bytes 0-1023/4096")
+	assert.True(t, ok)
+	assert.Equal(t, int64(0), start)
+	assert.Equal(t, int64(1023), end)
+
+	// with unknown total size
+	start, end, ok = parseContentRange("bytes 100-199/*")
+	assert.True(t, ok)
+	assert.Equal(t, int64(100), start)
+	assert.Equal(t, int64(199), end)
+
+	// with invalid input
+	_, _, ok = parseContentRange("bytes */4096")
+	assert.False(t, ok)
+	_, _, ok = parseContentRange("items 0-1/2")
+	assert.False(t, ok)
+	_, _, ok = parseContentRange("bytes 10-1/20")
+	assert.False(t, ok)
+}
+
 func TestToOptions(t *testing.T) {
 	config := &BootConfig{
 		Enabled:           false,
@@ -137,6 +730,69 @@ func TestWithEventLoggerEntry(t *testing.T) {
 	assert.Equal(t, entry, set.eventEntry)
 }
 
+func TestOptionSet_SetLevel(t *testing.T) {
+	// with logger entries which own configs
+	loggerConfig := zap.NewProductionConfig()
+	logger, _ := loggerConfig.Build()
+	loggerEntry := &rkentry.LoggerEntry{Logger: logger, LoggerConfig: &loggerConfig}
+	eventConfig := zap.NewProductionConfig()
+	eventEntry := rkentry.NewEventEntryNoop()
+	eventEntry.LoggerConfig = &eventConfig
+
+	set := NewOptionSet(
+		WithLoggerEntry(loggerEntry),
+		WithEventEntry(eventEntry)).(*optionSet)
+	assert.Equal(t, zapcore.InfoLevel, set.GetLevel().Level())
+	assert.False(t, set.zapLogger.Core().Enabled(zapcore.DebugLevel))
+
+	// levels could be lowered without changing levels of shared entries
+	set.SetLevel(zapcore.DebugLevel)
+	set.SetEventLevel(zapcore.DebugLevel)
+	assert.True(t, set.zapLogger.Core().Enabled(zapcore.DebugLevel))
+	assert.True(t, set.eventLoggerOverride.Core().Enabled(zapcore.DebugLevel))
+	assert.False(t, loggerEntry.Logger.Core().Enabled(zapcore.DebugLevel))
+	assert.Equal(t, zapcore.InfoLevel, loggerConfig.Level.Level())
+	assert.Equal(t, zapcore.InfoLevel, eventConfig.Level.Level())
+
+	// levels could be raised independently
+	set.SetLevel(zapcore.WarnLevel)
+	set.SetEventLevel(zapcore.ErrorLevel)
+	assert.Equal(t, zapcore.WarnLevel, set.GetLevel().Level())
+	assert.Equal(t, zapcore.ErrorLevel, set.GetEventLevel().Level())
+	assert.False(t, set.zapLogger.Core().Enabled(zapcore.InfoLevel))
+	assert.True(t, loggerEntry.Logger.Core().Enabled(zapcore.InfoLevel))
+
+	// events should be dropped if level is higher than info
+	assert.IsType(t, eventEntry.EventFactory.CreateEventNoop(), set.createEvent("/ut-path", "", false))
+
+	// without config, level could only be raised
+	core, logs := observer.New(zap.InfoLevel)
+	set = NewOptionSet(WithLoggerEntry(&rkentry.LoggerEntry{Logger: zap.New(core)})).(*optionSet)
+	set.zapLogger.Info("ut-info")
+	set.SetLevel(zapcore.ErrorLevel)
+	assert.Equal(t, zapcore.ErrorLevel, set.GetLevel().Level())
+	set.zapLogger.Warn("ut-warn")
+	set.zapLogger.With(zap.String("key", "value")).Warn("ut-warn")
+	assert.Equal(t, 1, logs.Len())
+	assert.Equal(t, zapcore.InfoLevel, rkentry.LoggerEntryStdout.LoggerConfig.Level.Level())
+}
+
+func TestWithEventEntryRef(t *testing.T) {
+	defer rkentry.GlobalAppCtx.RemoveEntryByType(rkentry.EventEntryType)
+
+	// with registered entry
+	entry := rkentry.NewEventEntryNoop()
+	rkentry.GlobalAppCtx.AddEntry(entry)
+	set := NewOptionSet(
+		WithEventEntryRef(entry.GetName())).(*optionSet)
+	assert.Equal(t, entry, set.eventEntry)
+
+	// with unresolved name, fallback to default
+	set = NewOptionSet(
+		WithEventEntryRef("not-exist")).(*optionSet)
+	assert.Equal(t, rkentry.GlobalAppCtx.GetEventEntryDefault(), set.eventEntry)
+}
+
 func TestWithLoggerEncoding(t *testing.T) {
 	set := NewOptionSet(
 		WithLoggerEncoding(json)).(*optionSet)
@@ -144,6 +800,32 @@ func TestWithLoggerEncoding(t *testing.T) {
 	assert.Equal(t, json, set.zapLoggerEncoding)
 }
 
+func TestResolveEncoding(t *testing.T) {
+	t.Setenv("DOMAIN", "")
+	t.Setenv("ENV", "")
+
+	// with explicit encoding
+	assert.Equal(t, json, resolveEncoding("JSON"))
+	assert.Equal(t, console, resolveEncoding("console"))
+
+	// with auto in production
+	assert.Equal(t, json, resolveEncoding("auto"))
+	t.Setenv("DOMAIN", "prod")
+	assert.Equal(t, json, resolveEncoding("auto"))
+
+	// with auto in dev
+	t.Setenv("DOMAIN", "dev")
+	assert.Equal(t, console, resolveEncoding("auto"))
+
+	// with ENV while DOMAIN is empty
+	t.Setenv("DOMAIN", "")
+	t.Setenv("ENV", "local")
+	assert.Equal(t, console, resolveEncoding("auto"))
+
+	set := NewOptionSet(WithEventEncoding("auto")).(*optionSet)
+	assert.Equal(t, rkquery.CONSOLE, set.eventLoggerEncoding)
+}
+
 func TestWithLoggerOutputPaths(t *testing.T) {
 	set := NewOptionSet(
 		WithLoggerOutputPaths("ut-path")).(*optionSet)
@@ -182,6 +864,12 @@ func TestNewOptionSetMock(t *testing.T) {
 	assert.NotNil(t, mock.AfterCtx("", "", ""))
 	mock.Before(nil)
 	mock.After(nil, nil)
+
+	levels := mock.(LevelInterface)
+	levels.SetLevel(zapcore.DebugLevel)
+	levels.SetEventLevel(zapcore.WarnLevel)
+	assert.Equal(t, zapcore.DebugLevel, levels.GetLevel().Level())
+	assert.Equal(t, zapcore.WarnLevel, levels.GetEventLevel().Level())
 }
 
 func assertNotPanic(t *testing.T) {
@@ -193,3 +881,10 @@ func assertNotPanic(t *testing.T) {
 		assert.True(t, true)
 	}
 }
+
+func TestOptionSet_Config(t *testing.T) {
+	set := NewOptionSet(WithEntryNameAndType("ut-config", "ut-type"))
+	config := set.Config()
+	assert.Equal(t, "ut-config", config["entryName"])
+	assert.Equal(t, "ut-type", config["entryType"])
+}
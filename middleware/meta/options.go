@@ -30,6 +30,8 @@ type OptionSetInterface interface {
 	BeforeCtx(*http.Request, rkquery.Event) *BeforeCtx
 
 	ShouldIgnore(string) bool
+
+	Config() map[string]interface{}
 }
 
 // ***************** OptionSet Implementation *****************
@@ -86,6 +88,20 @@ func (set *optionSet) GetEntryType() string {
 	return set.entryType
 }
 
+// Config returns effective configuration, secrets are redacted
+func (set *optionSet) Config() map[string]interface{} {
+	return map[string]interface{}{
+		"entryName":       set.entryName,
+		"entryType":       set.entryType,
+		"prefix":          set.prefix,
+		"appNameKey":      set.appNameKey,
+		"appVersionKey":   set.appVersionKey,
+		"appUnixTimeKey":  set.appUnixTimeKey,
+		"receivedTimeKey": set.receivedTimeKey,
+		"pathToIgnore":    set.pathToIgnore,
+	}
+}
+
 // BeforeCtx should be created before Before()
 func (set *optionSet) BeforeCtx(req *http.Request, event rkquery.Event) *BeforeCtx {
 	ctx := NewBeforeCtx()
@@ -163,6 +179,11 @@ func (mock *optionSetMock) GetEntryType() string {
 	return "mock"
 }
 
+// Config returns empty map
+func (mock *optionSetMock) Config() map[string]interface{} {
+	return map[string]interface{}{}
+}
+
 // BeforeCtx should be created before Before()
 func (mock *optionSetMock) BeforeCtx(req *http.Request, event rkquery.Event) *BeforeCtx {
 	return mock.before
@@ -78,3 +78,10 @@ func assertNotPanic(t *testing.T) {
 		assert.True(t, true)
 	}
 }
+
+func TestOptionSet_Config(t *testing.T) {
+	set := NewOptionSet(WithEntryNameAndType("ut-config", "ut-type"))
+	config := set.Config()
+	assert.Equal(t, "ut-config", config["entryName"])
+	assert.Equal(t, "ut-type", config["entryType"])
+}
@@ -0,0 +1,51 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkmid
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// CallWithTimeout calls f with context derived from ctx which will be cancelled after timeout,
+// so that user provided functions invoked by middleware, like extractors, could not block request indefinitely.
+//
+// context.DeadlineExceeded will be returned if f does not return in time, or error of ctx if it was done before.
+// f keeps running in background after timeout, it should return once context done.
+// Panic in f will be recovered and returned as error, since it could not reach recovery middleware from goroutine.
+// f will be called directly if timeout is not positive.
+func CallWithTimeout(ctx context.Context, timeout time.Duration, f func(context.Context) error) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if timeout <= 0 {
+		return f(ctx)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// buffered, so that f will not be blocked after timeout
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if recv := recover(); recv != nil {
+				done <- fmt.Errorf("panic occurs: %v", recv)
+			}
+		}()
+
+		done <- f(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
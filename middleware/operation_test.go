@@ -0,0 +1,51 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkmid
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestCallWithTimeout(t *testing.T) {
+	// without timeout
+	assert.Nil(t, CallWithTimeout(nil, 0, func(ctx context.Context) error {
+		assert.NotNil(t, ctx)
+		return nil
+	}))
+
+	// with error returned in time
+	err := CallWithTimeout(context.Background(), time.Second, func(ctx context.Context) error {
+		return errors.New("ut-error")
+	})
+	assert.EqualError(t, err, "ut-error")
+
+	// with timeout
+	err = CallWithTimeout(context.Background(), 10*time.Millisecond, func(ctx context.Context) error {
+		<-ctx.Done()
+		time.Sleep(10 * time.Millisecond)
+		return nil
+	})
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	// with panic
+	err = CallWithTimeout(context.Background(), time.Second, func(ctx context.Context) error {
+		panic("ut-panic")
+	})
+	assert.EqualError(t, err, "panic occurs: ut-panic")
+
+	// with parent cancelled
+	parent, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = CallWithTimeout(parent, time.Second, func(ctx context.Context) error {
+		<-ctx.Done()
+		return nil
+	})
+	assert.True(t, err == nil || err == context.Canceled)
+}
@@ -56,3 +56,10 @@ func TestNewOptionSetMock(t *testing.T) {
 	assert.NotNil(t, mock.BeforeCtx(nil, nil, nil))
 	mock.Before(nil)
 }
+
+func TestOptionSet_Config(t *testing.T) {
+	set := NewOptionSet(WithEntryNameAndType("ut-config", "ut-type"))
+	config := set.Config()
+	assert.Equal(t, "ut-config", config["entryName"])
+	assert.Equal(t, "ut-type", config["entryType"])
+}
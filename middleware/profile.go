@@ -0,0 +1,121 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkmid
+
+import "sync"
+
+const (
+	// SecurityProfileStrict is a built-in security profile which denies cross-origin access and framing
+	SecurityProfileStrict = "strict"
+	// SecurityProfileRelaxed is a built-in security profile which allows cross-origin access
+	SecurityProfileRelaxed = "relaxed"
+	// AllowOriginSameOrigin could be used as allowed origin of cors middleware, which allows only requests
+	// whose Origin has the same host as the request, all cross-origin requests will be refused.
+	AllowOriginSameOrigin = "same-origin"
+)
+
+var securityProfilesLock = sync.RWMutex{}
+
+var securityProfiles = map[string]*SecurityProfile{
+	SecurityProfileStrict: {
+		Secure: SecureProfile{
+			XssProtection:         "1; mode=block",
+			ContentTypeNosniff:    "nosniff",
+			XFrameOptions:         "DENY",
+			HstsMaxAge:            31536000,
+			ContentSecurityPolicy: "default-src 'self'",
+			ReferrerPolicy:        "no-referrer",
+		},
+		Cors: CorsProfile{
+			AllowOrigins: []string{AllowOriginSameOrigin},
+			AllowMethods: []string{"GET", "HEAD", "POST"},
+			MaxAge:       600,
+		},
+		Csrf: CsrfProfile{
+			TokenLength:    32,
+			CookieHttpOnly: true,
+			CookieSameSite: "strict",
+		},
+	},
+	SecurityProfileRelaxed: {
+		Secure: SecureProfile{
+			XssProtection:      "1; mode=block",
+			ContentTypeNosniff: "nosniff",
+			XFrameOptions:      "SAMEORIGIN",
+			ReferrerPolicy:     "strict-origin-when-cross-origin",
+		},
+		Cors: CorsProfile{
+			AllowOrigins: []string{"*"},
+			MaxAge:       86400,
+		},
+		Csrf: CsrfProfile{
+			CookieSameSite: "lax",
+		},
+	},
+}
+
+// SecurityProfile is a named preset of secure, cors and csrf middleware, selected by profile field of BootConfig.
+//
+// Values of profile are used as defaults while converting BootConfig into options,
+// non-empty fields of BootConfig will override them. Boolean fields enabled in profile could not be
+// disabled by BootConfig, register a custom profile instead.
+type SecurityProfile struct {
+	Secure SecureProfile
+	Cors   CorsProfile
+	Csrf   CsrfProfile
+}
+
+// SecureProfile defaults of secure middleware
+type SecureProfile struct {
+	XssProtection         string
+	ContentTypeNosniff    string
+	XFrameOptions         string
+	HstsMaxAge            int
+	HstsExcludeSubdomains bool
+	HstsPreloadEnabled    bool
+	ContentSecurityPolicy string
+	CspReportOnly         bool
+	ReferrerPolicy        string
+}
+
+// CorsProfile defaults of cors middleware
+type CorsProfile struct {
+	AllowOrigins     []string
+	AllowCredentials bool
+	AllowHeaders     []string
+	AllowMethods     []string
+	ExposeHeaders    []string
+	MaxAge           int
+}
+
+// CsrfProfile defaults of csrf middleware
+type CsrfProfile struct {
+	TokenLength    int
+	CookieMaxAge   int
+	CookieHttpOnly bool
+	CookieSameSite string
+}
+
+// RegisterSecurityProfile register custom security profile, built-in profile with the same name will be overridden.
+// It should be called before middlewares created.
+func RegisterSecurityProfile(name string, profile *SecurityProfile) {
+	if len(name) < 1 || profile == nil {
+		return
+	}
+
+	securityProfilesLock.Lock()
+	defer securityProfilesLock.Unlock()
+
+	securityProfiles[name] = profile
+}
+
+// GetSecurityProfile returns security profile with name, nil will be returned if not found
+func GetSecurityProfile(name string) *SecurityProfile {
+	securityProfilesLock.RLock()
+	defer securityProfilesLock.RUnlock()
+
+	return securityProfiles[name]
+}
@@ -0,0 +1,31 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkmid
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRegisterSecurityProfile(t *testing.T) {
+	// built-in profiles
+	assert.NotNil(t, GetSecurityProfile(SecurityProfileStrict))
+	assert.NotNil(t, GetSecurityProfile(SecurityProfileRelaxed))
+	assert.Nil(t, GetSecurityProfile("ut-non-exist"))
+
+	// with invalid input
+	RegisterSecurityProfile("", &SecurityProfile{})
+	RegisterSecurityProfile("ut-profile", nil)
+	assert.Nil(t, GetSecurityProfile(""))
+	assert.Nil(t, GetSecurityProfile("ut-profile"))
+
+	// with custom profile
+	profile := &SecurityProfile{}
+	profile.Secure.XFrameOptions = "DENY"
+	RegisterSecurityProfile("ut-profile", profile)
+	defer delete(securityProfiles, "ut-profile")
+	assert.Equal(t, profile, GetSecurityProfile("ut-profile"))
+}
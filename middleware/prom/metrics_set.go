@@ -10,7 +10,10 @@ import (
 	"errors"
 	"fmt"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
 	"github.com/prometheus/common/model"
+	"github.com/rookie-ninja/rk-entry/v2/entry"
+	"sort"
 	"strings"
 	"sync"
 )
@@ -20,6 +23,9 @@ const (
 	separator        = "::"
 	namespaceDefault = "rk"
 	subSystemDefault = "svc"
+
+	// OverflowLabelValue is label value of series which collects observations exceeding max series
+	OverflowLabelValue = "overflow"
 )
 
 // SummaryObjectives will track quantile of P50, P90, P99, P9999 by default.
@@ -37,6 +43,8 @@ var SummaryObjectives = map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001, 0
 // 7: histograms: map of histograms
 // 8: lock:       lock for thread safety
 // 9: registerer  prometheus.Registerer
+// 10: maxSeries  max number of distinct label values per metrics, 0 means unlimited
+// 11: series     distinct label values per metrics, tracked only if maxSeries enabled
 type MetricsSet struct {
 	namespace  string
 	subSystem  string
@@ -47,6 +55,8 @@ type MetricsSet struct {
 	histograms map[string]*prometheus.HistogramVec
 	lock       sync.Mutex
 	registerer prometheus.Registerer
+	maxSeries  int
+	series     map[string]map[string]struct{}
 }
 
 // NewMetricsSet creates metrics set with namespace, subSystem and registerer.
@@ -76,6 +86,7 @@ func NewMetricsSet(namespace, subSystem string, registerer prometheus.Registerer
 		histograms: make(map[string]*prometheus.HistogramVec),
 		lock:       sync.Mutex{},
 		registerer: registerer,
+		series:     make(map[string]map[string]struct{}),
 	}
 
 	if metrics.registerer == nil {
@@ -85,6 +96,12 @@ func NewMetricsSet(namespace, subSystem string, registerer prometheus.Registerer
 	return &metrics
 }
 
+// NewMetricsSetWithNamedRegistry creates metrics set with namespace, subSystem and registry named with registryName,
+// registry will be created if missing. See rkentry.GetNamedRegistry for details.
+func NewMetricsSetWithNamedRegistry(namespace, subSystem, registryName string) *MetricsSet {
+	return NewMetricsSet(namespace, subSystem, rkentry.GetNamedRegistry(registryName))
+}
+
 // GetNamespace returns namespace
 func (set *MetricsSet) GetNamespace() string {
 	return set.namespace
@@ -100,6 +117,61 @@ func (set *MetricsSet) GetRegisterer() prometheus.Registerer {
 	return set.registerer
 }
 
+// GetGatherer returns gatherer of metrics registered in this set.
+//
+// Registerer will be returned if it is a prometheus.Gatherer as well, like prometheus.Registry,
+// otherwise, prometheus.DefaultGatherer will be returned.
+func (set *MetricsSet) GetGatherer() prometheus.Gatherer {
+	if gatherer, ok := set.registerer.(prometheus.Gatherer); ok {
+		return gatherer
+	}
+
+	return prometheus.DefaultGatherer
+}
+
+// PushGateway returns push.Pusher which pushes metrics of this set to PushGateway at url with job name.
+//
+// It is useful for short-lived batch jobs which could not be scraped. Grouping labels will be added to pusher.
+// Call Push() or Add() on returned pusher, or use rkentry.PromEntry for periodic pushing.
+func (set *MetricsSet) PushGateway(url, job string, grouping map[string]string) *push.Pusher {
+	pusher := push.New(url, job).Gatherer(set.GetGatherer())
+
+	keys := make([]string, 0, len(grouping))
+	for k := range grouping {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		pusher = pusher.Grouping(k, grouping[k])
+	}
+
+	return pusher
+}
+
+// SetMaxSeries is thread safe
+//
+// Set max number of distinct label values of each metrics, 0 or negative value means unlimited.
+// Once exceeded, observations of new label values will be recorded into series whose label values are all
+// OverflowLabelValue, in order to protect memory from unbounded label values.
+func (set *MetricsSet) SetMaxSeries(n int) {
+	set.lock.Lock()
+	defer set.lock.Unlock()
+
+	if n < 0 {
+		n = 0
+	}
+	set.maxSeries = n
+}
+
+// GetMaxSeries returns max number of distinct label values of each metrics
+func (set *MetricsSet) GetMaxSeries() int {
+	set.lock.Lock()
+	defer set.lock.Unlock()
+
+	return set.maxSeries
+}
+
 // RegisterCounter is thread safe
 // Register a counter with namespace and subsystem in MetricsSet
 func (set *MetricsSet) RegisterCounter(name string, labelKeys ...string) error {
@@ -147,10 +219,11 @@ func (set *MetricsSet) UnRegisterCounter(name string) {
 
 	// check existence
 	if set.containsKey(key) {
-		prometheus.Unregister(set.counters[key])
+		set.registerer.Unregister(set.counters[key])
 
 		delete(set.counters, key)
 		delete(set.keys, key)
+		delete(set.series, key)
 	}
 }
 
@@ -205,6 +278,7 @@ func (set *MetricsSet) UnRegisterGauge(name string) {
 
 		delete(set.gauges, key)
 		delete(set.keys, key)
+		delete(set.series, key)
 	}
 }
 
@@ -267,6 +341,7 @@ func (set *MetricsSet) UnRegisterHistogram(name string) {
 
 		delete(set.histograms, key)
 		delete(set.keys, key)
+		delete(set.series, key)
 	}
 }
 
@@ -274,6 +349,16 @@ func (set *MetricsSet) UnRegisterHistogram(name string) {
 // Register a summary with namespace, subsystem and objectives in MetricsSet
 // If objectives is nil, then default SummaryObjectives would be applied
 func (set *MetricsSet) RegisterSummary(name string, objectives map[float64]float64, labelKeys ...string) error {
+	return set.RegisterSummaryWithOpts(name, prometheus.SummaryOpts{
+		Objectives: objectives,
+	}, labelKeys...)
+}
+
+// RegisterSummaryWithOpts thread safe
+// Register a summary with namespace, subsystem and summary options in MetricsSet, like MaxAge and AgeBuckets.
+// Namespace, subsystem and name of options will be overridden by MetricsSet.
+// If objectives is nil, then default SummaryObjectives would be applied
+func (set *MetricsSet) RegisterSummaryWithOpts(name string, opts prometheus.SummaryOpts, labelKeys ...string) error {
 	set.lock.Lock()
 	defer set.lock.Unlock()
 
@@ -287,17 +372,15 @@ func (set *MetricsSet) RegisterSummary(name string, objectives map[float64]float
 		return errors.New(fmt.Sprintf("duplicate summary name:%s", name))
 	}
 
-	if objectives == nil {
-		objectives = SummaryObjectives
+	if opts.Objectives == nil {
+		opts.Objectives = SummaryObjectives
 	}
 
-	// create a new one with default options
-	opts := prometheus.SummaryOpts{
-		Namespace:  set.namespace,
-		Subsystem:  set.subSystem,
-		Name:       name,
-		Objectives: objectives,
-		Help:       fmt.Sprintf("Summary for name:%s and labels:%s", name, labelKeys),
+	opts.Namespace = set.namespace
+	opts.Subsystem = set.subSystem
+	opts.Name = name
+	if len(opts.Help) < 1 {
+		opts.Help = fmt.Sprintf("Summary for name:%s and labels:%s", name, labelKeys)
 	}
 
 	// panic if labels are not matching
@@ -330,9 +413,40 @@ func (set *MetricsSet) UnRegisterSummary(name string) {
 
 		delete(set.summaries, key)
 		delete(set.keys, key)
+		delete(set.series, key)
 	}
 }
 
+// UnRegisterAll thread safe
+// Unregister all metrics tracked in MetricsSet, so that the same metrics could be registered again.
+func (set *MetricsSet) UnRegisterAll() {
+	set.lock.Lock()
+	defer set.lock.Unlock()
+
+	for _, v := range set.counters {
+		set.registerer.Unregister(v)
+	}
+
+	for _, v := range set.gauges {
+		set.registerer.Unregister(v)
+	}
+
+	for _, v := range set.histograms {
+		set.registerer.Unregister(v)
+	}
+
+	for _, v := range set.summaries {
+		set.registerer.Unregister(v)
+	}
+
+	set.keys = make(map[string]bool)
+	set.counters = make(map[string]*prometheus.CounterVec)
+	set.gauges = make(map[string]*prometheus.GaugeVec)
+	set.summaries = make(map[string]*prometheus.SummaryVec)
+	set.histograms = make(map[string]*prometheus.HistogramVec)
+	set.series = make(map[string]map[string]struct{})
+}
+
 // GetCounter is thread safe
 func (set *MetricsSet) GetCounter(name string) *prometheus.CounterVec {
 	set.lock.Lock()
@@ -426,7 +540,7 @@ func (set *MetricsSet) GetCounterWithValues(name string, values ...string) prome
 	if set.containsKey(key) {
 		counterVec := set.counters[key]
 		// ignore err
-		counter, _ := counterVec.GetMetricWithLabelValues(values...)
+		counter, _ := counterVec.GetMetricWithLabelValues(set.limitSeries(key, values)...)
 		return counter
 	}
 
@@ -446,7 +560,7 @@ func (set *MetricsSet) GetCounterWithLabels(name string, labels prometheus.Label
 	if set.containsKey(key) {
 		counterVec := set.counters[key]
 		// ignore error
-		counter, _ := counterVec.GetMetricWith(labels)
+		counter, _ := counterVec.GetMetricWith(set.limitSeriesWithLabels(key, labels))
 
 		return counter
 	}
@@ -467,7 +581,7 @@ func (set *MetricsSet) GetGaugeWithValues(name string, values ...string) prometh
 	if set.containsKey(key) {
 		gaugeVec := set.gauges[key]
 		// ignore error
-		gauge, _ := gaugeVec.GetMetricWithLabelValues(values...)
+		gauge, _ := gaugeVec.GetMetricWithLabelValues(set.limitSeries(key, values)...)
 
 		return gauge
 	}
@@ -487,7 +601,7 @@ func (set *MetricsSet) GetGaugeWithLabels(name string, labels prometheus.Labels)
 	if set.containsKey(key) {
 		gaugeVec := set.gauges[key]
 		// ignore error
-		gauge, _ := gaugeVec.GetMetricWith(labels)
+		gauge, _ := gaugeVec.GetMetricWith(set.limitSeriesWithLabels(key, labels))
 
 		return gauge
 	}
@@ -508,7 +622,7 @@ func (set *MetricsSet) GetSummaryWithValues(name string, values ...string) prome
 	if set.containsKey(key) {
 		summaryVec := set.summaries[key]
 		// ignore error
-		observer, _ := summaryVec.GetMetricWithLabelValues(values...)
+		observer, _ := summaryVec.GetMetricWithLabelValues(set.limitSeries(key, values)...)
 
 		return observer
 	}
@@ -529,7 +643,7 @@ func (set *MetricsSet) GetSummaryWithLabels(name string, labels prometheus.Label
 	if set.containsKey(key) {
 		summaryVec := set.summaries[key]
 		// ignore error
-		observer, _ := summaryVec.GetMetricWith(labels)
+		observer, _ := summaryVec.GetMetricWith(set.limitSeriesWithLabels(key, labels))
 
 		return observer
 	}
@@ -550,7 +664,7 @@ func (set *MetricsSet) GetHistogramWithValues(name string, values ...string) pro
 	if set.containsKey(key) {
 		hisVec := set.histograms[key]
 		// ignore error
-		observer, _ := hisVec.GetMetricWithLabelValues(values...)
+		observer, _ := hisVec.GetMetricWithLabelValues(set.limitSeries(key, values)...)
 
 		return observer
 	}
@@ -571,7 +685,7 @@ func (set *MetricsSet) GetHistogramWithLabels(name string, labels prometheus.Lab
 	if set.containsKey(key) {
 		hisVec := set.histograms[key]
 		// ignore error
-		observer, _ := hisVec.GetMetricWith(labels)
+		observer, _ := hisVec.GetMetricWith(set.limitSeriesWithLabels(key, labels))
 
 		return observer
 	}
@@ -579,6 +693,77 @@ func (set *MetricsSet) GetHistogramWithLabels(name string, labels prometheus.Lab
 	return nil
 }
 
+// Returns label values of overflow series if values are new and number of series of metrics reached maxSeries.
+// Should be called with lock held.
+func (set *MetricsSet) limitSeries(key string, values []string) []string {
+	if set.admitSeries(key, values) {
+		return values
+	}
+
+	overflow := make([]string, len(values))
+	for i := range overflow {
+		overflow[i] = OverflowLabelValue
+	}
+
+	return overflow
+}
+
+// Same as limitSeries with prometheus.Labels.
+// Should be called with lock held.
+func (set *MetricsSet) limitSeriesWithLabels(key string, labels prometheus.Labels) prometheus.Labels {
+	if set.maxSeries < 1 {
+		return labels
+	}
+
+	names := make([]string, 0, len(labels))
+	for k := range labels {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	values := make([]string, 0, len(names))
+	for i := range names {
+		values = append(values, labels[names[i]])
+	}
+
+	if set.admitSeries(key, values) {
+		return labels
+	}
+
+	overflow := prometheus.Labels{}
+	for i := range names {
+		overflow[names[i]] = OverflowLabelValue
+	}
+
+	return overflow
+}
+
+// Returns true if series with values already tracked or could be tracked without exceeding maxSeries.
+// Should be called with lock held.
+func (set *MetricsSet) admitSeries(key string, values []string) bool {
+	if set.maxSeries < 1 {
+		return true
+	}
+
+	series, ok := set.series[key]
+	if !ok {
+		series = make(map[string]struct{})
+		set.series[key] = series
+	}
+
+	seriesKey := strings.Join(values, separator)
+	if _, ok := series[seriesKey]; ok {
+		return true
+	}
+
+	if len(series) >= set.maxSeries {
+		return false
+	}
+
+	series[seriesKey] = struct{}{}
+	return true
+}
+
 // Construct key with format of namespace::subSystem::name
 func (set *MetricsSet) getKey(name string) string {
 	key := strings.Join([]string{
@@ -7,10 +7,17 @@ package rkmidprom
 
 import (
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rookie-ninja/rk-entry/v2/entry"
 	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"math"
 	"math/rand"
+	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 )
 
 const (
@@ -70,6 +77,52 @@ func TestNewMetricsSet_HappyCase(t *testing.T) {
 	assert.Equal(t, registerer, set.GetRegisterer())
 }
 
+func TestNewMetricsSetWithNamedRegistry(t *testing.T) {
+	defer rkentry.RemoveNamedRegistry("ut-public")
+	defer rkentry.RemoveNamedRegistry("ut-internal")
+
+	public := NewMetricsSetWithNamedRegistry("ut_namespace", "ut_subsystem", "ut-public")
+	internal := NewMetricsSetWithNamedRegistry("ut_namespace", "ut_subsystem", "ut-internal")
+	assert.Equal(t, rkentry.GetNamedRegistry("ut-public"), public.GetRegisterer())
+	assert.Equal(t, rkentry.GetNamedRegistry("ut-internal"), internal.GetRegisterer())
+	assert.NotSame(t, public.GetRegisterer(), internal.GetRegisterer())
+
+	// same metrics name could be registered in both of registries
+	assert.Nil(t, public.RegisterCounter("ut_counter"))
+	assert.Nil(t, internal.RegisterCounter("ut_counter"))
+	public.GetCounterWithValues("ut_counter").Inc()
+
+	families, _ := rkentry.GetNamedRegistry("ut-public").Gather()
+	assert.Len(t, families, 1)
+	families, _ = rkentry.GetNamedRegistry("ut-internal").Gather()
+	assert.Len(t, families, 0)
+}
+
+func TestMetricsSet_PushGateway(t *testing.T) {
+	// with default registerer
+	assert.Equal(t, prometheus.DefaultGatherer, NewMetricsSet("ns", "sub_sys", nil).GetGatherer())
+
+	var path, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		bytes, _ := ioutil.ReadAll(r.Body)
+		body = string(bytes)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	registry := prometheus.NewRegistry()
+	set := NewMetricsSet("ut_namespace", "ut_subsystem", registry)
+	assert.Equal(t, registry, set.GetGatherer())
+	assert.Nil(t, set.RegisterCounter("ut_counter"))
+	set.GetCounterWithValues("ut_counter").Inc()
+
+	pusher := set.PushGateway(server.URL, "ut-job", map[string]string{"instance": "ut-instance"})
+	assert.Nil(t, pusher.Push())
+	assert.Equal(t, "/metrics/job/ut-job/instance/ut-instance", path)
+	assert.Contains(t, body, "ut_namespace_ut_subsystem_ut_counter")
+}
+
 func TestMetricsSet_GetNamespace_WithEmptyNamespace(t *testing.T) {
 	set := NewMetricsSet("", "sub_sys", prometheus.NewRegistry())
 	assert.Equal(t, namespaceDefault, set.GetNamespace())
@@ -157,6 +210,30 @@ func TestMetricsSet_UnRegisterCounter_HappyCase(t *testing.T) {
 	assert.Nil(t, set.GetCounter(counter))
 }
 
+func TestMetricsSet_UnRegisterAll(t *testing.T) {
+	registry := prometheus.NewRegistry()
+
+	for i := 0; i < 3; i++ {
+		set := NewMetricsSet("", "", registry)
+		assert.Nil(t, set.RegisterCounter(counter, label))
+		assert.Nil(t, set.RegisterGauge(gauge, label))
+		assert.Nil(t, set.RegisterHistogram(histogram, nil, label))
+		assert.Nil(t, set.RegisterSummary(summary, nil, label))
+
+		set.UnRegisterAll()
+		assert.Empty(t, set.ListCounters())
+		assert.Empty(t, set.ListGauges())
+		assert.Empty(t, set.ListHistograms())
+		assert.Empty(t, set.ListSummaries())
+		assert.Empty(t, set.keys)
+	}
+
+	// all metrics should be unregistered from registry
+	families, err := registry.Gather()
+	assert.Nil(t, err)
+	assert.Empty(t, families)
+}
+
 // register and unregister gauge
 func TestMetricsSet_RegisterGauge_WithEmptyName(t *testing.T) {
 	set := NewMetricsSet("", "", prometheus.NewRegistry())
@@ -323,6 +400,29 @@ func TestMetricsSet_RegisterSummary_HappyCase(t *testing.T) {
 	assert.NotNil(t, set.GetSummary(summary))
 }
 
+func TestMetricsSet_RegisterSummaryWithOpts(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	set := NewMetricsSet("", "", registry)
+	err := set.RegisterSummaryWithOpts(summary, prometheus.SummaryOpts{
+		Name:       "ignored",
+		MaxAge:     20 * time.Millisecond,
+		AgeBuckets: 1,
+	}, label)
+	defer set.UnRegisterSummary(summary)
+	assert.Nil(t, err)
+
+	set.GetSummaryWithValues(summary, value).Observe(1)
+	quantile := func() float64 {
+		families, _ := registry.Gather()
+		return families[0].GetMetric()[0].GetSummary().GetQuantile()[0].GetValue()
+	}
+	assert.Equal(t, float64(1), quantile())
+
+	// observations older than max age are excluded
+	time.Sleep(50 * time.Millisecond)
+	assert.True(t, math.IsNaN(quantile()))
+}
+
 func TestMetricsSet_UnRegisterSummary_WithNonExistKey(t *testing.T) {
 	set := NewMetricsSet("", "", prometheus.NewRegistry())
 	set.UnRegisterSummary(summary)
@@ -587,3 +687,37 @@ func TestMetricsSet_validateName_WithExceedString(t *testing.T) {
 func TestMetricsSet_validateName_HappyCase(t *testing.T) {
 	assert.Nil(t, validateName(counter))
 }
+
+func TestMetricsSet_SetMaxSeries(t *testing.T) {
+	set := NewMetricsSet("ut", "series", prometheus.NewRegistry())
+	set.SetMaxSeries(-1)
+	assert.Equal(t, 0, set.GetMaxSeries())
+
+	set.SetMaxSeries(2)
+	assert.Equal(t, 2, set.GetMaxSeries())
+	assert.Nil(t, set.RegisterCounter(counter, label))
+	assert.Nil(t, set.RegisterSummary(summary, SummaryObjectives, label))
+
+	set.GetCounterWithValues(counter, "a").Inc()
+	set.GetCounterWithValues(counter, "b").Inc()
+	// exceeds max series, should be recorded into overflow series
+	set.GetCounterWithValues(counter, "c").Inc()
+	set.GetCounterWithLabels(counter, prometheus.Labels{label: "d"}).Inc()
+	// existing series should be recorded as it is
+	set.GetCounterWithLabels(counter, prometheus.Labels{label: "a"}).Inc()
+
+	counterVec := set.GetCounter(counter)
+	assert.Equal(t, 3, testutil.CollectAndCount(counterVec))
+	assert.Equal(t, float64(2), testutil.ToFloat64(counterVec.WithLabelValues("a")))
+	assert.Equal(t, float64(2), testutil.ToFloat64(counterVec.WithLabelValues(OverflowLabelValue)))
+
+	// series are tracked per metrics
+	set.GetSummaryWithValues(summary, "c").Observe(1)
+	assert.Equal(t, 1, testutil.CollectAndCount(set.GetSummary(summary)))
+
+	// series should be reset while unregister
+	set.UnRegisterCounter(counter)
+	assert.Nil(t, set.RegisterCounter(counter, label))
+	set.GetCounterWithValues(counter, "c").Inc()
+	assert.Equal(t, float64(1), testutil.ToFloat64(set.GetCounter(counter).WithLabelValues("c")))
+}
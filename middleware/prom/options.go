@@ -7,9 +7,14 @@
 package rkmidprom
 
 import (
+	"fmt"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rookie-ninja/rk-entry/v2/entry"
 	"github.com/rookie-ninja/rk-entry/v2/middleware"
+	"math"
 	"net/http"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -42,6 +47,11 @@ var (
 )
 
 const (
+	// labelKeyRetryAttempt is label of retry attempt, enabled with WithRetryHeader
+	labelKeyRetryAttempt = "retryAttempt"
+	// maxRetryAttemptLabel is max value of retryAttempt label, larger attempts will be labeled as 3+
+	maxRetryAttemptLabel = 3
+
 	// LabelerTypeHttp type of labeler
 	LabelerTypeHttp = "http"
 	// LabelerTypeGrpc type of labeler
@@ -65,19 +75,34 @@ type OptionSetInterface interface {
 	After(before *BeforeCtx, after *AfterCtx)
 
 	ShouldIgnore(string) bool
+
+	Config() map[string]interface{}
 }
 
 // ***************** OptionSet Implementation *****************
 
 // optionSet which is used for middleware implementation
 type optionSet struct {
-	entryName    string
-	entryType    string
-	registerer   prometheus.Registerer
-	labelerType  string
-	pathToIgnore []string
-	metricsSet   *MetricsSet
-	mock         OptionSetInterface
+	entryName     string
+	entryType     string
+	registerer    prometheus.Registerer
+	registryName  string
+	labelerType   string
+	pathToIgnore  []string
+	metricsSet    *MetricsSet
+	resCodeMapper func(string) string
+	retryHeader   string
+	maxSeries     int
+	// decay window of quantiles of elapsedNano summary, prometheus defaults will be used if zero
+	summaryMaxAge     time.Duration
+	summaryAgeBuckets uint32
+	// buckets in nanoseconds of elapsedNano histogram, elapsedNano will be registered as summary if empty
+	histogramBuckets []float64
+	// sizeMetrics enables histograms of request and response bytes
+	sizeMetrics bool
+	// synthesize restPath of gRPC request as /{grpcService}/{grpcMethod}
+	synthesizeGrpcPath bool
+	mock               OptionSetInterface
 }
 
 // NewOptionSet Create new optionSet with options.
@@ -98,10 +123,19 @@ func NewOptionSet(opts ...Option) OptionSetInterface {
 		return set.mock
 	}
 
+	if len(set.histogramBuckets) > 0 {
+		buckets, err := normalizeBuckets(set.histogramBuckets)
+		if err != nil {
+			rkentry.ShutdownWithError(err)
+		}
+		set.histogramBuckets = buckets
+	}
+
 	set.metricsSet = NewMetricsSet(
 		"rk",
 		"prom",
 		set.registerer)
+	set.metricsSet.SetMaxSeries(set.maxSeries)
 
 	if _, ok := optionsMap[set.entryName]; !ok {
 		optionsMap[set.entryName] = set
@@ -118,9 +152,28 @@ func NewOptionSet(opts ...Option) OptionSetInterface {
 		keys = labelKeysHttp
 	}
 
-	set.metricsSet.RegisterSummary(MetricsNameElapsedNano, SummaryObjectives, keys...)
+	// distinguish retries from first attempts
+	if len(set.retryHeader) > 0 {
+		keys = append(append([]string{}, keys...), labelKeyRetryAttempt)
+	}
+
+	// histogram could be aggregated across instances, summary is kept as default for compatibility
+	if len(set.histogramBuckets) > 0 {
+		set.metricsSet.RegisterHistogram(MetricsNameElapsedNano, set.histogramBuckets, keys...)
+	} else {
+		set.metricsSet.RegisterSummaryWithOpts(MetricsNameElapsedNano, prometheus.SummaryOpts{
+			Objectives: SummaryObjectives,
+			MaxAge:     set.summaryMaxAge,
+			AgeBuckets: set.summaryAgeBuckets,
+		}, keys...)
+	}
 	set.metricsSet.RegisterCounter(MetricsNameResCode, keys...)
 
+	if set.sizeMetrics {
+		set.metricsSet.RegisterHistogram(MetricsNameRequestBytes, SizeBuckets, keys...)
+		set.metricsSet.RegisterHistogram(MetricsNameResponseBytes, SizeBuckets, keys...)
+	}
+
 	return set
 }
 
@@ -134,6 +187,27 @@ func (set *optionSet) GetEntryType() string {
 	return set.entryType
 }
 
+// Config returns effective configuration, secrets are redacted
+func (set *optionSet) Config() map[string]interface{} {
+	return map[string]interface{}{
+		"entryName":          set.entryName,
+		"entryType":          set.entryType,
+		"labelerType":        set.labelerType,
+		"registry":           set.registryName,
+		"namespace":          set.metricsSet.GetNamespace(),
+		"subsystem":          set.metricsSet.GetSubSystem(),
+		"resCodeMapper":      set.resCodeMapper != nil,
+		"retryHeader":        set.retryHeader,
+		"maxSeries":          set.maxSeries,
+		"summaryMaxAge":      set.summaryMaxAge.String(),
+		"summaryAgeBuckets":  set.summaryAgeBuckets,
+		"histogramBuckets":   set.histogramBuckets,
+		"sizeMetrics":        set.sizeMetrics,
+		"synthesizeGrpcPath": set.synthesizeGrpcPath,
+		"pathToIgnore":       set.pathToIgnore,
+	}
+}
+
 // BeforeCtx should be created before Before()
 func (set *optionSet) BeforeCtx(req *http.Request) *BeforeCtx {
 	ctx := NewBeforeCtx()
@@ -142,6 +216,7 @@ func (set *optionSet) BeforeCtx(req *http.Request) *BeforeCtx {
 	if req != nil && req.URL != nil {
 		ctx.Input.RestMethod = req.Method
 		ctx.Input.RestPath = req.URL.Path
+		ctx.Input.RetryAttempt = rkmid.GetRetryAttempt(req, set.retryHeader)
 	}
 
 	return ctx
@@ -167,21 +242,32 @@ func (set *optionSet) After(before *BeforeCtx, after *AfterCtx) {
 		return
 	}
 
+	resCode := after.Input.ResCode
+	if set.resCodeMapper != nil {
+		resCode = set.resCodeMapper(resCode)
+	}
+
 	var l labeler
 
 	switch set.labelerType {
 	case LabelerTypeGrpc:
+		restPath := before.Input.RestPath
+		// requests from grpc-gateway already have path
+		if set.synthesizeGrpcPath && len(restPath) < 1 && len(before.Input.GrpcService) > 0 {
+			restPath = "/" + before.Input.GrpcService + "/" + before.Input.GrpcMethod
+		}
+
 		l = &labelerGrpc{
 			entryName:   set.entryName,
 			entryType:   set.entryType,
 			domain:      rkmid.Domain.String,
 			instance:    rkmid.LocalHostname.String,
-			restPath:    before.Input.RestPath,
+			restPath:    restPath,
 			restMethod:  before.Input.RestMethod,
 			grpcType:    before.Input.GrpcType,
 			grpcService: before.Input.GrpcService,
 			grpcMethod:  before.Input.GrpcMethod,
-			resCode:     after.Input.ResCode,
+			resCode:     resCode,
 		}
 	case LabelerTypeHttp:
 		l = &labelerHttp{
@@ -191,7 +277,7 @@ func (set *optionSet) After(before *BeforeCtx, after *AfterCtx) {
 			instance:  rkmid.LocalHostname.String,
 			method:    before.Input.RestMethod,
 			path:      before.Input.RestPath,
-			resCode:   after.Input.ResCode,
+			resCode:   resCode,
 		}
 	default:
 		l = &labelerHttp{
@@ -201,7 +287,14 @@ func (set *optionSet) After(before *BeforeCtx, after *AfterCtx) {
 			instance:  rkmid.LocalHostname.String,
 			method:    before.Input.RestMethod,
 			path:      before.Input.RestPath,
-			resCode:   after.Input.ResCode,
+			resCode:   resCode,
+		}
+	}
+
+	if len(set.retryHeader) > 0 {
+		l = &labelerRetry{
+			delegate: l,
+			attempt:  retryAttemptBucket(before.Input.RetryAttempt),
 		}
 	}
 
@@ -214,10 +307,29 @@ func (set *optionSet) After(before *BeforeCtx, after *AfterCtx) {
 	if resCodeMetrics := set.getServerResCodeMetrics(l); resCodeMetrics != nil {
 		resCodeMetrics.Inc()
 	}
+
+	// negative size means unknown
+	if set.sizeMetrics {
+		if after.Input.ReqBytes >= 0 {
+			if m := set.metricsSet.GetHistogramWithValues(MetricsNameRequestBytes, l.Values()...); m != nil {
+				m.Observe(float64(after.Input.ReqBytes))
+			}
+		}
+
+		if after.Input.ResBytes >= 0 {
+			if m := set.metricsSet.GetHistogramWithValues(MetricsNameResponseBytes, l.Values()...); m != nil {
+				m.Observe(float64(after.Input.ResBytes))
+			}
+		}
+	}
 }
 
 // getServerDurationMetrics server request elapsed metrics.
 func (set *optionSet) getServerDurationMetrics(l labeler) prometheus.Observer {
+	if len(set.histogramBuckets) > 0 {
+		return set.metricsSet.GetHistogramWithValues(MetricsNameElapsedNano, l.Values()...)
+	}
+
 	return set.metricsSet.GetSummaryWithValues(MetricsNameElapsedNano, l.Values()...)
 }
 
@@ -262,6 +374,11 @@ func (mock *optionSetMock) GetEntryType() string {
 	return "mock"
 }
 
+// Config returns empty map
+func (mock *optionSetMock) Config() map[string]interface{} {
+	return map[string]interface{}{}
+}
+
 // BeforeCtx should be created before Before()
 func (mock *optionSetMock) BeforeCtx(request *http.Request) *BeforeCtx {
 	return mock.before
@@ -298,6 +415,8 @@ func NewBeforeCtx() *BeforeCtx {
 // NewAfterCtx create new AfterCtx with fields initialized
 func NewAfterCtx() *AfterCtx {
 	ctx := &AfterCtx{}
+	ctx.Input.ReqBytes = -1
+	ctx.Input.ResBytes = -1
 	return ctx
 }
 
@@ -309,6 +428,8 @@ type BeforeCtx struct {
 		GrpcType    string
 		GrpcMethod  string
 		GrpcService string
+		// RetryAttempt parsed from retry header, 0 means first attempt
+		RetryAttempt int
 	}
 	Output struct {
 		StartTime time.Time
@@ -319,6 +440,9 @@ type BeforeCtx struct {
 type AfterCtx struct {
 	Input struct {
 		ResCode string
+		// ReqBytes and ResBytes are sizes of request and response body filled by adapters, negative value means unknown
+		ReqBytes int64
+		ResBytes int64
 	}
 	Output struct{}
 }
@@ -327,8 +451,20 @@ type AfterCtx struct {
 
 // BootConfig for YAML
 type BootConfig struct {
-	Enabled bool     `yaml:"enabled" json:"enabled"`
-	Ignore  []string `yaml:"ignore" json:"ignore"`
+	Enabled     bool   `yaml:"enabled" json:"enabled"`
+	RetryHeader string `yaml:"retryHeader" json:"retryHeader"`
+	Registry    string `yaml:"registry" json:"registry"`
+	MaxSeries   int    `yaml:"maxSeries" json:"maxSeries"`
+	Summary     struct {
+		MaxAgeMs   int64  `yaml:"maxAgeMs" json:"maxAgeMs"`
+		AgeBuckets uint32 `yaml:"ageBuckets" json:"ageBuckets"`
+	} `yaml:"summary" json:"summary"`
+	Histogram struct {
+		// Buckets in nanoseconds, elapsedNano will be registered as histogram instead of summary if not empty
+		Buckets []float64 `yaml:"buckets" json:"buckets"`
+	} `yaml:"histogram" json:"histogram"`
+	SizeMetrics bool     `yaml:"sizeMetrics" json:"sizeMetrics"`
+	Ignore      []string `yaml:"ignore" json:"ignore"`
 }
 
 // ToOptions convert BootConfig into Option list
@@ -341,7 +477,14 @@ func ToOptions(config *BootConfig,
 		opts = append(opts,
 			WithEntryNameAndType(entryName, entryType),
 			WithRegisterer(reg),
+			WithNamedRegistry(config.Registry),
 			WithLabelerType(labelerType),
+			WithRetryHeader(config.RetryHeader),
+			WithMaxSeries(config.MaxSeries),
+			WithSummaryMaxAge(time.Duration(config.Summary.MaxAgeMs)*time.Millisecond),
+			WithSummaryAgeBuckets(config.Summary.AgeBuckets),
+			WithHistogramBuckets(config.Histogram.Buckets),
+			WithSizeMetrics(config.SizeMetrics),
 			WithPathToIgnore(config.Ignore...))
 	}
 
@@ -375,6 +518,119 @@ func WithRegisterer(registerer prometheus.Registerer) Option {
 	}
 }
 
+// WithNamedRegistry provide name of registry created with rkentry.GetNamedRegistry, metrics will be registered in it
+// instead of registerer provided with WithRegisterer.
+func WithNamedRegistry(name string) Option {
+	return func(opt *optionSet) {
+		if len(name) > 0 {
+			opt.registryName = name
+			opt.registerer = rkentry.GetNamedRegistry(name)
+		}
+	}
+}
+
+// WithResCodeMapper provide function which normalize response code before it became label value.
+// For example, map 200 to 2xx. Response code will be used as it is by default.
+func WithResCodeMapper(mapper func(string) string) Option {
+	return func(opt *optionSet) {
+		if mapper != nil {
+			opt.resCodeMapper = mapper
+		}
+	}
+}
+
+// WithRetryHeader provide name of request header which contains retry count, like X-Retry-Count.
+// Label of retryAttempt will be added to metrics, missing header will be treated as attempt 0.
+// Values of label are bucketed into 0, 1, 2 and 3+ in order to bound cardinality.
+func WithRetryHeader(name string) Option {
+	return func(opt *optionSet) {
+		if len(name) > 0 {
+			opt.retryHeader = name
+		}
+	}
+}
+
+// WithMaxSeries provide max number of distinct label values of each metrics, 0 means unlimited.
+// Once exceeded, new label values will be recorded into series whose label values are all OverflowLabelValue.
+func WithMaxSeries(n int) Option {
+	return func(opt *optionSet) {
+		if n > 0 {
+			opt.maxSeries = n
+		}
+	}
+}
+
+// WithSummaryMaxAge provide duration of observations kept for quantiles of elapsedNano summary.
+// Default is prometheus.DefMaxAge which is 10 minutes.
+func WithSummaryMaxAge(d time.Duration) Option {
+	return func(opt *optionSet) {
+		if d > 0 {
+			opt.summaryMaxAge = d
+		}
+	}
+}
+
+// WithSummaryAgeBuckets provide number of buckets used to exclude observations older than max age
+// from quantiles of elapsedNano summary. Default is prometheus.DefAgeBuckets which is 5.
+func WithSummaryAgeBuckets(n uint32) Option {
+	return func(opt *optionSet) {
+		if n > 0 {
+			opt.summaryAgeBuckets = n
+		}
+	}
+}
+
+// WithHistogramBuckets provide buckets in nanoseconds, elapsedNano will be registered as histogram instead of summary,
+// which could be aggregated across instances. Summary will be used if buckets is empty.
+//
+// Buckets will be sorted and deduplicated, since prometheus requires increasing order of buckets.
+// NewOptionSet shuts down with error if any of buckets is NaN, so that invalid config is rejected
+// while bootstrapping instead of serving requests.
+func WithHistogramBuckets(buckets []float64) Option {
+	return func(opt *optionSet) {
+		if len(buckets) > 0 {
+			opt.histogramBuckets = buckets
+		}
+	}
+}
+
+// normalizeBuckets returns sorted and deduplicated copy of buckets, error will be returned if any of buckets is NaN
+func normalizeBuckets(buckets []float64) ([]float64, error) {
+	sorted := make([]float64, 0, len(buckets))
+	for i := range buckets {
+		if math.IsNaN(buckets[i]) {
+			return nil, fmt.Errorf("invalid histogram bucket of %s, NaN is not allowed", MetricsNameElapsedNano)
+		}
+		sorted = append(sorted, buckets[i])
+	}
+	sort.Float64s(sorted)
+
+	res := sorted[:1]
+	for i := 1; i < len(sorted); i++ {
+		if sorted[i] != res[len(res)-1] {
+			res = append(res, sorted[i])
+		}
+	}
+
+	return res, nil
+}
+
+// WithSizeMetrics enables histograms of request and response bytes with SizeBuckets.
+// Sizes should be filled into AfterCtx by adapters. Disabled by default to avoid extra series.
+func WithSizeMetrics(enabled bool) Option {
+	return func(opt *optionSet) {
+		opt.sizeMetrics = enabled
+	}
+}
+
+// WithSynthesizeGrpcPath synthesize restPath label of gRPC request as /{grpcService}/{grpcMethod} if restPath is empty,
+// so that path based dashboards work across protocols. Only takes effect with LabelerTypeGrpc.
+func WithSynthesizeGrpcPath(synthesize bool) Option {
+	return func(opt *optionSet) {
+		opt.synthesizeGrpcPath = synthesize
+	}
+}
+
 // WithPathToIgnore provide paths prefix that will ignore.
 func WithPathToIgnore(paths ...string) Option {
 	return func(set *optionSet) {
@@ -473,6 +729,35 @@ func (l *labelerGrpc) Values() []string {
 	}
 }
 
+// Implementation of labeler which appends retry attempt to delegate labeler
+type labelerRetry struct {
+	delegate labeler
+	attempt  string
+}
+
+// Keys returns key set
+func (l *labelerRetry) Keys() []string {
+	return append(append([]string{}, l.delegate.Keys()...), labelKeyRetryAttempt)
+}
+
+// Values returns value set
+func (l *labelerRetry) Values() []string {
+	return append(append([]string{}, l.delegate.Values()...), l.attempt)
+}
+
+// retryAttemptBucket bounds cardinality of retryAttempt label, attempts beyond maxRetryAttemptLabel share one value
+func retryAttemptBucket(attempt int) string {
+	if attempt < 0 {
+		attempt = 0
+	}
+
+	if attempt >= maxRetryAttemptLabel {
+		return strconv.Itoa(maxRetryAttemptLabel) + "+"
+	}
+
+	return strconv.Itoa(attempt)
+}
+
 // ***************** Global functions *****************
 
 const (
@@ -480,8 +765,15 @@ const (
 	MetricsNameElapsedNano = "elapsedNano"
 	// MetricsNameResCode records response code
 	MetricsNameResCode = "resCode"
+	// MetricsNameRequestBytes records size of request body
+	MetricsNameRequestBytes = "requestBytes"
+	// MetricsNameResponseBytes records size of response body
+	MetricsNameResponseBytes = "responseBytes"
 )
 
+// SizeBuckets are buckets of requestBytes and responseBytes, from 64B to 1MB
+var SizeBuckets = prometheus.ExponentialBuckets(64, 4, 8)
+
 // Global map stores metrics sets
 // Interceptor would distinguish metrics set based on
 var optionsMap = make(map[string]*optionSet)
@@ -498,8 +790,7 @@ func GetServerMetricsSet(entryName string) *MetricsSet {
 // Internal use only.
 func ClearAllMetrics() {
 	for _, v := range optionsMap {
-		v.metricsSet.UnRegisterSummary(MetricsNameElapsedNano)
-		v.metricsSet.UnRegisterCounter(MetricsNameResCode)
+		v.metricsSet.UnRegisterAll()
 	}
 
 	optionsMap = make(map[string]*optionSet)
@@ -6,10 +6,15 @@ package rkmidprom
 
 import (
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rookie-ninja/rk-entry/v2/entry"
+	"github.com/rookie-ninja/rk-entry/v2/middleware"
 	"github.com/stretchr/testify/assert"
+	"math"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 )
 
 func TestLabelerHttp_Keys(t *testing.T) {
@@ -161,6 +166,221 @@ func TestNewOptionSet(t *testing.T) {
 	ClearAllMetrics()
 }
 
+func TestWithResCodeMapper(t *testing.T) {
+	defer ClearAllMetrics()
+
+	set := NewOptionSet(
+		WithEntryNameAndType("ut-mapper", "ut-type"),
+		WithRegisterer(prometheus.NewRegistry()),
+		WithResCodeMapper(func(code string) string {
+			return code[:1] + "xx"
+		})).(*optionSet)
+
+	before := set.BeforeCtx(httptest.NewRequest(http.MethodGet, "/ut", nil))
+	set.Before(before)
+	set.After(before, set.AfterCtx("200"))
+
+	metrics := set.metricsSet.GetCounterWithLabels(MetricsNameResCode, prometheus.Labels{
+		"entryName":  "ut-mapper",
+		"entryType":  "ut-type",
+		"domain":     rkmid.Domain.String,
+		"instance":   rkmid.LocalHostname.String,
+		"restMethod": http.MethodGet,
+		"restPath":   "/ut",
+		"resCode":    "2xx",
+	})
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics))
+}
+
+func TestWithRetryHeader(t *testing.T) {
+	defer ClearAllMetrics()
+
+	set := NewOptionSet(
+		WithEntryNameAndType("ut-retry", "ut-type"),
+		WithRegisterer(prometheus.NewRegistry()),
+		WithRetryHeader(rkmid.HeaderRetryCount)).(*optionSet)
+
+	labels := prometheus.Labels{
+		"entryName":    "ut-retry",
+		"entryType":    "ut-type",
+		"domain":       rkmid.Domain.String,
+		"instance":     rkmid.LocalHostname.String,
+		"restMethod":   http.MethodGet,
+		"restPath":     "/ut",
+		"resCode":      "200",
+		"retryAttempt": "0",
+	}
+
+	// without retry header
+	before := set.BeforeCtx(httptest.NewRequest(http.MethodGet, "/ut", nil))
+	set.After(before, set.AfterCtx("200"))
+	assert.Equal(t, float64(1), testutil.ToFloat64(set.metricsSet.GetCounterWithLabels(MetricsNameResCode, labels)))
+
+	// with retry header
+	req := httptest.NewRequest(http.MethodGet, "/ut", nil)
+	req.Header.Set(rkmid.HeaderRetryCount, "2")
+	before = set.BeforeCtx(req)
+	set.After(before, set.AfterCtx("200"))
+	labels["retryAttempt"] = "2"
+	assert.Equal(t, float64(1), testutil.ToFloat64(set.metricsSet.GetCounterWithLabels(MetricsNameResCode, labels)))
+
+	// with large retry attempts, bucketed into 3+
+	for _, attempt := range []string{"3", "100"} {
+		req = httptest.NewRequest(http.MethodGet, "/ut", nil)
+		req.Header.Set(rkmid.HeaderRetryCount, attempt)
+		before = set.BeforeCtx(req)
+		set.After(before, set.AfterCtx("200"))
+	}
+	labels["retryAttempt"] = "3+"
+	assert.Equal(t, float64(2), testutil.ToFloat64(set.metricsSet.GetCounterWithLabels(MetricsNameResCode, labels)))
+}
+
+func TestWithMaxSeries(t *testing.T) {
+	defer ClearAllMetrics()
+
+	set := NewOptionSet(
+		WithEntryNameAndType("ut-series", "ut-type"),
+		WithRegisterer(prometheus.NewRegistry()),
+		WithMaxSeries(1)).(*optionSet)
+	assert.Equal(t, 1, set.Config()["maxSeries"])
+
+	for _, path := range []string{"/ut-1", "/ut-2", "/ut-3"} {
+		before := set.BeforeCtx(httptest.NewRequest(http.MethodGet, path, nil))
+		set.After(before, set.AfterCtx("200"))
+	}
+
+	// one series of /ut-1 and one overflow series
+	assert.Equal(t, 2, testutil.CollectAndCount(set.metricsSet.GetCounter(MetricsNameResCode)))
+}
+
+func TestWithSummaryMaxAge(t *testing.T) {
+	defer ClearAllMetrics()
+
+	config := &BootConfig{Enabled: true}
+	config.Summary.MaxAgeMs = 60000
+	config.Summary.AgeBuckets = 3
+	set := NewOptionSet(append(ToOptions(config, "ut-summary", "ut-type", prometheus.NewRegistry(), LabelerTypeHttp),
+		WithSummaryMaxAge(0),
+		WithSummaryAgeBuckets(0))...).(*optionSet)
+	assert.Equal(t, time.Minute.String(), set.Config()["summaryMaxAge"])
+	assert.Equal(t, uint32(3), set.Config()["summaryAgeBuckets"])
+	assert.NotNil(t, set.metricsSet.GetSummary(MetricsNameElapsedNano))
+}
+
+func TestWithHistogramBuckets(t *testing.T) {
+	defer ClearAllMetrics()
+
+	buckets := []float64{1e6, 1e7, 1e8}
+	config := &BootConfig{Enabled: true}
+	config.Histogram.Buckets = buckets
+	set := NewOptionSet(append(ToOptions(config, "ut-histogram", "ut-type", prometheus.NewRegistry(), LabelerTypeHttp),
+		WithHistogramBuckets(nil))...).(*optionSet)
+	assert.Equal(t, buckets, set.Config()["histogramBuckets"])
+	assert.NotNil(t, set.metricsSet.GetHistogram(MetricsNameElapsedNano))
+	assert.Nil(t, set.metricsSet.GetSummary(MetricsNameElapsedNano))
+
+	before := set.BeforeCtx(httptest.NewRequest(http.MethodGet, "/ut", nil))
+	set.After(before, set.AfterCtx("200"))
+	assert.Equal(t, 1, testutil.CollectAndCount(set.metricsSet.GetHistogram(MetricsNameElapsedNano)))
+
+	// with unsorted and duplicated buckets
+	set = NewOptionSet(
+		WithEntryNameAndType("ut-histogram-unsorted", "ut-type"),
+		WithRegisterer(prometheus.NewRegistry()),
+		WithHistogramBuckets([]float64{1e8, 1e6, 1e7, 1e6})).(*optionSet)
+	assert.Equal(t, buckets, set.Config()["histogramBuckets"])
+	before = set.BeforeCtx(httptest.NewRequest(http.MethodGet, "/ut", nil))
+	assert.NotPanics(t, func() {
+		set.After(before, set.AfterCtx("200"))
+	})
+
+	// with NaN
+	assert.Panics(t, func() {
+		NewOptionSet(WithHistogramBuckets([]float64{1e6, math.NaN()}))
+	})
+}
+
+func TestWithSizeMetrics(t *testing.T) {
+	defer ClearAllMetrics()
+
+	// without option
+	set := NewOptionSet(WithEntryNameAndType("ut-size-disabled", "ut-type")).(*optionSet)
+	assert.Nil(t, set.metricsSet.GetHistogram(MetricsNameRequestBytes))
+	assert.Nil(t, set.metricsSet.GetHistogram(MetricsNameResponseBytes))
+
+	// with option
+	config := &BootConfig{Enabled: true, SizeMetrics: true}
+	set = NewOptionSet(ToOptions(config, "ut-size", "ut-type", prometheus.NewRegistry(), LabelerTypeHttp)...).(*optionSet)
+	assert.True(t, set.Config()["sizeMetrics"].(bool))
+
+	// unknown sizes should not be observed
+	before := set.BeforeCtx(httptest.NewRequest(http.MethodGet, "/ut", nil))
+	set.After(before, set.AfterCtx("200"))
+	assert.Equal(t, 0, testutil.CollectAndCount(set.metricsSet.GetHistogram(MetricsNameRequestBytes)))
+	assert.Equal(t, 0, testutil.CollectAndCount(set.metricsSet.GetHistogram(MetricsNameResponseBytes)))
+
+	after := set.AfterCtx("200")
+	after.Input.ReqBytes = 0
+	after.Input.ResBytes = 1024
+	set.After(before, after)
+	assert.Equal(t, 1, testutil.CollectAndCount(set.metricsSet.GetHistogram(MetricsNameRequestBytes)))
+	assert.Equal(t, 1, testutil.CollectAndCount(set.metricsSet.GetHistogram(MetricsNameResponseBytes)))
+}
+
+func TestWithNamedRegistry(t *testing.T) {
+	defer ClearAllMetrics()
+	defer rkentry.RemoveNamedRegistry("ut-internal")
+
+	config := &BootConfig{Enabled: true, Registry: "ut-internal"}
+	set := NewOptionSet(append(ToOptions(config, "ut-named", "ut-type", prometheus.NewRegistry(), LabelerTypeHttp),
+		WithNamedRegistry(""))...).(*optionSet)
+	assert.Equal(t, "ut-internal", set.Config()["registry"])
+	assert.Equal(t, rkentry.GetNamedRegistry("ut-internal"), set.metricsSet.GetRegisterer())
+	assert.Contains(t, rkentry.ListNamedRegistries(), "ut-internal")
+}
+
+func TestWithSynthesizeGrpcPath(t *testing.T) {
+	defer ClearAllMetrics()
+
+	set := NewOptionSet(
+		WithEntryNameAndType("ut-grpc", "ut-type"),
+		WithRegisterer(prometheus.NewRegistry()),
+		WithLabelerType(LabelerTypeGrpc),
+		WithSynthesizeGrpcPath(true)).(*optionSet)
+	assert.Equal(t, true, set.Config()["synthesizeGrpcPath"])
+
+	labels := prometheus.Labels{
+		"entryName":   "ut-grpc",
+		"entryType":   "ut-type",
+		"domain":      rkmid.Domain.String,
+		"instance":    rkmid.LocalHostname.String,
+		"grpcService": "ut.Service",
+		"grpcMethod":  "Call",
+		"grpcType":    "unary",
+		"restMethod":  "",
+		"restPath":    "/ut.Service/Call",
+		"resCode":     "OK",
+	}
+
+	// with gRPC request
+	before := set.BeforeCtx(nil)
+	before.Input.GrpcService = "ut.Service"
+	before.Input.GrpcMethod = "Call"
+	before.Input.GrpcType = "unary"
+	set.After(before, set.AfterCtx("OK"))
+	assert.Equal(t, float64(1), testutil.ToFloat64(set.metricsSet.GetCounterWithLabels(MetricsNameResCode, labels)))
+
+	// with request from gateway, path should be kept
+	before = set.BeforeCtx(httptest.NewRequest(http.MethodGet, "/v1/call", nil))
+	before.Input.GrpcService = "ut.Service"
+	before.Input.GrpcMethod = "Call"
+	before.Input.GrpcType = "unary"
+	set.After(before, set.AfterCtx("OK"))
+	labels["restMethod"] = http.MethodGet
+	labels["restPath"] = "/v1/call"
+	assert.Equal(t, float64(1), testutil.ToFloat64(set.metricsSet.GetCounterWithLabels(MetricsNameResCode, labels)))
+}
+
 func TestOptionSet_ignore(t *testing.T) {
 	set := NewOptionSet(WithPathToIgnore("/ut-ignore")).(*optionSet)
 	assert.True(t, set.ShouldIgnore("/ut-ignore"))
@@ -279,3 +499,11 @@ func assertNotPanic(t *testing.T) {
 		assert.True(t, true)
 	}
 }
+
+func TestOptionSet_Config(t *testing.T) {
+	set := NewOptionSet(WithEntryNameAndType("ut-config", "ut-type"))
+	config := set.Config()
+	assert.Equal(t, "ut-config", config["entryName"])
+	assert.Equal(t, "ut-type", config["entryType"])
+	ClearAllMetrics()
+}
@@ -7,18 +7,27 @@
 package rkmidlimit
 
 import (
+	"container/list"
 	"errors"
+	"fmt"
+	"github.com/rookie-ninja/rk-entry/v2/entry"
 	"github.com/rookie-ninja/rk-entry/v2/error"
 	"github.com/rookie-ninja/rk-entry/v2/middleware"
 	uber "go.uber.org/ratelimit"
+	"math"
+	"net"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
 const (
-	LeakyBucket   = "leakyBucket"
-	DefaultLimit  = 1000000
-	GlobalLimiter = "rk-limiter"
+	LeakyBucket    = "leakyBucket"
+	DefaultLimit   = 1000000
+	GlobalLimiter  = "rk-limiter"
+	DefaultMaxKeys = 10000
 )
 
 // ***************** OptionSet Interface *****************
@@ -34,6 +43,8 @@ type OptionSetInterface interface {
 	BeforeCtx(*http.Request) *BeforeCtx
 
 	ShouldIgnore(string) bool
+
+	Config() map[string]interface{}
 }
 
 // ***************** OptionSet Implementation *****************
@@ -47,7 +58,11 @@ type optionSet struct {
 	algorithm       string
 	pathToIgnore    []string
 	limiter         map[string]Limiter
-	mock            OptionSetInterface
+	// keyExtractor returns key of request, e.g. API key or client IP, requests with empty key will not be limited by key
+	keyExtractor func(*http.Request) string
+	// keyedLimiter limits requests of each key independently, nil if keyed limit was not enabled
+	keyedLimiter *keyedLimiter
+	mock         OptionSetInterface
 }
 
 // NewOptionSet Create new optionSet with options.
@@ -70,6 +85,11 @@ func NewOptionSet(opts ...Option) OptionSetInterface {
 		return set.mock
 	}
 
+	// keyed limiter without key extractor would never limit anything
+	if set.keyedLimiter != nil && set.keyExtractor == nil {
+		rkentry.ShutdownWithError(errors.New("key extractor is required by keyed rate limit"))
+	}
+
 	switch set.algorithm {
 	case LeakyBucket:
 		if set.reqPerSec < 1 {
@@ -111,12 +131,30 @@ func (set *optionSet) GetEntryType() string {
 	return set.entryType
 }
 
+// Config returns effective configuration, secrets are redacted
+func (set *optionSet) Config() map[string]interface{} {
+	return map[string]interface{}{
+		"entryName":       set.entryName,
+		"entryType":       set.entryType,
+		"algorithm":       set.algorithm,
+		"reqPerSec":       set.reqPerSec,
+		"reqPerSecByPath": set.reqPerSecByPath,
+		"keyExtractor":    set.keyExtractor != nil,
+		"keyedLimiter":    set.keyedLimiter != nil,
+		"pathToIgnore":    set.pathToIgnore,
+	}
+}
+
 // BeforeCtx should be created before Before()
 func (set *optionSet) BeforeCtx(req *http.Request) *BeforeCtx {
 	ctx := NewBeforeCtx()
 
 	if req != nil && req.URL != nil {
 		ctx.Input.UrlPath = req.URL.Path
+
+		if set.keyExtractor != nil {
+			ctx.Input.Key = set.keyExtractor(req)
+		}
 	}
 
 	return ctx
@@ -133,6 +171,19 @@ func (set *optionSet) Before(ctx *BeforeCtx) {
 		return
 	}
 
+	// case 1: limit by key before global and path limiter, since keyed limiter never blocks
+	if set.keyedLimiter != nil && len(ctx.Input.Key) > 0 {
+		if retryAfter, ok := set.keyedLimiter.Allow(ctx.Input.Key); !ok {
+			seconds := int(math.Ceil(retryAfter.Seconds()))
+			if seconds < 1 {
+				seconds = 1
+			}
+			ctx.Output.HeadersToReturn[rkmid.HeaderRetryAfter] = strconv.Itoa(seconds)
+			ctx.Output.ErrResp = rkmid.GetErrorBuilder().New(http.StatusTooManyRequests, "slow down your request")
+			return
+		}
+	}
+
 	limiter := set.getLimiter(ctx.Input.UrlPath)
 	if err := limiter(); err != nil {
 		ctx.Output.ErrResp = rkmid.GetErrorBuilder().New(http.StatusTooManyRequests, err.Error())
@@ -193,6 +244,11 @@ func (mock *optionSetMock) GetEntryType() string {
 	return "mock"
 }
 
+// Config returns empty map
+func (mock *optionSetMock) Config() map[string]interface{} {
+	return map[string]interface{}{}
+}
+
 // BeforeCtx should be created before Before()
 func (mock *optionSetMock) BeforeCtx(request *http.Request) *BeforeCtx {
 	return mock.before
@@ -213,6 +269,7 @@ func (mock *optionSetMock) ShouldIgnore(string) bool {
 // NewBeforeCtx create new BeforeCtx with fields initialized
 func NewBeforeCtx() *BeforeCtx {
 	ctx := &BeforeCtx{}
+	ctx.Output.HeadersToReturn = make(map[string]string)
 	return ctx
 }
 
@@ -220,9 +277,13 @@ func NewBeforeCtx() *BeforeCtx {
 type BeforeCtx struct {
 	Input struct {
 		UrlPath string
+		// Key of request returned by key extractor
+		Key string
 	}
 	Output struct {
-		ErrResp rkerror.ErrorInterface
+		// HeadersToReturn should be written into response, Retry-After will be set if request was limited by key
+		HeadersToReturn map[string]string
+		ErrResp         rkerror.ErrorInterface
 	}
 }
 
@@ -238,6 +299,13 @@ type BootConfig struct {
 		Path      string `yaml:"path" json:"path"`
 		ReqPerSec int    `yaml:"reqPerSec" json:"reqPerSec"`
 	} `yaml:"paths" json:"paths"`
+	Key struct {
+		// Lookup is source of key, "ip" or "header:<name>"
+		Lookup    string `yaml:"lookup" json:"lookup"`
+		ReqPerSec int    `yaml:"reqPerSec" json:"reqPerSec"`
+		Burst     int    `yaml:"burst" json:"burst"`
+		MaxKeys   int    `yaml:"maxKeys" json:"maxKeys"`
+	} `yaml:"key" json:"key"`
 }
 
 // ToOptions convert BootConfig into Option list
@@ -258,12 +326,52 @@ func ToOptions(config *BootConfig, entryName, entryType string) []Option {
 			opts = append(opts, WithReqPerSecByPath(e.Path, e.ReqPerSec))
 		}
 
+		if len(config.Key.Lookup) > 0 {
+			extractor := keyExtractorFromLookup(config.Key.Lookup)
+			if extractor == nil {
+				rkentry.ShutdownWithError(fmt.Errorf("invalid key lookup of rate limit:%s", config.Key.Lookup))
+			}
+
+			opts = append(opts,
+				WithKeyExtractor(extractor),
+				WithRateLimitByKey(config.Key.ReqPerSec, config.Key.Burst, config.Key.MaxKeys))
+		}
+
 		opts = append(opts, WithPathToIgnore(config.Ignore...))
 	}
 
 	return opts
 }
 
+// Convert lookup of "ip" or "header:<name>" into key extractor, nil will be returned if lookup is invalid.
+//
+// "ip" uses client ip resolved by forwarded middleware from trusted proxies if enabled, otherwise, peer address
+// of connection. X-Forwarded-For sent by client is never used, since client could rotate it to get new buckets.
+func keyExtractorFromLookup(lookup string) func(*http.Request) string {
+	parts := strings.SplitN(strings.TrimSpace(lookup), ":", 2)
+
+	switch {
+	case len(parts) == 1 && parts[0] == "ip":
+		return func(req *http.Request) string {
+			if forwarded := rkmid.GetForwarded(req); forwarded != nil {
+				return forwarded.ClientIp
+			}
+
+			ip, _, err := net.SplitHostPort(req.RemoteAddr)
+			if err != nil {
+				return req.RemoteAddr
+			}
+			return ip
+		}
+	case len(parts) == 2 && parts[0] == "header" && len(parts[1]) > 0:
+		return func(req *http.Request) string {
+			return req.Header.Get(parts[1])
+		}
+	}
+
+	return nil
+}
+
 // ***************** Option *****************
 
 // Option if for middleware options while creating middleware
@@ -335,6 +443,39 @@ func WithLimiterByPath(path string, l Limiter) Option {
 	}
 }
 
+// WithKeyExtractor provide function which returns key of request, e.g. API key or client IP.
+// Requests with empty key will not be limited by key.
+func WithKeyExtractor(f func(*http.Request) string) Option {
+	return func(opt *optionSet) {
+		if f != nil {
+			opt.keyExtractor = f
+		}
+	}
+}
+
+// WithRateLimitByKey enables token bucket per key returned by key extractor, so that different clients get independent quotas.
+//
+// reqPerSec is refill rate of bucket, burst is capacity of bucket which defaults to reqPerSec,
+// maxKeys is max number of buckets kept in memory, least recently used bucket will be evicted, defaults to DefaultMaxKeys.
+// Requests exceed limit will be rejected with 429 and Retry-After header.
+func WithRateLimitByKey(reqPerSec, burst, maxKeys int) Option {
+	return func(opt *optionSet) {
+		if reqPerSec < 1 {
+			return
+		}
+
+		if burst < 1 {
+			burst = reqPerSec
+		}
+
+		if maxKeys < 1 {
+			maxKeys = DefaultMaxKeys
+		}
+
+		opt.keyedLimiter = newKeyedLimiter(reqPerSec, burst, maxKeys)
+	}
+}
+
 // WithPathToIgnore provide paths prefix that will ignore.
 func WithPathToIgnore(paths ...string) Option {
 	return func(set *optionSet) {
@@ -384,3 +525,80 @@ func (l *leakyBucketLimiter) Limit() error {
 	l.delegator.Take()
 	return nil
 }
+
+// tokenBucket refills tokens at rate per second up to capacity
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// keyedLimiter keeps a token bucket per key with LRU eviction
+type keyedLimiter struct {
+	rate     float64
+	capacity float64
+	maxKeys  int
+	now      func() time.Time
+	lock     sync.Mutex
+	lru      *list.List
+	buckets  map[string]*list.Element
+}
+
+// entry of lru list
+type keyedBucket struct {
+	key    string
+	bucket *tokenBucket
+}
+
+func newKeyedLimiter(reqPerSec, burst, maxKeys int) *keyedLimiter {
+	return &keyedLimiter{
+		rate:     float64(reqPerSec),
+		capacity: float64(burst),
+		maxKeys:  maxKeys,
+		now:      time.Now,
+		lru:      list.New(),
+		buckets:  make(map[string]*list.Element),
+	}
+}
+
+// Allow takes a token from bucket of key, returns time to wait for next token and false if bucket is empty
+func (l *keyedLimiter) Allow(key string) (time.Duration, bool) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := l.now()
+
+	var bucket *tokenBucket
+	if elem, ok := l.buckets[key]; ok {
+		l.lru.MoveToFront(elem)
+		bucket = elem.Value.(*keyedBucket).bucket
+
+		// refill
+		bucket.tokens = math.Min(l.capacity, bucket.tokens+now.Sub(bucket.last).Seconds()*l.rate)
+		bucket.last = now
+	} else {
+		bucket = &tokenBucket{tokens: l.capacity, last: now}
+		l.buckets[key] = l.lru.PushFront(&keyedBucket{key: key, bucket: bucket})
+
+		// evict least recently used bucket
+		if l.lru.Len() > l.maxKeys {
+			oldest := l.lru.Back()
+			l.lru.Remove(oldest)
+			delete(l.buckets, oldest.Value.(*keyedBucket).key)
+		}
+	}
+
+	if bucket.tokens < 1 {
+		return time.Duration((1 - bucket.tokens) / l.rate * float64(time.Second)), false
+	}
+
+	bucket.tokens--
+	return 0, true
+}
+
+// Len returns number of buckets
+func (l *keyedLimiter) Len() int {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	return l.lru.Len()
+}
@@ -6,11 +6,14 @@
 package rkmidlimit
 
 import (
+	"context"
 	"errors"
+	"github.com/rookie-ninja/rk-entry/v2/middleware"
 	"github.com/stretchr/testify/assert"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 )
 
 func TestNewOptionSet(t *testing.T) {
@@ -82,6 +85,103 @@ func TestOptionSet_Before(t *testing.T) {
 	assert.Nil(t, beforeCtx.Output.ErrResp)
 }
 
+func TestWithRateLimitByKey(t *testing.T) {
+	newReq := func(key string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/ut", nil)
+		req.Header.Set(rkmid.HeaderApiKey, key)
+		return req
+	}
+
+	config := &BootConfig{Enabled: true}
+	config.Key.Lookup = "header:" + rkmid.HeaderApiKey
+	config.Key.ReqPerSec = 1
+	config.Key.Burst = 2
+	config.Key.MaxKeys = 1
+	set := NewOptionSet(ToOptions(config, "", "")...).(*optionSet)
+	assert.True(t, set.Config()["keyedLimiter"].(bool))
+
+	now := time.Now()
+	set.keyedLimiter.now = func() time.Time { return now }
+
+	// burst of key-a
+	for i := 0; i < 2; i++ {
+		ctx := set.BeforeCtx(newReq("key-a"))
+		assert.Equal(t, "key-a", ctx.Input.Key)
+		set.Before(ctx)
+		assert.Nil(t, ctx.Output.ErrResp)
+	}
+
+	// exceeded
+	ctx := set.BeforeCtx(newReq("key-a"))
+	set.Before(ctx)
+	assert.Contains(t, ctx.Output.ErrResp.Error(), http.StatusText(http.StatusTooManyRequests))
+	assert.Equal(t, "1", ctx.Output.HeadersToReturn[rkmid.HeaderRetryAfter])
+
+	// refilled
+	now = now.Add(time.Second)
+	ctx = set.BeforeCtx(newReq("key-a"))
+	set.Before(ctx)
+	assert.Nil(t, ctx.Output.ErrResp)
+
+	// key-b has independent quota and evicts key-a
+	ctx = set.BeforeCtx(newReq("key-b"))
+	set.Before(ctx)
+	assert.Nil(t, ctx.Output.ErrResp)
+	assert.Equal(t, 1, set.keyedLimiter.Len())
+
+	// key-a gets a new bucket after eviction
+	ctx = set.BeforeCtx(newReq("key-a"))
+	set.Before(ctx)
+	assert.Nil(t, ctx.Output.ErrResp)
+
+	// request without key should not be limited by key
+	for i := 0; i < 3; i++ {
+		ctx = set.BeforeCtx(newReq(""))
+		set.Before(ctx)
+		assert.Nil(t, ctx.Output.ErrResp)
+	}
+
+	// with invalid options
+	set = NewOptionSet(WithKeyExtractor(nil), WithRateLimitByKey(0, 0, 0)).(*optionSet)
+	assert.Nil(t, set.keyExtractor)
+	assert.Nil(t, set.keyedLimiter)
+
+	// without key extractor
+	assert.Panics(t, func() {
+		NewOptionSet(WithRateLimitByKey(5, 0, 0))
+	})
+
+	// with invalid lookup
+	config.Key.Lookup = "query:key"
+	assert.Panics(t, func() {
+		ToOptions(config, "", "")
+	})
+
+	// with defaults
+	set = NewOptionSet(WithKeyExtractor((*http.Request).UserAgent), WithRateLimitByKey(5, 0, 0)).(*optionSet)
+	assert.Equal(t, float64(5), set.keyedLimiter.capacity)
+	assert.Equal(t, DefaultMaxKeys, set.keyedLimiter.maxKeys)
+}
+
+func TestKeyExtractorFromLookup(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/ut", nil)
+	req.RemoteAddr = "1.1.1.1:8080"
+	req.Header.Set(rkmid.HeaderApiKey, "ut-key")
+
+	assert.Equal(t, "1.1.1.1", keyExtractorFromLookup("ip")(req))
+
+	// X-Forwarded-For from client should be ignored
+	req.Header.Set("X-Forwarded-For", "2.2.2.2")
+	assert.Equal(t, "1.1.1.1", keyExtractorFromLookup("ip")(req))
+
+	// client ip resolved by forwarded middleware should be used
+	req = req.WithContext(context.WithValue(req.Context(), rkmid.ForwardedKey, &rkmid.Forwarded{ClientIp: "3.3.3.3"}))
+	assert.Equal(t, "3.3.3.3", keyExtractorFromLookup("ip")(req))
+	assert.Equal(t, "ut-key", keyExtractorFromLookup("header:"+rkmid.HeaderApiKey)(req))
+	assert.Nil(t, keyExtractorFromLookup("header:"))
+	assert.Nil(t, keyExtractorFromLookup("query:key"))
+}
+
 func TestToOptions(t *testing.T) {
 	// with disabled
 	config := &BootConfig{
@@ -112,3 +212,10 @@ func assertNotPanic(t *testing.T) {
 		assert.True(t, true)
 	}
 }
+
+func TestOptionSet_Config(t *testing.T) {
+	set := NewOptionSet(WithEntryNameAndType("ut-config", "ut-type"))
+	config := set.Config()
+	assert.Equal(t, "ut-config", config["entryName"])
+	assert.Equal(t, "ut-type", config["entryType"])
+}
@@ -0,0 +1,424 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+// Package rkmidresvalidate is a dev-mode middleware which validates response body against declared content type
+package rkmidresvalidate
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/rookie-ninja/rk-entry/v2/entry"
+	"github.com/rookie-ninja/rk-entry/v2/error"
+	"github.com/rookie-ninja/rk-entry/v2/middleware"
+	"go.uber.org/zap"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// DefaultMaxBodyBytes is max bytes of response body captured for validation by default
+const DefaultMaxBodyBytes = 1024 * 1024
+
+// ***************** OptionSet Interface *****************
+
+// OptionSetInterface mainly for testing purpose
+type OptionSetInterface interface {
+	GetEntryName() string
+
+	GetEntryType() string
+
+	BeforeCtx(*http.Request) *BeforeCtx
+
+	Before(*BeforeCtx)
+
+	AfterCtx() *AfterCtx
+
+	After(before *BeforeCtx, after *AfterCtx)
+
+	ShouldIgnore(string) bool
+
+	Config() map[string]interface{}
+}
+
+// ***************** OptionSet Implementation *****************
+
+// optionSet which is used for middleware implementation
+type optionSet struct {
+	entryName    string
+	entryType    string
+	pathToIgnore []string
+	// strict mode replaces invalid response with 500 and logs it as error instead of warning
+	strict bool
+	// allowProduction enables validation in production, which is disabled by default
+	allowProduction bool
+	// enabled is false in production unless allowProduction is true
+	enabled      bool
+	maxBodyBytes int64
+	mock         OptionSetInterface
+}
+
+// NewOptionSet Create new optionSet with options.
+func NewOptionSet(opts ...Option) OptionSetInterface {
+	set := &optionSet{
+		entryName:    "fake-entry",
+		entryType:    "",
+		pathToIgnore: []string{},
+		maxBodyBytes: DefaultMaxBodyBytes,
+	}
+
+	for i := range opts {
+		opts[i](set)
+	}
+
+	if set.mock != nil {
+		return set.mock
+	}
+
+	set.enabled = set.allowProduction || !isProduction()
+
+	return set
+}
+
+// GetEntryName returns entry name
+func (set *optionSet) GetEntryName() string {
+	return set.entryName
+}
+
+// GetEntryType returns entry type
+func (set *optionSet) GetEntryType() string {
+	return set.entryType
+}
+
+// Config returns effective configuration, secrets are redacted
+func (set *optionSet) Config() map[string]interface{} {
+	return map[string]interface{}{
+		"entryName":       set.entryName,
+		"entryType":       set.entryType,
+		"enabled":         set.enabled,
+		"strict":          set.strict,
+		"allowProduction": set.allowProduction,
+		"maxBodyBytes":    set.maxBodyBytes,
+		"pathToIgnore":    set.pathToIgnore,
+	}
+}
+
+// BeforeCtx should be created before Before()
+func (set *optionSet) BeforeCtx(req *http.Request) *BeforeCtx {
+	ctx := NewBeforeCtx()
+
+	if req != nil && req.URL != nil {
+		ctx.Input.UrlPath = req.URL.Path
+		ctx.Input.ResponseWriter = rkmid.GetCountingResponseWriter(req)
+	}
+
+	return ctx
+}
+
+// Before enables capturing of response body on writer shared by adapters.
+//
+// In strict mode, response is buffered until After() called, so that invalid response could be replaced.
+// Output.DeferFunc will be assigned in that case, adapters should defer it right after Before(),
+// so that buffered response is written even if handler panics.
+func (set *optionSet) Before(ctx *BeforeCtx) {
+	if ctx == nil || !set.enabled || set.ShouldIgnore(ctx.Input.UrlPath) {
+		return
+	}
+
+	writer := ctx.Input.ResponseWriter
+	if writer == nil {
+		return
+	}
+
+	writer.CaptureBody(set.maxBodyBytes)
+	if set.strict {
+		writer.BufferBody(set.maxBodyBytes)
+		ctx.Output.DeferFunc = writer.ReleaseBuffer
+	}
+}
+
+// AfterCtx should be created before After()
+func (set *optionSet) AfterCtx() *AfterCtx {
+	return NewAfterCtx()
+}
+
+// After validates captured response body against Content-Type of response.
+//
+// Response with invalid body will be logged as warning. In strict mode, it will be logged as error and replaced with
+// 500 written with error builder. Validation will be skipped if body is empty, truncated or content type
+// is neither JSON nor XML. Streaming responses, which were flushed or larger than max body bytes, are written
+// to client before After() called, so they will never be replaced.
+func (set *optionSet) After(before *BeforeCtx, after *AfterCtx) {
+	if before == nil || after == nil || !set.enabled || set.ShouldIgnore(before.Input.UrlPath) {
+		return
+	}
+
+	writer := before.Input.ResponseWriter
+	if writer == nil {
+		return
+	}
+	defer writer.ReleaseBuffer()
+
+	body, ok := writer.CapturedBody()
+	if !ok || len(body) < 1 {
+		return
+	}
+
+	contentType := writer.Header().Get(rkmid.HeaderContentType)
+	if err := validateBody(contentType, body); err != nil {
+		after.Output.Err = err
+
+		log := rkentry.LoggerEntryStdout.Warn
+		if set.strict {
+			log = rkentry.LoggerEntryStdout.Error
+		}
+		log("Response body does not match Content-Type",
+			zap.String("entryName", set.entryName),
+			zap.String("path", before.Input.UrlPath),
+			zap.String("contentType", contentType),
+			zap.Error(err))
+
+		if set.strict && writer.DiscardBuffer() {
+			writeErrResp(writer, rkmid.GetErrorBuilder().New(http.StatusInternalServerError,
+				"Response body does not match Content-Type", err.Error()))
+		}
+	}
+}
+
+// writeErrResp replaces headers describing discarded body and writes error as JSON
+func writeErrResp(writer http.ResponseWriter, errResp rkerror.ErrorInterface) {
+	raw, _ := json.Marshal(errResp)
+
+	writer.Header().Del("Content-Length")
+	writer.Header().Set(rkmid.HeaderContentType, "application/json")
+	writer.WriteHeader(errResp.Code())
+	writer.Write(raw)
+}
+
+// validateBody parses body as JSON or XML based on content type, other content types will be ignored
+func validateBody(contentType string, body []byte) error {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil
+	}
+
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		if !json.Valid(body) {
+			return errors.New("invalid JSON body")
+		}
+	case mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml"):
+		decoder := xml.NewDecoder(bytes.NewReader(body))
+		for {
+			if _, err := decoder.Token(); err != nil {
+				if err == io.EOF {
+					return nil
+				}
+				return fmt.Errorf("invalid XML body, %v", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// isProduction returns true if domain is prod or production
+func isProduction() bool {
+	switch strings.ToLower(rkmid.Domain.String) {
+	case "prod", "production":
+		return true
+	default:
+		return false
+	}
+}
+
+// ShouldIgnore determine whether validation should be ignored based on path
+func (set *optionSet) ShouldIgnore(path string) bool {
+	for i := range set.pathToIgnore {
+		if strings.HasPrefix(path, set.pathToIgnore[i]) {
+			return true
+		}
+	}
+
+	return rkmid.ShouldIgnoreGlobal(path)
+}
+
+// ***************** OptionSet Mock *****************
+
+// NewOptionSetMock for testing purpose
+func NewOptionSetMock(before *BeforeCtx, after *AfterCtx) OptionSetInterface {
+	return &optionSetMock{
+		before: before,
+		after:  after,
+	}
+}
+
+type optionSetMock struct {
+	before *BeforeCtx
+	after  *AfterCtx
+}
+
+// GetEntryName returns entry name
+func (mock *optionSetMock) GetEntryName() string {
+	return "mock"
+}
+
+// GetEntryType returns entry type
+func (mock *optionSetMock) GetEntryType() string {
+	return "mock"
+}
+
+// Config returns empty map
+func (mock *optionSetMock) Config() map[string]interface{} {
+	return map[string]interface{}{}
+}
+
+// BeforeCtx should be created before Before()
+func (mock *optionSetMock) BeforeCtx(request *http.Request) *BeforeCtx {
+	return mock.before
+}
+
+// Before should run before user handler
+func (mock *optionSetMock) Before(ctx *BeforeCtx) {
+	return
+}
+
+// AfterCtx should be created before After()
+func (mock *optionSetMock) AfterCtx() *AfterCtx {
+	return mock.after
+}
+
+// After should run after user handler
+func (mock *optionSetMock) After(before *BeforeCtx, after *AfterCtx) {
+	return
+}
+
+// ShouldIgnore should run before user handler
+func (mock *optionSetMock) ShouldIgnore(string) bool {
+	return false
+}
+
+// ***************** Context *****************
+
+// NewBeforeCtx create new BeforeCtx with fields initialized
+func NewBeforeCtx() *BeforeCtx {
+	ctx := &BeforeCtx{}
+	return ctx
+}
+
+// NewAfterCtx create new AfterCtx with fields initialized
+func NewAfterCtx() *AfterCtx {
+	ctx := &AfterCtx{}
+	return ctx
+}
+
+// BeforeCtx context for Before() function
+type BeforeCtx struct {
+	Input struct {
+		UrlPath string
+		// ResponseWriter stored by adapters with rkmid.SetCountingResponseWriter, validation is skipped if nil
+		ResponseWriter *rkmid.CountingResponseWriter
+	}
+	Output struct {
+		// DeferFunc writes buffered response in strict mode, nil if response is not buffered
+		DeferFunc func()
+	}
+}
+
+// AfterCtx context for After() function
+type AfterCtx struct {
+	Input  struct{}
+	Output struct {
+		// Err is validation error of response body, nil if body is valid or validation was skipped
+		Err error
+	}
+}
+
+// ***************** BootConfig *****************
+
+// BootConfig for YAML
+type BootConfig struct {
+	Enabled         bool     `yaml:"enabled" json:"enabled"`
+	Strict          bool     `yaml:"strict" json:"strict"`
+	AllowProduction bool     `yaml:"allowProduction" json:"allowProduction"`
+	MaxBodyBytes    int64    `yaml:"maxBodyBytes" json:"maxBodyBytes"`
+	Ignore          []string `yaml:"ignore" json:"ignore"`
+}
+
+// ToOptions convert BootConfig into Option list
+func ToOptions(config *BootConfig, entryName, entryType string) []Option {
+	opts := make([]Option, 0)
+
+	if config.Enabled {
+		opts = append(opts,
+			WithEntryNameAndType(entryName, entryType),
+			WithStrict(config.Strict),
+			WithAllowProduction(config.AllowProduction),
+			WithMaxBodyBytes(config.MaxBodyBytes),
+			WithPathToIgnore(config.Ignore...))
+	}
+
+	return opts
+}
+
+// ***************** Option *****************
+
+// Option if for middleware options while creating middleware
+type Option func(*optionSet)
+
+// WithEntryNameAndType provide entry name and entry type.
+func WithEntryNameAndType(entryName, entryType string) Option {
+	return func(opt *optionSet) {
+		opt.entryName = entryName
+		opt.entryType = entryType
+	}
+}
+
+// WithStrict provide strict mode, invalid response body will be logged as error and replaced with 500.
+// Optional. Default value false, which means warning will be logged.
+func WithStrict(strict bool) Option {
+	return func(opt *optionSet) {
+		opt.strict = strict
+	}
+}
+
+// WithAllowProduction enables validation while domain is prod or production.
+// Validation costs memory of captured body and CPU of parsing, it is disabled in production by default.
+func WithAllowProduction(allow bool) Option {
+	return func(opt *optionSet) {
+		opt.allowProduction = allow
+	}
+}
+
+// WithMaxBodyBytes provide max bytes of response body captured, larger body will not be validated.
+// Optional. Default value DefaultMaxBodyBytes.
+func WithMaxBodyBytes(maxBytes int64) Option {
+	return func(opt *optionSet) {
+		if maxBytes > 0 {
+			opt.maxBodyBytes = maxBytes
+		}
+	}
+}
+
+// WithPathToIgnore provide paths prefix that will ignore.
+func WithPathToIgnore(paths ...string) Option {
+	return func(set *optionSet) {
+		for i := range paths {
+			if len(paths[i]) > 0 {
+				set.pathToIgnore = append(set.pathToIgnore, paths[i])
+			}
+		}
+	}
+}
+
+// WithMockOptionSet provide mock OptionSetInterface
+func WithMockOptionSet(mock OptionSetInterface) Option {
+	return func(set *optionSet) {
+		set.mock = mock
+	}
+}
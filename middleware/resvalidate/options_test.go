@@ -0,0 +1,189 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkmidresvalidate
+
+import (
+	"github.com/rookie-ninja/rk-entry/v2/middleware"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestToOptions(t *testing.T) {
+	// with disabled
+	config := &BootConfig{
+		Enabled: false,
+	}
+	assert.Empty(t, ToOptions(config, "", ""))
+
+	// with enabled
+	config.Enabled = true
+	assert.NotEmpty(t, ToOptions(config, "", ""))
+}
+
+func TestNewOptionSet(t *testing.T) {
+	// with default
+	set := NewOptionSet().(*optionSet)
+	assert.False(t, set.strict)
+	assert.True(t, set.enabled)
+	assert.Equal(t, int64(DefaultMaxBodyBytes), set.maxBodyBytes)
+
+	// with options
+	set = NewOptionSet(
+		WithEntryNameAndType("ut-entry", "ut-type"),
+		WithStrict(true),
+		WithMaxBodyBytes(10),
+		WithPathToIgnore("/ut-ignore")).(*optionSet)
+	assert.Equal(t, "ut-entry", set.GetEntryName())
+	assert.Equal(t, "ut-type", set.GetEntryType())
+	assert.True(t, set.strict)
+	assert.Equal(t, int64(10), set.maxBodyBytes)
+	assert.True(t, set.ShouldIgnore("/ut-ignore"))
+	assert.Equal(t, true, set.Config()["strict"])
+
+	// with mock
+	mock := NewOptionSetMock(NewBeforeCtx(), NewAfterCtx())
+	assert.Equal(t, mock, NewOptionSet(WithMockOptionSet(mock)))
+}
+
+func TestNewOptionSet_Production(t *testing.T) {
+	domain := rkmid.Domain
+	defer func() {
+		rkmid.Domain = domain
+	}()
+	rkmid.Domain = zap.String(domain.Key, "prod")
+
+	// disabled in production by default
+	set := NewOptionSet().(*optionSet)
+	assert.False(t, set.enabled)
+
+	writer, before := newBeforeCtx(set, "application/json")
+	set.Before(before)
+	writer.Write([]byte("{invalid"))
+	after := set.AfterCtx()
+	set.After(before, after)
+	_, captured := writer.CapturedBody()
+	assert.False(t, captured)
+
+	// with production allowed
+	set = NewOptionSet(WithAllowProduction(true)).(*optionSet)
+	assert.True(t, set.enabled)
+}
+
+func TestOptionSet_After(t *testing.T) {
+	defer assertNotPanic(t)
+
+	// with invalid JSON, warning only
+	set := NewOptionSet().(*optionSet)
+	after := runHandler(set, "application/json; charset=utf-8", "{invalid")
+	assert.NotNil(t, after.Output.Err)
+
+	// with invalid JSON in strict mode
+	set = NewOptionSet(WithStrict(true)).(*optionSet)
+	after = runHandler(set, "application/json; charset=utf-8", "{invalid")
+	assert.NotNil(t, after.Output.Err)
+
+	// with valid JSON of structured suffix
+	after = runHandler(set, "application/problem+json", `{"title":"ut"}`)
+	assert.Nil(t, after.Output.Err)
+
+	// with invalid XML
+	after = runHandler(set, "application/xml", "<a><b></a>")
+	assert.NotNil(t, after.Output.Err)
+
+	// with valid XML
+	after = runHandler(set, "text/xml", "<a><b/></a>")
+	assert.Nil(t, after.Output.Err)
+
+	// with other content type
+	after = runHandler(set, "text/plain", "{invalid")
+	assert.Nil(t, after.Output.Err)
+
+	// with truncated body
+	set = NewOptionSet(WithStrict(true), WithMaxBodyBytes(2)).(*optionSet)
+	after = runHandler(set, "application/json", `{"title":"ut"}`)
+	assert.Nil(t, after.Output.Err)
+
+	// with ignored path
+	set = NewOptionSet(WithStrict(true), WithPathToIgnore("/ut-path")).(*optionSet)
+	after = runHandler(set, "application/json", "{invalid")
+	assert.Nil(t, after.Output.Err)
+
+	// without writer
+	set = NewOptionSet(WithStrict(true)).(*optionSet)
+	before := set.BeforeCtx(httptest.NewRequest(http.MethodGet, "/ut-path", nil))
+	set.Before(before)
+	after = set.AfterCtx()
+	set.After(before, after)
+	assert.Nil(t, after.Output.Err)
+}
+
+func TestOptionSet_After_Strict(t *testing.T) {
+	send := func(set OptionSetInterface, contentType, body string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		writer := rkmid.NewCountingResponseWriter(recorder)
+		writer.Header().Set(rkmid.HeaderContentType, contentType)
+		req := rkmid.SetCountingResponseWriter(httptest.NewRequest(http.MethodGet, "/ut-path", nil), writer)
+		before := set.BeforeCtx(req)
+		set.Before(before)
+		if before.Output.DeferFunc != nil {
+			defer before.Output.DeferFunc()
+		}
+		writer.WriteHeader(http.StatusCreated)
+		writer.Write([]byte(body))
+		set.After(before, set.AfterCtx())
+		return recorder
+	}
+
+	// with invalid JSON in strict mode, response should be replaced
+	recorder := send(NewOptionSet(WithStrict(true)), "application/json", "{invalid")
+	assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "Response body does not match Content-Type")
+	assert.Equal(t, "application/json", recorder.Header().Get(rkmid.HeaderContentType))
+
+	// with valid JSON in strict mode, response should be written as it is
+	recorder = send(NewOptionSet(WithStrict(true)), "application/json", `{"title":"ut"}`)
+	assert.Equal(t, http.StatusCreated, recorder.Code)
+	assert.Equal(t, `{"title":"ut"}`, recorder.Body.String())
+
+	// with invalid JSON larger than max body bytes, response was streamed and should not be replaced
+	recorder = send(NewOptionSet(WithStrict(true), WithMaxBodyBytes(2)), "application/json", "{invalid")
+	assert.Equal(t, http.StatusCreated, recorder.Code)
+	assert.Equal(t, "{invalid", recorder.Body.String())
+
+	// with invalid JSON without strict mode, response should not be replaced
+	recorder = send(NewOptionSet(), "application/json", "{invalid")
+	assert.Equal(t, http.StatusCreated, recorder.Code)
+	assert.Equal(t, "{invalid", recorder.Body.String())
+}
+
+func newBeforeCtx(set OptionSetInterface, contentType string) (*rkmid.CountingResponseWriter, *BeforeCtx) {
+	writer := rkmid.NewCountingResponseWriter(httptest.NewRecorder())
+	writer.Header().Set(rkmid.HeaderContentType, contentType)
+	req := rkmid.SetCountingResponseWriter(httptest.NewRequest(http.MethodGet, "/ut-path", nil), writer)
+	return writer, set.BeforeCtx(req)
+}
+
+func runHandler(set OptionSetInterface, contentType, body string) *AfterCtx {
+	writer, before := newBeforeCtx(set, contentType)
+	set.Before(before)
+	writer.Write([]byte(body))
+	after := set.AfterCtx()
+	set.After(before, after)
+	return after
+}
+
+func assertNotPanic(t *testing.T) {
+	if r := recover(); r != nil {
+		// Expect panic to be called with non nil error
+		assert.True(t, false)
+	} else {
+		// This should never be called in case of a bug
+		assert.True(t, true)
+	}
+}
@@ -7,12 +7,18 @@
 package rkmidsec
 
 import (
+	"errors"
 	"fmt"
+	"github.com/rookie-ninja/rk-entry/v2/entry"
 	"github.com/rookie-ninja/rk-entry/v2/middleware"
+	"go.uber.org/zap"
 	"net/http"
 	"strings"
 )
 
+// hstsPreloadMinMaxAge is minimum max-age in seconds required by https://hstspreload.org/
+const hstsPreloadMinMaxAge = 31536000
+
 // ***************** OptionSet Interface *****************
 
 // OptionSetInterface mainly for testing purpose
@@ -26,6 +32,8 @@ type OptionSetInterface interface {
 	BeforeCtx(*http.Request) *BeforeCtx
 
 	ShouldIgnore(string) bool
+
+	Config() map[string]interface{}
 }
 
 // ***************** OptionSet Implementation *****************
@@ -83,12 +91,22 @@ type optionSet struct {
 	// Optional. Default value "".
 	contentSecurityPolicy string
 
+	// ContentSecurityPolicyFunc returns `Content-Security-Policy` header value per request,
+	// which allows CSP to vary by path. Empty value returned will fallback to contentSecurityPolicy.
+	// Optional. Default value nil.
+	contentSecurityPolicyFunc func(*http.Request) string
+
 	// HSTSPreloadEnabled will add the preload tag in the `Strict Transport Security`
 	// header, which enables the domain to be included in the HSTS preload list
 	// maintained by Chrome (and used by Firefox and Safari): https://hstspreload.org/
 	// Optional.  Default value false.
 	hstsPreloadEnabled bool
 
+	// HSTSPreloadCheckStrict will shutdown application if HSTS preload was enabled with
+	// configuration which would be rejected by preload list, otherwise, a warning will be logged.
+	// Optional. Default value false.
+	hstsPreloadCheckStrict bool
+
 	// CSPReportOnly would use the `Content-Security-Policy-Report-Only` header instead
 	// of the `Content-Security-Policy` header. This allows iterative updates of the
 	// content security policy by only reporting the violations that would
@@ -101,6 +119,29 @@ type optionSet struct {
 	// Optional. Default value "".
 	referrerPolicy string
 
+	// PermissionsPolicy sets the `Permissions-Policy` header which controls browser features
+	// available to the page, e.g. "geolocation=(), camera=()".
+	// Optional. Default value "".
+	permissionsPolicy string
+
+	// CrossOriginOpenerPolicy sets the `Cross-Origin-Opener-Policy` header which isolates
+	// browsing context from cross-origin documents.
+	// Optional. Default value "".
+	// Possible values: "unsafe-none", "same-origin-allow-popups", "same-origin".
+	crossOriginOpenerPolicy string
+
+	// CrossOriginEmbedderPolicy sets the `Cross-Origin-Embedder-Policy` header which prevents
+	// document from loading cross-origin resources without explicit permission.
+	// Optional. Default value "".
+	// Possible values: "unsafe-none", "require-corp", "credentialless".
+	crossOriginEmbedderPolicy string
+
+	// CrossOriginResourcePolicy sets the `Cross-Origin-Resource-Policy` header which restricts
+	// which origins could load the resource.
+	// Optional. Default value "".
+	// Possible values: "same-site", "same-origin", "cross-origin".
+	crossOriginResourcePolicy string
+
 	mock OptionSetInterface
 }
 
@@ -124,9 +165,36 @@ func NewOptionSet(opts ...Option) OptionSetInterface {
 		return set.mock
 	}
 
+	if err := set.validateHSTSPreload(); err != nil {
+		if set.hstsPreloadCheckStrict {
+			rkentry.ShutdownWithError(err)
+		} else {
+			rkentry.LoggerEntryStdout.Warn("Invalid HSTS preload config, domain will be rejected by preload list",
+				zap.String("entryName", set.entryName),
+				zap.Error(err))
+		}
+	}
+
 	return set
 }
 
+// Check whether HSTS config meets requirements of preload list while preload enabled
+func (set *optionSet) validateHSTSPreload() error {
+	if !set.hstsPreloadEnabled {
+		return nil
+	}
+
+	if set.hstsExcludeSubdomains {
+		return errors.New("HSTS preload requires includeSubDomains, hstsExcludeSubdomains should be false")
+	}
+
+	if set.hstsMaxAge < hstsPreloadMinMaxAge {
+		return fmt.Errorf("HSTS preload requires max-age >= %d, but got %d", hstsPreloadMinMaxAge, set.hstsMaxAge)
+	}
+
+	return nil
+}
+
 // GetEntryName returns entry name
 func (set *optionSet) GetEntryName() string {
 	return set.entryName
@@ -137,14 +205,44 @@ func (set *optionSet) GetEntryType() string {
 	return set.entryType
 }
 
+// Config returns effective configuration, secrets are redacted
+func (set *optionSet) Config() map[string]interface{} {
+	return map[string]interface{}{
+		"entryName":                 set.entryName,
+		"entryType":                 set.entryType,
+		"xssProtection":             set.xssProtection,
+		"contentTypeNosniff":        set.contentTypeNosniff,
+		"xFrameOptions":             set.xFrameOptions,
+		"hstsMaxAge":                set.hstsMaxAge,
+		"hstsExcludeSubdomains":     set.hstsExcludeSubdomains,
+		"hstsPreloadEnabled":        set.hstsPreloadEnabled,
+		"hstsPreloadCheck":          set.hstsPreloadCheckStrict,
+		"contentSecurityPolicy":     set.contentSecurityPolicy,
+		"cspFunc":                   set.contentSecurityPolicyFunc != nil,
+		"cspReportOnly":             set.cspReportOnly,
+		"referrerPolicy":            set.referrerPolicy,
+		"permissionsPolicy":         set.permissionsPolicy,
+		"crossOriginOpenerPolicy":   set.crossOriginOpenerPolicy,
+		"crossOriginEmbedderPolicy": set.crossOriginEmbedderPolicy,
+		"crossOriginResourcePolicy": set.crossOriginResourcePolicy,
+		"pathToIgnore":              set.pathToIgnore,
+	}
+}
+
 // BeforeCtx should be created before Before()
 func (set *optionSet) BeforeCtx(req *http.Request) *BeforeCtx {
 	ctx := NewBeforeCtx()
 
 	if req != nil && req.URL != nil && req.Header != nil {
+		ctx.Input.Request = req
 		ctx.Input.UrlPath = req.URL.Path
 		ctx.Input.isTLS = req.TLS != nil
 		ctx.Input.xForwardedProto = req.Header.Get(rkmid.HeaderXForwardedProto)
+
+		// prefer normalized proto if forwarded middleware is enabled
+		if forwarded := rkmid.GetForwarded(req); forwarded != nil {
+			ctx.Input.xForwardedProto = forwarded.Proto
+		}
 	}
 
 	return ctx
@@ -185,11 +283,11 @@ func (set *optionSet) Before(ctx *BeforeCtx) {
 	}
 
 	// Add Content-Security-Policy-Report-Only or Content-Security-Policy header
-	if set.contentSecurityPolicy != "" {
+	if csp := set.getContentSecurityPolicy(ctx.Input.Request); csp != "" {
 		if set.cspReportOnly {
-			ctx.Output.HeadersToReturn[rkmid.HeaderContentSecurityPolicyReportOnly] = set.contentSecurityPolicy
+			ctx.Output.HeadersToReturn[rkmid.HeaderContentSecurityPolicyReportOnly] = csp
 		} else {
-			ctx.Output.HeadersToReturn[rkmid.HeaderContentSecurityPolicy] = set.contentSecurityPolicy
+			ctx.Output.HeadersToReturn[rkmid.HeaderContentSecurityPolicy] = csp
 		}
 	}
 
@@ -198,6 +296,36 @@ func (set *optionSet) Before(ctx *BeforeCtx) {
 		ctx.Output.HeadersToReturn[rkmid.HeaderReferrerPolicy] = set.referrerPolicy
 	}
 
+	// Add Permissions-Policy header
+	if set.permissionsPolicy != "" {
+		ctx.Output.HeadersToReturn[rkmid.HeaderPermissionsPolicy] = set.permissionsPolicy
+	}
+
+	// Add Cross-Origin-Opener-Policy header
+	if set.crossOriginOpenerPolicy != "" {
+		ctx.Output.HeadersToReturn[rkmid.HeaderCrossOriginOpenerPolicy] = set.crossOriginOpenerPolicy
+	}
+
+	// Add Cross-Origin-Embedder-Policy header
+	if set.crossOriginEmbedderPolicy != "" {
+		ctx.Output.HeadersToReturn[rkmid.HeaderCrossOriginEmbedderPolicy] = set.crossOriginEmbedderPolicy
+	}
+
+	// Add Cross-Origin-Resource-Policy header
+	if set.crossOriginResourcePolicy != "" {
+		ctx.Output.HeadersToReturn[rkmid.HeaderCrossOriginResourcePolicy] = set.crossOriginResourcePolicy
+	}
+}
+
+// get Content-Security-Policy of request, fallback to static value
+func (set *optionSet) getContentSecurityPolicy(req *http.Request) string {
+	if set.contentSecurityPolicyFunc != nil && req != nil {
+		if csp := set.contentSecurityPolicyFunc(req); csp != "" {
+			return csp
+		}
+	}
+
+	return set.contentSecurityPolicy
 }
 
 // ShouldIgnore determine whether auth should be ignored based on path
@@ -234,6 +362,11 @@ func (mock *optionSetMock) GetEntryType() string {
 	return "mock"
 }
 
+// Config returns empty map
+func (mock *optionSetMock) Config() map[string]interface{} {
+	return map[string]interface{}{}
+}
+
 // BeforeCtx should be created before Before()
 func (mock *optionSetMock) BeforeCtx(request *http.Request) *BeforeCtx {
 	return mock.before
@@ -261,6 +394,7 @@ func NewBeforeCtx() *BeforeCtx {
 // BeforeCtx context for Before() function
 type BeforeCtx struct {
 	Input struct {
+		Request         *http.Request
 		UrlPath         string
 		xForwardedProto string
 		isTLS           bool
@@ -274,24 +408,34 @@ type BeforeCtx struct {
 
 // BootConfig for YAML
 type BootConfig struct {
-	Enabled               bool     `yaml:"enabled" json:"enabled"`
-	Ignore                []string `yaml:"ignore" json:"ignore"`
-	XssProtection         string   `yaml:"xssProtection" json:"xssProtection"`
-	ContentTypeNosniff    string   `yaml:"contentTypeNosniff" json:"contentTypeNosniff"`
-	XFrameOptions         string   `yaml:"xFrameOptions" json:"xFrameOptions"`
-	HstsMaxAge            int      `yaml:"hstsMaxAge" json:"hstsMaxAge"`
-	HstsExcludeSubdomains bool     `yaml:"hstsExcludeSubdomains" json:"hstsExcludeSubdomains"`
-	HstsPreloadEnabled    bool     `yaml:"hstsPreloadEnabled" json:"hstsPreloadEnabled"`
-	ContentSecurityPolicy string   `yaml:"contentSecurityPolicy" json:"contentSecurityPolicy"`
-	CspReportOnly         bool     `yaml:"cspReportOnly" json:"cspReportOnly"`
-	ReferrerPolicy        string   `yaml:"referrerPolicy" json:"referrerPolicy"`
+	Enabled                   bool     `yaml:"enabled" json:"enabled"`
+	Profile                   string   `yaml:"profile" json:"profile"`
+	Ignore                    []string `yaml:"ignore" json:"ignore"`
+	XssProtection             string   `yaml:"xssProtection" json:"xssProtection"`
+	ContentTypeNosniff        string   `yaml:"contentTypeNosniff" json:"contentTypeNosniff"`
+	XFrameOptions             string   `yaml:"xFrameOptions" json:"xFrameOptions"`
+	HstsMaxAge                int      `yaml:"hstsMaxAge" json:"hstsMaxAge"`
+	HstsExcludeSubdomains     bool     `yaml:"hstsExcludeSubdomains" json:"hstsExcludeSubdomains"`
+	HstsPreloadEnabled        bool     `yaml:"hstsPreloadEnabled" json:"hstsPreloadEnabled"`
+	HstsPreloadCheck          bool     `yaml:"hstsPreloadCheck" json:"hstsPreloadCheck"`
+	ContentSecurityPolicy     string   `yaml:"contentSecurityPolicy" json:"contentSecurityPolicy"`
+	CspReportOnly             bool     `yaml:"cspReportOnly" json:"cspReportOnly"`
+	ReferrerPolicy            string   `yaml:"referrerPolicy" json:"referrerPolicy"`
+	PermissionsPolicy         string   `yaml:"permissionsPolicy" json:"permissionsPolicy"`
+	CrossOriginOpenerPolicy   string   `yaml:"crossOriginOpenerPolicy" json:"crossOriginOpenerPolicy"`
+	CrossOriginEmbedderPolicy string   `yaml:"crossOriginEmbedderPolicy" json:"crossOriginEmbedderPolicy"`
+	CrossOriginResourcePolicy string   `yaml:"crossOriginResourcePolicy" json:"crossOriginResourcePolicy"`
 }
 
 // ToOptions convert BootConfig into Option list
+//
+// If Profile provided, values of rkmid.SecurityProfile will be used as defaults of empty fields.
 func ToOptions(config *BootConfig, entryName, entryType string) []Option {
 	opts := make([]Option, 0)
 
 	if config.Enabled {
+		config = withSecurityProfile(config)
+
 		opts = append(opts,
 			WithEntryNameAndType(entryName, entryType),
 			WithXSSProtection(config.XssProtection),
@@ -300,15 +444,61 @@ func ToOptions(config *BootConfig, entryName, entryType string) []Option {
 			WithHSTSMaxAge(config.HstsMaxAge),
 			WithHSTSExcludeSubdomains(config.HstsExcludeSubdomains),
 			WithHSTSPreloadEnabled(config.HstsPreloadEnabled),
+			WithStrictTransportSecurityPreloadCheck(config.HstsPreloadCheck),
 			WithContentSecurityPolicy(config.ContentSecurityPolicy),
 			WithCSPReportOnly(config.CspReportOnly),
 			WithReferrerPolicy(config.ReferrerPolicy),
+			WithPermissionsPolicy(config.PermissionsPolicy),
+			WithCrossOriginOpenerPolicy(config.CrossOriginOpenerPolicy),
+			WithCrossOriginEmbedderPolicy(config.CrossOriginEmbedderPolicy),
+			WithCrossOriginResourcePolicy(config.CrossOriginResourcePolicy),
 			WithPathToIgnore(config.Ignore...))
 	}
 
 	return opts
 }
 
+// Fill empty fields of BootConfig with security profile, application will shutdown if profile not found.
+// Boolean fields enabled in profile could not be disabled by BootConfig, register a custom profile instead.
+func withSecurityProfile(config *BootConfig) *BootConfig {
+	if len(config.Profile) < 1 {
+		return config
+	}
+
+	profile := rkmid.GetSecurityProfile(config.Profile)
+	if profile == nil {
+		rkentry.ShutdownWithError(fmt.Errorf("security profile not found:%s", config.Profile))
+		return config
+	}
+
+	res := *config
+	p := profile.Secure
+
+	if len(res.XssProtection) < 1 {
+		res.XssProtection = p.XssProtection
+	}
+	if len(res.ContentTypeNosniff) < 1 {
+		res.ContentTypeNosniff = p.ContentTypeNosniff
+	}
+	if len(res.XFrameOptions) < 1 {
+		res.XFrameOptions = p.XFrameOptions
+	}
+	if res.HstsMaxAge == 0 {
+		res.HstsMaxAge = p.HstsMaxAge
+	}
+	if len(res.ContentSecurityPolicy) < 1 {
+		res.ContentSecurityPolicy = p.ContentSecurityPolicy
+	}
+	if len(res.ReferrerPolicy) < 1 {
+		res.ReferrerPolicy = p.ReferrerPolicy
+	}
+	res.HstsExcludeSubdomains = res.HstsExcludeSubdomains || p.HstsExcludeSubdomains
+	res.HstsPreloadEnabled = res.HstsPreloadEnabled || p.HstsPreloadEnabled
+	res.CspReportOnly = res.CspReportOnly || p.CspReportOnly
+
+	return &res
+}
+
 // ***************** Option *****************
 
 // Option
@@ -374,6 +564,16 @@ func WithHSTSPreloadEnabled(val bool) Option {
 	}
 }
 
+// WithStrictTransportSecurityPreloadCheck provide strict validation of HSTS preload.
+// If enabled, application will shutdown if HSTS preload was enabled without includeSubDomains
+// or max-age less than 31536000, otherwise, a warning will be logged.
+// Optional. Default value false.
+func WithStrictTransportSecurityPreloadCheck(strict bool) Option {
+	return func(opt *optionSet) {
+		opt.hstsPreloadCheckStrict = strict
+	}
+}
+
 // WithContentSecurityPolicy provide Content-Security-Policy header value.
 // Optional. Default value "".
 func WithContentSecurityPolicy(val string) Option {
@@ -382,6 +582,17 @@ func WithContentSecurityPolicy(val string) Option {
 	}
 }
 
+// WithContentSecurityPolicyFunc provide function which returns Content-Security-Policy header value per request.
+// Empty value returned by function will fallback to value provided by WithContentSecurityPolicy.
+// Optional. Default value nil.
+func WithContentSecurityPolicyFunc(f func(*http.Request) string) Option {
+	return func(opt *optionSet) {
+		if f != nil {
+			opt.contentSecurityPolicyFunc = f
+		}
+	}
+}
+
 // WithCSPReportOnly provide Content-Security-Policy-Report-Only header value.
 // Optional. Default value false.
 func WithCSPReportOnly(val bool) Option {
@@ -400,6 +611,46 @@ func WithReferrerPolicy(val string) Option {
 	}
 }
 
+// WithPermissionsPolicy provide Permissions-Policy header value.
+// Optional. Default value "".
+func WithPermissionsPolicy(val string) Option {
+	return func(opt *optionSet) {
+		if len(val) > 0 {
+			opt.permissionsPolicy = val
+		}
+	}
+}
+
+// WithCrossOriginOpenerPolicy provide Cross-Origin-Opener-Policy header value.
+// Optional. Default value "".
+func WithCrossOriginOpenerPolicy(val string) Option {
+	return func(opt *optionSet) {
+		if len(val) > 0 {
+			opt.crossOriginOpenerPolicy = val
+		}
+	}
+}
+
+// WithCrossOriginEmbedderPolicy provide Cross-Origin-Embedder-Policy header value.
+// Optional. Default value "".
+func WithCrossOriginEmbedderPolicy(val string) Option {
+	return func(opt *optionSet) {
+		if len(val) > 0 {
+			opt.crossOriginEmbedderPolicy = val
+		}
+	}
+}
+
+// WithCrossOriginResourcePolicy provide Cross-Origin-Resource-Policy header value.
+// Optional. Default value "".
+func WithCrossOriginResourcePolicy(val string) Option {
+	return func(opt *optionSet) {
+		if len(val) > 0 {
+			opt.crossOriginResourcePolicy = val
+		}
+	}
+}
+
 // WithPathToIgnore provide paths prefix that will ignore.
 func WithPathToIgnore(paths ...string) Option {
 	return func(set *optionSet) {
@@ -6,11 +6,13 @@
 package rkmidsec
 
 import (
+	"context"
 	"crypto/tls"
 	"github.com/rookie-ninja/rk-entry/v2/middleware"
 	"github.com/stretchr/testify/assert"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 )
 
@@ -67,6 +69,11 @@ func TestOptionSet_BeforeCtx(t *testing.T) {
 	assert.Equal(t, "/ut", ctx.Input.UrlPath)
 	assert.True(t, ctx.Input.isTLS)
 	assert.Equal(t, "https", ctx.Input.xForwardedProto)
+
+	// with normalized forwarded values
+	req = req.WithContext(context.WithValue(req.Context(), rkmid.ForwardedKey, &rkmid.Forwarded{Proto: "http"}))
+	ctx = set.BeforeCtx(req)
+	assert.Equal(t, "http", ctx.Input.xForwardedProto)
 }
 
 func TestOptionSet_Before(t *testing.T) {
@@ -107,6 +114,100 @@ func TestOptionSet_Before(t *testing.T) {
 		rkmid.HeaderReferrerPolicy)
 }
 
+func TestWithCrossOriginPolicies(t *testing.T) {
+	// without options, headers should not be returned
+	set := NewOptionSet()
+	ctx := set.BeforeCtx(httptest.NewRequest(http.MethodGet, "/ut", nil))
+	set.Before(ctx)
+	assert.NotContains(t, ctx.Output.HeadersToReturn, rkmid.HeaderPermissionsPolicy)
+	assert.NotContains(t, ctx.Output.HeadersToReturn, rkmid.HeaderCrossOriginOpenerPolicy)
+	assert.NotContains(t, ctx.Output.HeadersToReturn, rkmid.HeaderCrossOriginEmbedderPolicy)
+	assert.NotContains(t, ctx.Output.HeadersToReturn, rkmid.HeaderCrossOriginResourcePolicy)
+
+	// with BootConfig
+	set = NewOptionSet(ToOptions(&BootConfig{
+		Enabled:                   true,
+		PermissionsPolicy:         "camera=()",
+		CrossOriginOpenerPolicy:   "same-origin",
+		CrossOriginEmbedderPolicy: "require-corp",
+		CrossOriginResourcePolicy: "same-site",
+	}, "", "")...)
+	assert.Equal(t, "camera=()", set.Config()["permissionsPolicy"])
+	ctx = set.BeforeCtx(httptest.NewRequest(http.MethodGet, "/ut", nil))
+	set.Before(ctx)
+	assert.Equal(t, "camera=()", ctx.Output.HeadersToReturn[rkmid.HeaderPermissionsPolicy])
+	assert.Equal(t, "same-origin", ctx.Output.HeadersToReturn[rkmid.HeaderCrossOriginOpenerPolicy])
+	assert.Equal(t, "require-corp", ctx.Output.HeadersToReturn[rkmid.HeaderCrossOriginEmbedderPolicy])
+	assert.Equal(t, "same-site", ctx.Output.HeadersToReturn[rkmid.HeaderCrossOriginResourcePolicy])
+}
+
+func TestWithContentSecurityPolicyFunc(t *testing.T) {
+	set := NewOptionSet(
+		WithContentSecurityPolicy("default-src 'self'"),
+		WithContentSecurityPolicyFunc(func(req *http.Request) string {
+			if strings.HasPrefix(req.URL.Path, "/docs") {
+				return "style-src 'self' 'unsafe-inline'"
+			}
+			return ""
+		}))
+
+	// with docs path
+	ctx := set.BeforeCtx(httptest.NewRequest(http.MethodGet, "/docs/index.html", nil))
+	set.Before(ctx)
+	assert.Equal(t, "style-src 'self' 'unsafe-inline'", ctx.Output.HeadersToReturn[rkmid.HeaderContentSecurityPolicy])
+
+	// with other path, fallback to static value
+	ctx = set.BeforeCtx(httptest.NewRequest(http.MethodGet, "/ut", nil))
+	set.Before(ctx)
+	assert.Equal(t, "default-src 'self'", ctx.Output.HeadersToReturn[rkmid.HeaderContentSecurityPolicy])
+
+	// with report only
+	set = NewOptionSet(
+		WithCSPReportOnly(true),
+		WithContentSecurityPolicyFunc(func(req *http.Request) string {
+			return "ut-policy"
+		}))
+	ctx = set.BeforeCtx(httptest.NewRequest(http.MethodGet, "/ut", nil))
+	set.Before(ctx)
+	assert.Equal(t, "ut-policy", ctx.Output.HeadersToReturn[rkmid.HeaderContentSecurityPolicyReportOnly])
+	assert.Empty(t, ctx.Output.HeadersToReturn[rkmid.HeaderContentSecurityPolicy])
+}
+
+func TestWithStrictTransportSecurityPreloadCheck(t *testing.T) {
+	// without preload
+	set := NewOptionSet(WithHSTSMaxAge(10)).(*optionSet)
+	assert.Nil(t, set.validateHSTSPreload())
+
+	// with subdomains excluded
+	set = NewOptionSet(
+		WithHSTSMaxAge(hstsPreloadMinMaxAge),
+		WithHSTSExcludeSubdomains(true),
+		WithHSTSPreloadEnabled(true)).(*optionSet)
+	assert.NotNil(t, set.validateHSTSPreload())
+
+	// with small max age
+	set = NewOptionSet(
+		WithHSTSMaxAge(10),
+		WithHSTSPreloadEnabled(true)).(*optionSet)
+	assert.NotNil(t, set.validateHSTSPreload())
+
+	// with valid config
+	set = NewOptionSet(
+		WithHSTSMaxAge(hstsPreloadMinMaxAge),
+		WithHSTSPreloadEnabled(true),
+		WithStrictTransportSecurityPreloadCheck(true)).(*optionSet)
+	assert.Nil(t, set.validateHSTSPreload())
+
+	// with strict check and invalid config
+	defer func() {
+		assert.NotNil(t, recover())
+	}()
+	NewOptionSet(
+		WithHSTSMaxAge(10),
+		WithHSTSPreloadEnabled(true),
+		WithStrictTransportSecurityPreloadCheck(true))
+}
+
 func TestToOptions(t *testing.T) {
 	// with disabled
 	config := &BootConfig{
@@ -119,6 +220,25 @@ func TestToOptions(t *testing.T) {
 	assert.NotEmpty(t, ToOptions(config, "", ""))
 }
 
+func TestToOptions_WithProfile(t *testing.T) {
+	// with profile, explicit values should override profile
+	config := &BootConfig{
+		Enabled:       true,
+		Profile:       rkmid.SecurityProfileStrict,
+		XFrameOptions: "SAMEORIGIN",
+	}
+	set := NewOptionSet(ToOptions(config, "", "")...).(*optionSet)
+	assert.Equal(t, "SAMEORIGIN", set.xFrameOptions)
+	assert.Equal(t, "no-referrer", set.referrerPolicy)
+	assert.Empty(t, config.ReferrerPolicy)
+
+	// with unknown profile
+	config.Profile = "ut-non-exist"
+	assert.Panics(t, func() {
+		ToOptions(config, "", "")
+	})
+}
+
 func TestNewOptionSetMock(t *testing.T) {
 	mock := NewOptionSetMock(NewBeforeCtx())
 	assert.NotEmpty(t, mock.GetEntryName())
@@ -132,3 +252,10 @@ func containsHeader(t *testing.T, in map[string]string, headers ...string) {
 		assert.Contains(t, in, v)
 	}
 }
+
+func TestOptionSet_Config(t *testing.T) {
+	set := NewOptionSet(WithEntryNameAndType("ut-config", "ut-type"))
+	config := set.Config()
+	assert.Equal(t, "ut-config", config["entryName"])
+	assert.Equal(t, "ut-type", config["entryType"])
+}
@@ -36,6 +36,8 @@ type OptionSetInterface interface {
 	Before(*BeforeCtx)
 
 	ShouldIgnore(string) bool
+
+	Config() map[string]interface{}
 }
 
 // ***************** OptionSet Implementation *****************
@@ -82,6 +84,21 @@ func (set *optionSet) GetEntryType() string {
 	return set.entryType
 }
 
+// Config returns effective configuration, secrets are redacted
+func (set *optionSet) Config() map[string]interface{} {
+	timeouts := make(map[string]string)
+	for k, v := range set.timeouts {
+		timeouts[k] = v.String()
+	}
+
+	return map[string]interface{}{
+		"entryName":    set.entryName,
+		"entryType":    set.entryType,
+		"timeouts":     timeouts,
+		"pathToIgnore": set.pathToIgnore,
+	}
+}
+
 // BeforeCtx should be created before Before()
 func (set *optionSet) BeforeCtx(req *http.Request, event rkquery.Event) *BeforeCtx {
 	ctx := NewBeforeCtx()
@@ -198,6 +215,11 @@ func (mock *optionSetMock) GetEntryType() string {
 	return "mock"
 }
 
+// Config returns empty map
+func (mock *optionSetMock) Config() map[string]interface{} {
+	return map[string]interface{}{}
+}
+
 // BeforeCtx should be created before Before()
 func (mock *optionSetMock) BeforeCtx(request *http.Request, event rkquery.Event) *BeforeCtx {
 	return mock.before
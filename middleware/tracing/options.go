@@ -8,14 +8,17 @@ package rkmidtrace
 
 import (
 	"context"
+	"fmt"
 	"github.com/rookie-ninja/rk-entry/v2/entry"
 	"github.com/rookie-ninja/rk-entry/v2/middleware"
+	"github.com/rookie-ninja/rk-entry/v2/middleware/prom"
 	"github.com/rookie-ninja/rk-logger"
 	"go.opentelemetry.io/contrib"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
 	otexporterotlp "go.opentelemetry.io/otel/exporters/otlp/otlptrace"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
 	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
 	otexporterzipkin "go.opentelemetry.io/otel/exporters/zipkin"
 	"go.opentelemetry.io/otel/propagation"
@@ -23,10 +26,15 @@ import (
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
 	oteltrace "go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -53,6 +61,8 @@ type OptionSetInterface interface {
 	GetPropagator() propagation.TextMapPropagator
 
 	ShouldIgnore(string) bool
+
+	Config() map[string]interface{}
 }
 
 // ***************** OptionSet Implementation *****************
@@ -62,12 +72,39 @@ type optionSet struct {
 	entryName    string
 	entryType    string
 	exporter     sdktrace.SpanExporter
-	processor    sdktrace.SpanProcessor
+	processors   []sdktrace.SpanProcessor
 	provider     *sdktrace.TracerProvider
 	propagator   propagation.TextMapPropagator
 	tracer       oteltrace.Tracer
 	pathToIgnore []string
-	mock         OptionSetInterface
+	metricsSet   *rkmidprom.MetricsSet
+	peerService  func(*http.Request) string
+	linkExtract  func(*http.Request) []oteltrace.Link
+	idGenerator  sdktrace.IDGenerator
+	sampler      sdktrace.Sampler
+	// otlpFactory creates otlp exporter converted from BootConfig with endpoint
+	otlpFactory      func(string) sdktrace.SpanExporter
+	otlpEndpoint     string
+	endpointResolver func() string
+	// preserveTraceState fills W3C tracestate of incoming request into parent span context
+	// if it was not extracted by propagator, so that vendor specific entries survive the hop.
+	preserveTraceState bool
+	// responseTraceHeader is name of response header which carries trace id of server span, disabled if empty
+	responseTraceHeader string
+	// queueMetricsSet records estimated queue depth of default batch span processor
+	queueMetricsSet *rkmidprom.MetricsSet
+	// batchOpts tunes default batch span processor
+	batchOpts []sdktrace.BatchSpanProcessorOption
+	// resourceAttrs are merged into resource of provider after built-in attributes
+	resourceAttrs []attribute.KeyValue
+	// spanFilters drop ended spans before they reach processors
+	spanFilters []func(sdktrace.ReadOnlySpan) bool
+	// classifier decides sampling of local trace after request finished,
+	// traces classified as default are sampled by ratio of trace id
+	classifier          rkmid.RequestClassifier
+	classifierRatio     sdktrace.Sampler
+	classifierProcessor *classifierProcessor
+	mock                OptionSetInterface
 }
 
 // NewOptionSet Create new optionSet with options.
@@ -86,33 +123,94 @@ func NewOptionSet(opts ...Option) OptionSetInterface {
 		return set.mock
 	}
 
+	if set.exporter == nil && set.otlpFactory != nil {
+		if set.endpointResolver != nil {
+			static := set.otlpEndpoint
+			set.exporter = NewResolvingExporter(func() string {
+				if endpoint := set.endpointResolver(); len(endpoint) > 0 {
+					return endpoint
+				}
+				return static
+			}, set.otlpFactory)
+		} else {
+			set.exporter = set.otlpFactory(set.otlpEndpoint)
+		}
+	}
+
 	if set.exporter == nil {
 		set.exporter = NewNoopExporter()
 	}
 
-	if set.processor == nil {
-		set.processor = sdktrace.NewBatchSpanProcessor(set.exporter)
+	if set.sampler == nil {
+		set.sampler = sdktrace.AlwaysSample()
+	}
+
+	if set.metricsSet != nil {
+		set.exporter = NewMetricsExporter(set.exporter, set.metricsSet, set.entryName)
+	}
+
+	// batch processor of exporter is registered only if no processor provided by user
+	if len(set.processors) < 1 {
+		if set.queueMetricsSet != nil {
+			set.processors = append(set.processors, NewQueueMetricsProcessor(set.exporter, set.queueMetricsSet, set.entryName, set.batchOpts...))
+		} else {
+			set.processors = append(set.processors, sdktrace.NewBatchSpanProcessor(set.exporter, set.batchOpts...))
+		}
+	}
+
+	// chain span filters before processors
+	if len(set.spanFilters) > 0 {
+		for i := range set.processors {
+			set.processors[i] = NewFilterSpanProcessor(set.processors[i], set.spanFilters...)
+		}
 	}
 
 	if set.provider == nil {
+		sampler, processors := set.sampler, set.processors
+		// classifier decides after request finished, spans dropped by sampler should be recorded,
+		// while sampled flag propagated to downstream services is still decided by sampler
+		if set.classifier != nil {
+			sampler = &classifierSampler{delegate: set.sampler}
+			set.classifierProcessor = newClassifierProcessor(set.entryName, set.processors...)
+			processors = []sdktrace.SpanProcessor{set.classifierProcessor}
+		}
+
+		attrs := []attribute.KeyValue{
+			semconv.ServiceNameKey.String(rkentry.GlobalAppCtx.GetAppInfoEntry().AppName),
+			semconv.ServiceVersionKey.String(rkentry.GlobalAppCtx.GetAppInfoEntry().Version),
+			attribute.String("service.entryName", set.entryName),
+			attribute.String("service.entryType", set.entryType),
+			semconv.TelemetrySDKLanguageGo,
+		}
+		// the last value wins if keys are duplicated
+		attrs = append(attrs, set.resourceAttrs...)
+
 		res, _ := sdkresource.New(context.Background(),
 			sdkresource.WithFromEnv(),
 			sdkresource.WithProcess(),
 			sdkresource.WithTelemetrySDK(),
 			sdkresource.WithHost(),
-			sdkresource.WithAttributes(
-				semconv.ServiceNameKey.String(rkentry.GlobalAppCtx.GetAppInfoEntry().AppName),
-				semconv.ServiceVersionKey.String(rkentry.GlobalAppCtx.GetAppInfoEntry().Version),
-				attribute.String("service.entryName", set.entryName),
-				attribute.String("service.entryType", set.entryType),
-				semconv.TelemetrySDKLanguageGo,
-			),
+			sdkresource.WithAttributes(attrs...),
 		)
-		set.provider = sdktrace.NewTracerProvider(
-			sdktrace.WithSampler(sdktrace.AlwaysSample()),
-			sdktrace.WithSpanProcessor(set.processor),
+		providerOpts := []sdktrace.TracerProviderOption{
+			sdktrace.WithSampler(sampler),
 			sdktrace.WithResource(res),
-		)
+		}
+
+		// register processors in the order they were provided
+		for i := range processors {
+			providerOpts = append(providerOpts, sdktrace.WithSpanProcessor(processors[i]))
+		}
+
+		// use random id generator provided by sdktrace by default
+		if set.idGenerator != nil {
+			providerOpts = append(providerOpts, sdktrace.WithIDGenerator(set.idGenerator))
+		}
+
+		set.provider = sdktrace.NewTracerProvider(providerOpts...)
+
+		// flush spans buffered in batch processor while shutting down, provider provided by user is managed by user
+		registerProvider(set.entryName, set.provider)
 	}
 
 	set.tracer = set.provider.Tracer(set.entryName, oteltrace.WithInstrumentationVersion(contrib.SemVersion()))
@@ -126,6 +224,39 @@ func NewOptionSet(opts ...Option) OptionSetInterface {
 	return set
 }
 
+// providers created by option sets, grouped by entry name, since one entry may create multiple option sets
+var providers = struct {
+	sync.Mutex
+	byEntry map[string][]*sdktrace.TracerProvider
+}{byEntry: make(map[string][]*sdktrace.TracerProvider)}
+
+// registerProvider registers one shutdown hook per entry, which shuts down every provider created by the entry
+func registerProvider(entryName string, provider *sdktrace.TracerProvider) {
+	providers.Lock()
+	providers.byEntry[entryName] = append(providers.byEntry[entryName], provider)
+	providers.Unlock()
+
+	rkentry.GlobalAppCtx.AddShutdownHookWithContext("rk-tracing-"+entryName, func(ctx context.Context) {
+		shutdownProviders(ctx, entryName)
+	})
+}
+
+// shutdownProviders flush and shutdown providers of entry within deadline of ctx
+func shutdownProviders(ctx context.Context, entryName string) {
+	providers.Lock()
+	list := providers.byEntry[entryName]
+	delete(providers.byEntry, entryName)
+	providers.Unlock()
+
+	for i := range list {
+		if err := list[i].Shutdown(ctx); err != nil {
+			rkentry.LoggerEntryStdout.Warn("Failed to shutdown tracer provider",
+				zap.String("entryName", entryName),
+				zap.Error(err))
+		}
+	}
+}
+
 // GetEntryName returns entry name
 func (set *optionSet) GetEntryName() string {
 	return set.entryName
@@ -136,6 +267,34 @@ func (set *optionSet) GetEntryType() string {
 	return set.entryType
 }
 
+// Config returns effective configuration, secrets are redacted
+func (set *optionSet) Config() map[string]interface{} {
+	processors := make([]string, 0)
+	for i := range set.processors {
+		processors = append(processors, fmt.Sprintf("%T", set.processors[i]))
+	}
+
+	return map[string]interface{}{
+		"entryName":           set.entryName,
+		"entryType":           set.entryType,
+		"exporter":            fmt.Sprintf("%T", set.exporter),
+		"processors":          processors,
+		"propagator":          set.propagator.Fields(),
+		"sampler":             set.sampler.Description(),
+		"exporterMetrics":     set.metricsSet != nil,
+		"queueMetrics":        set.queueMetricsSet != nil,
+		"batchOptions":        len(set.batchOpts),
+		"resourceAttributes":  len(set.resourceAttrs),
+		"spanFilters":         len(set.spanFilters),
+		"requestClassifier":   set.classifier != nil,
+		"endpointResolver":    set.endpointResolver != nil,
+		"preserveTraceState":  set.preserveTraceState,
+		"responseTraceHeader": set.responseTraceHeader,
+		"spanLinkExtractor":   set.linkExtract != nil,
+		"pathToIgnore":        set.pathToIgnore,
+	}
+}
+
 // GetTracer returns oteltrace.Tracer
 func (set *optionSet) GetTracer() oteltrace.Tracer {
 	return set.tracer
@@ -167,6 +326,18 @@ func (set *optionSet) BeforeCtx(req *http.Request, isClient bool, attrs ...attri
 			rkentry.GlobalAppCtx.GetAppInfoEntry().AppName, req.URL.Path, req)...)
 		ctx.Input.SpanName = req.URL.Path
 
+		// tag client span with peer service name
+		if isClient && set.peerService != nil {
+			if peer := set.peerService(req); len(peer) > 0 {
+				ctx.Input.Attributes = append(ctx.Input.Attributes, semconv.PeerServiceKey.String(peer))
+			}
+		}
+
+		// link span to spans referenced by request, e.g. producers of messages in batch
+		if set.linkExtract != nil {
+			ctx.Input.Links = append(ctx.Input.Links, set.linkExtract(req)...)
+		}
+
 		ctx.Input.RequestCtx = req.Context()
 		ctx.Input.Carrier = propagation.HeaderCarrier(req.Header)
 		ctx.Input.UrlPath = req.URL.Path
@@ -192,6 +363,10 @@ func (set *optionSet) Before(ctx *BeforeCtx) {
 		oteltrace.WithAttributes(ctx.Input.Attributes...),
 	}
 
+	if len(ctx.Input.Links) > 0 {
+		opts = append(opts, oteltrace.WithLinks(ctx.Input.Links...))
+	}
+
 	if ctx.Input.IsClient {
 		opts = append(opts, oteltrace.WithSpanKind(oteltrace.SpanKindClient))
 	} else {
@@ -200,11 +375,40 @@ func (set *optionSet) Before(ctx *BeforeCtx) {
 
 	// 1: extract tracing info from request header
 	spanCtx := oteltrace.SpanContextFromContext(set.propagator.Extract(ctx.Input.RequestCtx, ctx.Input.Carrier))
+	if set.preserveTraceState {
+		spanCtx = withTraceState(spanCtx, ctx.Input.Carrier)
+	}
 
 	// 2: start new span
-	ctx.Output.NewCtx, ctx.Output.Span = set.tracer.Start(
-		oteltrace.ContextWithRemoteSpanContext(ctx.Input.RequestCtx, spanCtx),
-		ctx.Input.SpanName, opts...)
+	// outbound request usually carries no tracing header, keep span in request context as parent in that case
+	parentCtx := ctx.Input.RequestCtx
+	if !ctx.Input.IsClient || spanCtx.IsValid() {
+		parentCtx = oteltrace.ContextWithRemoteSpanContext(ctx.Input.RequestCtx, spanCtx)
+	}
+
+	ctx.Output.NewCtx, ctx.Output.Span = set.tracer.Start(parentCtx, ctx.Input.SpanName, opts...)
+
+	// 3: echo trace id back to client
+	if len(set.responseTraceHeader) > 0 && !ctx.Input.IsClient {
+		if traceId := ctx.Output.Span.SpanContext().TraceID(); traceId.IsValid() {
+			ctx.Output.HeadersToReturn[set.responseTraceHeader] = traceId.String()
+		}
+	}
+}
+
+// Fill tracestate from carrier into span context if propagator did not extract it.
+// Span context without valid parent will be returned as it is, since tracestate is meaningless without it.
+func withTraceState(spanCtx oteltrace.SpanContext, carrier propagation.TextMapCarrier) oteltrace.SpanContext {
+	if !spanCtx.IsValid() || spanCtx.TraceState().Len() > 0 || carrier == nil {
+		return spanCtx
+	}
+
+	state, err := oteltrace.ParseTraceState(carrier.Get(headerTraceState))
+	if err != nil || state.Len() < 1 {
+		return spanCtx
+	}
+
+	return spanCtx.WithTraceState(state)
 }
 
 // AfterCtx should be created before After()
@@ -219,6 +423,8 @@ func (set *optionSet) AfterCtx(resCode int, resMsg string, attrs ...attribute.Ke
 }
 
 // After should run after user handler
+//
+// If response code was deferred with AfterCtx.Defer(), span will be ended while AfterCtx.Finalize() called.
 func (set *optionSet) After(before *BeforeCtx, after *AfterCtx) {
 	if before == nil || after == nil {
 		return
@@ -228,6 +434,15 @@ func (set *optionSet) After(before *BeforeCtx, after *AfterCtx) {
 		return
 	}
 
+	if after.DeferFinalize(func() { set.finalize(before, after) }) {
+		return
+	}
+
+	set.finalize(before, after)
+}
+
+// finalize records response and end span
+func (set *optionSet) finalize(before *BeforeCtx, after *AfterCtx) {
 	if after.Input.ResCode >= 0 {
 		before.Output.Span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(after.Input.ResCode)...)
 	}
@@ -239,9 +454,48 @@ func (set *optionSet) After(before *BeforeCtx, after *AfterCtx) {
 
 	before.Output.Span.SetStatus(code, after.Input.ResMsg)
 	before.Output.Span.SetAttributes(after.Input.Attributes...)
+
+	if set.classifierProcessor != nil {
+		set.classifierProcessor.decide(before.Output.Span.SpanContext(), set.classify(before, after))
+	}
+
 	before.Output.Span.End()
 }
 
+// classify decides sampling of request with path and response code of middleware context.
+// Requests classified as default are dropped if trace id is not sampled by ratio.
+func (set *optionSet) classify(before *BeforeCtx, after *AfterCtx) rkmid.SamplingDecision {
+	info := &rkmid.RequestInfo{
+		UrlPath: before.Input.UrlPath,
+		ResCode: after.Input.ResCode,
+	}
+	for _, attr := range before.Input.Attributes {
+		if attr.Key == semconv.HTTPMethodKey {
+			info.Method = attr.Value.AsString()
+		}
+	}
+	if span, ok := before.Output.Span.(sdktrace.ReadOnlySpan); ok {
+		info.Latency = time.Since(span.StartTime())
+	}
+
+	decision := set.classifier.Classify(info)
+	if decision != rkmid.SamplingDecisionDefault {
+		return decision
+	}
+
+	// sample by trace id, so that decision is consistent across services
+	res := set.classifierRatio.ShouldSample(sdktrace.SamplingParameters{
+		ParentContext: context.Background(),
+		TraceID:       before.Output.Span.SpanContext().TraceID(),
+		Name:          before.Input.SpanName,
+	})
+	if res.Decision != sdktrace.RecordAndSample {
+		return rkmid.SamplingDecisionDrop
+	}
+
+	return decision
+}
+
 // ShouldIgnore determine whether auth should be ignored based on path
 func (set *optionSet) ShouldIgnore(path string) bool {
 	for i := range set.pathToIgnore {
@@ -299,6 +553,11 @@ func (mock *optionSetMock) GetEntryType() string {
 	return "mock"
 }
 
+// Config returns empty map
+func (mock *optionSetMock) Config() map[string]interface{} {
+	return map[string]interface{}{}
+}
+
 // BeforeCtx should be created before Before()
 func (mock *optionSetMock) BeforeCtx(req *http.Request, isClient bool, attrs ...attribute.KeyValue) *BeforeCtx {
 	return mock.before
@@ -330,6 +589,7 @@ func (mock *optionSetMock) ShouldIgnore(string) bool {
 func NewBeforeCtx() *BeforeCtx {
 	ctx := &BeforeCtx{}
 	ctx.Input.Attributes = make([]attribute.KeyValue, 0)
+	ctx.Output.HeadersToReturn = make(map[string]string)
 
 	return ctx
 }
@@ -348,16 +608,28 @@ type BeforeCtx struct {
 		SpanName   string
 		IsClient   bool
 		Attributes []attribute.KeyValue
+		// Links are added to span while starting it
+		Links      []oteltrace.Link
 		RequestCtx context.Context
 		Carrier    propagation.TextMapCarrier
 	}
 	Output struct {
 		NewCtx context.Context
 		Span   oteltrace.Span
+		// HeadersToReturn should be written into response before user handler
+		HeadersToReturn map[string]string
 	}
 }
 
 // AfterCtx context for After() function
+//
+// For streaming or async handlers, response code may not be known while After() runs.
+// Lifecycle of deferred response code:
+//  1. Adapter calls Defer() before After(), After() will record nothing and return.
+//  2. Adapter fills Input.ResCode and Input.ResMsg once response completes.
+//  3. Adapter calls Finalize(), span will be ended with values in Input.
+//
+// Finalize() is safe to be called before After(), in that case After() ends span directly.
 type AfterCtx struct {
 	Input struct {
 		ResCode    int
@@ -365,6 +637,8 @@ type AfterCtx struct {
 		Attributes []attribute.KeyValue
 	}
 	Output struct{}
+
+	rkmid.Finalizer
 }
 
 // ***************** BootConfig *****************
@@ -379,14 +653,107 @@ type BootConfig struct {
 			OutputPath string `yaml:"outputPath" json:"outputPath"`
 		} `yaml:"file" json:"file"`
 		Otlp struct {
-			Enabled  bool   `yaml:"enabled" json:"enabled"`
-			Endpoint string `yaml:"endpoint" json:"endpoint"`
+			Enabled    bool              `yaml:"enabled" json:"enabled"`
+			Endpoint   string            `yaml:"endpoint" json:"endpoint"`
+			Headers    map[string]string `yaml:"headers" json:"headers"`
+			TlsEnabled bool              `yaml:"tlsEnabled" json:"tlsEnabled"`
+			// Protocol of exporter, one of grpc and http, grpc will be used by default
+			Protocol string `yaml:"protocol" json:"protocol"`
 		} `yaml:"otlp" json:"otlp"`
 		Zipkin struct {
 			Enabled  bool   `yaml:"enabled" json:"enabled"`
 			Endpoint string `yaml:"endpoint" json:"endpoint"`
 		} `yaml:"zipkin" json:"zipkin"`
 	} `yaml:"exporter" json:"exporter"`
+	Sampler SamplerConfig `yaml:"sampler" json:"sampler"`
+	Batch   BatchConfig   `yaml:"batch" json:"batch"`
+	// Attributes added to resource of spans, like deployment.environment
+	Attributes map[string]string `yaml:"attributes" json:"attributes"`
+	// ResponseTraceHeader is name of response header which carries trace id, disabled if empty
+	ResponseTraceHeader string `yaml:"responseTraceHeader" json:"responseTraceHeader"`
+}
+
+// BatchConfig for YAML, tunes default batch span processor, zero values will be ignored
+type BatchConfig struct {
+	MaxQueueSize       int   `yaml:"maxQueueSize" json:"maxQueueSize"`
+	MaxExportBatchSize int   `yaml:"maxExportBatchSize" json:"maxExportBatchSize"`
+	BatchTimeoutMs     int64 `yaml:"batchTimeoutMs" json:"batchTimeoutMs"`
+	ExportTimeoutMs    int64 `yaml:"exportTimeoutMs" json:"exportTimeoutMs"`
+}
+
+// ToBatchOptions convert BatchConfig into sdktrace.BatchSpanProcessorOption list
+func (config *BatchConfig) ToBatchOptions() []sdktrace.BatchSpanProcessorOption {
+	opts := make([]sdktrace.BatchSpanProcessorOption, 0)
+
+	if config.MaxQueueSize > 0 {
+		opts = append(opts, sdktrace.WithMaxQueueSize(config.MaxQueueSize))
+	}
+	if config.MaxExportBatchSize > 0 {
+		opts = append(opts, sdktrace.WithMaxExportBatchSize(config.MaxExportBatchSize))
+	}
+	if config.BatchTimeoutMs > 0 {
+		opts = append(opts, sdktrace.WithBatchTimeout(time.Duration(config.BatchTimeoutMs)*time.Millisecond))
+	}
+	if config.ExportTimeoutMs > 0 {
+		opts = append(opts, sdktrace.WithExportTimeout(time.Duration(config.ExportTimeoutMs)*time.Millisecond))
+	}
+
+	return opts
+}
+
+const (
+	// OtlpProtocolGrpc exports spans to OTLP/gRPC endpoint
+	OtlpProtocolGrpc = "grpc"
+	// OtlpProtocolHttp exports spans to OTLP/HTTP endpoint with protobuf encoding
+	OtlpProtocolHttp = "http"
+)
+
+const (
+	// SamplerAlways samples every span
+	SamplerAlways = "always"
+	// SamplerNever samples no span
+	SamplerNever = "never"
+	// SamplerTraceIdRatio samples fraction of traces based on trace id
+	SamplerTraceIdRatio = "traceIdRatio"
+)
+
+// SamplerConfig for YAML, like below:
+//
+//	sampler:
+//	  type: traceIdRatio
+//	  ratio: 0.1
+//	  parentBased: true
+type SamplerConfig struct {
+	// Type of sampler, one of always, never and traceIdRatio
+	Type string `yaml:"type" json:"type"`
+	// Ratio of traces sampled by traceIdRatio sampler
+	Ratio float64 `yaml:"ratio" json:"ratio"`
+	// ParentBased wraps sampler, so that decision of remote parent will be respected
+	ParentBased bool `yaml:"parentBased" json:"parentBased"`
+}
+
+// ToSampler convert SamplerConfig into sdktrace.Sampler, nil will be returned if type is empty
+func (config *SamplerConfig) ToSampler() sdktrace.Sampler {
+	var sampler sdktrace.Sampler
+
+	switch config.Type {
+	case "":
+		return nil
+	case SamplerAlways:
+		sampler = sdktrace.AlwaysSample()
+	case SamplerNever:
+		sampler = sdktrace.NeverSample()
+	case SamplerTraceIdRatio:
+		sampler = sdktrace.TraceIDRatioBased(config.Ratio)
+	default:
+		rkentry.ShutdownWithError(fmt.Errorf("unknown sampler type:%s", config.Type))
+	}
+
+	if config.ParentBased {
+		sampler = sdktrace.ParentBased(sampler)
+	}
+
+	return sampler
 }
 
 // ToOptions convert BootConfig into Option list
@@ -400,18 +767,9 @@ func ToOptions(config *BootConfig, entryName, entryType string) []Option {
 			exporter = NewFileExporter(config.Exporter.File.OutputPath)
 		}
 		if config.Exporter.Otlp.Enabled {
-			opts := make([]otlptracegrpc.Option, 0)
-			client := otlptracegrpc.NewClient(opts...)
-			if len(config.Exporter.Otlp.Endpoint) > 0 {
-				opts := []otlptracegrpc.Option{
-					otlptracegrpc.WithInsecure(),
-					otlptracegrpc.WithEndpoint(config.Exporter.Otlp.Endpoint),
-					otlptracegrpc.WithReconnectionPeriod(50 * time.Millisecond),
-				}
-				client = otlptracegrpc.NewClient(opts...)
-			}
-
-			exporter = NewOTLPTraceExporter(client)
+			// otlp exporter will be created in NewOptionSet, since endpoint may be provided by WithEndpointResolver
+			exporter = nil
+			opts = append(opts, withOtlpExporter(config.Exporter.Otlp.Endpoint, newOtlpExporterFactory(config)))
 		}
 		if config.Exporter.Zipkin.Enabled {
 			var url string
@@ -424,12 +782,63 @@ func ToOptions(config *BootConfig, entryName, entryType string) []Option {
 		opts = append(opts,
 			WithEntryNameAndType(entryName, entryType),
 			WithExporter(exporter),
+			WithSampler(config.Sampler.ToSampler()),
+			WithBatchOptions(config.Batch.ToBatchOptions()...),
+			WithResourceAttributes(toResourceAttributes(config.Attributes)...),
+			WithResponseTraceHeader(config.ResponseTraceHeader),
 			WithPathToIgnore(config.Ignore...))
 	}
 
 	return opts
 }
 
+// toResourceAttributes convert attributes into attribute.KeyValue list sorted by key
+func toResourceAttributes(attrs map[string]string) []attribute.KeyValue {
+	keys := make([]string, 0)
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	res := make([]attribute.KeyValue, 0)
+	for _, k := range keys {
+		res = append(res, attribute.String(k, attrs[k]))
+	}
+
+	return res
+}
+
+// newOtlpExporterFactory returns function which creates otlp exporter to endpoint with protocol, headers and TLS of config
+func newOtlpExporterFactory(config *BootConfig) func(string) sdktrace.SpanExporter {
+	otlp := config.Exporter.Otlp
+
+	switch otlp.Protocol {
+	case "", OtlpProtocolGrpc:
+	case OtlpProtocolHttp:
+		return func(endpoint string) sdktrace.SpanExporter {
+			return NewOTLPHTTPTraceExporter(endpoint, otlp.Headers, otlp.TlsEnabled)
+		}
+	default:
+		rkentry.ShutdownWithError(fmt.Errorf("unknown otlp protocol:%s", otlp.Protocol))
+	}
+
+	return func(endpoint string) sdktrace.SpanExporter {
+		opts := make([]otlptracegrpc.Option, 0)
+		// system cert pool will be used if TLS enabled
+		if !otlp.TlsEnabled {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		if len(endpoint) > 0 {
+			opts = append(opts, otlptracegrpc.WithEndpoint(endpoint))
+		}
+		if len(otlp.Headers) > 0 {
+			opts = append(opts, otlptracegrpc.WithHeaders(otlp.Headers))
+		}
+
+		return NewOTLPTraceExporterWithOpts(opts...)
+	}
+}
+
 // ***************** Option *****************
 
 // Option is used while creating middleware as param
@@ -444,11 +853,98 @@ func WithExporter(exporter sdktrace.SpanExporter) Option {
 	}
 }
 
+// withOtlpExporter provide static endpoint and factory of otlp exporter converted from BootConfig
+func withOtlpExporter(endpoint string, factory func(string) sdktrace.SpanExporter) Option {
+	return func(opt *optionSet) {
+		opt.otlpEndpoint = endpoint
+		opt.otlpFactory = factory
+	}
+}
+
+// WithEndpointResolver provide function which resolves endpoint of otlp collector in BootConfig,
+// like address discovered from consul, so that collector could move without changing config.
+//
+// Endpoint will be resolved while creating middleware, and re-resolved if export failed.
+// Static endpoint in BootConfig will be used if resolver returns empty string,
+// and if it is empty too, gRPC exporter falls back to OTEL_EXPORTER_OTLP_ENDPOINT or localhost:4317,
+// HTTP exporter falls back to localhost:4318.
+//
+// It takes no effect if otlp exporter was not enabled in BootConfig, use NewResolvingExporter for other exporters.
+func WithEndpointResolver(resolver func() string) Option {
+	return func(opt *optionSet) {
+		if resolver != nil {
+			opt.endpointResolver = resolver
+		}
+	}
+}
+
+// WithResourceAttributes provide attributes merged into resource of provider, like deployment.environment.
+//
+// Built-in attributes, like service.name, are kept, and will be overridden by attributes with the same key.
+// The last one wins if keys are duplicated. It will be ignored if WithTracerProvider was provided.
+func WithResourceAttributes(attrs ...attribute.KeyValue) Option {
+	return func(set *optionSet) {
+		for i := range attrs {
+			if attrs[i].Valid() {
+				set.resourceAttrs = append(set.resourceAttrs, attrs[i])
+			}
+		}
+	}
+}
+
+// WithBatchOptions provide sdktrace.BatchSpanProcessorOption to tune default batch span processor,
+// like sdktrace.WithMaxQueueSize. It takes no effect if processor provided with WithSpanProcessor.
+func WithBatchOptions(opts ...sdktrace.BatchSpanProcessorOption) Option {
+	return func(set *optionSet) {
+		for i := range opts {
+			if opts[i] != nil {
+				set.batchOpts = append(set.batchOpts, opts[i])
+			}
+		}
+	}
+}
+
 // WithSpanProcessor provide sdktrace.SpanProcessor.
+// Processors are additive, each of them will be registered to provider in the order provided.
+// Default batch processor of exporter is registered only if no processor provided,
+// add sdktrace.NewBatchSpanProcessor explicitly in order to keep exporting along with custom processors.
 func WithSpanProcessor(processor sdktrace.SpanProcessor) Option {
 	return func(opt *optionSet) {
 		if processor != nil {
-			opt.processor = processor
+			opt.processors = append(opt.processors, processor)
+		}
+	}
+}
+
+// WithSpanFilter provide predicate which decides whether ended span should be exported.
+// Spans rejected by any of filters will be dropped before reaching span processors.
+//
+// It complements WithPathToIgnore, spans could be filtered by name or attributes,
+// like spans of health endpoints propagated from upstream.
+// It takes no effect if provider was provided with WithTracerProvider.
+func WithSpanFilter(filter func(sdktrace.ReadOnlySpan) bool) Option {
+	return func(opt *optionSet) {
+		if filter != nil {
+			opt.spanFilters = append(opt.spanFilters, filter)
+		}
+	}
+}
+
+// WithRequestClassifier provide rkmid.RequestClassifier consulted while spans started by middleware ended.
+//
+// Spans classified as rkmid.SamplingDecisionKeep are exported, rkmid.SamplingDecisionDrop ones are dropped,
+// others are exported by fraction of trace id, like 0.01 for 1% of spans.
+// Share the same classifier with logging middleware, so that a single policy governs sampling of both traces and events.
+//
+// Since decision is made after request finished, spans dropped by sampler are still recorded, and spans of local trace
+// are buffered until span started by middleware ended, then exported or dropped together.
+// Sampled flag propagated to downstream services is still decided by sampler provided with WithSampler.
+// It takes no effect if provider was provided with WithTracerProvider.
+func WithRequestClassifier(classifier rkmid.RequestClassifier, fraction float64) Option {
+	return func(opt *optionSet) {
+		if classifier != nil {
+			opt.classifier = classifier
+			opt.classifierRatio = sdktrace.TraceIDRatioBased(fraction)
 		}
 	}
 }
@@ -471,6 +967,68 @@ func WithPropagator(propagator propagation.TextMapPropagator) Option {
 	}
 }
 
+// WithSampler provide sdktrace.Sampler, sdktrace.AlwaysSample will be used by default.
+// It will be ignored if WithTracerProvider was provided.
+func WithSampler(sampler sdktrace.Sampler) Option {
+	return func(opt *optionSet) {
+		if sampler != nil {
+			opt.sampler = sampler
+		}
+	}
+}
+
+// WithIDGenerator provide sdktrace.IDGenerator, mainly used for generating deterministic IDs in tests.
+// It will be ignored if WithTracerProvider was provided.
+func WithIDGenerator(generator sdktrace.IDGenerator) Option {
+	return func(opt *optionSet) {
+		if generator != nil {
+			opt.idGenerator = generator
+		}
+	}
+}
+
+// WithPreserveTraceState preserve W3C tracestate of incoming request on new span.
+//
+// Tracestate will be parsed from carrier if propagator, like B3, did not extract it,
+// so that vendor specific entries will be carried by Output.NewCtx and propagated to downstream.
+func WithPreserveTraceState(preserve bool) Option {
+	return func(opt *optionSet) {
+		opt.preserveTraceState = preserve
+	}
+}
+
+// WithResponseTraceHeader provide name of response header, like rkmid.HeaderTraceId, which carries trace id of
+// server span, so that trace could be found with id copied from developer tools of browser.
+// Header will be added to Output.HeadersToReturn of BeforeCtx, adapters should write it into response.
+//
+// Disabled by default. Notice: trace id is an internal identifier which will be exposed to every client,
+// enable it only if that is acceptable.
+func WithResponseTraceHeader(name string) Option {
+	return func(opt *optionSet) {
+		opt.responseTraceHeader = strings.TrimSpace(name)
+	}
+}
+
+// WithPeerServiceAttribute provide function which returns name of target service.
+// The name will be set as peer.service attribute of client span.
+func WithPeerServiceAttribute(f func(*http.Request) string) Option {
+	return func(opt *optionSet) {
+		if f != nil {
+			opt.peerService = f
+		}
+	}
+}
+
+// WithSpanLinkExtractor provide function which returns links of span started for request,
+// which is useful for correlating batch or fan-out requests with spans of upstream producers.
+func WithSpanLinkExtractor(f func(*http.Request) []oteltrace.Link) Option {
+	return func(opt *optionSet) {
+		if f != nil {
+			opt.linkExtract = f
+		}
+	}
+}
+
 // WithEntryNameAndType provide entry name and entry type.
 func WithEntryNameAndType(entryName, entryType string) Option {
 	return func(opt *optionSet) {
@@ -490,6 +1048,29 @@ func WithPathToIgnore(paths ...string) Option {
 	}
 }
 
+// WithExporterMetrics provide *rkmidprom.MetricsSet which records span export results.
+//
+// Exporter will be wrapped with NewMetricsExporter, exported spans and export failures will be counted.
+func WithExporterMetrics(metricsSet *rkmidprom.MetricsSet) Option {
+	return func(set *optionSet) {
+		if metricsSet != nil {
+			set.metricsSet = metricsSet
+		}
+	}
+}
+
+// WithQueueMetrics provide *rkmidprom.MetricsSet which records estimated queue depth of batch span processor.
+//
+// Batch span processor of exporter will be wrapped with NewQueueMetricsProcessor.
+// It takes no effect if processor provided with WithSpanProcessor.
+func WithQueueMetrics(metricsSet *rkmidprom.MetricsSet) Option {
+	return func(set *optionSet) {
+		if metricsSet != nil {
+			set.queueMetricsSet = metricsSet
+		}
+	}
+}
+
 // WithMockOptionSet provide mock OptionSetInterface
 func WithMockOptionSet(mock OptionSetInterface) Option {
 	return func(set *optionSet) {
@@ -497,8 +1078,92 @@ func WithMockOptionSet(mock OptionSetInterface) Option {
 	}
 }
 
+// ***************** RoundTripper *****************
+
+// NewTracingRoundTripper create http.RoundTripper which traces outbound requests with http.DefaultTransport.
+//
+// Client span will be started for every request with parent span in context of request,
+// and tracing headers will be injected with propagator of OptionSetInterface.
+func NewTracingRoundTripper(set OptionSetInterface) http.RoundTripper {
+	return &tracingRoundTripper{
+		set:  set,
+		next: http.DefaultTransport,
+	}
+}
+
+// WithOutboundTracing returns a copy of http.Client whose transport traces outbound requests.
+// http.DefaultTransport will be wrapped if Transport of client is nil.
+func WithOutboundTracing(client *http.Client, set OptionSetInterface) *http.Client {
+	res := &http.Client{}
+	if client != nil {
+		*res = *client
+	}
+
+	rt := NewTracingRoundTripper(set).(*tracingRoundTripper)
+	if res.Transport != nil {
+		rt.next = res.Transport
+	}
+	res.Transport = rt
+
+	return res
+}
+
+type tracingRoundTripper struct {
+	set  OptionSetInterface
+	next http.RoundTripper
+}
+
+// RoundTrip starts client span, injects tracing headers and ends span once response received.
+func (rt *tracingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if rt.set == nil {
+		return rt.next.RoundTrip(req)
+	}
+
+	// RoundTripper should not modify original request
+	req = req.Clone(req.Context())
+
+	beforeCtx := rt.set.BeforeCtx(req, true)
+	rt.set.Before(beforeCtx)
+
+	// path ignored
+	if beforeCtx == nil || beforeCtx.Output.Span == nil {
+		return rt.next.RoundTrip(req)
+	}
+
+	if propagator := rt.set.GetPropagator(); propagator != nil {
+		propagator.Inject(beforeCtx.Output.NewCtx, propagation.HeaderCarrier(req.Header))
+	}
+
+	resp, err := rt.next.RoundTrip(req.WithContext(beforeCtx.Output.NewCtx))
+	if err != nil {
+		beforeCtx.Output.Span.RecordError(err)
+		rt.set.After(beforeCtx, rt.set.AfterCtx(-1, err.Error()))
+		return resp, err
+	}
+
+	rt.set.After(beforeCtx, rt.set.AfterCtx(resp.StatusCode, resp.Status))
+
+	return resp, nil
+}
+
 // ***************** Global *****************
 
+const (
+	// MetricsNameSpansExported records number of spans exported successfully
+	MetricsNameSpansExported = "spansExported"
+	// MetricsNameExportFailures records number of failed exports
+	MetricsNameExportFailures = "spanExportFailures"
+
+	// MetricsNameExporterQueueSize records estimated number of spans waiting in queue of batch span processor
+	MetricsNameExporterQueueSize = "spanExporterQueueSize"
+
+	// W3C tracestate header
+	headerTraceState = "tracestate"
+)
+
+// interval of sampling queue depth into gauge
+var queueMetricsInterval = time.Second
+
 // NoopExporter noop
 type NoopExporter struct{}
 
@@ -513,6 +1178,384 @@ func NewNoopExporter() sdktrace.SpanExporter {
 	return &NoopExporter{}
 }
 
+// MetricsExporter wraps sdktrace.SpanExporter and records export results into rkmidprom.MetricsSet
+type MetricsExporter struct {
+	delegate   sdktrace.SpanExporter
+	metricsSet *rkmidprom.MetricsSet
+	entryName  string
+}
+
+// ExportSpans export spans with delegate and count exported spans or failures.
+func (e *MetricsExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	err := e.delegate.ExportSpans(ctx, spans)
+
+	if err != nil {
+		if counter := e.metricsSet.GetCounterWithValues(MetricsNameExportFailures, e.entryName); counter != nil {
+			counter.Inc()
+		}
+	} else {
+		if counter := e.metricsSet.GetCounterWithValues(MetricsNameSpansExported, e.entryName); counter != nil {
+			counter.Add(float64(len(spans)))
+		}
+	}
+
+	return err
+}
+
+// Shutdown stops the delegate exporter.
+func (e *MetricsExporter) Shutdown(ctx context.Context) error {
+	return e.delegate.Shutdown(ctx)
+}
+
+// NewMetricsExporter create an exporter which records export results into metricsSet.
+//
+// Counters of MetricsNameSpansExported and MetricsNameExportFailures will be registered with label of entryName.
+func NewMetricsExporter(delegate sdktrace.SpanExporter, metricsSet *rkmidprom.MetricsSet, entryName string) sdktrace.SpanExporter {
+	if delegate == nil {
+		delegate = NewNoopExporter()
+	}
+
+	if metricsSet == nil {
+		return delegate
+	}
+
+	// ignore error of duplicate registration
+	metricsSet.RegisterCounter(MetricsNameSpansExported, "entryName")
+	metricsSet.RegisterCounter(MetricsNameExportFailures, "entryName")
+
+	return &MetricsExporter{
+		delegate:   delegate,
+		metricsSet: metricsSet,
+		entryName:  entryName,
+	}
+}
+
+// FilterSpanProcessor wraps sdktrace.SpanProcessor and drops ended spans rejected by filters
+type FilterSpanProcessor struct {
+	sdktrace.SpanProcessor
+
+	filters []func(sdktrace.ReadOnlySpan) bool
+}
+
+// OnEnd pass span to delegate only if all filters accept it
+func (p *FilterSpanProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	for i := range p.filters {
+		if !p.filters[i](s) {
+			return
+		}
+	}
+
+	p.SpanProcessor.OnEnd(s)
+}
+
+// NewFilterSpanProcessor create span processor which passes ended spans accepted by all filters to delegate.
+func NewFilterSpanProcessor(delegate sdktrace.SpanProcessor, filters ...func(sdktrace.ReadOnlySpan) bool) sdktrace.SpanProcessor {
+	res := &FilterSpanProcessor{
+		SpanProcessor: delegate,
+		filters:       make([]func(sdktrace.ReadOnlySpan) bool, 0),
+	}
+
+	for i := range filters {
+		if filters[i] != nil {
+			res.filters = append(res.filters, filters[i])
+		}
+	}
+
+	return res
+}
+
+// classifierSampler records spans dropped by delegate, so that they could be kept by rkmid.RequestClassifier.
+// Sampled flag of span context is still decided by delegate, which is propagated to downstream services.
+type classifierSampler struct {
+	delegate sdktrace.Sampler
+}
+
+// ShouldSample returns result of delegate, spans dropped by delegate will be recorded only
+func (s *classifierSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	res := s.delegate.ShouldSample(p)
+	if res.Decision == sdktrace.Drop {
+		res.Decision = sdktrace.RecordOnly
+	}
+
+	return res
+}
+
+// Description returns description of delegate
+func (s *classifierSampler) Description() string {
+	return s.delegate.Description()
+}
+
+// classifierProcessor buffers ended spans of local trace until its root span started by middleware ended,
+// then passes or drops all of them to processors based on decision of rkmid.RequestClassifier.
+//
+// Spans of traces which were not started by middleware, or ended after root span, are passed as they are.
+type classifierProcessor struct {
+	processors []sdktrace.SpanProcessor
+	scope      string
+	lock       sync.Mutex
+	traces     map[oteltrace.TraceID]*classifiedTrace
+}
+
+// classifiedTrace is local trace waiting for decision of root span
+type classifiedTrace struct {
+	root     oteltrace.SpanID
+	decision rkmid.SamplingDecision
+	spans    []sdktrace.ReadOnlySpan
+}
+
+// newClassifierProcessor create classifierProcessor which tracks local root spans of tracer named with scope
+func newClassifierProcessor(scope string, processors ...sdktrace.SpanProcessor) *classifierProcessor {
+	return &classifierProcessor{
+		processors: processors,
+		scope:      scope,
+		traces:     make(map[oteltrace.TraceID]*classifiedTrace),
+	}
+}
+
+// OnStart tracks local root span started by middleware
+func (p *classifierProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	if s.InstrumentationScope().Name == p.scope && (!s.Parent().IsValid() || s.Parent().IsRemote()) {
+		p.lock.Lock()
+		if _, ok := p.traces[s.SpanContext().TraceID()]; !ok {
+			p.traces[s.SpanContext().TraceID()] = &classifiedTrace{
+				root: s.SpanContext().SpanID(),
+			}
+		}
+		p.lock.Unlock()
+	}
+
+	for i := range p.processors {
+		p.processors[i].OnStart(parent, s)
+	}
+}
+
+// OnEnd buffers span of tracked trace, spans will be passed or dropped together while root span ended
+func (p *classifierProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	p.lock.Lock()
+	trace, ok := p.traces[s.SpanContext().TraceID()]
+	if ok && trace.root != s.SpanContext().SpanID() {
+		trace.spans = append(trace.spans, s)
+		p.lock.Unlock()
+		return
+	}
+	if ok {
+		delete(p.traces, s.SpanContext().TraceID())
+	}
+	p.lock.Unlock()
+
+	if !ok {
+		p.end(s)
+		return
+	}
+
+	spans := append(trace.spans, s)
+	for i := range spans {
+		switch trace.decision {
+		case rkmid.SamplingDecisionDrop:
+			return
+		case rkmid.SamplingDecisionKeep:
+			p.end(&sampledSpan{ReadOnlySpan: spans[i]})
+		default:
+			// spans dropped by sampler were recorded for classifier only
+			if spans[i].SpanContext().IsSampled() {
+				p.end(spans[i])
+			}
+		}
+	}
+}
+
+// decide records decision of root span, it should be called before root span ended
+func (p *classifierProcessor) decide(spanCtx oteltrace.SpanContext, decision rkmid.SamplingDecision) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if trace, ok := p.traces[spanCtx.TraceID()]; ok && trace.root == spanCtx.SpanID() {
+		trace.decision = decision
+	}
+}
+
+func (p *classifierProcessor) end(s sdktrace.ReadOnlySpan) {
+	for i := range p.processors {
+		p.processors[i].OnEnd(s)
+	}
+}
+
+// Shutdown drops buffered spans and shutdown processors
+func (p *classifierProcessor) Shutdown(ctx context.Context) error {
+	p.lock.Lock()
+	p.traces = make(map[oteltrace.TraceID]*classifiedTrace)
+	p.lock.Unlock()
+
+	var err error
+	for i := range p.processors {
+		if e := p.processors[i].Shutdown(ctx); e != nil && err == nil {
+			err = e
+		}
+	}
+
+	return err
+}
+
+// ForceFlush flush processors, spans waiting for decision are not flushed
+func (p *classifierProcessor) ForceFlush(ctx context.Context) error {
+	var err error
+	for i := range p.processors {
+		if e := p.processors[i].ForceFlush(ctx); e != nil && err == nil {
+			err = e
+		}
+	}
+
+	return err
+}
+
+// sampledSpan marks span recorded only as sampled, so that it will be exported by batch span processor
+type sampledSpan struct {
+	sdktrace.ReadOnlySpan
+}
+
+// SpanContext returns span context with sampled flag
+func (s *sampledSpan) SpanContext() oteltrace.SpanContext {
+	spanCtx := s.ReadOnlySpan.SpanContext()
+	return spanCtx.WithTraceFlags(spanCtx.TraceFlags().WithSampled(true))
+}
+
+// QueueMetricsProcessor wraps batch span processor and records its estimated queue depth into rkmidprom.MetricsSet
+//
+// OpenTelemetry does not expose queue of batch span processor, so the depth is estimated by
+// counting spans enqueued with OnEnd() and spans handed to exporter.
+// Spans dropped by a full queue can not be observed, depth is capped at max queue size instead.
+type QueueMetricsProcessor struct {
+	sdktrace.SpanProcessor
+
+	metricsSet *rkmidprom.MetricsSet
+	entryName  string
+	maxSize    int64
+	depth      int64
+	stopCh     chan struct{}
+	stopOnce   sync.Once
+}
+
+// OnEnd count sampled span as enqueued and pass it to batch span processor
+func (p *QueueMetricsProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	if s.SpanContext().IsSampled() {
+		for {
+			depth := atomic.LoadInt64(&p.depth)
+			// span will be dropped by batch span processor if queue is full
+			if depth >= p.maxSize || atomic.CompareAndSwapInt64(&p.depth, depth, depth+1) {
+				break
+			}
+		}
+	}
+
+	p.SpanProcessor.OnEnd(s)
+}
+
+// Shutdown stops sampling and batch span processor
+func (p *QueueMetricsProcessor) Shutdown(ctx context.Context) error {
+	p.stopOnce.Do(func() {
+		close(p.stopCh)
+	})
+
+	err := p.SpanProcessor.Shutdown(ctx)
+	p.sample()
+
+	return err
+}
+
+// QueueSize returns estimated number of spans waiting in queue
+func (p *QueueMetricsProcessor) QueueSize() int64 {
+	return atomic.LoadInt64(&p.depth)
+}
+
+// dequeue spans handed to exporter
+func (p *QueueMetricsProcessor) dequeue(n int) {
+	for {
+		depth := atomic.LoadInt64(&p.depth)
+		res := depth - int64(n)
+		if res < 0 {
+			res = 0
+		}
+		if atomic.CompareAndSwapInt64(&p.depth, depth, res) {
+			return
+		}
+	}
+}
+
+// sample estimated queue depth into gauge
+func (p *QueueMetricsProcessor) sample() {
+	if gauge := p.metricsSet.GetGaugeWithValues(MetricsNameExporterQueueSize, p.entryName); gauge != nil {
+		gauge.Set(float64(p.QueueSize()))
+	}
+}
+
+// sample periodically until processor shutdown
+func (p *QueueMetricsProcessor) run() {
+	ticker := time.NewTicker(queueMetricsInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			p.sample()
+		case <-p.stopCh:
+			return
+		}
+	}
+}
+
+// queueExporter dequeue spans from QueueMetricsProcessor before exporting
+type queueExporter struct {
+	sdktrace.SpanExporter
+	processor *QueueMetricsProcessor
+}
+
+// ExportSpans dequeue spans and export them with delegate
+func (e *queueExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	e.processor.dequeue(len(spans))
+	return e.SpanExporter.ExportSpans(ctx, spans)
+}
+
+// NewQueueMetricsProcessor create batch span processor whose estimated queue depth will be sampled
+// into gauge of MetricsNameExporterQueueSize with label of entryName periodically.
+//
+// Operators could compare the gauge with max queue size to detect spans about to be dropped because of slow collector.
+func NewQueueMetricsProcessor(exporter sdktrace.SpanExporter, metricsSet *rkmidprom.MetricsSet, entryName string,
+	opts ...sdktrace.BatchSpanProcessorOption) sdktrace.SpanProcessor {
+	if exporter == nil {
+		exporter = NewNoopExporter()
+	}
+
+	if metricsSet == nil {
+		return sdktrace.NewBatchSpanProcessor(exporter, opts...)
+	}
+
+	// ignore error of duplicate registration
+	metricsSet.RegisterGauge(MetricsNameExporterQueueSize, "entryName")
+
+	// resolve max queue size the same way as batch span processor
+	o := sdktrace.BatchSpanProcessorOptions{
+		MaxQueueSize: sdktrace.DefaultMaxQueueSize,
+	}
+	for i := range opts {
+		opts[i](&o)
+	}
+
+	p := &QueueMetricsProcessor{
+		metricsSet: metricsSet,
+		entryName:  entryName,
+		maxSize:    int64(o.MaxQueueSize),
+		stopCh:     make(chan struct{}),
+	}
+	p.SpanProcessor = sdktrace.NewBatchSpanProcessor(&queueExporter{
+		SpanExporter: exporter,
+		processor:    p,
+	}, opts...)
+
+	go p.run()
+
+	return p
+}
+
 // NewFileExporter create a file exporter whose default output is stdout.
 func NewFileExporter(outputPath string, opts ...stdouttrace.Option) sdktrace.SpanExporter {
 	if opts == nil {
@@ -561,6 +1604,126 @@ func NewOTLPTraceExporter(client otexporterotlp.Client) sdktrace.SpanExporter {
 
 	return exporter
 }
+
+// NewOTLPTraceExporterWithOpts create otlp exporter with grpc client options,
+// like otlptracegrpc.WithHeaders for collectors behind auth proxy.
+//
+// Client connects to localhost:4317 with TLS unless otlptracegrpc.WithEndpoint and otlptracegrpc.WithInsecure provided.
+func NewOTLPTraceExporterWithOpts(opts ...otlptracegrpc.Option) sdktrace.SpanExporter {
+	opts = append([]otlptracegrpc.Option{
+		otlptracegrpc.WithReconnectionPeriod(50 * time.Millisecond),
+	}, opts...)
+
+	return NewOTLPTraceExporter(otlptracegrpc.NewClient(opts...))
+}
+
+// NewResolvingExporter create exporter which delegates to exporter created by factory with endpoint returned by resolver.
+//
+// Endpoint is resolved while creating exporter. If export failed, endpoint will be re-resolved, and
+// delegate will be replaced and retried once if endpoint changed, previous delegate will be shutdown.
+func NewResolvingExporter(resolver func() string, factory func(endpoint string) sdktrace.SpanExporter) sdktrace.SpanExporter {
+	endpoint := resolver()
+	return &resolvingExporter{
+		resolver: resolver,
+		factory:  factory,
+		endpoint: endpoint,
+		delegate: factory(endpoint),
+	}
+}
+
+// resolvingExporter re-creates delegate while endpoint changed
+type resolvingExporter struct {
+	resolver func() string
+	factory  func(string) sdktrace.SpanExporter
+	lock     sync.Mutex
+	endpoint string
+	delegate sdktrace.SpanExporter
+}
+
+// ExportSpans exports spans with delegate, re-resolve endpoint if failed
+func (e *resolvingExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	delegate := e.current()
+	err := delegate.ExportSpans(ctx, spans)
+	if err == nil {
+		return nil
+	}
+
+	if next := e.reResolve(delegate); next != nil {
+		return next.ExportSpans(ctx, spans)
+	}
+
+	return err
+}
+
+// Shutdown current delegate
+func (e *resolvingExporter) Shutdown(ctx context.Context) error {
+	return e.current().Shutdown(ctx)
+}
+
+// current returns current delegate
+func (e *resolvingExporter) current() sdktrace.SpanExporter {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return e.delegate
+}
+
+// reResolve replaces failed delegate if endpoint changed, nil will be returned if not replaced
+func (e *resolvingExporter) reResolve(failed sdktrace.SpanExporter) sdktrace.SpanExporter {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	// replaced by concurrent export
+	if e.delegate != failed {
+		return e.delegate
+	}
+
+	endpoint := e.resolver()
+	if endpoint == e.endpoint {
+		return nil
+	}
+
+	e.endpoint, e.delegate = endpoint, e.factory(endpoint)
+	go failed.Shutdown(context.Background())
+
+	return e.delegate
+}
+
+// NewOTLPHTTPTraceExporter create otlp exporter which posts spans encoded with protobuf to OTLP/HTTP endpoint.
+//
+// Spans will be sent to /v1/traces of endpoint, which is localhost:4318 by default.
+// Endpoint could be either host:port or URL with scheme, scheme of URL takes precedence over tlsEnabled,
+// and path of URL will be used instead of /v1/traces if provided.
+// Headers will be added to every request, like Authorization for collectors behind auth proxy.
+// The system cert pool will be used if TLS enabled.
+func NewOTLPHTTPTraceExporter(endpoint string, headers map[string]string, tlsEnabled bool) sdktrace.SpanExporter {
+	opts := make([]otlptracehttp.Option, 0)
+
+	if strings.HasPrefix(endpoint, "http://") || strings.HasPrefix(endpoint, "https://") {
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			rkentry.ShutdownWithError(fmt.Errorf("invalid otlp endpoint:%s, %v", endpoint, err))
+		}
+
+		endpoint = u.Host
+		tlsEnabled = u.Scheme == "https"
+		if len(strings.Trim(u.Path, "/")) > 0 {
+			opts = append(opts, otlptracehttp.WithURLPath(u.Path))
+		}
+	}
+
+	if len(endpoint) > 0 {
+		opts = append(opts, otlptracehttp.WithEndpoint(endpoint))
+	}
+	if !tlsEnabled {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	if len(headers) > 0 {
+		opts = append(opts, otlptracehttp.WithHeaders(headers))
+	}
+
+	return NewOTLPTraceExporter(otlptracehttp.NewClient(opts...))
+}
+
 func NewZipkinExporter(url string) sdktrace.SpanExporter {
 	// Assign default zipkin endpoint which is localhost:9411
 	if url == "" {
@@ -5,13 +5,29 @@
 package rkmidtrace
 
 import (
+	"context"
+	"errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rookie-ninja/rk-entry/v2/entry"
+	"github.com/rookie-ninja/rk-entry/v2/middleware"
+	"github.com/rookie-ninja/rk-entry/v2/middleware/prom"
 	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
 	"go.opentelemetry.io/otel/propagation"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	oteltrace "go.opentelemetry.io/otel/trace"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/protobuf/proto"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestWithEntryNameAndType(t *testing.T) {
@@ -35,7 +51,260 @@ func TestWithSpanProcessor(t *testing.T) {
 	set := NewOptionSet(
 		WithSpanProcessor(processor)).(*optionSet)
 
-	assert.Equal(t, processor, set.processor)
+	assert.Len(t, set.processors, 1)
+	assert.Equal(t, processor, set.processors[0])
+
+	// without processor, batch processor should be used
+	set = NewOptionSet().(*optionSet)
+	assert.Len(t, set.processors, 1)
+
+	// with processor, default batch processor of exporter should not be registered
+	exporter := &countingExporter{}
+	counting := &countingProcessor{}
+	set = NewOptionSet(
+		WithExporter(exporter),
+		WithSpanProcessor(counting)).(*optionSet)
+	ctx := set.BeforeCtx(httptest.NewRequest(http.MethodGet, "/ut", nil), false)
+	set.Before(ctx)
+	set.After(ctx, set.AfterCtx(200, ""))
+	assert.Nil(t, set.provider.ForceFlush(context.Background()))
+	assert.Equal(t, 1, counting.ended)
+	assert.Equal(t, 0, exporter.exported)
+
+	// with processor of exporter provided explicitly
+	counting = &countingProcessor{}
+	set = NewOptionSet(
+		WithSpanProcessor(counting),
+		WithSpanProcessor(sdktrace.NewSimpleSpanProcessor(exporter))).(*optionSet)
+	ctx = set.BeforeCtx(httptest.NewRequest(http.MethodGet, "/ut", nil), false)
+	set.Before(ctx)
+	set.After(ctx, set.AfterCtx(200, ""))
+	assert.Equal(t, 1, counting.ended)
+	assert.Equal(t, 1, exporter.exported)
+}
+
+func TestWithSpanProcessor_Multiple(t *testing.T) {
+	first, second := &countingProcessor{}, &countingProcessor{}
+	set := NewOptionSet(
+		WithSpanProcessor(first),
+		WithSpanProcessor(second)).(*optionSet)
+	assert.Len(t, set.processors, 2)
+
+	ctx := set.BeforeCtx(httptest.NewRequest(http.MethodGet, "/ut", nil), false)
+	set.Before(ctx)
+	set.After(ctx, set.AfterCtx(200, ""))
+
+	assert.Equal(t, 1, first.ended)
+	assert.Equal(t, 1, second.ended)
+}
+
+func TestWithSpanFilter(t *testing.T) {
+	processor := &countingProcessor{}
+	set := NewOptionSet(
+		WithSpanProcessor(processor),
+		WithSpanFilter(nil),
+		WithSpanFilter(func(s sdktrace.ReadOnlySpan) bool {
+			return s.Name() != "/healthz"
+		})).(*optionSet)
+	assert.IsType(t, &FilterSpanProcessor{}, set.processors[0])
+	assert.Equal(t, 1, set.Config()["spanFilters"])
+
+	// with span rejected by filter, even if it was propagated from upstream
+	req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	req.Header.Set("traceparent", "00-0102030405060708090a0b0c0d0e0f10-0102030405060708-01")
+	ctx := set.BeforeCtx(req, false)
+	set.Before(ctx)
+	set.After(ctx, set.AfterCtx(200, ""))
+	assert.Equal(t, 0, processor.ended)
+
+	// with span accepted by filter
+	ctx = set.BeforeCtx(httptest.NewRequest(http.MethodGet, "/ut", nil), false)
+	set.Before(ctx)
+	set.After(ctx, set.AfterCtx(200, ""))
+	assert.Equal(t, 1, processor.ended)
+}
+
+func TestWithRequestClassifier(t *testing.T) {
+	processor := &countingProcessor{}
+	set := NewOptionSet(
+		WithSpanProcessor(processor),
+		WithRequestClassifier(nil, 1),
+		WithRequestClassifier(rkmid.RequestClassifierFunc(func(info *rkmid.RequestInfo) rkmid.SamplingDecision {
+			switch info.UrlPath {
+			case "/keep":
+				return rkmid.SamplingDecisionKeep
+			case "/drop":
+				return rkmid.SamplingDecisionDrop
+			}
+			if info.ResCode >= 500 {
+				return rkmid.SamplingDecisionKeep
+			}
+			return rkmid.SamplingDecisionDefault
+		}), 0)).(*optionSet)
+	assert.True(t, set.Config()["requestClassifier"].(bool))
+
+	send := func(path string, code int) {
+		ctx := set.BeforeCtx(httptest.NewRequest(http.MethodGet, path, nil), false)
+		set.Before(ctx)
+		set.After(ctx, set.AfterCtx(code, ""))
+	}
+
+	// with request dropped by classifier
+	send("/drop", http.StatusInternalServerError)
+	assert.Equal(t, 0, processor.ended)
+
+	// with request sampled by fraction of zero
+	send("/ut", http.StatusOK)
+	assert.Equal(t, 0, processor.ended)
+
+	// with request kept by classifier
+	send("/keep", http.StatusOK)
+	assert.Equal(t, 1, processor.ended)
+
+	// with request kept by response code
+	send("/ut", http.StatusInternalServerError)
+	assert.Equal(t, 2, processor.ended)
+
+	// with spans not started by middleware
+	_, span := set.GetProvider().Tracer("user").Start(context.Background(), "/drop")
+	span.End()
+	assert.Equal(t, 3, processor.ended)
+
+	// child spans should be dropped or kept together with span of middleware
+	sendWithChild := func(path string) {
+		ctx := set.BeforeCtx(httptest.NewRequest(http.MethodGet, path, nil), false)
+		set.Before(ctx)
+		_, child := set.GetTracer().Start(ctx.Output.NewCtx, "child")
+		child.End()
+		set.After(ctx, set.AfterCtx(http.StatusOK, ""))
+	}
+	sendWithChild("/drop")
+	assert.Equal(t, 3, processor.ended)
+	sendWithChild("/keep")
+	assert.Equal(t, 5, processor.ended)
+
+	// path should be read from context instead of span name, like span of gRPC
+	ctx := set.BeforeCtx(httptest.NewRequest(http.MethodGet, "/keep", nil), false)
+	ctx.Input.SpanName = "/ut.Service/Method"
+	set.Before(ctx)
+	set.After(ctx, set.AfterCtx(http.StatusOK, ""))
+	assert.Equal(t, 6, processor.ended)
+
+	// with never sampler, spans should still be recorded for classifier while sampled flag follows sampler
+	processor = &countingProcessor{}
+	set = NewOptionSet(
+		WithSpanProcessor(processor),
+		WithSampler(sdktrace.NeverSample()),
+		WithRequestClassifier(rkmid.RequestClassifierFunc(func(info *rkmid.RequestInfo) rkmid.SamplingDecision {
+			if info.UrlPath == "/keep" {
+				return rkmid.SamplingDecisionKeep
+			}
+			return rkmid.SamplingDecisionDefault
+		}), 1)).(*optionSet)
+	assert.Equal(t, sdktrace.NeverSample().Description(), set.Config()["sampler"])
+
+	ctx = set.BeforeCtx(httptest.NewRequest(http.MethodGet, "/keep", nil), false)
+	set.Before(ctx)
+	assert.False(t, ctx.Output.Span.SpanContext().IsSampled())
+	set.After(ctx, set.AfterCtx(http.StatusOK, ""))
+	assert.Equal(t, 1, processor.ended)
+
+	// with default decision, spans dropped by sampler should not be passed
+	send("/ut", http.StatusOK)
+	assert.Equal(t, 1, processor.ended)
+
+	// spans kept by classifier should be exported even if dropped by sampler
+	exporter := &countingExporter{}
+	set = NewOptionSet(
+		WithExporter(exporter),
+		WithSampler(sdktrace.NeverSample()),
+		WithRequestClassifier(rkmid.RequestClassifierFunc(func(*rkmid.RequestInfo) rkmid.SamplingDecision {
+			return rkmid.SamplingDecisionKeep
+		}), 0)).(*optionSet)
+	send("/ut", http.StatusOK)
+	assert.Nil(t, set.provider.ForceFlush(context.Background()))
+	assert.Equal(t, 1, exporter.exported)
+}
+
+func TestWithSampler(t *testing.T) {
+	// with default sampler
+	set := NewOptionSet().(*optionSet)
+	assert.Equal(t, sdktrace.AlwaysSample().Description(), set.Config()["sampler"])
+
+	// with never sampler
+	processor := &countingProcessor{}
+	set = NewOptionSet(
+		WithSpanProcessor(processor),
+		WithSampler(nil),
+		WithSampler(sdktrace.NeverSample())).(*optionSet)
+	assert.Equal(t, sdktrace.NeverSample().Description(), set.Config()["sampler"])
+
+	ctx := set.BeforeCtx(httptest.NewRequest(http.MethodGet, "/ut", nil), false)
+	set.Before(ctx)
+	set.After(ctx, set.AfterCtx(200, ""))
+	assert.Equal(t, 0, processor.ended)
+}
+
+func TestSamplerConfig_ToSampler(t *testing.T) {
+	// with empty type
+	assert.Nil(t, (&SamplerConfig{}).ToSampler())
+
+	// with always
+	assert.Equal(t, sdktrace.AlwaysSample().Description(), (&SamplerConfig{
+		Type: SamplerAlways,
+	}).ToSampler().Description())
+
+	// with never
+	assert.Equal(t, sdktrace.NeverSample().Description(), (&SamplerConfig{
+		Type: SamplerNever,
+	}).ToSampler().Description())
+
+	// with parent based trace id ratio
+	assert.Equal(t, sdktrace.ParentBased(sdktrace.TraceIDRatioBased(0.1)).Description(), (&SamplerConfig{
+		Type:        SamplerTraceIdRatio,
+		Ratio:       0.1,
+		ParentBased: true,
+	}).ToSampler().Description())
+
+	// with unknown type
+	assert.Panics(t, func() {
+		(&SamplerConfig{Type: "unknown"}).ToSampler()
+	})
+
+	// with BootConfig
+	config := &BootConfig{Enabled: true}
+	config.Sampler.Type = SamplerNever
+	set := NewOptionSet(ToOptions(config, "", "")...).(*optionSet)
+	assert.Equal(t, sdktrace.NeverSample().Description(), set.Config()["sampler"])
+}
+
+func TestWithResourceAttributes(t *testing.T) {
+	config := &BootConfig{
+		Enabled: true,
+		Attributes: map[string]string{
+			"team":                   "ut-team",
+			"deployment.environment": "ut-env",
+		},
+	}
+	set := NewOptionSet(append(ToOptions(config, "ut-entry", "ut-type"),
+		WithResourceAttributes(attribute.KeyValue{}),
+		WithResourceAttributes(attribute.String("team", "ut-team-override")))...).(*optionSet)
+	assert.Equal(t, 3, set.Config()["resourceAttributes"])
+
+	_, span := set.GetTracer().Start(context.Background(), "ut-span")
+	span.End()
+	res := span.(sdktrace.ReadOnlySpan).Resource().Set()
+
+	// built-in attributes are kept
+	v, _ := res.Value("service.entryName")
+	assert.Equal(t, "ut-entry", v.AsString())
+
+	v, _ = res.Value("deployment.environment")
+	assert.Equal(t, "ut-env", v.AsString())
+
+	// the last one wins
+	v, _ = res.Value("team")
+	assert.Equal(t, "ut-team-override", v.AsString())
 }
 
 func TestWithTracerProvider(t *testing.T) {
@@ -54,6 +323,105 @@ func TestWithPropagator(t *testing.T) {
 	assert.Equal(t, prop, set.GetPropagator())
 }
 
+func TestWithExporterMetrics(t *testing.T) {
+	metricsSet := rkmidprom.NewMetricsSet("ut", "trace", prometheus.NewRegistry())
+	set := NewOptionSet(
+		WithEntryNameAndType("ut-entry", "ut-type"),
+		WithExporterMetrics(metricsSet)).(*optionSet)
+
+	assert.IsType(t, &MetricsExporter{}, set.exporter)
+	assert.NotNil(t, metricsSet.GetCounter(MetricsNameSpansExported))
+	assert.NotNil(t, metricsSet.GetCounter(MetricsNameExportFailures))
+}
+
+func TestMetricsExporter_ExportSpans(t *testing.T) {
+	metricsSet := rkmidprom.NewMetricsSet("ut", "trace", prometheus.NewRegistry())
+
+	// with successful export
+	exporter := NewMetricsExporter(NewNoopExporter(), metricsSet, "ut-entry")
+	assert.Nil(t, exporter.ExportSpans(context.TODO(), make([]sdktrace.ReadOnlySpan, 2)))
+	assert.Equal(t, float64(2), testutil.ToFloat64(metricsSet.GetCounterWithValues(MetricsNameSpansExported, "ut-entry")))
+	assert.Equal(t, float64(0), testutil.ToFloat64(metricsSet.GetCounterWithValues(MetricsNameExportFailures, "ut-entry")))
+
+	// with failed export
+	exporter = NewMetricsExporter(&failExporter{}, metricsSet, "ut-entry")
+	assert.NotNil(t, exporter.ExportSpans(context.TODO(), make([]sdktrace.ReadOnlySpan, 2)))
+	assert.Equal(t, float64(2), testutil.ToFloat64(metricsSet.GetCounterWithValues(MetricsNameSpansExported, "ut-entry")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(metricsSet.GetCounterWithValues(MetricsNameExportFailures, "ut-entry")))
+
+	assert.Nil(t, exporter.Shutdown(context.TODO()))
+}
+
+func TestWithQueueMetrics(t *testing.T) {
+	metricsSet := rkmidprom.NewMetricsSet("ut", "trace", prometheus.NewRegistry())
+	set := NewOptionSet(
+		WithEntryNameAndType("ut-entry", "ut-type"),
+		WithQueueMetrics(metricsSet)).(*optionSet)
+
+	assert.Len(t, set.processors, 1)
+	assert.IsType(t, &QueueMetricsProcessor{}, set.processors[0])
+	assert.NotNil(t, metricsSet.GetGauge(MetricsNameExporterQueueSize))
+	assert.Equal(t, true, set.Config()["queueMetrics"])
+	assert.Nil(t, set.provider.Shutdown(context.TODO()))
+}
+
+func TestWithBatchOptions(t *testing.T) {
+	metricsSet := rkmidprom.NewMetricsSet("ut", "trace", prometheus.NewRegistry())
+	set := NewOptionSet(
+		WithQueueMetrics(metricsSet),
+		WithBatchOptions(nil, sdktrace.WithMaxQueueSize(2), sdktrace.WithBatchTimeout(time.Hour))).(*optionSet)
+	assert.Equal(t, 2, set.Config()["batchOptions"])
+	assert.Equal(t, int64(2), set.processors[0].(*QueueMetricsProcessor).maxSize)
+	assert.Nil(t, set.provider.Shutdown(context.TODO()))
+
+	// with BootConfig
+	config := &BootConfig{Enabled: true}
+	assert.Empty(t, config.Batch.ToBatchOptions())
+	config.Batch = BatchConfig{
+		MaxQueueSize:       10,
+		MaxExportBatchSize: 5,
+		BatchTimeoutMs:     100,
+		ExportTimeoutMs:    1000,
+	}
+	assert.Len(t, config.Batch.ToBatchOptions(), 4)
+	set = NewOptionSet(ToOptions(config, "", "")...).(*optionSet)
+	assert.Equal(t, 4, set.Config()["batchOptions"])
+}
+
+func TestQueueMetricsProcessor(t *testing.T) {
+	metricsSet := rkmidprom.NewMetricsSet("ut", "trace", prometheus.NewRegistry())
+
+	// without metrics set
+	assert.IsType(t, sdktrace.NewBatchSpanProcessor(nil), NewQueueMetricsProcessor(nil, nil, "ut-entry"))
+
+	// spans stay in queue until batch timeout
+	processor := NewQueueMetricsProcessor(NewNoopExporter(), metricsSet, "ut-entry",
+		sdktrace.WithBatchTimeout(time.Hour)).(*QueueMetricsProcessor)
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(processor))
+	for i := 0; i < 3; i++ {
+		_, span := provider.Tracer("ut").Start(context.TODO(), "ut-span")
+		span.End()
+	}
+	assert.Equal(t, int64(3), processor.QueueSize())
+
+	processor.sample()
+	assert.Equal(t, float64(3), testutil.ToFloat64(metricsSet.GetGaugeWithValues(MetricsNameExporterQueueSize, "ut-entry")))
+
+	// spans handed to exporter
+	assert.Nil(t, processor.ForceFlush(context.TODO()))
+	assert.Equal(t, int64(0), processor.QueueSize())
+
+	// gauge sampled while shutdown
+	assert.Nil(t, provider.Shutdown(context.TODO()))
+	assert.Equal(t, float64(0), testutil.ToFloat64(metricsSet.GetGaugeWithValues(MetricsNameExporterQueueSize, "ut-entry")))
+
+	// depth never goes below zero
+	processor = &QueueMetricsProcessor{maxSize: 2}
+	processor.depth = 2
+	processor.dequeue(5)
+	assert.Equal(t, int64(0), processor.QueueSize())
+}
+
 func TestNoopExporter_ExportSpans(t *testing.T) {
 	exporter := NoopExporter{}
 	assert.Nil(t, exporter.ExportSpans(nil, nil))
@@ -79,7 +447,132 @@ func TestCreateOtlpExporter(t *testing.T) {
 	client := otlptracegrpc.NewClient(opts...)
 	exporter = NewOTLPTraceExporter(client)
 	assert.NotNil(t, exporter)
+
+	// with options
+	exporter = NewOTLPTraceExporterWithOpts(
+		otlptracegrpc.WithInsecure(),
+		otlptracegrpc.WithHeaders(map[string]string{"X-Scope-OrgID": "ut-org"}))
+	assert.NotNil(t, exporter)
 }
+func TestCreateOtlpHttpExporter(t *testing.T) {
+	defer assertNotPanic(t)
+
+	received := &coltracepb.ExportTraceServiceRequest{}
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		body, _ := io.ReadAll(r.Body)
+		proto.Unmarshal(body, received)
+		if r.URL.Path != "/v1/traces" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	// without endpoint
+	assert.NotNil(t, NewOTLPHTTPTraceExporter("", nil, true))
+
+	// with endpoint and headers
+	exporter := NewOTLPHTTPTraceExporter(strings.TrimPrefix(server.URL, "http://"), map[string]string{
+		"Authorization": "Bearer ut-token",
+	}, false)
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	_, span := provider.Tracer("ut-tracer").Start(context.Background(), "ut-span")
+	span.End()
+
+	assert.Equal(t, "application/x-protobuf", header.Get(rkmid.HeaderContentType))
+	assert.Equal(t, "Bearer ut-token", header.Get("Authorization"))
+	assert.Equal(t, "ut-span", received.ResourceSpans[0].ScopeSpans[0].Spans[0].Name)
+
+	// with failed response
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+	assert.NotNil(t, exporter.ExportSpans(context.Background(), []sdktrace.ReadOnlySpan{span.(sdktrace.ReadOnlySpan)}))
+	assert.Nil(t, provider.Shutdown(context.Background()))
+
+	// with endpoint of URL, scheme takes precedence over tlsEnabled
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	exporter = NewOTLPHTTPTraceExporter(server.URL, nil, true)
+	assert.Nil(t, exporter.ExportSpans(context.Background(), []sdktrace.ReadOnlySpan{span.(sdktrace.ReadOnlySpan)}))
+	assert.Nil(t, exporter.Shutdown(context.Background()))
+
+	// with endpoint of URL and path
+	exporter = NewOTLPHTTPTraceExporter(server.URL+"/ut-path", nil, false)
+	assert.NotNil(t, exporter.ExportSpans(context.Background(), []sdktrace.ReadOnlySpan{span.(sdktrace.ReadOnlySpan)}))
+	assert.Nil(t, exporter.Shutdown(context.Background()))
+}
+
+func TestNewResolvingExporter(t *testing.T) {
+	endpoint := "ut-endpoint-1"
+	created := make([]string, 0)
+	exporter := NewResolvingExporter(func() string {
+		return endpoint
+	}, func(e string) sdktrace.SpanExporter {
+		created = append(created, e)
+		if e == "ut-endpoint-1" {
+			return &failExporter{}
+		}
+		return NewNoopExporter()
+	})
+	assert.Equal(t, []string{"ut-endpoint-1"}, created)
+
+	// with failed export and unchanged endpoint
+	assert.NotNil(t, exporter.ExportSpans(context.Background(), nil))
+	assert.Len(t, created, 1)
+
+	// with failed export and moved endpoint
+	endpoint = "ut-endpoint-2"
+	assert.Nil(t, exporter.ExportSpans(context.Background(), nil))
+	assert.Equal(t, []string{"ut-endpoint-1", "ut-endpoint-2"}, created)
+
+	assert.Nil(t, exporter.Shutdown(context.Background()))
+}
+
+func TestWithEndpointResolver(t *testing.T) {
+	defer assertNotPanic(t)
+
+	paths := make([]string, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+	}))
+	defer server.Close()
+
+	config := &BootConfig{Enabled: true}
+	config.Exporter.Otlp.Enabled = true
+	config.Exporter.Otlp.Protocol = OtlpProtocolHttp
+	config.Exporter.Otlp.Endpoint = "localhost:1"
+
+	// without resolver, static endpoint will be used
+	set := NewOptionSet(ToOptions(config, "", "")...).(*optionSet)
+	assert.False(t, set.Config()["endpointResolver"].(bool))
+	assert.Contains(t, set.Config()["exporter"], "otlptrace")
+
+	// with resolver
+	set = NewOptionSet(append(ToOptions(config, "", ""),
+		WithEndpointResolver(nil),
+		WithEndpointResolver(func() string {
+			return strings.TrimPrefix(server.URL, "http://")
+		}))...).(*optionSet)
+	assert.True(t, set.Config()["endpointResolver"].(bool))
+	assert.IsType(t, &resolvingExporter{}, set.exporter)
+	_, span := sdktrace.NewTracerProvider().Tracer("ut-tracer").Start(context.Background(), "ut-span")
+	span.End()
+	assert.Nil(t, set.exporter.ExportSpans(context.Background(), []sdktrace.ReadOnlySpan{span.(sdktrace.ReadOnlySpan)}))
+	assert.Equal(t, []string{"/v1/traces"}, paths)
+
+	// with resolver returns empty endpoint, static one will be used
+	set = NewOptionSet(append(ToOptions(config, "", ""),
+		WithEndpointResolver(func() string {
+			return ""
+		}))...).(*optionSet)
+	assert.Equal(t, "localhost:1", set.exporter.(*resolvingExporter).endpoint)
+}
+
 func TestCreateZipkinExporter(t *testing.T) {
 	defer assertNotPanic(t)
 
@@ -131,6 +624,23 @@ func TestToOptions(t *testing.T) {
 	}
 	config.Exporter.Otlp.Enabled = true
 	NewOptionSet(ToOptions(config, "", "")...)
+	// with otlp collector behind auth proxy
+	config.Exporter.Otlp.Endpoint = "localhost:4317"
+	config.Exporter.Otlp.TlsEnabled = true
+	config.Exporter.Otlp.Headers = map[string]string{
+		"Authorization": "Bearer ut-token",
+		"X-Scope-OrgID": "ut-org",
+	}
+	NewOptionSet(ToOptions(config, "", "")...)
+	// with otlp http collector
+	config.Exporter.Otlp.Protocol = OtlpProtocolHttp
+	set := NewOptionSet(ToOptions(config, "", "")...)
+	assert.Contains(t, set.Config()["exporter"], "otlptrace")
+	// with unknown protocol
+	config.Exporter.Otlp.Protocol = "unknown"
+	assert.Panics(t, func() {
+		ToOptions(config, "", "")
+	})
 	// with zipkin
 	config = &BootConfig{
 		Enabled: true,
@@ -155,6 +665,180 @@ func TestOptionSet_BeforeCtx(t *testing.T) {
 	assert.NotNil(t, ctx.Input.Carrier)
 }
 
+func TestWithIDGenerator(t *testing.T) {
+	set := NewOptionSet(
+		WithIDGenerator(&fixedIDGenerator{})).(*optionSet)
+
+	req := httptest.NewRequest(http.MethodGet, "/ut", nil)
+	ctx := set.BeforeCtx(req, false)
+	set.Before(ctx)
+
+	assert.Equal(t, fixedTraceID, ctx.Output.Span.SpanContext().TraceID())
+	assert.Equal(t, fixedSpanID, ctx.Output.Span.SpanContext().SpanID())
+}
+
+func TestWithPeerServiceAttribute(t *testing.T) {
+	set := NewOptionSet(WithPeerServiceAttribute(func(req *http.Request) string {
+		return req.URL.Host
+	}))
+	req := httptest.NewRequest(http.MethodGet, "http://ut-service/ut", nil)
+
+	// with client span
+	ctx := set.BeforeCtx(req, true)
+	assert.Contains(t, ctx.Input.Attributes, semconv.PeerServiceKey.String("ut-service"))
+
+	// with server span
+	ctx = set.BeforeCtx(req, false)
+	assert.NotContains(t, ctx.Input.Attributes, semconv.PeerServiceKey.String("ut-service"))
+}
+
+func TestWithSpanLinkExtractor(t *testing.T) {
+	linked := oteltrace.NewSpanContext(oteltrace.SpanContextConfig{
+		TraceID:    oteltrace.TraceID{0x01, 0x02},
+		SpanID:     oteltrace.SpanID{0x03, 0x04},
+		TraceFlags: oteltrace.FlagsSampled,
+		Remote:     true,
+	})
+
+	recorder := tracetest.NewSpanRecorder()
+	set := NewOptionSet(
+		WithSpanProcessor(recorder),
+		WithSpanLinkExtractor(func(req *http.Request) []oteltrace.Link {
+			return []oteltrace.Link{{SpanContext: linked}}
+		}))
+	assert.True(t, set.Config()["spanLinkExtractor"].(bool))
+
+	ctx := set.BeforeCtx(httptest.NewRequest(http.MethodGet, "/ut", nil), false)
+	assert.Len(t, ctx.Input.Links, 1)
+	set.Before(ctx)
+
+	spans := recorder.Started()
+	assert.Len(t, spans, 1)
+	assert.Len(t, spans[0].Links(), 1)
+	assert.Equal(t, linked.TraceID(), spans[0].Links()[0].SpanContext.TraceID())
+	assert.Equal(t, linked.SpanID(), spans[0].Links()[0].SpanContext.SpanID())
+
+	// without extractor
+	recorder = tracetest.NewSpanRecorder()
+	set = NewOptionSet(WithSpanProcessor(recorder), WithSpanLinkExtractor(nil))
+	ctx = set.BeforeCtx(httptest.NewRequest(http.MethodGet, "/ut", nil), false)
+	set.Before(ctx)
+	assert.Empty(t, recorder.Started()[0].Links())
+}
+
+func TestWithPreserveTraceState(t *testing.T) {
+	newReq := func() *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/ut", nil)
+		req.Header.Set("traceparent", "00-0102030405060708090a0b0c0d0e0f10-0102030405060708-01")
+		req.Header.Set(headerTraceState, "vendor1=value1,vendor2=value2")
+		return req
+	}
+
+	// without option, tracestate dropped by propagator will be lost
+	set := NewOptionSet(WithPropagator(&noTraceStatePropagator{}))
+	ctx := set.BeforeCtx(newReq(), false)
+	set.Before(ctx)
+	assert.Zero(t, oteltrace.SpanContextFromContext(ctx.Output.NewCtx).TraceState().Len())
+
+	// with option
+	set = NewOptionSet(WithPropagator(&noTraceStatePropagator{}), WithPreserveTraceState(true))
+	ctx = set.BeforeCtx(newReq(), false)
+	set.Before(ctx)
+	state := oteltrace.SpanContextFromContext(ctx.Output.NewCtx).TraceState()
+	assert.Equal(t, "value1", state.Get("vendor1"))
+	assert.Equal(t, "value2", state.Get("vendor2"))
+
+	// with invalid tracestate
+	req := newReq()
+	req.Header.Set(headerTraceState, "invalid")
+	ctx = set.BeforeCtx(req, false)
+	set.Before(ctx)
+	assert.Zero(t, oteltrace.SpanContextFromContext(ctx.Output.NewCtx).TraceState().Len())
+
+	// without parent
+	req = httptest.NewRequest(http.MethodGet, "/ut", nil)
+	req.Header.Set(headerTraceState, "vendor1=value1")
+	ctx = set.BeforeCtx(req, false)
+	set.Before(ctx)
+	assert.Zero(t, oteltrace.SpanContextFromContext(ctx.Output.NewCtx).TraceState().Len())
+}
+
+func TestWithResponseTraceHeader(t *testing.T) {
+	newReq := func() *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/ut", nil)
+		req.Header.Set("traceparent", "00-0102030405060708090a0b0c0d0e0f10-0102030405060708-01")
+		return req
+	}
+
+	// without option
+	set := NewOptionSet()
+	ctx := set.BeforeCtx(newReq(), false)
+	set.Before(ctx)
+	assert.Empty(t, ctx.Output.HeadersToReturn)
+
+	// with option
+	set = NewOptionSet(WithResponseTraceHeader(rkmid.HeaderTraceId))
+	assert.Equal(t, rkmid.HeaderTraceId, set.Config()["responseTraceHeader"])
+	ctx = set.BeforeCtx(newReq(), false)
+	set.Before(ctx)
+	assert.Equal(t, "0102030405060708090a0b0c0d0e0f10", ctx.Output.HeadersToReturn[rkmid.HeaderTraceId])
+
+	// with client span
+	ctx = set.BeforeCtx(newReq(), true)
+	set.Before(ctx)
+	assert.Empty(t, ctx.Output.HeadersToReturn)
+
+	// with BootConfig
+	config := &BootConfig{Enabled: true, ResponseTraceHeader: "X-Ut-Trace"}
+	set = NewOptionSet(ToOptions(config, "ut-entry", "ut-type")...)
+	ctx = set.BeforeCtx(newReq(), false)
+	set.Before(ctx)
+	assert.Equal(t, "0102030405060708090a0b0c0d0e0f10", ctx.Output.HeadersToReturn["X-Ut-Trace"])
+}
+
+func TestNewTracingRoundTripper(t *testing.T) {
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Clone()
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	set := NewOptionSet()
+
+	// start parent span like an inbound request does
+	parent := set.BeforeCtx(httptest.NewRequest(http.MethodGet, "/ut-inbound", nil), false)
+	set.Before(parent)
+
+	client := WithOutboundTracing(nil, set)
+	req, _ := http.NewRequestWithContext(parent.Output.NewCtx, http.MethodGet, server.URL+"/ut", nil)
+	resp, err := client.Do(req)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	resp.Body.Close()
+
+	// tracing header should be injected with trace id of parent span
+	spanCtx := oteltrace.SpanContextFromContext(
+		set.GetPropagator().Extract(context.TODO(), propagation.HeaderCarrier(header)))
+	assert.True(t, spanCtx.IsValid())
+	assert.Equal(t, parent.Output.Span.SpanContext().TraceID(), spanCtx.TraceID())
+	assert.NotEqual(t, parent.Output.Span.SpanContext().SpanID(), spanCtx.SpanID())
+	// original request should not be modified
+	assert.Empty(t, req.Header.Get("traceparent"))
+
+	// with ignored path
+	client = &http.Client{Transport: NewTracingRoundTripper(NewOptionSet(WithPathToIgnore("/ut")))}
+	resp, err = client.Get(server.URL + "/ut")
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Empty(t, header.Get("traceparent"))
+
+	// with error
+	client = &http.Client{Transport: NewTracingRoundTripper(set)}
+	_, err = client.Get("http://127.0.0.1:0/ut")
+	assert.NotNil(t, err)
+}
+
 func TestOptionSet_AfterCtx(t *testing.T) {
 	set := NewOptionSet()
 	ctx := set.AfterCtx(200, "msg")
@@ -196,6 +880,58 @@ func TestOptionSet_After(t *testing.T) {
 	set.After(before, after)
 }
 
+func TestAfterCtx_Finalize(t *testing.T) {
+	processor := &countingProcessor{}
+	set := NewOptionSet(WithSpanProcessor(processor))
+
+	before := set.BeforeCtx(httptest.NewRequest(http.MethodGet, "/ut", nil), false)
+	set.Before(before)
+
+	// span should not be ended until finalized
+	after := set.AfterCtx(0, "")
+	after.Defer()
+	set.After(before, after)
+	assert.Equal(t, 0, processor.ended)
+
+	after.Input.ResCode = http.StatusOK
+	after.Finalize()
+	assert.Equal(t, 1, processor.ended)
+
+	// finalize twice should be no-op
+	after.Finalize()
+	assert.Equal(t, 1, processor.ended)
+}
+
+func TestNewOptionSet_ShutdownHook(t *testing.T) {
+	// option sets of the same entry, like unary and stream interceptors, share one shutdown hook
+	exporter, other := &countingExporter{}, &countingExporter{}
+	set := NewOptionSet(
+		WithEntryNameAndType("ut-shutdown", "ut-type"),
+		WithExporter(exporter))
+	otherSet := NewOptionSet(
+		WithEntryNameAndType("ut-shutdown", "ut-type"),
+		WithExporter(other))
+	defer rkentry.GlobalAppCtx.RemoveShutdownHook("rk-tracing-ut-shutdown")
+
+	for _, s := range []OptionSetInterface{set, otherSet} {
+		ctx := s.BeforeCtx(httptest.NewRequest(http.MethodGet, "/ut", nil), false)
+		s.Before(ctx)
+		ctx.Output.Span.End()
+	}
+
+	// spans buffered in batch processor should be flushed by shutdown hook
+	hook := rkentry.GlobalAppCtx.GetShutdownHook("rk-tracing-ut-shutdown")
+	assert.NotNil(t, hook)
+	assert.Zero(t, exporter.exported)
+	assert.Zero(t, other.exported)
+	hook()
+	assert.Equal(t, 1, exporter.exported)
+	assert.Equal(t, 1, other.exported)
+
+	// providers should be shutdown only once
+	hook()
+}
+
 func TestNewOptionSetMock(t *testing.T) {
 	mock := NewOptionSetMock(NewBeforeCtx(), NewAfterCtx(), nil, nil, nil)
 	assert.NotEmpty(t, mock.GetEntryName())
@@ -209,6 +945,52 @@ func TestNewOptionSetMock(t *testing.T) {
 	assert.Nil(t, mock.GetPropagator())
 }
 
+var (
+	fixedTraceID = oteltrace.TraceID{1}
+	fixedSpanID  = oteltrace.SpanID{1}
+)
+
+type fixedIDGenerator struct{}
+
+func (g *fixedIDGenerator) NewIDs(context.Context) (oteltrace.TraceID, oteltrace.SpanID) {
+	return fixedTraceID, fixedSpanID
+}
+
+func (g *fixedIDGenerator) NewSpanID(context.Context, oteltrace.TraceID) oteltrace.SpanID {
+	return fixedSpanID
+}
+
+type countingProcessor struct {
+	ended int
+}
+
+func (p *countingProcessor) OnStart(context.Context, sdktrace.ReadWriteSpan) {}
+
+func (p *countingProcessor) OnEnd(sdktrace.ReadOnlySpan) { p.ended++ }
+
+func (p *countingProcessor) Shutdown(context.Context) error { return nil }
+
+func (p *countingProcessor) ForceFlush(context.Context) error { return nil }
+
+type failExporter struct{}
+
+func (e *failExporter) ExportSpans(context.Context, []sdktrace.ReadOnlySpan) error {
+	return errors.New("ut-error")
+}
+
+func (e *failExporter) Shutdown(context.Context) error { return nil }
+
+type countingExporter struct {
+	exported int
+}
+
+func (e *countingExporter) ExportSpans(_ context.Context, spans []sdktrace.ReadOnlySpan) error {
+	e.exported += len(spans)
+	return nil
+}
+
+func (e *countingExporter) Shutdown(context.Context) error { return nil }
+
 func assertNotPanic(t *testing.T) {
 	if r := recover(); r != nil {
 		// Expect panic to be called with non nil error
@@ -218,3 +1000,20 @@ func assertNotPanic(t *testing.T) {
 		assert.True(t, true)
 	}
 }
+
+func TestOptionSet_Config(t *testing.T) {
+	set := NewOptionSet(WithEntryNameAndType("ut-config", "ut-type"))
+	config := set.Config()
+	assert.Equal(t, "ut-config", config["entryName"])
+	assert.Equal(t, "ut-type", config["entryType"])
+}
+
+// noTraceStatePropagator extracts traceparent only
+type noTraceStatePropagator struct {
+	propagation.TraceContext
+}
+
+func (p *noTraceStatePropagator) Extract(ctx context.Context, carrier propagation.TextMapCarrier) context.Context {
+	spanCtx := oteltrace.SpanContextFromContext(p.TraceContext.Extract(ctx, carrier))
+	return oteltrace.ContextWithRemoteSpanContext(ctx, spanCtx.WithTraceState(oteltrace.TraceState{}))
+}